@@ -0,0 +1,8 @@
+package resources
+
+import _ "embed"
+
+// StatementTemplate is the HTML template of the printable reports.
+//
+//go:embed templates/reports/statement.html
+var StatementTemplate string
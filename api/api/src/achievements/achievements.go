@@ -3,6 +3,7 @@ package achievements
 import (
 	"fmt"
 	"math"
+	"sort"
 
 	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
 	"github.com/google/uuid"
@@ -14,26 +15,41 @@ type Store interface {
 	Get(userID uuid.UUID, code string, tx *gorm.DB) (models.UserAchievement, error)
 	Set(userID uuid.UUID, code string, state bool, tx *gorm.DB) (models.UserAchievement, error)
 	SetCompletion(userID uuid.UUID, code string, value float64, tx *gorm.DB) (models.UserAchievement, error)
+	SetMilestone(userID uuid.UUID, code string, value float64, tx *gorm.DB) (models.UserAchievement, error)
 }
 
 type Service struct {
 	db    *gorm.DB
 	store Store
+
+	// milestones are the completion values, in ascending order, at which the
+	// user is notified of their progress towards an achievement.
+	milestones []float64
 }
 
 // New initializes the achievements service.
-func New(db *gorm.DB, store Store) (*Service, error) {
+//
+// The milestones are completion values, between 0 and 1 (exclusive), at which
+// users are notified of their progress. Values outside that range are ignored.
+func New(db *gorm.DB, store Store, milestones ...float64) (*Service, error) {
 	achs := make([]models.Achievement, len(list))
 	for i, a := range list {
-		achs[i] = a.Achievement
-		achs[i].ImageURI = fmt.Sprintf("/public/assets/achievements/%s.svg", a.Code)
+		achs[i] = a.withImage()
 	}
 
 	err := db.Clauses(clause.OnConflict{
 		UpdateAll: true,
 	}).CreateInBatches(achs, 50).Error
 
-	return &Service{db, store}, err
+	ms := make([]float64, 0, len(milestones))
+	for _, m := range milestones {
+		if m > 0 && m < 1 {
+			ms = append(ms, m)
+		}
+	}
+	sort.Float64s(ms)
+
+	return &Service{db, store, ms}, err
 }
 
 type State struct {
@@ -43,10 +59,19 @@ type State struct {
 	Initiatives int64
 }
 
-func (s *Service) Update(userID uuid.UUID, state State) ([]models.UserAchievement, error) {
+// Update recalculates the user's achievements from the given state.
+//
+// It returns the newly achieved achievements, and the achievements that have
+// crossed a new milestone (see UserAchievement.Milestone) without being
+// achieved. Each milestone is only returned once per user and achievement.
+func (s *Service) Update(
+	userID uuid.UUID,
+	state State,
+) (achieved, milestones []models.UserAchievement, err error) {
 	newAchievements := make([]models.UserAchievement, 0, 5)
+	newMilestones := make([]models.UserAchievement, 0, 5)
 
-	err := s.db.Transaction(func(tx *gorm.DB) error {
+	err = s.db.Transaction(func(tx *gorm.DB) error {
 		for _, ach := range list {
 			dbAch, err := s.store.Get(userID, ach.Code, tx)
 			if err != nil {
@@ -71,13 +96,40 @@ func (s *Service) Update(userID uuid.UUID, state State) ([]models.UserAchievemen
 				}
 
 				newAchievements = append(newAchievements, newAch)
+			} else if m := s.milestone(dbAch); m > 0 {
+				newAch, err := s.store.SetMilestone(userID, ach.Code, m, tx)
+				if err != nil {
+					return err
+				}
+
+				newAch.Achievement = ach.withImage()
+				newMilestones = append(newMilestones, newAch)
 			}
 		}
 
 		return nil
 	})
 
-	return newAchievements, err
+	return newAchievements, newMilestones, err
+}
+
+// milestone returns the highest milestone reached by the achievement that the
+// user hasn't been notified of yet, or 0 if there is none.
+func (s *Service) milestone(ach models.UserAchievement) float64 {
+	if ach.Achieved {
+		return 0
+	}
+
+	result := 0.0
+	for _, m := range s.milestones {
+		if m > ach.Completion {
+			break
+		}
+		if m > ach.Milestone {
+			result = m
+		}
+	}
+	return result
 }
 
 type achievement struct {
@@ -86,6 +138,13 @@ type achievement struct {
 	completion func(s State) float64
 }
 
+// withImage returns the achievement model with its ImageURI set.
+func (a achievement) withImage() models.Achievement {
+	result := a.Achievement
+	result.ImageURI = fmt.Sprintf("/public/assets/achievements/%s.svg", a.Code)
+	return result
+}
+
 // list contains all achievements and their respective triggers.
 var list = [...]achievement{
 	// Rides
@@ -23,7 +23,7 @@ func TestAchievements(t *testing.T) {
 	db := testDb.Begin()
 	defer db.Rollback()
 
-	achievements, err := New(db, query.Achievements)
+	achievements, err := New(db, query.Achievements, 0.9, 0.5)
 	require.NoError(t, err)
 	require.NotEmpty(t, achievements)
 
@@ -33,7 +33,7 @@ func TestAchievements(t *testing.T) {
 	}
 	db.Create(&user)
 
-	newAchs, err := achievements.Update(user.ID, State{
+	newAchs, milestones, err := achievements.Update(user.ID, State{
 		Rides:       3,
 		Distance:    30,
 		Initiatives: 4,
@@ -41,6 +41,15 @@ func TestAchievements(t *testing.T) {
 	})
 	require.NoError(t, err)
 	assert.Len(t, newAchs, 4)
+	require.Len(t, milestones, 3)
+	assert.Equal(t, "rides-traveler", milestones[0].AchievementCode)
+	assert.Equal(t, 0.5, milestones[0].Milestone)
+	assert.Equal(t, 0.6, milestones[0].Completion)
+	assert.Equal(t, "Traveler", milestones[0].Achievement.Name)
+	assert.Equal(t, "dst-steady-rider", milestones[1].AchievementCode)
+	assert.Equal(t, 0.5, milestones[1].Milestone)
+	assert.Equal(t, "ini-heart-of-gold", milestones[2].AchievementCode)
+	assert.Equal(t, 0.5, milestones[2].Milestone)
 
 	now := time.Now()
 
@@ -62,7 +71,7 @@ func TestAchievements(t *testing.T) {
 		Completion:      1,
 	}, newAchs[1])
 
-	newAchs, err = achievements.Update(user.ID, State{
+	newAchs, milestones, err = achievements.Update(user.ID, State{
 		Rides:       100,
 		Distance:    30,
 		Initiatives: 4,
@@ -70,6 +79,8 @@ func TestAchievements(t *testing.T) {
 	})
 	require.NoError(t, err)
 	assert.Len(t, newAchs, 2)
+	// milestones are only notified once
+	assert.Empty(t, milestones)
 
 	newAchs[0].AchievedAt = &now
 	assert.Equal(t, models.UserAchievement{
@@ -88,6 +99,18 @@ func TestAchievements(t *testing.T) {
 		AchievedAt:      &now,
 		Completion:      1,
 	}, newAchs[1])
+
+	newAchs, milestones, err = achievements.Update(user.ID, State{
+		Rides:       100,
+		Distance:    46,
+		Initiatives: 4,
+		Credits:     5,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, newAchs)
+	require.Len(t, milestones, 1)
+	assert.Equal(t, "dst-steady-rider", milestones[0].AchievementCode)
+	assert.Equal(t, 0.9, milestones[0].Milestone)
 }
 
 func TestMain(m *testing.M) {
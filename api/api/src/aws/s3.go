@@ -1,17 +1,21 @@
 package aws
 
 import (
+	"bytes"
 	"context"
+	"io"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	signer "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 const (
-	userFilesPrefix  = "user-files/"
-	profilePicSuffix = "/profilepic"
+	userFilesPrefix   = "user-files/"
+	profilePicSuffix  = "/profilepic"
+	dataExportsSuffix = "/data-exports/"
 
 	initiativeFilesPrefix = "initiative-files/"
 	initiativeImgSuffix   = "/banner-img"
@@ -19,6 +23,8 @@ const (
 	institutionFilesPrefix = "institution-files/"
 	institutionLogoSuffix  = "/logo"
 
+	reportExportsPrefix = "report-exports/"
+
 	presignedUrlExpiration = 20 * time.Minute
 )
 
@@ -87,6 +93,39 @@ func profilePicKey(userID string) string {
 	return userFilesPrefix + userID + profilePicSuffix
 }
 
+// DeleteUserFiles deletes all the objects of the given user from the user
+// files bucket, i.e. the profile picture and the archives of data exports.
+func (c *S3) DeleteUserFiles(ctx context.Context, userID string) error {
+	prefix := userFilesPrefix + userID + "/"
+	paginator := s3.NewListObjectsV2Paginator(c.Client, &s3.ListObjectsV2Input{
+		Bucket: &c.bucketName,
+		Prefix: &prefix,
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		if len(page.Contents) == 0 {
+			continue
+		}
+
+		objects := make([]types.ObjectIdentifier, len(page.Contents))
+		for i, obj := range page.Contents {
+			objects[i] = types.ObjectIdentifier{Key: obj.Key}
+		}
+		if _, err := c.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: &c.bucketName,
+			Delete: &types.Delete{Objects: objects, Quiet: true},
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func wrapProfilePicPresign(
 	userID string,
 	f func(string) (*signer.PresignedHTTPRequest, error),
@@ -169,3 +208,87 @@ func (c *S3) PresignDeleteInstitutionLogo(
 ) (url, method string, err error) {
 	return wrapInstitutionLogoPresign(institutionID, c.PresignDelete)
 }
+
+func dataExportKey(userID, exportID string) string {
+	return userFilesPrefix + userID + dataExportsSuffix + exportID + ".zip"
+}
+
+// PutDataExport stores the archive of an export of a user's data in the user
+// files bucket.
+func (c *S3) PutDataExport(
+	ctx context.Context,
+	userID, exportID string,
+	data []byte,
+) error {
+	key := dataExportKey(userID, exportID)
+	_, err := c.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      &c.bucketName,
+		Key:         &key,
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/zip"),
+	})
+	return err
+}
+
+// PresignGetDataExport generates a pre-signed url to download the archive of
+// an export of a user's data, which expires after the given duration.
+func (c *S3) PresignGetDataExport(
+	userID, exportID string,
+	expires time.Duration,
+) (string, error) {
+	key := dataExportKey(userID, exportID)
+	req, err := c.PresignGetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: &c.bucketName,
+		Key:    &key,
+	}, func(opts *s3.PresignOptions) {
+		opts.Expires = expires
+	})
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+func reportExportKey(exportID, format string) string {
+	return reportExportsPrefix + exportID + "." + format
+}
+
+// PutReportExport stores the spreadsheet of an export of a list or the
+// metrics of the platform in the user files bucket.
+func (c *S3) PutReportExport(
+	ctx context.Context,
+	exportID, format, contentType string,
+	body io.Reader,
+) error {
+	key := reportExportKey(exportID, format)
+	_, err := c.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      &c.bucketName,
+		Key:         &key,
+		Body:        body,
+		ContentType: &contentType,
+	})
+	return err
+}
+
+// PresignGetReportExport generates a pre-signed url to download the
+// spreadsheet of an export as an attachment with the given file name, which
+// expires after the given duration.
+func (c *S3) PresignGetReportExport(
+	exportID, format, filename string,
+	expires time.Duration,
+) (string, error) {
+	key := reportExportKey(exportID, format)
+	req, err := c.PresignGetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: &c.bucketName,
+		Key:    &key,
+		ResponseContentDisposition: aws.String(
+			`attachment; filename="` + filename + `"`,
+		),
+	}, func(opts *s3.PresignOptions) {
+		opts.Expires = expires
+	})
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
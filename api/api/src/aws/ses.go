@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"html/template"
 	"net/url"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ses"
@@ -29,6 +30,8 @@ const (
 	base            = "resources/templates/base.html"
 	passwordReset   = "resources/templates/password-reset.html"
 	passwordChanged = "resources/templates/password-changed.html"
+	dataExport      = "resources/templates/data-export.html"
+	verifyEmail     = "resources/templates/verify-email.html"
 )
 
 func newSES(config aws.Config, domain, fromName, apiScheme, apiEndpoint string) *SES {
@@ -49,6 +52,8 @@ func parseTemplates() map[string]*template.Template {
 	for _, src := range []string{
 		passwordReset,
 		passwordChanged,
+		dataExport,
+		verifyEmail,
 	} {
 		result[src] = template.Must(template.ParseFiles(src, base))
 	}
@@ -116,3 +121,24 @@ func (c *SES) SendPasswordChangedEmail(email string) error {
 	return c.sendTemplatedEmail("Password Changed", email, passwordChanged,
 		struct{}{})
 }
+
+func (c *SES) verifyEmailLink(code string) string {
+	return fmt.Sprintf("%s://%s/email-verification/redirect?code=%s",
+		c.apiScheme, c.apiEndpoint, code)
+}
+
+// SendVerificationEmail sends the code to verify the email of a user.
+func (c *SES) SendVerificationEmail(email, code string) error {
+	return c.sendTemplatedEmail("Email Verification", email, verifyEmail,
+		struct{ Link string }{c.verifyEmailLink(code)})
+}
+
+// SendDataExportEmail sends the link to download the export of the user's
+// data, which expires at the given time.
+func (c *SES) SendDataExportEmail(email, link string, expiresAt time.Time) error {
+	return c.sendTemplatedEmail("Your Data", email, dataExport,
+		struct {
+			Link      string
+			ExpiresAt string
+		}{link, expiresAt.UTC().Format("2006-01-02 15:04 MST")})
+}
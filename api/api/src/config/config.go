@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"strings"
 
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/stringutil"
 	"github.com/joho/godotenv"
 )
 
@@ -42,8 +43,17 @@ type Config struct {
 
 	SENTRY_DSN string
 
+	// METRICS_TOKEN is the bearer token required to scrape the Prometheus
+	// metrics at /metrics. The metrics aren't served when it's empty.
+	METRICS_TOKEN string
+
 	GOOGLE_API_KEY string
 
+	// ACHIEVEMENT_MILESTONES is a comma separated list of completion values,
+	// between 0 and 1, at which users are notified of their progress towards
+	// an achievement. An empty value uses the default milestones.
+	ACHIEVEMENT_MILESTONES string `default:"0.5,0.9"`
+
 	GIN_MODE string `default:"debug"`
 }
 
@@ -114,6 +124,19 @@ func (c *Config) DbDsn() string {
 		"sslmode=" + c.DB_SSL
 }
 
+// defaultAchievementMilestones are the milestones used when
+// ACHIEVEMENT_MILESTONES is set, but empty.
+const defaultAchievementMilestones = "0.5,0.9"
+
+// AchievementMilestones parses ACHIEVEMENT_MILESTONES into a list of floats.
+func (c *Config) AchievementMilestones() ([]float64, error) {
+	milestones := c.ACHIEVEMENT_MILESTONES
+	if strings.TrimSpace(milestones) == "" {
+		milestones = defaultAchievementMilestones
+	}
+	return stringutil.AllFloats(milestones)
+}
+
 func (c *Config) ServerBaseURL() string {
 	return fmt.Sprintf("%s://%s", c.SCHEME, c.API_HOST)
 }
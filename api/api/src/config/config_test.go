@@ -40,3 +40,19 @@ func TestLoad(t *testing.T) {
 	assert.Equal(t, "localhost", conf.DB_HOST)
 	assert.Equal(t, uint16(5432), conf.DEX_DB_PORT)
 }
+
+func TestAchievementMilestones(t *testing.T) {
+	for i, tc := range []struct {
+		val string
+		exp []float64
+	}{
+		{"0.25,0.75", []float64{0.25, 0.75}},
+		{"", []float64{0.5, 0.9}},
+		{" ", []float64{0.5, 0.9}},
+	} {
+		conf := Config{ACHIEVEMENT_MILESTONES: tc.val}
+		milestones, err := conf.AchievementMilestones()
+		require.NoError(t, err, "failed on test %d", i)
+		assert.Equal(t, tc.exp, milestones, "failed on test %d", i)
+	}
+}
@@ -21,21 +21,48 @@ func Init(dsn string) (*gorm.DB, error) {
 		return nil, err
 	}
 
+	// The sponsors of initiatives are stored with the terms of the
+	// sponsorship.
+	if err := db.SetupJoinTable(
+		&models.Initiative{}, "Sponsors", &models.InitiativeSponsor{},
+	); err != nil {
+		return nil, err
+	}
+
 	migrate(
 		db,
 		&models.Settings{},
+		&models.CreditsCentsRatio{},
 
 		&models.Language{},
 		&models.User{},
 		&models.PasswordResetCode{},
+		&models.EmailVerificationCode{},
 		&models.FCMToken{},
+		&models.DataExport{},
+		&models.Follow{},
 		&models.Achievement{},
 		&models.UserAchievement{},
 
 		&models.Institution{},
+		&models.InstitutionMembership{},
+		&models.Team{},
+		&models.TeamMembership{},
 		&models.SDG{},
 		&models.Initiative{},
+		&models.InitiativeChange{},
+		&models.InitiativeSnapshot{},
+		&models.CreditAllocation{},
+		&models.AllocationShare{},
+		&models.Parish{},
 		&models.Trip{},
+		&models.TripSplit{},
+		&models.CreditTransaction{},
+		&models.InitiativeContribution{},
+		&models.LeaderboardRank{},
+		&models.DailyMetrics{},
+		&models.DailyActiveRider{},
+		&models.ReportExport{},
 
 		&models.PointOfInterest{},
 		&models.ExternalContent{},
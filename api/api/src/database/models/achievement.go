@@ -24,4 +24,8 @@ type UserAchievement struct {
 	Completion float64    `json:"completion" gorm:"not null;default:0"`
 	Achieved   bool       `json:"achieved" gorm:"not null;default:false"`
 	AchievedAt *time.Time `json:"achievedAt,omitempty" gorm:"default:null"`
+
+	// Milestone is the highest completion milestone the user has already been
+	// notified of, from 0 to 1.
+	Milestone float64 `json:"-" gorm:"not null;default:0"`
 }
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MaxAllocationShares is the maximum number of initiatives a user can split
+// their credits across.
+const MaxAllocationShares = 5
+
+// CreditAllocation is a split of the future credits of a user across
+// initiatives.
+//
+// Allocations are never modified: changing the split records a new
+// allocation, and the user's latest allocation is the one in effect.
+type CreditAllocation struct {
+	ID        uuid.UUID `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()" example:"45314277-a7a3-41d4-9626-a5f00db330fa"`
+	CreatedAt time.Time `json:"createdAt" gorm:"not null;index:idx_credit_allocations_user,priority:2" example:"2023-03-30T17:23:57.146262+02:00"`
+
+	UserID uuid.UUID `json:"userId" gorm:"not null;index:idx_credit_allocations_user,priority:1"`
+	User   *User     `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	// Shares of the credits of each initiative. An allocation without shares
+	// means the user doesn't support any initiative.
+	Shares []AllocationShare `json:"shares" gorm:"foreignKey:AllocationID"`
+}
+
+// AllocationShare is the percentage of the credits of a user allocated to an
+// initiative.
+type AllocationShare struct {
+	AllocationID uuid.UUID         `json:"-" gorm:"primaryKey"`
+	Allocation   *CreditAllocation `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	InitiativeID uuid.UUID   `json:"initiativeId" gorm:"primaryKey;index"`
+	Initiative   *Initiative `json:"initiative,omitempty" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	Percentage uint8 `json:"percentage" gorm:"not null;check:percentage > 0 AND percentage <= 100" example:"50"`
+}
+
+// Migrate implements the Migrator interface.
+// Users who selected an initiative before the introduction of allocations
+// are given an allocation of all their credits to it.
+func (AllocationShare) Migrate(db *gorm.DB) error {
+	return db.Exec(`
+		WITH legacy AS (
+			INSERT INTO credit_allocations (user_id, created_at)
+			SELECT u.id, now() FROM users u
+			WHERE u.initiative_id IS NOT NULL
+			AND NOT EXISTS (
+				SELECT 1 FROM credit_allocations a WHERE a.user_id = u.id
+			)
+			RETURNING id, user_id
+		)
+		INSERT INTO allocation_shares (allocation_id, initiative_id, percentage)
+		SELECT legacy.id, users.initiative_id, 100
+		FROM legacy JOIN users ON users.id = legacy.user_id
+	`).Error
+}
+
+// MainInitiative returns the ID of the initiative with the largest share, or
+// nil if there are no shares.
+func (a *CreditAllocation) MainInitiative() *uuid.UUID {
+	var main *AllocationShare
+	for i, share := range a.Shares {
+		if main == nil || share.Percentage > main.Percentage {
+			main = &a.Shares[i]
+		}
+	}
+
+	if main == nil {
+		return nil
+	}
+	id := main.InitiativeID
+	return &id
+}
+
+// Without returns a new allocation of the same user without the share of the
+// given initiative. The remaining shares are scaled up to add up to 100, and
+// the rounding remainder goes to the largest one.
+func (a *CreditAllocation) Without(initiativeID uuid.UUID) CreditAllocation {
+	res := CreditAllocation{UserID: a.UserID}
+	var total uint
+	for _, share := range a.Shares {
+		if share.InitiativeID == initiativeID {
+			continue
+		}
+		res.Shares = append(res.Shares, AllocationShare{
+			InitiativeID: share.InitiativeID,
+			Percentage:   share.Percentage,
+		})
+		total += uint(share.Percentage)
+	}
+	if total == 0 {
+		return res
+	}
+
+	var sum uint
+	largest := 0
+	for i := range res.Shares {
+		percentage := uint(res.Shares[i].Percentage) * 100 / total
+		res.Shares[i].Percentage = uint8(percentage)
+		sum += percentage
+		if res.Shares[i].Percentage > res.Shares[largest].Percentage {
+			largest = i
+		}
+	}
+	res.Shares[largest].Percentage += uint8(100 - sum)
+	return res
+}
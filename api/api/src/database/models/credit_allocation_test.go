@@ -0,0 +1,46 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreditAllocationMainInitiative(t *testing.T) {
+	assert.Nil(t, (&CreditAllocation{}).MainInitiative())
+
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+	allocation := CreditAllocation{Shares: []AllocationShare{
+		{InitiativeID: ids[0], Percentage: 30},
+		{InitiativeID: ids[1], Percentage: 40},
+		{InitiativeID: ids[2], Percentage: 30},
+	}}
+	assert.Equal(t, ids[1], *allocation.MainInitiative())
+}
+
+func TestCreditAllocationWithout(t *testing.T) {
+	userID := uuid.New()
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+	allocation := CreditAllocation{UserID: userID, Shares: []AllocationShare{
+		{InitiativeID: ids[0], Percentage: 40},
+		{InitiativeID: ids[1], Percentage: 35},
+		{InitiativeID: ids[2], Percentage: 25},
+	}}
+
+	res := allocation.Without(ids[0])
+	assert.Equal(t, userID, res.UserID)
+	assert.Equal(t, []AllocationShare{
+		{InitiativeID: ids[1], Percentage: 59},
+		{InitiativeID: ids[2], Percentage: 41},
+	}, res.Shares)
+
+	res = allocation.Without(uuid.New())
+	assert.Len(t, res.Shares, 3)
+	assert.Equal(t, uint8(40), res.Shares[0].Percentage)
+
+	single := CreditAllocation{UserID: userID, Shares: []AllocationShare{
+		{InitiativeID: ids[0], Percentage: 100},
+	}}
+	assert.Empty(t, single.Without(ids[0]).Shares)
+}
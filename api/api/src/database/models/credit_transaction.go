@@ -0,0 +1,137 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CreditTransaction is an entry of the credit ledger.
+//
+// Every credit movement is recorded in the ledger, which is append-only: its
+// entries outlive the trips they refer to, and users are anonymized instead of
+// deleted. The Credits of users and initiatives are caches of the sums of the
+// ledger, updated in the same transaction as the respective entries are
+// inserted.
+type CreditTransaction struct {
+	ID        uuid.UUID `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()" example:"45314277-a7a3-41d4-9626-a5f00db330fa"`
+	CreatedAt time.Time `json:"createdAt" gorm:"not null;index" example:"2023-03-30T17:23:57.146262+02:00"`
+
+	// Source of the credits. See CreditSource.
+	Source CreditSource `json:"source" gorm:"type:varchar(16);not null" example:"trip"`
+
+	// Amount of credits awarded to the user, and to the initiative if
+	// present. The credits matched by a sponsor are only awarded to the
+	// initiative.
+	Amount float64 `json:"amount" gorm:"not null"`
+
+	UserID uuid.UUID `json:"userId" gorm:"not null;index"`
+	User   *User     `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;"`
+
+	// TripID is the trip that earned the credits. It's null for other
+	// sources, and once the trip is deleted along with its initiative.
+	TripID *uuid.UUID `json:"tripId,omitempty" gorm:"index"`
+	Trip   *Trip      `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+
+	// InitiativeID is the initiative that received the credits. It's null
+	// when the user had no initiative selected, or the initiative had ended.
+	InitiativeID *uuid.UUID  `json:"initiativeId,omitempty" gorm:"index"`
+	Initiative   *Initiative `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+
+	// SponsorID is the institution that matched the credits of the trip,
+	// present in the entries of source CreditSourceSponsorMatch.
+	SponsorID *uuid.UUID   `json:"sponsorId,omitempty" gorm:"index"`
+	Sponsor   *Institution `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+}
+
+// CreditSource is the origin of the credits of a CreditTransaction.
+type CreditSource string
+
+const (
+	// The credits were awarded for a valid trip.
+	CreditSourceTrip CreditSource = "trip"
+	// The credits were matched by a sponsor of the initiative, for the
+	// credits the user awarded it with a trip.
+	CreditSourceSponsorMatch CreditSource = "sponsor-match"
+)
+
+// Migrate implements the Migrator interface.
+// Updates to the ledger entries are rejected by a trigger, and the trips
+// uploaded before the ledger existed are added to it. The foreign keys that
+// deleted the entries along with their trip or user are replaced.
+func (CreditTransaction) Migrate(db *gorm.DB) error {
+	if err := db.Exec(`
+		DO $$
+		DECLARE r record;
+		BEGIN
+			FOR r IN SELECT conname FROM pg_constraint
+				WHERE conrelid = 'credit_transactions'::regclass
+				AND confrelid IN ('trips'::regclass, 'users'::regclass)
+				AND contype = 'f' AND confdeltype = 'c'
+			LOOP
+				EXECUTE 'ALTER TABLE credit_transactions DROP CONSTRAINT '
+					|| quote_ident(r.conname);
+			END LOOP;
+		END $$
+	`).Error; err != nil {
+		return err
+	}
+	for _, name := range []string{"User", "Trip"} {
+		if !db.Migrator().HasConstraint(&CreditTransaction{}, name) {
+			if err := db.Migrator().
+				CreateConstraint(&CreditTransaction{}, name); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := db.Exec(`
+		CREATE OR REPLACE FUNCTION reject_credit_transaction_update()
+		RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'credit transactions are append-only';
+		END;
+		$$ LANGUAGE plpgsql
+	`).Error; err != nil {
+		return err
+	}
+
+	if err := db.Exec(`
+		DROP TRIGGER IF EXISTS credit_transactions_append_only
+		ON credit_transactions
+	`).Error; err != nil {
+		return err
+	}
+
+	// Updates caused by foreign key actions (OnDelete:SET NULL) are allowed.
+	if err := db.Exec(`
+		CREATE TRIGGER credit_transactions_append_only
+		BEFORE UPDATE OF id, created_at, source, amount, user_id, trip_id
+		ON credit_transactions
+		FOR EACH ROW
+		WHEN (
+			OLD.id IS DISTINCT FROM NEW.id
+			OR OLD.created_at IS DISTINCT FROM NEW.created_at
+			OR OLD.source IS DISTINCT FROM NEW.source
+			OR OLD.amount IS DISTINCT FROM NEW.amount
+			OR OLD.user_id IS DISTINCT FROM NEW.user_id
+			OR NEW.trip_id IS DISTINCT FROM OLD.trip_id
+				AND NEW.trip_id IS NOT NULL
+		)
+		EXECUTE FUNCTION reject_credit_transaction_update()
+	`).Error; err != nil {
+		return err
+	}
+
+	// Historical trips are assumed to have credited the initiative they
+	// reference.
+	return db.Exec(`
+		INSERT INTO credit_transactions
+			(created_at, source, amount, user_id, trip_id, initiative_id)
+		SELECT created_at, 'trip', credits, user_id, id, initiative_id
+		FROM trips
+		WHERE is_valid = true
+		AND NOT EXISTS (SELECT 1 FROM credit_transactions)
+	`).Error
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CreditsCentsRatio is an entry of the history of Settings.CreditsCentsRatio.
+// It's used to convert credits to money with the ratio in effect at the time
+// they were awarded, so that changing the Settings doesn't affect past
+// statements.
+type CreditsCentsRatio struct {
+	// ValidFrom is the time from which the ratio is in effect, until the
+	// ValidFrom of the next entry.
+	ValidFrom time.Time `json:"validFrom" gorm:"primaryKey"`
+	// Ratio is how many credits correspond to 0.01€:
+	// `credits / cents = ratio`
+	Ratio float32 `json:"ratio" gorm:"not null;type:real"`
+}
+
+// Migrate implements the Migrator interface.
+// A trigger records every change of the ratio in the Settings. If the history
+// is empty, the current ratio is assumed to have always been in effect.
+func (CreditsCentsRatio) Migrate(db *gorm.DB) error {
+	if err := db.Exec(`
+		CREATE OR REPLACE FUNCTION record_credits_cents_ratio()
+		RETURNS trigger AS $$
+		BEGIN
+			INSERT INTO credits_cents_ratios (valid_from, ratio)
+			VALUES (now(), NEW.credits_cents_ratio)
+			ON CONFLICT (valid_from) DO UPDATE SET ratio = EXCLUDED.ratio;
+			RETURN NEW;
+		END;
+		$$ LANGUAGE plpgsql
+	`).Error; err != nil {
+		return err
+	}
+
+	if err := db.Exec(`
+		DROP TRIGGER IF EXISTS settings_credits_cents_ratio ON settings
+	`).Error; err != nil {
+		return err
+	}
+
+	if err := db.Exec(`
+		CREATE TRIGGER settings_credits_cents_ratio
+		AFTER UPDATE OF credits_cents_ratio ON settings
+		FOR EACH ROW
+		WHEN (OLD.credits_cents_ratio IS DISTINCT FROM NEW.credits_cents_ratio)
+		EXECUTE FUNCTION record_credits_cents_ratio()
+	`).Error; err != nil {
+		return err
+	}
+
+	return db.Exec(`
+		INSERT INTO credits_cents_ratios (valid_from, ratio)
+		SELECT 'epoch', credits_cents_ratio FROM settings
+		WHERE NOT EXISTS (SELECT 1 FROM credits_cents_ratios)
+		LIMIT 1
+	`).Error
+}
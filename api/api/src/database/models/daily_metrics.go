@@ -0,0 +1,34 @@
+package models
+
+import (
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/types"
+	"github.com/google/uuid"
+)
+
+// DailyMetrics is a daily rollup of the activity in the platform, from which
+// the time series of the platform metrics are computed.
+type DailyMetrics struct {
+	Date types.Date `json:"date" gorm:"primaryKey" example:"2023-03-30"`
+
+	// NewUsers is the number of users who signed up in the day.
+	NewUsers int64 `json:"newUsers" gorm:"not null"`
+	// ActiveRiders is the number of users with valid trips in the day.
+	ActiveRiders int64 `json:"activeRiders" gorm:"not null"`
+	// ValidTrips is the number of valid trips uploaded in the day.
+	ValidTrips int64 `json:"validTrips" gorm:"not null"`
+	// InvalidTrips is the number of invalid trips uploaded in the day.
+	InvalidTrips int64 `json:"invalidTrips" gorm:"not null"`
+	// Distance is the total distance of the valid trips, in kilometers.
+	Distance float64 `json:"distance" gorm:"not null"`
+	// Credits is the sum of the entries of the credit ledger of the day.
+	Credits float64 `json:"credits" gorm:"not null"`
+}
+
+// DailyActiveRider records that a user had valid trips in a day, so the number
+// of distinct active riders of longer intervals can be counted without
+// scanning the trips.
+type DailyActiveRider struct {
+	Date   types.Date `gorm:"primaryKey"`
+	UserID uuid.UUID  `gorm:"primaryKey;index"`
+	User   *User      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
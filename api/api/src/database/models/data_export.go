@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DataExport is a request of a user to download their data.
+//
+// The data is bundled asynchronously in a zip archive, stored in the S3
+// bucket, and the user is sent a link to download it.
+type DataExport struct {
+	BaseModel
+
+	UserID uuid.UUID `json:"userId" gorm:"not null;index"`
+	User   *User     `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	// Status of the export. See DataExportStatus.
+	Status DataExportStatus `json:"status" gorm:"type:varchar(10);not null;default:pending" example:"ready"`
+
+	// ExpiresAt is the time at which the download link expires. It's set when
+	// the archive is ready.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" gorm:"default:null" example:"2023-04-06T17:23:57.146262+02:00"`
+}
+
+// DataExportStatus is the status of a DataExport:
+//
+//   - pending: the archive is being prepared;
+//   - ready: the archive is stored and the user has been sent the link;
+//   - failed: the archive couldn't be prepared.
+type DataExportStatus string
+
+const (
+	DataExportPending DataExportStatus = "pending"
+	DataExportReady   DataExportStatus = "ready"
+	DataExportFailed  DataExportStatus = "failed"
+)
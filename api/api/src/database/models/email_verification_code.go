@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EmailVerificationCode is sent to a user to confirm they own the email address
+// of their account.
+type EmailVerificationCode struct {
+	Code   string    `gorm:"primaryKey;type:char(32)"`
+	UserID uuid.UUID `gorm:"not null;index"`
+	User   User      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	// Email is the address the code was sent to. The code is only valid while
+	// it's still the email of the user.
+	Email     string    `gorm:"not null"`
+	CreatedAt time.Time `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	Used      bool      `gorm:"not null;default:false"`
+}
+
+func (c *EmailVerificationCode) IsExpired() bool {
+	return time.Now().After(c.ExpiresAt)
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FollowStatus is the state of a follow request:
+//
+//   - pending: the followed user has a private profile and hasn't approved the
+//     request yet;
+//   - accepted: the follower is following the user.
+type FollowStatus string
+
+const (
+	FollowPending  FollowStatus = "pending"
+	FollowAccepted FollowStatus = "accepted"
+)
+
+// Follow is a relation where a user (the follower) follows another user (the
+// followee).
+type Follow struct {
+	FollowerID uuid.UUID `json:"followerId" gorm:"primaryKey"`
+	Follower   *User     `json:"follower,omitempty" gorm:"foreignKey:FollowerID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	FolloweeID uuid.UUID `json:"followeeId" gorm:"primaryKey;index"`
+	Followee   *User     `json:"followee,omitempty" gorm:"foreignKey:FolloweeID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	Status FollowStatus `json:"status" gorm:"type:varchar(10);not null" example:"accepted"`
+
+	CreatedAt time.Time `json:"createdAt" gorm:"not null" example:"2023-03-30T17:23:57.146262+02:00"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"not null" example:"2023-03-30T17:34:43.497929+02:00"`
+}
@@ -1,21 +1,39 @@
 package models
 
 import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+
 	"bitbucket.org/pensarmais/cycleforlisbon/src/database/types"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/latlon"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type Initiative struct {
 	BaseModel
-	Title       string     `json:"title" gorm:"unique;not null"`
-	Description string     `json:"description" gorm:"not null"`
-	EndDate     types.Date `json:"endDate" gorm:"not null"`
+	Title       string `json:"title" gorm:"unique;not null"`
+	Description string `json:"description" gorm:"not null"`
+
+	// StartDate is the date from which the initiative accepts credits. If
+	// null, the initiative starts as soon as it's enabled.
+	StartDate *types.Date `json:"startDate,omitempty" gorm:"default:null"`
+	EndDate   types.Date  `json:"endDate" gorm:"not null"`
 
 	// Goal is the target number of credits.
 	Goal uint32 `json:"goal" gorm:"not null"`
-	// Credits is the current credit score.
+	// Credits is the current credit score. It's a cache of the sum of the
+	// initiative's entries in the credit ledger.
 	Credits float64 `json:"credits" gorm:"nol null;default:0"`
 
+	// State of the initiative in its lifecycle. See InitiativeState.
+	State InitiativeState `json:"state" gorm:"type:varchar(12);not null;default:draft;index" example:"active"`
+
+	// Enabled indicates whether the initiative is visible to regular users.
+	// It's kept in sync with the State: only draft and archived initiatives
+	// are disabled.
 	Enabled bool `json:"enabled" gorm:"not null;default:false"`
 
 	InstitutionID uuid.UUID   `json:"institutionId"`
@@ -24,4 +42,187 @@ type Initiative struct {
 	Sponsors []Institution `json:"sponsors,omitempty" gorm:"many2many:initiative_sponsors;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 
 	SDGs []SDG `json:"sdgs" gorm:"many2many:initiative_sdgs;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	// Area restricts the initiative to a geographic area. If present, the
+	// initiative is only credited with the distance of the trips inside it.
+	Area *latlon.Area `json:"area,omitempty" gorm:"serializer:json;type:jsonb;default:null"`
+}
+
+// Migrate implements the Migrator interface.
+//
+// Initiatives created before the introduction of the State are enabled but
+// left in the default draft state. Their state is derived from their dates
+// and credits.
+//
+// A generated column with the full-text search document of the title and
+// description, in Portuguese, is also added.
+func (Initiative) Migrate(db *gorm.DB) error {
+	if err := db.Exec(`
+		UPDATE initiatives SET state = CASE
+			WHEN credits >= goal THEN 'goal-reached'
+			WHEN end_date < now() THEN 'expired'
+			ELSE 'active'
+		END
+		WHERE enabled = true AND state = 'draft'
+	`).Error; err != nil {
+		return err
+	}
+
+	if err := db.Exec(`
+		ALTER TABLE initiatives ADD COLUMN IF NOT EXISTS search tsvector
+		GENERATED ALWAYS AS (
+			setweight(to_tsvector('portuguese', title), 'A') ||
+			setweight(to_tsvector('portuguese', description), 'B')
+		) STORED
+	`).Error; err != nil {
+		return err
+	}
+
+	return db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_initiatives_search
+		ON initiatives USING GIN (search)
+	`).Error
+}
+
+// InitiativeState is the state of an initiative in its lifecycle:
+//
+//   - draft: created, but not yet enabled;
+//   - scheduled: enabled, waiting for the StartDate;
+//   - active: accepting credits;
+//   - goal-reached: the credits have reached the Goal;
+//   - expired: the EndDate has passed before reaching the Goal;
+//   - archived: disabled after having been enabled.
+type InitiativeState string
+
+const (
+	InitiativeDraft       InitiativeState = "draft"
+	InitiativeScheduled   InitiativeState = "scheduled"
+	InitiativeActive      InitiativeState = "active"
+	InitiativeGoalReached InitiativeState = "goal-reached"
+	InitiativeExpired     InitiativeState = "expired"
+	InitiativeArchived    InitiativeState = "archived"
+)
+
+var ErrInvalidStateTransition = errors.New("invalid initiative state transition")
+
+// initiativeTransitions maps each state to the states it can transition to.
+var initiativeTransitions = map[InitiativeState][]InitiativeState{
+	InitiativeDraft: {
+		InitiativeScheduled, InitiativeActive, InitiativeGoalReached,
+		InitiativeExpired, InitiativeArchived,
+	},
+	InitiativeScheduled: {
+		InitiativeActive, InitiativeGoalReached, InitiativeExpired,
+		InitiativeArchived,
+	},
+	InitiativeActive: {
+		InitiativeScheduled, InitiativeGoalReached, InitiativeExpired,
+		InitiativeArchived,
+	},
+	// Ended initiatives can be reactivated by raising the goal or extending
+	// the end date.
+	InitiativeGoalReached: {
+		InitiativeActive, InitiativeExpired, InitiativeArchived,
+	},
+	InitiativeExpired: {
+		InitiativeActive, InitiativeGoalReached, InitiativeArchived,
+	},
+	InitiativeArchived: {
+		InitiativeScheduled, InitiativeActive, InitiativeGoalReached,
+		InitiativeExpired,
+	},
+}
+
+// CanTransitionTo returns true if an initiative in state s can be moved to
+// state to.
+func (s InitiativeState) CanTransitionTo(to InitiativeState) bool {
+	for _, state := range initiativeTransitions[s] {
+		if state == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsEnabled returns true if initiatives in this state are visible to regular
+// users.
+func (s InitiativeState) IsEnabled() bool {
+	return s != InitiativeDraft && s != InitiativeArchived && s != ""
+}
+
+// HasEnded returns true if the initiative no longer accepts credits, and won't
+// without the intervention of an admin.
+func (s InitiativeState) HasEnded() bool {
+	return s == InitiativeGoalReached || s == InitiativeExpired
+}
+
+// Scan implements sql.Scanner so that InitiativeStates can be read from a
+// database.
+// Database types that map to string and []byte are supported.
+func (s *InitiativeState) Scan(src any) error {
+	var val string
+	if s, ok := src.(string); ok {
+		val = s
+	} else if b, ok := src.([]byte); ok {
+		val = string(b)
+	} else {
+		return fmt.Errorf("unable to scan type %T into InitiativeState", src)
+	}
+
+	if !isValidInitiativeState(val) {
+		return fmt.Errorf("invalid value for InitiativeState: %s", val)
+	}
+
+	*s = InitiativeState(val)
+	return nil
+}
+
+// Value implements sql.Valuer so that InitiativeStates can be written to a
+// database.
+// InitiativeState maps to string.
+func (s InitiativeState) Value() (driver.Value, error) {
+	if !isValidInitiativeState(string(s)) {
+		return "", fmt.Errorf("invalid value for InitiativeState: %s", s)
+	}
+	return string(s), nil
+}
+
+func isValidInitiativeState(val string) bool {
+	_, ok := initiativeTransitions[InitiativeState(val)]
+	return ok
+}
+
+// StateAt returns the state that an enabled initiative should be in at time t,
+// according to its dates and credits.
+func (i *Initiative) StateAt(t time.Time) InitiativeState {
+	switch {
+	case i.Credits >= float64(i.Goal):
+		return InitiativeGoalReached
+	case t.After(i.EndDate.Time()):
+		return InitiativeExpired
+	case i.StartDate != nil && t.Before(i.StartDate.Time()):
+		return InitiativeScheduled
+	default:
+		return InitiativeActive
+	}
+}
+
+// TransitionTo moves the initiative to the given state, and updates the
+// Enabled flag accordingly.
+//
+// Returns ErrInvalidStateTransition if the transition isn't allowed.
+// Transitioning to the current state is a no-op.
+func (i *Initiative) TransitionTo(state InitiativeState) error {
+	if i.State == state {
+		return nil
+	}
+
+	if !i.State.CanTransitionTo(state) {
+		return fmt.Errorf("%w: from %s to %s",
+			ErrInvalidStateTransition, i.State, state)
+	}
+
+	i.State = state
+	i.Enabled = state.IsEnabled()
+	return nil
 }
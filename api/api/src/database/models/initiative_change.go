@@ -0,0 +1,21 @@
+package models
+
+import "github.com/google/uuid"
+
+// InitiativeChange is an audit log entry of a change to one of the fields of
+// an initiative that affect its lifecycle, such as the Goal or the EndDate.
+type InitiativeChange struct {
+	BaseModel
+	InitiativeID uuid.UUID  `json:"initiativeId" gorm:"not null;index"`
+	Initiative   Initiative `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	// UserID is the ID of the user who made the change.
+	UserID *uuid.UUID `json:"userId"`
+	User   *User      `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+
+	// Field is the name of the changed field, as it appears in the JSON
+	// representation of the initiative.
+	Field    string `json:"field" gorm:"not null" example:"goal"`
+	OldValue string `json:"oldValue" example:"5000"`
+	NewValue string `json:"newValue" example:"7000"`
+}
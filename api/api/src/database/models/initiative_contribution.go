@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// InitiativeContribution is the sum of the credits a user contributed to an
+// initiative.
+//
+// It's a projection of the credit ledger, updated in the same transaction as
+// the respective entries are inserted, so the ranking of the contributors of
+// an initiative doesn't have to aggregate the ledger.
+type InitiativeContribution struct {
+	InitiativeID uuid.UUID   `json:"initiativeId" gorm:"primaryKey;index:idx_initiative_contributions_rank,priority:1"`
+	Initiative   *Initiative `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	UserID uuid.UUID `json:"userId" gorm:"primaryKey;index"`
+	User   *User     `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	// Credits is the total amount of credits contributed.
+	Credits float64 `json:"credits" gorm:"not null;default:0;index:idx_initiative_contributions_rank,priority:2,sort:desc"`
+	// TripCount is the number of trips that contributed.
+	TripCount uint `json:"tripCount" gorm:"type:integer;not null;default:0"`
+
+	UpdatedAt time.Time `json:"updatedAt" gorm:"not null"`
+}
+
+// Migrate implements the Migrator interface.
+// The contributions are computed from the ledger when the table is created.
+func (InitiativeContribution) Migrate(db *gorm.DB) error {
+	return db.Exec(`
+		INSERT INTO initiative_contributions
+			(initiative_id, user_id, credits, trip_count, updated_at)
+		SELECT initiative_id, user_id, sum(amount), count(DISTINCT trip_id),
+			max(created_at)
+		FROM credit_transactions
+		WHERE initiative_id IS NOT NULL
+		AND NOT EXISTS (SELECT 1 FROM initiative_contributions)
+		GROUP BY initiative_id, user_id
+	`).Error
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/types"
+	"github.com/google/uuid"
+)
+
+// InitiativeSnapshot is a daily record of the progress of an initiative.
+type InitiativeSnapshot struct {
+	InitiativeID uuid.UUID  `json:"-" gorm:"primaryKey"`
+	Initiative   Initiative `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Date         types.Date `json:"date" gorm:"primaryKey" example:"2023-03-30"`
+
+	// Credits is the initiative's credit score at the time of the snapshot.
+	Credits float64 `json:"credits" gorm:"not null"`
+	// Contributors is the number of users who have contributed to the
+	// initiative until the time of the snapshot.
+	Contributors int64 `json:"contributors" gorm:"not null"`
+}
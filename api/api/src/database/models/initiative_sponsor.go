@@ -0,0 +1,90 @@
+package models
+
+import (
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/types"
+	"github.com/google/uuid"
+)
+
+// InitiativeSponsor is the relationship between an initiative and one of its
+// sponsors, with the terms of the sponsorship.
+//
+// Sponsors match the credits earned by users for the initiative: for each
+// credit, the sponsor adds `Multiplier - 1` credits, until the value of the
+// matched credits reaches the Cap.
+type InitiativeSponsor struct {
+	InitiativeID  uuid.UUID `json:"initiativeId" gorm:"primaryKey"`
+	InstitutionID uuid.UUID `json:"institutionId" gorm:"primaryKey"`
+
+	// Multiplier applied to the credits earned by users. A multiplier of 1
+	// means the sponsor doesn't match any credits.
+	Multiplier float64 `json:"multiplier" gorm:"not null;default:1" example:"2"`
+	// Cap is the maximum amount, in euros, the sponsor commits to match. If
+	// null, there's no limit.
+	Cap *float64 `json:"cap,omitempty" gorm:"default:null" example:"500"`
+	// ValidFrom is the first day in which credits are matched. If null,
+	// credits are matched since the start of the initiative.
+	ValidFrom *types.Date `json:"validFrom,omitempty" gorm:"default:null" example:"2023-03-01"`
+	// ValidUntil is the last day in which credits are matched. If null,
+	// credits are matched until the end of the initiative.
+	ValidUntil *types.Date `json:"validUntil,omitempty" gorm:"default:null" example:"2023-03-30"`
+
+	// MatchedCredits is the total of credits added by the sponsor.
+	MatchedCredits float64 `json:"matchedCredits" gorm:"not null;default:0"`
+	// Committed is the value, in euros, of the matched credits.
+	Committed float64 `json:"committed" gorm:"not null;default:0"`
+}
+
+// IsValidAt returns true if the sponsor matches credits at time t.
+func (s *InitiativeSponsor) IsValidAt(t time.Time) bool {
+	if s.ValidFrom != nil && t.Before(s.ValidFrom.Time()) {
+		return false
+	}
+	if s.ValidUntil != nil && !t.Before(s.ValidUntil.Time().AddDate(0, 0, 1)) {
+		return false
+	}
+	return true
+}
+
+// Remaining returns the amount, in euros, the sponsor can still commit, or nil
+// if there's no cap.
+func (s *InitiativeSponsor) Remaining() *float64 {
+	if s.Cap == nil {
+		return nil
+	}
+
+	remaining := *s.Cap - s.Committed
+	if remaining < 0 {
+		remaining = 0
+	}
+	return &remaining
+}
+
+// Match adds the sponsor's match of the given credits, earned at time t, to
+// its totals, and returns the matched credits.
+//
+// The credits are valued with the given credits to cents ratio. If the
+// sponsor reaches its cap, only the credits corresponding to the remaining
+// amount are matched.
+func (s *InitiativeSponsor) Match(
+	credits float64,
+	ratio float32,
+	t time.Time,
+) float64 {
+	if s.Multiplier <= 1 || credits <= 0 || ratio <= 0 || !s.IsValidAt(t) {
+		return 0
+	}
+
+	matched := credits * (s.Multiplier - 1)
+	euros := matched / float64(ratio) / 100
+
+	if remaining := s.Remaining(); remaining != nil && euros > *remaining {
+		euros = *remaining
+		matched = euros * 100 * float64(ratio)
+	}
+
+	s.MatchedCredits += matched
+	s.Committed += euros
+	return matched
+}
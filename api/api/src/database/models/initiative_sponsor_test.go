@@ -0,0 +1,68 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitiativeSponsorIsValidAt(t *testing.T) {
+	from := types.Date("2023-06-01")
+	until := types.Date("2023-06-30")
+
+	for i, tc := range []struct {
+		t   time.Time
+		exp bool
+	}{
+		{time.Date(2023, 5, 31, 23, 59, 0, 0, time.UTC), false},
+		{time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC), true},
+		{time.Date(2023, 6, 30, 23, 59, 0, 0, time.UTC), true},
+		{time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC), false},
+	} {
+		s := InitiativeSponsor{ValidFrom: &from, ValidUntil: &until}
+		assert.Equal(t, tc.exp, s.IsValidAt(tc.t), "failed test case %d", i)
+	}
+
+	assert.True(t, (&InitiativeSponsor{}).IsValidAt(time.Now()))
+}
+
+func TestInitiativeSponsorMatch(t *testing.T) {
+	cap := 1.5
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	expired := types.Date("2023-05-31")
+
+	for i, tc := range []struct {
+		sponsor   InitiativeSponsor
+		credits   float64
+		matched   float64
+		committed float64
+	}{
+		// 10 credits are worth 1€ with a ratio of 0.1.
+		{InitiativeSponsor{Multiplier: 1}, 10, 0, 0},
+		{InitiativeSponsor{Multiplier: 2}, 10, 10, 1},
+		{InitiativeSponsor{Multiplier: 3}, 10, 20, 2},
+		{InitiativeSponsor{Multiplier: 1.5}, 10, 5, 0.5},
+		{InitiativeSponsor{Multiplier: 2, Cap: &cap}, 10, 10, 1},
+		{InitiativeSponsor{Multiplier: 3, Cap: &cap}, 10, 15, 1.5},
+		{InitiativeSponsor{Multiplier: 2, Cap: &cap, Committed: 1}, 10, 5, 1.5},
+		{InitiativeSponsor{Multiplier: 2, Cap: &cap, Committed: 1.5}, 10, 0, 1.5},
+		{InitiativeSponsor{Multiplier: 2, ValidUntil: &expired}, 10, 0, 0},
+	} {
+		matched := tc.sponsor.Match(tc.credits, 0.1, now)
+		assert.InDelta(t, tc.matched, matched, 1e-6, "failed test case %d", i)
+		assert.InDelta(t, tc.matched, tc.sponsor.MatchedCredits, 1e-6,
+			"failed test case %d", i)
+		assert.InDelta(t, tc.committed, tc.sponsor.Committed, 1e-6,
+			"failed test case %d", i)
+	}
+}
+
+func TestInitiativeSponsorRemaining(t *testing.T) {
+	assert.Nil(t, (&InitiativeSponsor{Committed: 10}).Remaining())
+
+	cap := 100.0
+	assert.Equal(t, 60.0, *(&InitiativeSponsor{Cap: &cap, Committed: 40}).Remaining())
+	assert.Equal(t, 0.0, *(&InitiativeSponsor{Cap: &cap, Committed: 120}).Remaining())
+}
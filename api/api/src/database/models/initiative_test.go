@@ -0,0 +1,114 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitiativeStateScan(t *testing.T) {
+	for i, tc := range []struct {
+		src any
+		err string
+		exp string
+	}{
+		{"draft", "", "draft"},
+		{"active", "", "active"},
+		{"goal-reached", "", "goal-reached"},
+		{"", "invalid value for InitiativeState: ", ""},
+		{"invalid", "invalid value for InitiativeState: invalid", ""},
+		{[]byte("scheduled"), "", "scheduled"},
+		{[]byte("archived"), "", "archived"},
+		{[]byte("invalid"), "invalid value for InitiativeState: invalid", ""},
+		{1, "unable to scan type int into InitiativeState", ""},
+	} {
+		s := new(InitiativeState)
+		err := s.Scan(tc.src)
+		if tc.err == "" {
+			assert.NoError(t, err, "failed test case %d", i)
+		} else {
+			assert.EqualError(t, err, tc.err, "failed test case %d", i)
+		}
+		assert.Equal(t, tc.exp, string(*s), "failed test case %d", i)
+	}
+}
+
+func TestInitiativeStateValue(t *testing.T) {
+	for i, tc := range []struct {
+		s   InitiativeState
+		err string
+		exp string
+	}{
+		{"expired", "", "expired"},
+		{"archived", "", "archived"},
+		{"", "invalid value for InitiativeState: ", ""},
+		{"invalid", "invalid value for InitiativeState: invalid", ""},
+	} {
+		val, err := tc.s.Value()
+		if tc.err == "" {
+			assert.NoError(t, err, "failed test case %d", i)
+		} else {
+			assert.EqualError(t, err, tc.err, "failed test case %d", i)
+		}
+		assert.Equal(t, tc.exp, val, "failed test case %d", i)
+	}
+}
+
+func TestInitiativeStateAt(t *testing.T) {
+	start := types.Date("2023-06-01")
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, tc := range []struct {
+		initiative Initiative
+		exp        InitiativeState
+	}{
+		{Initiative{EndDate: "2023-12-31", Goal: 100}, InitiativeActive},
+		{Initiative{EndDate: "2023-12-31", Goal: 100, Credits: 99.9}, InitiativeActive},
+		{Initiative{EndDate: "2023-12-31", Goal: 100, Credits: 100}, InitiativeGoalReached},
+		{Initiative{EndDate: "2023-04-30", Goal: 100}, InitiativeExpired},
+		{Initiative{EndDate: "2023-04-30", Goal: 100, Credits: 150}, InitiativeGoalReached},
+		{Initiative{StartDate: &start, EndDate: "2023-12-31", Goal: 100}, InitiativeScheduled},
+	} {
+		assert.Equal(t, tc.exp, tc.initiative.StateAt(now),
+			"failed test case %d", i)
+	}
+}
+
+func TestInitiativeTransitionTo(t *testing.T) {
+	for i, tc := range []struct {
+		from    InitiativeState
+		to      InitiativeState
+		err     string
+		enabled bool
+	}{
+		{InitiativeDraft, InitiativeActive, "", true},
+		{InitiativeDraft, InitiativeScheduled, "", true},
+		{InitiativeDraft, InitiativeDraft, "", false},
+		{InitiativeScheduled, InitiativeActive, "", true},
+		{InitiativeActive, InitiativeGoalReached, "", true},
+		{InitiativeActive, InitiativeExpired, "", true},
+		{InitiativeActive, InitiativeArchived, "", false},
+		{InitiativeExpired, InitiativeActive, "", true},
+		{InitiativeArchived, InitiativeActive, "", true},
+		{InitiativeActive, InitiativeDraft,
+			"invalid initiative state transition: from active to draft", false},
+		{InitiativeGoalReached, InitiativeScheduled,
+			"invalid initiative state transition: from goal-reached to scheduled", false},
+	} {
+		initiative := Initiative{State: tc.from}
+		err := initiative.TransitionTo(tc.to)
+		if tc.err == "" {
+			assert.NoError(t, err, "failed test case %d", i)
+			assert.Equal(t, tc.to, initiative.State, "failed test case %d", i)
+			assert.Equal(t, tc.enabled, initiative.Enabled,
+				"failed test case %d", i)
+		} else {
+			assert.ErrorIs(t, err, ErrInvalidStateTransition,
+				"failed test case %d", i)
+			assert.EqualError(t, err, tc.err, "failed test case %d", i)
+			assert.Equal(t, tc.from, initiative.State, "failed test case %d", i)
+		}
+	}
+}
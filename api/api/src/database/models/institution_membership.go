@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// InstitutionRole is the role of a member of an institution:
+//
+//   - viewer: has access to the contributors and reports of the institution's
+//     initiatives, and to the institution's sponsor reports;
+//   - manager: can also edit the institution's initiatives and their images;
+//   - owner: can also edit the institution, its logo and its members.
+type InstitutionRole string
+
+const (
+	InstitutionViewer  InstitutionRole = "viewer"
+	InstitutionManager InstitutionRole = "manager"
+	InstitutionOwner   InstitutionRole = "owner"
+)
+
+// institutionRoleRanks orders the roles: each role has the permissions of the
+// roles with a lower rank.
+var institutionRoleRanks = map[InstitutionRole]int{
+	InstitutionViewer:  1,
+	InstitutionManager: 2,
+	InstitutionOwner:   3,
+}
+
+// Includes returns true if the role has the permissions of the other role.
+func (r InstitutionRole) Includes(other InstitutionRole) bool {
+	rank, ok := institutionRoleRanks[r]
+	return ok && rank >= institutionRoleRanks[other]
+}
+
+// InstitutionMembership grants a user a role in an institution.
+type InstitutionMembership struct {
+	InstitutionID uuid.UUID    `json:"institutionId" gorm:"primaryKey"`
+	Institution   *Institution `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	UserID uuid.UUID `json:"userId" gorm:"primaryKey;index"`
+	User   *User     `json:"user,omitempty" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	Role InstitutionRole `json:"role" gorm:"type:varchar(12);not null" example:"manager"`
+
+	CreatedAt time.Time `json:"createdAt" gorm:"not null" example:"2023-03-30T17:23:57.146262+02:00"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"not null" example:"2023-03-30T17:34:43.497929+02:00"`
+}
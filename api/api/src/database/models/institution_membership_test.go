@@ -0,0 +1,44 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstitutionRoleIncludes(t *testing.T) {
+	for i, tc := range []struct {
+		role  InstitutionRole
+		other InstitutionRole
+		exp   bool
+	}{
+		{InstitutionOwner, InstitutionOwner, true},
+		{InstitutionOwner, InstitutionManager, true},
+		{InstitutionOwner, InstitutionViewer, true},
+		{InstitutionManager, InstitutionOwner, false},
+		{InstitutionManager, InstitutionManager, true},
+		{InstitutionManager, InstitutionViewer, true},
+		{InstitutionViewer, InstitutionManager, false},
+		{InstitutionViewer, InstitutionViewer, true},
+		{"", InstitutionViewer, false},
+		{"invalid", InstitutionViewer, false},
+	} {
+		assert.Equal(t, tc.exp, tc.role.Includes(tc.other),
+			"failed test case %d", i)
+	}
+}
+
+func TestUserHasRole(t *testing.T) {
+	institution := uuid.New()
+	user := User{Memberships: []InstitutionMembership{
+		{InstitutionID: uuid.New(), Role: InstitutionOwner},
+		{InstitutionID: institution, Role: InstitutionManager},
+	}}
+
+	assert.True(t, user.HasRole(institution, InstitutionViewer))
+	assert.True(t, user.HasRole(institution, InstitutionManager))
+	assert.False(t, user.HasRole(institution, InstitutionOwner))
+	assert.False(t, user.HasRole(uuid.New(), InstitutionViewer))
+	assert.False(t, User{}.HasRole(institution, InstitutionViewer))
+}
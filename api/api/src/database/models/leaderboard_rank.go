@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LeaderboardRank is the rank of a user in the snapshot of a leaderboard.
+//
+// The snapshots of the global leaderboards are taken periodically by a worker
+// job, so the leaderboards don't have to rank every user on each request.
+type LeaderboardRank struct {
+	// Period of the leaderboard: all, week, month or season.
+	Period string `json:"period" gorm:"primaryKey;type:varchar(10);index:idx_leaderboard_ranks_rank,priority:1"`
+	// Metric by which the users are ranked: distance, credits or trips.
+	Metric string `json:"metric" gorm:"primaryKey;type:varchar(10);index:idx_leaderboard_ranks_rank,priority:2"`
+
+	UserID uuid.UUID `json:"userId" gorm:"primaryKey;index;index:idx_leaderboard_ranks_rank,priority:4"`
+	User   *User     `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	// Rank of the user. Users with the same value share the same rank, and
+	// the next value is ranked right after them.
+	Rank int `json:"rank" gorm:"not null;index:idx_leaderboard_ranks_rank,priority:3"`
+	// PreviousRank is the rank of the user before it last changed, or nil if
+	// it hasn't changed since the start of the period.
+	PreviousRank *int `json:"previousRank"`
+
+	TripCount uint    `json:"tripCount" gorm:"type:integer;not null"`
+	TotalDist float64 `json:"totalDist" gorm:"not null"`
+	Credits   float64 `json:"credits" gorm:"not null"`
+
+	// PeriodStart is the start of the period of the snapshot, or nil for all
+	// time.
+	PeriodStart *time.Time `json:"periodStart"`
+	TakenAt     time.Time  `json:"takenAt" gorm:"not null"`
+}
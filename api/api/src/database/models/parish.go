@@ -0,0 +1,15 @@
+package models
+
+import "bitbucket.org/pensarmais/cycleforlisbon/src/util/latlon"
+
+// Parish is an administrative division of the city (a freguesia). The start
+// and end of each trip are assigned to the parishes that contain them when
+// the trip is uploaded.
+type Parish struct {
+	BaseModel
+
+	Name string `json:"name" gorm:"unique;not null" example:"Arroios"`
+	// Boundary of the parish. It's omitted from the lists of parishes, as
+	// it's usually large.
+	Boundary latlon.Boundary `json:"boundary,omitempty" gorm:"serializer:json;type:jsonb;not null"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReportExport is a request of an admin to export a list or the metrics of
+// the platform as a spreadsheet, when it's too large to be downloaded
+// directly.
+//
+// The spreadsheet is written asynchronously and stored in the S3 bucket, from
+// where it's downloaded through a pre-signed link.
+type ReportExport struct {
+	BaseModel
+
+	UserID uuid.UUID `json:"userId" gorm:"not null;index"`
+	User   *User     `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	// Kind of the records exported: users, trips, initiatives, contributions
+	// or metrics.
+	Kind string `json:"kind" gorm:"type:varchar(16);not null" example:"trips"`
+	// Format of the spreadsheet: csv or xlsx.
+	Format string `json:"format" gorm:"type:varchar(4);not null" example:"xlsx"`
+
+	// Status of the export. See ReportExportStatus.
+	Status ReportExportStatus `json:"status" gorm:"type:varchar(10);not null;default:pending" example:"ready"`
+	// Rows is the number of rows exported, besides the header. It's set when
+	// the spreadsheet is ready.
+	Rows int64 `json:"rows" gorm:"not null;default:0"`
+
+	// ExpiresAt is the time at which the spreadsheet is no longer available
+	// for download. It's set when the spreadsheet is ready.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" gorm:"default:null" example:"2023-04-06T17:23:57.146262+02:00"`
+}
+
+// ReportExportStatus is the status of a ReportExport:
+//
+//   - pending: the spreadsheet is being written;
+//   - ready: the spreadsheet is stored and can be downloaded;
+//   - failed: the spreadsheet couldn't be written.
+type ReportExportStatus string
+
+const (
+	ReportExportPending ReportExportStatus = "pending"
+	ReportExportReady   ReportExportStatus = "ready"
+	ReportExportFailed  ReportExportStatus = "failed"
+)
@@ -10,6 +10,12 @@ type Settings struct {
 	// CreditsCentsRatio is how many credits correspond to 0.01€:
 	// `credits / cents = ratio`
 	CreditsCentsRatio float32 `gorm:"not null;type:real;default:0.01"`
+	// CO2PerKilometer is the mass of CO2, in kilograms, that isn't emitted
+	// for each kilometer cycled instead of driven.
+	CO2PerKilometer float32 `gorm:"column:co2_per_kilometer;not null;type:real;default:0.12"`
+	// RequireVerifiedEmail indicates whether users who signed up with a
+	// password must verify their email before their trips are credited.
+	RequireVerifiedEmail bool `gorm:"not null;default:false"`
 }
 
 // Migrate implements the Migrator interface.
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Team is a group of users, such as the employees of a company or the students
+// of a school, that compete together in the team leaderboard.
+// Users join a team with its join code.
+type Team struct {
+	BaseModel
+	Name        string `json:"name" gorm:"not null" binding:"required"`
+	Description string `json:"description,omitempty"`
+	// JoinCode is only visible to the admins of the team.
+	JoinCode string `json:"joinCode,omitempty" gorm:"type:varchar(12);unique;not null"`
+}
+
+// TeamRole is the role of a member of a team:
+//
+//   - member: takes part in the team's totals and leaderboard;
+//   - admin: can also edit the team, manage its members and join code, and
+//     export the stats of its members.
+type TeamRole string
+
+const (
+	TeamMember TeamRole = "member"
+	TeamAdmin  TeamRole = "admin"
+)
+
+// TeamMembership is the period in which a user was a member of a team.
+// Memberships are kept after the user leaves the team, so the team's totals
+// only include the trips made during each membership.
+type TeamMembership struct {
+	BaseModel
+
+	TeamID uuid.UUID `json:"teamId" gorm:"not null;uniqueIndex:idx_team_memberships_active,where:left_at IS NULL"`
+	Team   *Team     `json:"team,omitempty" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	UserID uuid.UUID `json:"userId" gorm:"not null;index;uniqueIndex:idx_team_memberships_active,where:left_at IS NULL"`
+	User   *User     `json:"user,omitempty" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	Role TeamRole `json:"role" gorm:"type:varchar(10);not null" example:"member"`
+
+	JoinedAt time.Time `json:"joinedAt" gorm:"not null;default:now()"`
+	// LeftAt is when the user left the team, or null if they're still a
+	// member.
+	LeftAt *time.Time `json:"leftAt,omitempty" gorm:"default:null"`
+}
@@ -15,6 +15,15 @@ type Trip struct {
 	StartAddr string  `json:"startAddr,omitempty"` // StartAddr is the address of the starting point.
 	EndAddr   string  `json:"endAddr,omitempty"`   // EndAddr is the address of the ending point.
 
+	// StartParishID is the parish of the starting point, if it's inside any
+	// of the imported parishes.
+	StartParishID *uuid.UUID `json:"startParishId,omitempty" gorm:"default:null;index"`
+	StartParish   *Parish    `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	// EndParishID is the parish of the ending point, if it's inside any of
+	// the imported parishes.
+	EndParishID *uuid.UUID `json:"endParishId,omitempty" gorm:"default:null;index"`
+	EndParish   *Parish    `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+
 	// IsValid indicates whether the trip was considered to have been performed
 	// on a bicycle.
 	//
@@ -24,6 +33,10 @@ type Trip struct {
 	// NotValidReason is the reason why the trip was not considered valid.
 	NotValidReason string `json:"notValidReason,omitempty"`
 
+	// CreditsPending indicates that the trip is held until the user verifies
+	// their email, when it's credited and counted in the user's stats.
+	CreditsPending bool `json:"creditsPending" gorm:"not null;default:false"`
+
 	// Distance is the total distance of the trip, in kilometers.
 	Distance float64 `json:"distance" gorm:"not null;default:0"`
 
@@ -42,4 +55,11 @@ type Trip struct {
 
 	InitiativeID *uuid.UUID  `json:"initiativeId,omitempty"`
 	Initiative   *Initiative `json:"initiative,omitempty" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	// AllocationID is the user's allocation of credits in effect when the
+	// trip was uploaded.
+	AllocationID *uuid.UUID        `json:"allocationId,omitempty" gorm:"default:null"`
+	Allocation   *CreditAllocation `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	// Splits are the parts of the credits awarded to each initiative.
+	Splits []TripSplit `json:"splits,omitempty"`
 }
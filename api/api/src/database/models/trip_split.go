@@ -0,0 +1,64 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TripSplit is the part of the credits of a trip allocated to an initiative,
+// according to the user's allocation when the trip was uploaded.
+type TripSplit struct {
+	TripID uuid.UUID `json:"-" gorm:"primaryKey"`
+	Trip   *Trip     `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	InitiativeID uuid.UUID   `json:"initiativeId" gorm:"primaryKey;index"`
+	Initiative   *Initiative `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	// Percentage of the credits allocated to the initiative.
+	Percentage uint8 `json:"percentage" gorm:"not null" example:"50"`
+
+	// Distance of the trip, in kilometers, that counted towards the
+	// initiative. If the initiative is restricted to an area, it's the
+	// distance inside the area. It's 0 if the initiative wasn't credited.
+	Distance float64 `json:"distance" gorm:"not null;default:0"`
+	// Credits awarded to the initiative. It's 0 if the initiative had ended.
+	Credits float64 `json:"credits" gorm:"not null;default:0"`
+	// AreaRanges are the parts of the trip inside the initiative's area, as
+	// ranges of indexes of the points of the GPX track, with the first and
+	// last point of each part. They're only set if the initiative was
+	// credited.
+	AreaRanges [][2]int `json:"areaRanges,omitempty" gorm:"serializer:json;type:jsonb;default:null"`
+}
+
+// Migrate implements the Migrator interface.
+// The initiative's distance, credits and area ranges were columns of the
+// trips before the splits existed. They're moved to a split of the whole
+// credits of the trip, and dropped.
+func (TripSplit) Migrate(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&Trip{}, "initiative_distance") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`
+			INSERT INTO trip_splits
+				(trip_id, initiative_id, percentage, distance, credits, area_ranges)
+			SELECT id, initiative_id, 100,
+				initiative_distance, initiative_credits, area_ranges
+			FROM trips
+			WHERE initiative_id IS NOT NULL AND initiative_credits > 0
+			ON CONFLICT DO NOTHING
+		`).Error; err != nil {
+			return err
+		}
+
+		for _, column := range []string{
+			"initiative_distance", "initiative_credits", "area_ranges",
+		} {
+			if err := tx.Migrator().DropColumn(&Trip{}, column); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
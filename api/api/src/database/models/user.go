@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"bitbucket.org/pensarmais/cycleforlisbon/src/database/types"
 	"github.com/google/uuid"
 )
@@ -8,6 +10,7 @@ import (
 type User struct {
 	BaseModel
 	Profile
+	Privacy
 	Subject        string `json:"subject" gorm:"unique;not null"`
 	Email          string `json:"email" gorm:"unique;not null"`
 	HashedPassword string `json:"-" gorm:"type:varchar(60);default:null"`
@@ -21,11 +24,74 @@ type User struct {
 	// kilometers.
 	TotalDist float64 `json:"totalDist" gorm:"not null;default:0"`
 
-	// Credits is the total number of credits earned by the user.
+	// Credits is the total number of credits earned by the user. It's a
+	// cache of the sum of the user's entries in the credit ledger.
 	Credits float64 `json:"credits" gorm:"not null;default:0"`
 
+	// Private indicates whether the user must approve their followers.
+	Private bool `json:"private" gorm:"not null;default:false"`
+
 	InitiativeID *uuid.UUID  `json:"initiativeId,omitempty" gorm:"default:null"`
 	Initiative   *Initiative `json:"initiative,omitempty" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	// Memberships are the roles of the user in institutions.
+	Memberships []InstitutionMembership `json:"memberships,omitempty"`
+
+	// TeamMemberships are the current memberships of the user in teams.
+	TeamMemberships []TeamMembership `json:"teamMemberships,omitempty"`
+
+	// AnonymizedAt is when the user deleted their account. The personal data
+	// of deleted users is erased, but the user is kept so that their trips and
+	// credits still count towards the platform's totals.
+	AnonymizedAt *time.Time `json:"-" gorm:"default:null"`
+}
+
+// Anonymized returns true if the user's account has been deleted.
+func (u User) Anonymized() bool {
+	return u.AnonymizedAt != nil
+}
+
+// HasTeamRole returns true if the user is currently a member of the team with
+// the given ID, with the given role. The user's TeamMemberships must be loaded.
+func (u User) HasTeamRole(teamID uuid.UUID, role TeamRole) bool {
+	for _, membership := range u.TeamMemberships {
+		if membership.TeamID == teamID && membership.LeftAt == nil {
+			return role == TeamMember || membership.Role == role
+		}
+	}
+	return false
+}
+
+// PendingVerification returns true if the user signed up with a password and
+// hasn't verified their email yet.
+func (u User) PendingVerification() bool {
+	return u.HashedPassword != "" && !u.Verified
+}
+
+// HasRole returns true if the user is a member of the institution with the
+// given ID, with a role that includes the given role.
+// The user's Memberships must be loaded.
+func (u User) HasRole(institutionID uuid.UUID, role InstitutionRole) bool {
+	for _, membership := range u.Memberships {
+		if membership.InstitutionID == institutionID {
+			return membership.Role.Includes(role)
+		}
+	}
+	return false
+}
+
+// Privacy are the settings that control what other users can see of a user.
+// Admins can always see every user.
+type Privacy struct {
+	// HideFromLeaderboards excludes the user from the leaderboards shown to
+	// other users. The user still sees their own position.
+	HideFromLeaderboards bool `json:"hideFromLeaderboards" gorm:"not null;default:false"`
+	// LeaderboardAnonymous hides the user's name, username and ID in the
+	// leaderboards shown to other users.
+	LeaderboardAnonymous bool `json:"leaderboardAnonymous" gorm:"not null;default:false"`
+	// HiddenProfile hides the user's profile from other users, and shows them
+	// as anonymous in the leaderboards.
+	HiddenProfile bool `json:"hiddenProfile" gorm:"not null;default:false"`
 }
 
 type Profile struct {
@@ -75,6 +75,23 @@ func (achievements) SetCompletion(
 	return ach, err
 }
 
+func (achievements) SetMilestone(
+	userID uuid.UUID,
+	code string,
+	value float64,
+	tx *gorm.DB,
+) (models.UserAchievement, error) {
+	ach, err := Achievements.getOrCreate(userID, code, tx)
+	if err != nil {
+		return models.UserAchievement{}, err
+	}
+
+	ach.Milestone = value
+
+	err = tx.Save(&ach).Error
+	return ach, err
+}
+
 func (achievements) Get(
 	userID uuid.UUID,
 	code string,
@@ -0,0 +1,104 @@
+package query
+
+import (
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type allocations struct{}
+
+var Allocations allocations
+
+// Current retrieves the allocation in effect of the user with the given ID,
+// with its shares. If the user has never allocated credits, an allocation
+// without ID nor shares is returned.
+func (allocations) Current(
+	userID string,
+	db *gorm.DB,
+) (models.CreditAllocation, error) {
+	var allocation models.CreditAllocation
+	err := db.Model(&models.CreditAllocation{}).
+		Preload("Shares", func(db *gorm.DB) *gorm.DB {
+			return db.Order("percentage DESC")
+		}).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Limit(1).
+		Find(&allocation).Error
+
+	return allocation, err
+}
+
+// Record saves a new allocation, which becomes the one in effect, and sets the
+// user's current initiative to the one with the largest share.
+func (allocations) Record(
+	allocation *models.CreditAllocation,
+	tx *gorm.DB,
+) error {
+	if err := tx.Create(allocation).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&models.User{}).
+		Where("id = ?", allocation.UserID).
+		Update("initiative_id", allocation.MainInitiative()).Error
+}
+
+// DropInitiative records a new allocation for each user whose allocation in
+// effect has a share of the initiative with the given ID, splitting their
+// credits across the other initiatives of the allocation.
+// Returns the number of affected users.
+func (allocations) DropInitiative(
+	initiativeID uuid.UUID,
+	tx *gorm.DB,
+) (int64, error) {
+	var current []models.CreditAllocation
+	if err := tx.Model(&models.CreditAllocation{}).
+		Preload("Shares", func(db *gorm.DB) *gorm.DB {
+			return db.Order("percentage DESC")
+		}).
+		Where(`EXISTS (
+			SELECT 1 FROM allocation_shares s
+			WHERE s.allocation_id = credit_allocations.id
+			AND s.initiative_id = ?
+		)`, initiativeID).
+		Where(`credit_allocations.id = (
+			SELECT a.id FROM credit_allocations a
+			WHERE a.user_id = credit_allocations.user_id
+			ORDER BY a.created_at DESC
+			LIMIT 1
+		)`).
+		Find(&current).Error; err != nil {
+		return 0, err
+	}
+
+	for _, allocation := range current {
+		rebalanced := allocation.Without(initiativeID)
+		if err := Allocations.Record(&rebalanced, tx); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(current)), nil
+}
+
+// HistoryOf lists the allocations of the user with the given ID, most recent
+// first.
+func (allocations) HistoryOf(
+	userID string,
+	limit, offset int,
+	db *gorm.DB,
+) ([]models.CreditAllocation, error) {
+	var history []models.CreditAllocation
+	err := db.Model(&models.CreditAllocation{}).
+		Preload("Shares", func(db *gorm.DB) *gorm.DB {
+			return db.Order("percentage DESC")
+		}).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&history).Error
+
+	return history, err
+}
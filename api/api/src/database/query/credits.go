@@ -0,0 +1,117 @@
+package query
+
+import (
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type credits struct{}
+
+var Credits credits
+
+// Record appends a transaction to the credit ledger, and adds the credits
+// received by an initiative to the user's contribution to it.
+//
+// It should be called in the same database transaction that updates the
+// credits of the respective user and initiative.
+func (credits) Record(entry *models.CreditTransaction, tx *gorm.DB) error {
+	if err := tx.Create(entry).Error; err != nil {
+		return err
+	}
+	if entry.InitiativeID == nil {
+		return nil
+	}
+
+	// The credits matched by sponsors count towards the contribution of the
+	// user whose trip was matched, but the trip is only counted once.
+	trips := 0
+	if entry.TripID != nil && entry.Source == models.CreditSourceTrip {
+		trips = 1
+	}
+	return tx.Exec(`
+		INSERT INTO initiative_contributions
+			(initiative_id, user_id, credits, trip_count, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (initiative_id, user_id) DO UPDATE SET
+			credits = initiative_contributions.credits + excluded.credits,
+			trip_count = initiative_contributions.trip_count + excluded.trip_count,
+			updated_at = excluded.updated_at
+	`, entry.InitiativeID, entry.UserID, entry.Amount, trips, entry.CreatedAt).
+		Error
+}
+
+// HistoryOf lists the ledger entries of the credits awarded to the user with
+// the given ID, most recent first. The credits matched by sponsors, which
+// aren't awarded to the user, are omitted.
+func (credits) HistoryOf(
+	userID string,
+	limit, offset int,
+	db *gorm.DB,
+) ([]models.CreditTransaction, error) {
+	var entries []models.CreditTransaction
+	err := db.Model(&models.CreditTransaction{}).
+		Where("user_id = ?", userID).
+		Where("source <> ?", models.CreditSourceSponsorMatch).
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&entries).Error
+
+	return entries, err
+}
+
+// Contributor is the sum of the contributions of a user to an initiative.
+type Contributor struct {
+	UserID   uuid.UUID `json:"userId"`
+	Username string    `json:"username,omitempty"`
+	// Credits is the total amount of credits contributed.
+	Credits float64 `json:"credits"`
+	// Trips is the number of trips that contributed.
+	Trips int64 `json:"trips"`
+	// LastContributionAt is the time of the user's latest contribution.
+	LastContributionAt time.Time `json:"lastContributionAt"`
+	// Anonymous is true if the user chose to be shown as anonymous. The ID
+	// and username of anonymous users are only shown to themselves.
+	Anonymous bool `json:"anonymous"`
+}
+
+// ContributorsOf lists the users who contributed to the initiative with the
+// given ID, sorted by the credits contributed, as shown to the user with the
+// given ID. Like in the leaderboards, users that deleted their account or are
+// hidden from the leaderboards are excluded, and anonymous users are shown
+// without their identity.
+func (credits) ContributorsOf(
+	initiativeID, viewerID string,
+	limit, offset int,
+	db *gorm.DB,
+) ([]Contributor, error) {
+	var contributors []Contributor
+	err := db.Model(&models.CreditTransaction{}).
+		Select(`
+			credit_transactions.user_id,
+			u.username,
+			sum(credit_transactions.amount) AS credits,
+			count(DISTINCT credit_transactions.trip_id) AS trips,
+			max(credit_transactions.created_at) AS last_contribution_at,
+			`+leaderboardAnonymous).
+		Joins("JOIN users u ON u.id = credit_transactions.user_id").
+		Where("credit_transactions.initiative_id = ?", initiativeID).
+		Where(leaderboardUsers, viewerID).
+		Group("credit_transactions.user_id, u.id").
+		Order("credits DESC, last_contribution_at").
+		Limit(limit).
+		Offset(offset).
+		Scan(&contributors).Error
+
+	for i, contributor := range contributors {
+		if contributor.Anonymous && contributor.UserID.String() != viewerID {
+			contributors[i].UserID = uuid.Nil
+			contributors[i].Username = ""
+		}
+	}
+
+	return contributors, err
+}
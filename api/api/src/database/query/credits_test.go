@@ -0,0 +1,114 @@
+package query
+
+import (
+	"testing"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/config"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/random"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type CreditQueriesTestSuite struct {
+	suite.Suite
+	db *gorm.DB
+	tx *gorm.DB
+}
+
+// Run each test in a transaction.
+func (s *CreditQueriesTestSuite) SetupTest() {
+	s.tx = s.db.Begin()
+}
+
+// Rollback the transaction after each test.
+func (s *CreditQueriesTestSuite) TearDownTest() {
+	s.tx.Rollback()
+}
+
+func (s *CreditQueriesTestSuite) TestLedger() {
+	initiative := models.Initiative{
+		Title:       random.String(50),
+		Description: random.String(50),
+		Goal:        1000,
+		EndDate:     "2500-01-01",
+		Institution: models.Institution{
+			Name: random.AlphanumericString(20),
+		},
+	}
+	s.Require().NoError(s.tx.Create(&initiative).Error)
+
+	users := []models.User{
+		{Subject: random.String(30), Email: random.String(30)},
+		{Subject: random.String(30), Email: random.String(30)},
+		{Subject: random.String(30), Email: random.String(30)},
+		{Subject: random.String(30), Email: random.String(30)},
+	}
+	users[1].Username = random.String(20)
+	users[2].HiddenProfile = true
+	users[3].HideFromLeaderboards = true
+	s.Require().NoError(s.tx.Create(&users).Error)
+
+	for _, entry := range []models.CreditTransaction{
+		{Amount: 10, UserID: users[0].ID, InitiativeID: &initiative.ID},
+		{Amount: 15, UserID: users[1].ID, InitiativeID: &initiative.ID},
+		{Amount: 20, UserID: users[0].ID, InitiativeID: &initiative.ID},
+		{Amount: 5, UserID: users[1].ID},
+		{Amount: 12, UserID: users[2].ID, InitiativeID: &initiative.ID},
+		{Amount: 50, UserID: users[3].ID, InitiativeID: &initiative.ID},
+	} {
+		entry.Source = models.CreditSourceTrip
+		s.Require().NoError(Credits.Record(&entry, s.tx))
+	}
+
+	contributors, err := Credits.ContributorsOf(initiative.ID.String(),
+		users[0].ID.String(), 10, 0, s.tx)
+	s.Require().NoError(err)
+	s.Require().Len(contributors, 3)
+	s.Equal(users[0].ID, contributors[0].UserID)
+	s.Equal(30.0, contributors[0].Credits)
+	s.Equal(users[1].ID, contributors[1].UserID)
+	s.Equal(users[1].Username, contributors[1].Username)
+	s.Equal(15.0, contributors[1].Credits)
+	// The identity of users with a hidden profile is omitted.
+	s.True(contributors[2].Anonymous)
+	s.Equal(uuid.Nil, contributors[2].UserID)
+	s.Equal(12.0, contributors[2].Credits)
+
+	// Users see their own contributions.
+	contributors, err = Credits.ContributorsOf(initiative.ID.String(),
+		users[3].ID.String(), 10, 0, s.tx)
+	s.Require().NoError(err)
+	s.Require().Len(contributors, 4)
+	s.Equal(users[3].ID, contributors[0].UserID)
+
+	// The contributions are projected from the ledger.
+	var contribution models.InitiativeContribution
+	s.Require().NoError(s.tx.First(&contribution,
+		"initiative_id = ? AND user_id = ?", initiative.ID, users[0].ID).Error)
+	s.Equal(30.0, contribution.Credits)
+
+	history, err := Credits.HistoryOf(users[1].ID.String(), 10, 0, s.tx)
+	s.Require().NoError(err)
+	s.Len(history, 2)
+
+	// The ledger is append-only.
+	err = s.tx.Model(&history[0]).Update("amount", 100).Error
+	s.ErrorContains(err, "credit transactions are append-only")
+}
+
+func TestCreditQueries(t *testing.T) {
+	config, err := config.Load("../../../.env")
+	require.NoError(t, err)
+
+	db, err := database.Init(config.DbDsn())
+	require.NoError(t, err)
+
+	db.Logger = logger.Default.LogMode(logger.Silent)
+
+	suite.Run(t, &CreditQueriesTestSuite{db: db})
+}
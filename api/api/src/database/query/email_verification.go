@@ -0,0 +1,39 @@
+package query
+
+import (
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"gorm.io/gorm"
+)
+
+type emailVerificationCodes struct{}
+
+var EmailVerificationCodes emailVerificationCodes
+
+// CountSince returns the number of verification codes sent to the user since
+// the given time.
+func (emailVerificationCodes) CountSince(
+	userID string,
+	since time.Time,
+	db *gorm.DB,
+) (int64, error) {
+	var count int64
+	err := db.Model(&models.EmailVerificationCode{}).
+		Where("user_id = ?", userID).
+		Where("created_at > ?", since).
+		Count(&count).Error
+	return count, err
+}
+
+// DeleteOlderThan deletes all email verification codes from the database that
+// have been expired for longer than t, and the codes that have been used.
+func (emailVerificationCodes) DeleteOlderThan(
+	t time.Duration, db *gorm.DB,
+) (int, error) {
+	minExpiryDate := time.Now().Add(-t)
+	result := db.Delete(&models.EmailVerificationCode{},
+		"expires_at < ? OR used", minExpiryDate)
+
+	return int(result.RowsAffected), result.Error
+}
@@ -22,6 +22,28 @@ func (fcmtokens) Of(userID string, db *gorm.DB) ([]string, error) {
 	return tokens, err
 }
 
+// OfInitiative retrieves the FCM tokens of the users whose current initiative
+// is the one with the given ID, or who allocate a share of their credits to it.
+func (fcmtokens) OfInitiative(initiativeID string, db *gorm.DB) ([]string, error) {
+	var tokens []string
+	err := db.Model(&models.FCMToken{}).
+		Select("fcm_tokens.token").
+		Joins("JOIN users ON users.id = fcm_tokens.user_id").
+		Where(`users.initiative_id = @initiative OR EXISTS (
+			SELECT 1 FROM allocation_shares s
+			WHERE s.initiative_id = @initiative
+			AND s.allocation_id = (
+				SELECT a.id FROM credit_allocations a
+				WHERE a.user_id = users.id
+				ORDER BY a.created_at DESC
+				LIMIT 1
+			)
+		)`, map[string]any{"initiative": initiativeID}).
+		Find(&tokens).Error
+
+	return tokens, err
+}
+
 // Increments the failure count of a token, identified by its token string.
 func (fcmtokens) IncrementFailureCount(token string, db *gorm.DB) error {
 	return db.Model(&models.FCMToken{}).
@@ -0,0 +1,32 @@
+package query
+
+import (
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"gorm.io/gorm"
+)
+
+type follows struct{}
+
+var Follows follows
+
+// IsFollowing returns true if the follower has an accepted follow of the
+// followee.
+func (follows) IsFollowing(followerID, followeeID string, db *gorm.DB) (bool, error) {
+	var count int64
+	err := db.Model(&models.Follow{}).
+		Where("follower_id = ?", followerID).
+		Where("followee_id = ?", followeeID).
+		Where("status = ?", models.FollowAccepted).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// AcceptPending accepts all pending follow requests of the user with the given
+// ID. Returns the number of accepted requests.
+func (follows) AcceptPending(followeeID string, db *gorm.DB) (int64, error) {
+	res := db.Model(&models.Follow{}).
+		Where("followee_id = ?", followeeID).
+		Where("status = ?", models.FollowPending).
+		Update("status", models.FollowAccepted)
+	return res.RowsAffected, res.Error
+}
@@ -2,9 +2,11 @@ package query
 
 import (
 	"errors"
+	"math"
 	"time"
 
 	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/types"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -13,6 +15,10 @@ type initiatives struct{}
 
 var Initiatives initiatives
 
+// maxProjectionDays is the limit of the projections of the goal completion
+// dates.
+const maxProjectionDays = 100 * 365
+
 var (
 	ErrInitiativeEnded = errors.New("the initiative has reached its goal or expired")
 )
@@ -26,25 +32,375 @@ func (initiatives) WithAssociations(db *gorm.DB) (tx *gorm.DB) {
 		Preload("SDGs")
 }
 
-// Credit adds the given value to the initiative's credit score.
+// InitiativeStatus groups the initiative states for filtering.
+type InitiativeStatus string
+
+const (
+	// Active initiatives are accepting credits.
+	InitiativeStatusActive InitiativeStatus = "active"
+	// Near goal initiatives are active and have reached nearGoalThreshold of
+	// their goal.
+	InitiativeStatusNearGoal InitiativeStatus = "near-goal"
+	// Ended initiatives have reached their goal or expired.
+	InitiativeStatusEnded InitiativeStatus = "ended"
+)
+
+// nearGoalThreshold is the fraction of the goal above which an initiative is
+// considered near its goal.
+const nearGoalThreshold = 0.8
+
+type InitiativeFilters struct {
+	// SDGs filters initiatives associated with any of the SDGs.
+	SDGs []int
+	// InstitutionID filters initiatives of the institution.
+	InstitutionID string
+	// SponsorID filters initiatives sponsored by the institution.
+	SponsorID string
+	Status    InitiativeStatus
+	// Search filters initiatives whose title or description match the search
+	// terms, using Postgres' full-text search with the Portuguese
+	// configuration. The results are sorted by relevance.
+	Search string
+}
+
+// Filter adds the conditions of the given filters to a query for initiatives.
+func (initiatives) Filter(db *gorm.DB, filters InitiativeFilters) (tx *gorm.DB) {
+	tx = db
+
+	if len(filters.SDGs) > 0 {
+		tx = tx.Where(`EXISTS (
+			SELECT 1 FROM initiative_sdgs
+			WHERE initiative_sdgs.initiative_id = initiatives.id
+			AND initiative_sdgs.sdg_code IN ?
+		)`, filters.SDGs)
+	}
+
+	if filters.InstitutionID != "" {
+		tx = tx.Where("initiatives.institution_id = ?", filters.InstitutionID)
+	}
+
+	if filters.SponsorID != "" {
+		tx = tx.Where(`EXISTS (
+			SELECT 1 FROM initiative_sponsors
+			WHERE initiative_sponsors.initiative_id = initiatives.id
+			AND initiative_sponsors.institution_id = ?
+		)`, filters.SponsorID)
+	}
+
+	switch filters.Status {
+	case InitiativeStatusActive:
+		tx = tx.Where("initiatives.state = ?", models.InitiativeActive)
+	case InitiativeStatusNearGoal:
+		tx = tx.Where("initiatives.state = ?", models.InitiativeActive).
+			Where("initiatives.credits >= initiatives.goal * ?",
+				nearGoalThreshold)
+	case InitiativeStatusEnded:
+		tx = tx.Where("initiatives.state IN ?", []models.InitiativeState{
+			models.InitiativeGoalReached,
+			models.InitiativeExpired,
+		})
+	}
+
+	if filters.Search != "" {
+		tx = tx.
+			Where("initiatives.search @@ websearch_to_tsquery('portuguese', ?)",
+				filters.Search).
+			Order(clause.Expr{
+				SQL: "ts_rank(initiatives.search, " +
+					"websearch_to_tsquery('portuguese', ?)) DESC",
+				Vars: []any{filters.Search},
+			})
+	}
+
+	return tx
+}
+
+// Credit records the given ledger entry of the credits received by an
+// initiative, along with an entry for the credits matched by each of its
+// sponsors, adds them to the initiative's credit score, and moves it to the
+// goal-reached state if the goal is reached.
 //
-// If the initiative is no longer active (has reached the goal or has expired),
-// ErrInitiativeEnded is returned.
-func (initiatives) Credit(id string, v float64, tx *gorm.DB) error {
+// If the initiative isn't active (is disabled, hasn't started yet, has reached
+// the goal or has expired), ErrInitiativeEnded is returned and nothing is
+// recorded. Whether it has started or expired is decided by its dates, not its
+// stored state, so scheduled initiatives that have started are activated.
+func (initiatives) Credit(
+	entry models.CreditTransaction,
+	tx *gorm.DB,
+) (models.Initiative, error) {
 	var initiative models.Initiative
+	if entry.InitiativeID == nil {
+		return initiative, errors.New("the entry has no initiative")
+	}
 	if err := tx.Model(&models.Initiative{}).
 		Clauses(clause.Locking{Strength: "UPDATE"}).
-		Where("id = ?", id).
+		Where("id = ?", entry.InitiativeID).
 		Find(&initiative).Error; err != nil {
-		return err
+		return initiative, err
 	}
 
 	if !initiative.Enabled ||
-		initiative.Credits >= float64(initiative.Goal) ||
-		time.Now().After(initiative.EndDate.Time()) {
-		return ErrInitiativeEnded
+		initiative.StateAt(time.Now()) != models.InitiativeActive {
+		return initiative, ErrInitiativeEnded
+	}
+	if err := initiative.
+		TransitionTo(models.InitiativeActive); err != nil {
+		return initiative, err
+	}
+
+	if err := Credits.Record(&entry, tx); err != nil {
+		return initiative, err
+	}
+
+	matched, err := Initiatives.matchSponsors(entry, tx)
+	if err != nil {
+		return initiative, err
+	}
+
+	initiative.Credits += entry.Amount + matched
+	if initiative.Credits >= float64(initiative.Goal) {
+		if err := initiative.
+			TransitionTo(models.InitiativeGoalReached); err != nil {
+			return initiative, err
+		}
+	}
+
+	return initiative, tx.Save(&initiative).Error
+}
+
+// matchSponsors applies the sponsors' matches of the credits of the given
+// ledger entry, records the credits matched by each sponsor in the ledger,
+// and returns their total. See models.InitiativeSponsor.Match.
+func (initiatives) matchSponsors(
+	entry models.CreditTransaction,
+	tx *gorm.DB,
+) (float64, error) {
+	var sponsors []models.InitiativeSponsor
+	if err := tx.Model(&models.InitiativeSponsor{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("initiative_id = ?", entry.InitiativeID).
+		Where("multiplier > 1").
+		Order("institution_id").
+		Find(&sponsors).Error; err != nil {
+		return 0, err
+	}
+	if len(sponsors) == 0 {
+		return 0, nil
+	}
+
+	ratio, err := Settings.CreditsCentsRatio(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	var total float64
+	now := time.Now()
+	for _, sponsor := range sponsors {
+		matched := sponsor.Match(entry.Amount, ratio, now)
+		if matched == 0 {
+			continue
+		}
+		total += matched
+
+		if err := tx.Model(&sponsor).
+			Select("matched_credits", "committed").
+			Updates(&sponsor).Error; err != nil {
+			return 0, err
+		}
+
+		sponsorID := sponsor.InstitutionID
+		if err := Credits.Record(&models.CreditTransaction{
+			Source:       models.CreditSourceSponsorMatch,
+			Amount:       matched,
+			UserID:       entry.UserID,
+			TripID:       entry.TripID,
+			InitiativeID: entry.InitiativeID,
+			SponsorID:    &sponsorID,
+		}, tx); err != nil {
+			return 0, err
+		}
+	}
+
+	return total, nil
+}
+
+// Sponsorships lists the terms of the sponsorships of the initiative with the
+// given ID.
+func (initiatives) Sponsorships(
+	initiativeID string,
+	db *gorm.DB,
+) ([]models.InitiativeSponsor, error) {
+	var sponsors []models.InitiativeSponsor
+	err := db.Model(&models.InitiativeSponsor{}).
+		Where("initiative_id = ?", initiativeID).
+		Order("institution_id").
+		Find(&sponsors).Error
+	return sponsors, err
+}
+
+// UpdateStates moves the enabled initiatives that are scheduled or active to
+// the state they should be in at time t. See models.Initiative.StateAt.
+//
+// Returns the initiatives which have ended as a result.
+func (initiatives) UpdateStates(
+	t time.Time,
+	tx *gorm.DB,
+) ([]models.Initiative, error) {
+	var initiatives []models.Initiative
+	if err := tx.Model(&models.Initiative{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("enabled = true").
+		Where("state IN ?", []models.InitiativeState{
+			models.InitiativeScheduled,
+			models.InitiativeActive,
+		}).
+		Find(&initiatives).Error; err != nil {
+		return nil, err
+	}
+
+	var ended []models.Initiative
+	for _, initiative := range initiatives {
+		state := initiative.StateAt(t)
+		if state == initiative.State {
+			continue
+		}
+
+		if err := initiative.TransitionTo(state); err != nil {
+			return nil, err
+		}
+		if err := tx.Model(&initiative).
+			Select("state", "enabled").
+			Updates(&initiative).Error; err != nil {
+			return nil, err
+		}
+
+		if state.HasEnded() {
+			ended = append(ended, initiative)
+		}
+	}
+
+	return ended, nil
+}
+
+// Snapshot records the current credits and number of contributors of the
+// active initiatives, as well as of the initiatives that have ended since
+// their last snapshot.
+//
+// Snapshots are identified by the initiative and the current date, so taking
+// more than one snapshot in the same day overwrites the previous one.
+// Returns the number of snapshots taken.
+func (initiatives) Snapshot(tx *gorm.DB) (int64, error) {
+	res := tx.Exec(`
+		INSERT INTO initiative_snapshots
+			(initiative_id, date, credits, contributors)
+		SELECT
+			i.id,
+			current_date,
+			i.credits,
+			(
+				SELECT count(DISTINCT c.user_id)
+				FROM credit_transactions c
+				WHERE c.initiative_id = i.id
+			)
+		FROM initiatives i
+		WHERE i.state = ?
+		OR (
+			i.state IN ?
+			AND NOT EXISTS (
+				SELECT 1 FROM initiative_snapshots s
+				WHERE s.initiative_id = i.id
+				AND s.credits = i.credits
+			)
+		)
+		ON CONFLICT (initiative_id, date) DO UPDATE SET
+			credits = EXCLUDED.credits,
+			contributors = EXCLUDED.contributors
+	`,
+		models.InitiativeActive,
+		[]models.InitiativeState{
+			models.InitiativeGoalReached,
+			models.InitiativeExpired,
+		},
+	)
+
+	return res.RowsAffected, res.Error
+}
+
+// Snapshots lists the snapshots of the initiative with the given ID taken
+// between the given dates, sorted by date. Empty dates are ignored.
+func (initiatives) Snapshots(
+	initiativeID string,
+	from, to types.Date,
+	db *gorm.DB,
+) ([]models.InitiativeSnapshot, error) {
+	tx := db.Model(&models.InitiativeSnapshot{}).
+		Where("initiative_id = ?", initiativeID)
+
+	if from != "" {
+		tx = tx.Where("date >= ?", from)
 	}
+	if to != "" {
+		tx = tx.Where("date <= ?", to)
+	}
+
+	var snapshots []models.InitiativeSnapshot
+	err := tx.Order("date").Find(&snapshots).Error
+	return snapshots, err
+}
+
+// CreditRate returns the average number of credits per day received by the
+// initiative since the first of the given snapshots taken within the window
+// before t.
+//
+// The snapshots are expected to be sorted by date. Returns 0 if there are no
+// snapshots in the window.
+func (initiatives) CreditRate(
+	initiative models.Initiative,
+	snapshots []models.InitiativeSnapshot,
+	window time.Duration,
+	t time.Time,
+) float64 {
+	start := t.Add(-window)
+	for _, snapshot := range snapshots {
+		date := snapshot.Date.Time()
+		if date.Before(start) {
+			continue
+		}
 
-	initiative.Credits += v
-	return tx.Save(initiative).Error
+		days := t.Sub(date).Hours() / 24
+		if days < 1 {
+			return 0
+		}
+		return (initiative.Credits - snapshot.Credits) / days
+	}
+
+	return 0
+}
+
+// ProjectCompletion returns the date at which the initiative is expected to
+// reach its goal, receiving the given number of credits per day from t
+// onwards.
+//
+// If the goal has already been reached, the date of t is returned. Returns nil
+// if the goal isn't reached at the given rate within maxProjectionDays.
+func (initiatives) ProjectCompletion(
+	initiative models.Initiative,
+	rate float64,
+	t time.Time,
+) *types.Date {
+	remaining := float64(initiative.Goal) - initiative.Credits
+	if remaining <= 0 {
+		date := types.Date(t.Format(types.DateFormat))
+		return &date
+	}
+	if rate <= 0 {
+		return nil
+	}
+
+	days := math.Ceil(remaining / rate)
+	if days > maxProjectionDays {
+		return nil
+	}
+	date := types.Date(t.AddDate(0, 0, int(days)).Format(types.DateFormat))
+	return &date
 }
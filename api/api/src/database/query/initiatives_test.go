@@ -0,0 +1,348 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/config"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/types"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type InitiativeQueriesTestSuite struct {
+	suite.Suite
+	db *gorm.DB
+	tx *gorm.DB
+}
+
+// Run each test in a transaction.
+func (s *InitiativeQueriesTestSuite) SetupTest() {
+	s.tx = s.db.Begin()
+}
+
+// Rollback the transaction after each test.
+func (s *InitiativeQueriesTestSuite) TearDownTest() {
+	s.tx.Rollback()
+}
+
+func (s *InitiativeQueriesTestSuite) createInitiative(
+	initiative models.Initiative,
+) models.Initiative {
+	initiative.Title = random.String(50)
+	initiative.Description = random.String(50)
+	initiative.Institution = models.Institution{
+		Name: random.AlphanumericString(20),
+	}
+	s.Require().NoError(s.tx.Create(&initiative).Error)
+	return initiative
+}
+
+// credit credits the initiative with the given credits, awarded by a new
+// user.
+func (s *InitiativeQueriesTestSuite) credit(
+	initiative models.Initiative,
+	credits float64,
+) (models.Initiative, error) {
+	user := models.User{Subject: random.String(30), Email: random.String(30)}
+	s.Require().NoError(s.tx.Create(&user).Error)
+
+	return Initiatives.Credit(models.CreditTransaction{
+		Source:       models.CreditSourceTrip,
+		Amount:       credits,
+		UserID:       user.ID,
+		InitiativeID: &initiative.ID,
+	}, s.tx)
+}
+
+func (s *InitiativeQueriesTestSuite) TestCredit() {
+	active := s.createInitiative(models.Initiative{
+		Goal:    100,
+		EndDate: "2500-01-01",
+		State:   models.InitiativeActive,
+		Enabled: true,
+	})
+	disabled := s.createInitiative(models.Initiative{
+		Goal:    100,
+		EndDate: "2500-01-01",
+		State:   models.InitiativeArchived,
+	})
+	expired := s.createInitiative(models.Initiative{
+		Goal:    100,
+		EndDate: "2000-01-01",
+		State:   models.InitiativeActive,
+		Enabled: true,
+	})
+	started := s.createInitiative(models.Initiative{
+		Goal:      100,
+		StartDate: &[]types.Date{"2000-01-01"}[0],
+		EndDate:   "2500-01-01",
+		State:     models.InitiativeScheduled,
+		Enabled:   true,
+	})
+
+	res, err := s.credit(active, 60)
+	s.NoError(err)
+	s.Equal(60.0, res.Credits)
+	s.Equal(models.InitiativeActive, res.State)
+
+	res, err = s.credit(active, 60)
+	s.NoError(err)
+	s.Equal(120.0, res.Credits)
+	s.Equal(models.InitiativeGoalReached, res.State)
+
+	_, err = s.credit(active, 60)
+	s.ErrorIs(err, ErrInitiativeEnded)
+
+	var dbInitiative models.Initiative
+	s.NoError(s.tx.First(&dbInitiative, "id = ?", active.ID).Error)
+	s.Equal(120.0, dbInitiative.Credits)
+	s.Equal(models.InitiativeGoalReached, dbInitiative.State)
+	s.True(dbInitiative.Enabled)
+
+	_, err = s.credit(disabled, 10)
+	s.ErrorIs(err, ErrInitiativeEnded)
+
+	_, err = s.credit(expired, 10)
+	s.ErrorIs(err, ErrInitiativeEnded)
+
+	// Scheduled initiatives are activated once their start date has passed.
+	res, err = s.credit(started, 10)
+	s.Require().NoError(err)
+	s.Equal(10.0, res.Credits)
+	s.Equal(models.InitiativeActive, res.State)
+
+	s.NoError(s.tx.First(&dbInitiative, "id = ?", started.ID).Error)
+	s.Equal(models.InitiativeActive, dbInitiative.State)
+}
+
+func (s *InitiativeQueriesTestSuite) TestCreditSponsorMatch() {
+	// 10 credits are worth 1€.
+	s.Require().NoError(s.tx.Model(&models.Settings{}).
+		Where("true").
+		Update("credits_cents_ratio", 0.1).Error)
+
+	cap := 1.5
+	expired := types.Date("2000-01-01")
+	initiative := s.createInitiative(models.Initiative{
+		Goal:    1000,
+		EndDate: "2500-01-01",
+		State:   models.InitiativeActive,
+		Enabled: true,
+		Sponsors: []models.Institution{
+			{Name: random.AlphanumericString(20)},
+			{Name: random.AlphanumericString(20)},
+			{Name: random.AlphanumericString(20)},
+		},
+	})
+
+	for i, sponsorship := range []models.InitiativeSponsor{
+		{Multiplier: 2, Cap: &cap},
+		{Multiplier: 1.5},
+		{Multiplier: 3, ValidUntil: &expired},
+	} {
+		s.Require().NoError(s.tx.Model(&models.InitiativeSponsor{}).
+			Where("initiative_id = ?", initiative.ID).
+			Where("institution_id = ?", initiative.Sponsors[i].ID).
+			Updates(&sponsorship).Error)
+	}
+
+	res, err := s.credit(initiative, 10)
+	s.Require().NoError(err)
+	s.InDelta(10+10+5, res.Credits, 1e-4)
+
+	// The first sponsor reaches its cap.
+	res, err = s.credit(initiative, 10)
+	s.Require().NoError(err)
+	s.InDelta(25+10+5+5, res.Credits, 1e-4)
+
+	sponsorships, err := Initiatives.Sponsorships(initiative.ID.String(), s.tx)
+	s.Require().NoError(err)
+	s.Require().Len(sponsorships, 3)
+	for _, sponsorship := range sponsorships {
+		switch sponsorship.InstitutionID {
+		case initiative.Sponsors[0].ID:
+			s.InDelta(15, sponsorship.MatchedCredits, 1e-4)
+			s.InDelta(1.5, sponsorship.Committed, 1e-4)
+			s.InDelta(0, *sponsorship.Remaining(), 1e-4)
+		case initiative.Sponsors[1].ID:
+			s.InDelta(10, sponsorship.MatchedCredits, 1e-4)
+			s.InDelta(1, sponsorship.Committed, 1e-4)
+			s.Nil(sponsorship.Remaining())
+		case initiative.Sponsors[2].ID:
+			s.Zero(sponsorship.MatchedCredits)
+		}
+	}
+
+	// ------------------------------------------------------ //
+	// Records the matches in the ledger, tagged with sponsor //
+	// ------------------------------------------------------ //
+	var matches []models.CreditTransaction
+	s.Require().NoError(s.tx.
+		Where("initiative_id = ?", initiative.ID).
+		Where("source = ?", models.CreditSourceSponsorMatch).
+		Order("created_at, amount").
+		Find(&matches).Error)
+	s.Require().Len(matches, 4)
+	var matched float64
+	for _, match := range matches {
+		s.Require().NotNil(match.SponsorID)
+		s.Contains([]any{initiative.Sponsors[0].ID, initiative.Sponsors[1].ID},
+			*match.SponsorID)
+		matched += match.Amount
+	}
+	s.InDelta(15+10, matched, 1e-4)
+
+	var total float64
+	s.Require().NoError(s.tx.Model(&models.CreditTransaction{}).
+		Select("sum(amount)").
+		Where("initiative_id = ?", initiative.ID).
+		Scan(&total).Error)
+	s.InDelta(res.Credits, total, 1e-4)
+}
+
+func (s *InitiativeQueriesTestSuite) TestUpdateStates() {
+	start := types.Date("2023-06-01")
+	now := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	started := s.createInitiative(models.Initiative{
+		Goal:      100,
+		StartDate: &start,
+		EndDate:   "2023-12-31",
+		State:     models.InitiativeScheduled,
+		Enabled:   true,
+	})
+	expired := s.createInitiative(models.Initiative{
+		Goal:    100,
+		EndDate: "2023-06-30",
+		State:   models.InitiativeActive,
+		Enabled: true,
+	})
+	draft := s.createInitiative(models.Initiative{
+		Goal:    100,
+		EndDate: "2023-06-30",
+		State:   models.InitiativeDraft,
+	})
+
+	ended, err := Initiatives.UpdateStates(now, s.tx)
+	s.Require().NoError(err)
+
+	var endedIDs []string
+	for _, initiative := range ended {
+		endedIDs = append(endedIDs, initiative.ID.String())
+	}
+	s.Contains(endedIDs, expired.ID.String())
+	s.NotContains(endedIDs, started.ID.String())
+	s.NotContains(endedIDs, draft.ID.String())
+
+	for _, tc := range []struct {
+		initiative models.Initiative
+		exp        models.InitiativeState
+	}{
+		{started, models.InitiativeActive},
+		{expired, models.InitiativeExpired},
+		{draft, models.InitiativeDraft},
+	} {
+		var dbInitiative models.Initiative
+		s.NoError(s.tx.First(&dbInitiative, "id = ?", tc.initiative.ID).Error)
+		s.Equal(tc.exp, dbInitiative.State)
+	}
+}
+
+func (s *InitiativeQueriesTestSuite) TestSnapshot() {
+	active := s.createInitiative(models.Initiative{
+		Goal:    100,
+		Credits: 40,
+		EndDate: "2500-01-01",
+		State:   models.InitiativeActive,
+		Enabled: true,
+	})
+	draft := s.createInitiative(models.Initiative{
+		Goal:    100,
+		EndDate: "2500-01-01",
+		State:   models.InitiativeDraft,
+	})
+
+	_, err := Initiatives.Snapshot(s.tx)
+	s.Require().NoError(err)
+
+	s.Require().NoError(s.tx.Model(&active).Update("credits", 60).Error)
+	_, err = Initiatives.Snapshot(s.tx)
+	s.Require().NoError(err)
+
+	snapshots, err := Initiatives.Snapshots(active.ID.String(), "", "", s.tx)
+	s.Require().NoError(err)
+	s.Require().Len(snapshots, 1)
+	s.Equal(60.0, snapshots[0].Credits)
+	s.Equal(int64(0), snapshots[0].Contributors)
+
+	snapshots, err = Initiatives.Snapshots(draft.ID.String(), "", "", s.tx)
+	s.Require().NoError(err)
+	s.Empty(snapshots)
+}
+
+func TestCreditRate(t *testing.T) {
+	now := time.Date(2023, 5, 15, 12, 0, 0, 0, time.UTC)
+	window := 14 * 24 * time.Hour
+	initiative := models.Initiative{Credits: 300}
+
+	for i, tc := range []struct {
+		snapshots []models.InitiativeSnapshot
+		exp       float64
+	}{
+		{nil, 0},
+		{[]models.InitiativeSnapshot{{Date: "2023-05-15", Credits: 290}}, 0},
+		{[]models.InitiativeSnapshot{
+			{Date: "2023-04-01", Credits: 0},
+			{Date: "2023-05-05", Credits: 200},
+			{Date: "2023-05-10", Credits: 250},
+		}, 100 / 10.5},
+		{[]models.InitiativeSnapshot{{Date: "2023-04-01", Credits: 0}}, 0},
+	} {
+		assert.InDelta(t, tc.exp,
+			Initiatives.CreditRate(initiative, tc.snapshots, window, now),
+			0.0001, "failed test case %d", i)
+	}
+}
+
+func TestProjectCompletion(t *testing.T) {
+	now := time.Date(2023, 5, 15, 12, 0, 0, 0, time.UTC)
+	date := func(d string) *types.Date {
+		date := types.Date(d)
+		return &date
+	}
+
+	for i, tc := range []struct {
+		initiative models.Initiative
+		rate       float64
+		exp        *types.Date
+	}{
+		{models.Initiative{Goal: 100, Credits: 50}, 10, date("2023-05-20")},
+		{models.Initiative{Goal: 100, Credits: 50}, 7, date("2023-05-23")},
+		{models.Initiative{Goal: 100, Credits: 100}, 0, date("2023-05-15")},
+		{models.Initiative{Goal: 100, Credits: 50}, 0, nil},
+		{models.Initiative{Goal: 100, Credits: 50}, 1e-9, nil},
+	} {
+		assert.Equal(t, tc.exp,
+			Initiatives.ProjectCompletion(tc.initiative, tc.rate, now),
+			"failed test case %d", i)
+	}
+}
+
+func TestInitiativeQueries(t *testing.T) {
+	config, err := config.Load("../../../.env")
+	require.NoError(t, err)
+
+	db, err := database.Init(config.DbDsn())
+	require.NoError(t, err)
+
+	db.Logger = logger.Default.LogMode(logger.Silent)
+
+	suite.Run(t, &InitiativeQueriesTestSuite{db: db})
+}
@@ -1,6 +1,10 @@
 package query
 
 import (
+	"database/sql"
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -17,31 +21,537 @@ type LeaderboardEntry struct {
 	TripCount uint      `json:"tripCount"`
 	TotalDist float64   `json:"totalDist"`
 	Credits   float64   `json:"credits"`
+	// Anonymous is true if the user chose to be shown as anonymous. The ID,
+	// name and username of anonymous users are only shown to themselves.
+	Anonymous bool `json:"anonymous"`
+	// PositionChange is the number of places the user moved up the last time
+	// their position changed, or down if negative. Only set in the
+	// leaderboards served from snapshots.
+	PositionChange int `json:"positionChange,omitempty"`
+}
+
+// LeaderboardPeriod is the period of the trips considered in a leaderboard.
+type LeaderboardPeriod string
+
+const (
+	LeaderboardAllTime LeaderboardPeriod = "all"
+	LeaderboardWeek    LeaderboardPeriod = "week"
+	LeaderboardMonth   LeaderboardPeriod = "month"
+	LeaderboardSeason  LeaderboardPeriod = "season"
+)
+
+// LeaderboardPeriods are the periods of the leaderboards with snapshots.
+var LeaderboardPeriods = []LeaderboardPeriod{
+	LeaderboardAllTime,
+	LeaderboardWeek,
+	LeaderboardMonth,
+	LeaderboardSeason,
+}
+
+// Start returns the start of the period that includes t, or nil for all time.
+func (p LeaderboardPeriod) Start(t time.Time) *time.Time {
+	y, m, d := t.Date()
+	var start time.Time
+
+	switch p {
+	case LeaderboardWeek:
+		// Weeks start on Monday.
+		offset := (int(t.Weekday()) + 6) % 7
+		start = time.Date(y, m, d-offset, 0, 0, 0, 0, t.Location())
+	case LeaderboardMonth:
+		start = time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+	case LeaderboardSeason:
+		// Seasons start in December, March, June and September. The season
+		// that starts in December ends in the following year.
+		if m == time.December {
+			y++
+		}
+		first := time.Month(int(m) % 12 / 3 * 3)
+		start = time.Date(y, first, 1, 0, 0, 0, 0, t.Location())
+	default:
+		return nil
+	}
+
+	return &start
+}
+
+// LeaderboardMetric is the value by which users are ranked in a leaderboard.
+type LeaderboardMetric string
+
+const (
+	LeaderboardDistance LeaderboardMetric = "distance"
+	LeaderboardCredits  LeaderboardMetric = "credits"
+	LeaderboardTrips    LeaderboardMetric = "trips"
+)
+
+// LeaderboardMetrics are the metrics of the leaderboards with snapshots.
+var LeaderboardMetrics = []LeaderboardMetric{
+	LeaderboardDistance,
+	LeaderboardCredits,
+	LeaderboardTrips,
+}
+
+// column returns the column of the ranking with the value of the metric.
+func (m LeaderboardMetric) column() string {
+	switch m {
+	case LeaderboardCredits:
+		return "credits"
+	case LeaderboardTrips:
+		return "trip_count"
+	default:
+		return "total_dist"
+	}
+}
+
+// LeaderboardWindow defines the ranking of a leaderboard.
+type LeaderboardWindow struct {
+	// Period of the leaderboard, which identifies its snapshot.
+	Period LeaderboardPeriod
+	// Since is the start of the period of the trips that are considered, or
+	// nil for all time.
+	Since *time.Time
+	// Metric by which the users are ranked. Defaults to the distance.
+	Metric LeaderboardMetric
+}
+
+// leaderboardUsers is the condition of the users `u` shown in a leaderboard
+// to the user with the ID given as argument. Users that deleted their account
+// are excluded, as well as users hidden from the leaderboards, except the
+// user that sees the leaderboard.
+const leaderboardUsers = `
+	u.anonymized_at IS NULL
+	AND (NOT u.hide_from_leaderboards OR u.id = ?)
+`
+
+// leaderboardAnonymous is true for the users `u` that chose to be shown as
+// anonymous in the leaderboards.
+const leaderboardAnonymous = `
+	(u.leaderboard_anonymous OR u.hidden_profile) AS anonymous
+`
+
+// maskAnonymous hides the identity of the anonymous users in the entries,
+// except for the user with the given ID.
+func maskAnonymous(entries []LeaderboardEntry, viewerID string) {
+	for i, entry := range entries {
+		if entry.Anonymous && entry.ID.String() != viewerID {
+			entries[i].ID = uuid.Nil
+			entries[i].Name = ""
+			entries[i].Username = ""
+		}
+	}
+}
+
+// ranking ranks the users that match the condition, as shown to the user
+// with the given ID. Users with the same value share the same position.
+//
+// The all-time rankings use the totals of the users. Otherwise, the totals
+// are computed from the valid trips made since the start of the window, and
+// only the users with trips in the window are ranked.
+func ranking(
+	viewerID string,
+	window LeaderboardWindow,
+	db *gorm.DB,
+	cond string,
+	args ...any,
+) *gorm.DB {
+	order := window.Metric.column()
+
+	if window.Since == nil {
+		return db.Raw(`
+			SELECT u.id, u.name, u.username,
+				u.total_dist, u.trip_count, u.credits,
+				`+leaderboardAnonymous+`,
+				dense_rank() over(ORDER BY u.`+order+` DESC) AS position
+			FROM users u
+			WHERE `+leaderboardUsers+` AND (`+cond+`)
+		`, append([]any{viewerID}, args...)...)
+	}
+
+	return db.Raw(`
+		SELECT u.id, u.name, u.username,
+			t.total_dist, t.trip_count, t.credits,
+			`+leaderboardAnonymous+`,
+			dense_rank() over(ORDER BY t.`+order+` DESC) AS position
+		FROM users u
+		JOIN (
+			SELECT user_id,
+				sum(distance) AS total_dist,
+				count(*) AS trip_count,
+				sum(credits) AS credits
+			FROM trips
+			WHERE is_valid AND created_at >= ?
+			GROUP BY user_id
+		) t ON t.user_id = u.id
+		WHERE `+leaderboardUsers+` AND (`+cond+`)
+	`, append([]any{*window.Since, viewerID}, args...)...)
 }
 
-func (leaderboard) Top(db *gorm.DB) ([]LeaderboardEntry, error) {
+// rankingTop returns `limit` entries of the ranking, skipping the first
+// `offset` entries.
+func rankingTop(
+	rank *gorm.DB,
+	viewerID string,
+	limit, offset int,
+	db *gorm.DB,
+) ([]LeaderboardEntry, error) {
 	var entries []LeaderboardEntry
-	err := db.Raw(`
-		SELECT id, name, username, total_dist, trip_count, credits,
-			row_number() over(ORDER BY total_dist DESC) as position
-		FROM users
-		ORDER BY total_dist DESC
-		LIMIT 10
-	`).Find(&entries).Error
+	err := db.Table("(?) AS ranking", rank).
+		Order("position, id").
+		Limit(limit).
+		Offset(offset).
+		Find(&entries).Error
 
+	maskAnonymous(entries, viewerID)
 	return entries, err
 }
 
-func (leaderboard) PositionOf(userID string, db *gorm.DB) (int, error) {
+// rankingAround returns the `n` entries before and the `n` entries after the
+// entry of the user with the given ID in the ranking, along with their own
+// entry, at the given position. Ties are broken by the ID of the users, so
+// the window has the same size however many users share a position.
+func rankingAround(
+	rank *gorm.DB,
+	viewerID string,
+	position, n int,
+	db *gorm.DB,
+) ([]LeaderboardEntry, error) {
+	var before, after []LeaderboardEntry
+	if n > 0 {
+		if err := db.Table("(?) AS ranking", rank).
+			Where("(position, id) < (?, ?)", position, viewerID).
+			Order("position DESC, id DESC").
+			Limit(n).
+			Find(&before).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if err := db.Table("(?) AS ranking", rank).
+		Where("(position, id) >= (?, ?)", position, viewerID).
+		Order("position, id").
+		Limit(n + 1).
+		Find(&after).Error; err != nil {
+		return nil, err
+	}
+
+	entries := make([]LeaderboardEntry, 0, len(before)+len(after))
+	for i := len(before) - 1; i >= 0; i-- {
+		entries = append(entries, before[i])
+	}
+	entries = append(entries, after...)
+
+	maskAnonymous(entries, viewerID)
+	return entries, nil
+}
+
+// rankingPosition returns the position of the user in the ranking, or 0 if
+// they aren't ranked.
+func rankingPosition(rank *gorm.DB, userID string, db *gorm.DB) (int, error) {
 	var pos int
-	err := db.Raw(`
-		SELECT position
-		FROM (
-			SELECT id, 
-				row_number() over(order by total_dist desc) as position
-			FROM users
-		) AS top
-		WHERE id = ?
-	`, userID).Scan(&pos).Error
+	err := db.Table("(?) AS ranking", rank).
+		Select("position").
+		Where("id = ?", userID).
+		Scan(&pos).Error
 	return pos, err
 }
+
+// Snapshot ranks the users in the leaderboard of the window and stores their
+// ranks, replacing the previous snapshot of the leaderboard. Returns the
+// number of users ranked.
+//
+// Users hidden from the leaderboards are stored with the rank they would have
+// among the visible users, so they can still see their own position. The
+// previous rank of a user is kept until their rank changes, and is cleared
+// when a new period starts.
+func (leaderboard) Snapshot(
+	window LeaderboardWindow,
+	takenAt time.Time,
+	tx *gorm.DB,
+) (int64, error) {
+	col := window.Metric.column()
+
+	totals := tx.Raw(`
+		SELECT u.id AS user_id, u.total_dist, u.trip_count, u.credits,
+			u.hide_from_leaderboards AS hidden
+		FROM users u
+		WHERE u.anonymized_at IS NULL
+	`)
+	if window.Since != nil {
+		totals = tx.Raw(`
+			SELECT u.id AS user_id, t.total_dist, t.trip_count, t.credits,
+				u.hide_from_leaderboards AS hidden
+			FROM users u
+			JOIN (
+				SELECT user_id,
+					sum(distance) AS total_dist,
+					count(*) AS trip_count,
+					sum(credits) AS credits
+				FROM trips
+				WHERE is_valid AND created_at >= ?
+				GROUP BY user_id
+			) t ON t.user_id = u.id
+			WHERE u.anonymized_at IS NULL
+		`, *window.Since)
+	}
+
+	res := tx.Exec(`
+		WITH totals AS (?),
+		ranked AS (
+			SELECT user_id, total_dist, trip_count, credits,
+				dense_rank() over(ORDER BY `+col+` DESC) AS rank
+			FROM totals
+			WHERE NOT hidden
+			UNION ALL
+			SELECT t.user_id, t.total_dist, t.trip_count, t.credits,
+				1 + (
+					SELECT count(DISTINCT v.`+col+`)
+					FROM totals v
+					WHERE NOT v.hidden AND v.`+col+` > t.`+col+`
+				)
+			FROM totals t
+			WHERE t.hidden
+		)
+		INSERT INTO leaderboard_ranks (
+			period, metric, user_id, rank, previous_rank,
+			trip_count, total_dist, credits, period_start, taken_at
+		)
+		SELECT ?, ?, r.user_id, r.rank,
+			CASE
+				WHEN p.period_start IS DISTINCT FROM CAST(? AS timestamptz)
+					THEN NULL
+				WHEN p.rank <> r.rank THEN p.rank
+				ELSE p.previous_rank
+			END,
+			r.trip_count, r.total_dist, r.credits,
+			CAST(? AS timestamptz), CAST(? AS timestamptz)
+		FROM ranked r
+		LEFT JOIN leaderboard_ranks p
+			ON p.period = ? AND p.metric = ? AND p.user_id = r.user_id
+		ON CONFLICT (period, metric, user_id) DO UPDATE SET
+			rank = EXCLUDED.rank,
+			previous_rank = EXCLUDED.previous_rank,
+			trip_count = EXCLUDED.trip_count,
+			total_dist = EXCLUDED.total_dist,
+			credits = EXCLUDED.credits,
+			period_start = EXCLUDED.period_start,
+			taken_at = EXCLUDED.taken_at
+	`,
+		totals,
+		window.Period, window.Metric, window.Since, window.Since, takenAt,
+		window.Period, window.Metric,
+	)
+	if res.Error != nil {
+		return 0, res.Error
+	}
+
+	// Remove the users that are no longer ranked.
+	err := tx.
+		Where("period = ? AND metric = ?", window.Period, window.Metric).
+		Where("taken_at < ?", takenAt).
+		Delete(&models.LeaderboardRank{}).Error
+
+	return res.RowsAffected, err
+}
+
+// SnapshotTakenAt returns the time of the last snapshot of the leaderboard of
+// the window, or nil if there isn't one.
+func (leaderboard) SnapshotTakenAt(
+	window LeaderboardWindow,
+	db *gorm.DB,
+) (*time.Time, error) {
+	var takenAt sql.NullTime
+	err := db.Model(&models.LeaderboardRank{}).
+		Select("max(taken_at)").
+		Where("period = ? AND metric = ?", window.Period, window.Metric).
+		Scan(&takenAt).Error
+	if err != nil || !takenAt.Valid {
+		return nil, err
+	}
+	return &takenAt.Time, nil
+}
+
+// snapshot selects the entries of the snapshot of the leaderboard of the
+// window, as shown to the user with the given ID.
+func snapshot(viewerID string, window LeaderboardWindow, db *gorm.DB) *gorm.DB {
+	return db.Table("leaderboard_ranks r").
+		Select(`r.rank AS position, u.id, u.name, u.username,
+			r.trip_count, r.total_dist, r.credits,
+			coalesce(r.previous_rank - r.rank, 0) AS position_change,
+			`+leaderboardAnonymous).
+		Joins("JOIN users u ON u.id = r.user_id").
+		Where("r.period = ? AND r.metric = ?", window.Period, window.Metric).
+		Where(leaderboardUsers, viewerID)
+}
+
+// Top returns `limit` entries of the last snapshot of the leaderboard shown to
+// the user with the given ID, skipping the first `offset` entries.
+func (leaderboard) Top(
+	viewerID string,
+	window LeaderboardWindow,
+	limit, offset int,
+	db *gorm.DB,
+) ([]LeaderboardEntry, error) {
+	var entries []LeaderboardEntry
+	err := snapshot(viewerID, window, db).
+		Order("r.rank, r.user_id").
+		Limit(limit).
+		Offset(offset).
+		Find(&entries).Error
+
+	maskAnonymous(entries, viewerID)
+	return entries, err
+}
+
+// Around returns the `n` entries before and the `n` entries after the entry of
+// the user with the given ID in the last snapshot of the leaderboard, along
+// with their own entry, at the given position.
+func (leaderboard) Around(
+	viewerID string,
+	window LeaderboardWindow,
+	position, n int,
+	db *gorm.DB,
+) ([]LeaderboardEntry, error) {
+	var before, after []LeaderboardEntry
+	if n > 0 {
+		if err := snapshot(viewerID, window, db).
+			Where("(r.rank, r.user_id) < (?, ?)", position, viewerID).
+			Order("r.rank DESC, r.user_id DESC").
+			Limit(n).
+			Find(&before).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if err := snapshot(viewerID, window, db).
+		Where("(r.rank, r.user_id) >= (?, ?)", position, viewerID).
+		Order("r.rank, r.user_id").
+		Limit(n + 1).
+		Find(&after).Error; err != nil {
+		return nil, err
+	}
+
+	entries := make([]LeaderboardEntry, 0, len(before)+len(after))
+	for i := len(before) - 1; i >= 0; i-- {
+		entries = append(entries, before[i])
+	}
+	entries = append(entries, after...)
+
+	maskAnonymous(entries, viewerID)
+	return entries, nil
+}
+
+// PositionOf returns the entry of the user in the last snapshot of the
+// leaderboard shown to them, or an empty entry if they aren't ranked. Users
+// hidden from the leaderboards still see their own position.
+func (leaderboard) PositionOf(
+	userID string,
+	window LeaderboardWindow,
+	db *gorm.DB,
+) (LeaderboardEntry, error) {
+	var entries []LeaderboardEntry
+	err := snapshot(userID, window, db).
+		Where("r.user_id = ?", userID).
+		Find(&entries).Error
+	if err != nil || len(entries) == 0 {
+		return LeaderboardEntry{}, err
+	}
+	return entries[0], nil
+}
+
+// friendsRanking ranks the user with the given ID and the users they follow.
+func friendsRanking(
+	userID string,
+	window LeaderboardWindow,
+	db *gorm.DB,
+) *gorm.DB {
+	return ranking(userID, window, db, `
+		u.id = ? OR u.id IN (
+			SELECT followee_id
+			FROM follows
+			WHERE follower_id = ? AND status = ?
+		)
+	`, userID, userID, models.FollowAccepted)
+}
+
+// FriendsTop returns the top entries of the leaderboard restricted to the user
+// with the given ID and the users they follow.
+func (leaderboard) FriendsTop(
+	userID string,
+	window LeaderboardWindow,
+	limit, offset int,
+	db *gorm.DB,
+) ([]LeaderboardEntry, error) {
+	return rankingTop(friendsRanking(userID, window, db),
+		userID, limit, offset, db)
+}
+
+// FriendsAround returns the `n` entries before and the `n` entries after the
+// entry of the user with the given ID, at the given position, in the
+// leaderboard restricted to them and the users they follow.
+func (leaderboard) FriendsAround(
+	userID string,
+	window LeaderboardWindow,
+	position, n int,
+	db *gorm.DB,
+) ([]LeaderboardEntry, error) {
+	return rankingAround(friendsRanking(userID, window, db),
+		userID, position, n, db)
+}
+
+// FriendsPositionOf returns the position of the user in the leaderboard
+// restricted to them and the users they follow.
+func (leaderboard) FriendsPositionOf(
+	userID string,
+	window LeaderboardWindow,
+	db *gorm.DB,
+) (int, error) {
+	return rankingPosition(friendsRanking(userID, window, db), userID, db)
+}
+
+// initiativeRanking ranks the users who contributed to the initiative with the
+// given ID by the credits contributed, as shown to the user with the given ID.
+func initiativeRanking(initiativeID, viewerID string, db *gorm.DB) *gorm.DB {
+	return db.Raw(`
+		SELECT u.id, u.name, u.username, c.trip_count, c.credits,
+			`+leaderboardAnonymous+`,
+			dense_rank() over(ORDER BY c.credits DESC) AS position
+		FROM initiative_contributions c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.initiative_id = ? AND `+leaderboardUsers,
+		initiativeID, viewerID)
+}
+
+// InitiativeTop returns `limit` entries of the leaderboard of the
+// contributors of the initiative with the given ID, skipping the first
+// `offset` entries. The entries don't include the distance.
+func (leaderboard) InitiativeTop(
+	initiativeID, viewerID string,
+	limit, offset int,
+	db *gorm.DB,
+) ([]LeaderboardEntry, error) {
+	return rankingTop(initiativeRanking(initiativeID, viewerID, db),
+		viewerID, limit, offset, db)
+}
+
+// InitiativeAround returns the `n` entries before and the `n` entries after
+// the entry of the user with the given ID, at the given position, in the
+// leaderboard of the contributors of the initiative with the given ID.
+func (leaderboard) InitiativeAround(
+	initiativeID, viewerID string,
+	position, n int,
+	db *gorm.DB,
+) ([]LeaderboardEntry, error) {
+	return rankingAround(initiativeRanking(initiativeID, viewerID, db),
+		viewerID, position, n, db)
+}
+
+// InitiativePositionOf returns the position of the user in the leaderboard of
+// the contributors of the initiative with the given ID, or 0 if they haven't
+// contributed to it.
+func (leaderboard) InitiativePositionOf(
+	initiativeID, userID string,
+	db *gorm.DB,
+) (int, error) {
+	return rankingPosition(initiativeRanking(initiativeID, userID, db),
+		userID, db)
+}
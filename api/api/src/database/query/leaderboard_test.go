@@ -0,0 +1,258 @@
+package query
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/config"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestPeriodStart(t *testing.T) {
+	date := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+	now := time.Date(2023, time.May, 18, 15, 4, 5, 0, time.UTC)
+
+	for i, tc := range []struct {
+		period LeaderboardPeriod
+		t      time.Time
+		exp    *time.Time
+	}{
+		{period: "all", t: now, exp: nil},
+		{period: "", t: now, exp: nil},
+		{period: "week", t: now, exp: &[]time.Time{date(2023, time.May, 15)}[0]},
+		{
+			period: "week",
+			t:      date(2023, time.January, 1),
+			exp:    &[]time.Time{date(2022, time.December, 26)}[0],
+		},
+		{period: "month", t: now, exp: &[]time.Time{date(2023, time.May, 1)}[0]},
+		{period: "season", t: now, exp: &[]time.Time{date(2023, time.March, 1)}[0]},
+		{
+			period: "season",
+			t:      date(2023, time.February, 28),
+			exp:    &[]time.Time{date(2022, time.December, 1)}[0],
+		},
+		{
+			period: "season",
+			t:      date(2023, time.December, 31),
+			exp:    &[]time.Time{date(2023, time.December, 1)}[0],
+		},
+	} {
+		assert.Equal(t, tc.exp, tc.period.Start(tc.t), "failed on test %d", i)
+	}
+}
+
+type LeaderboardQueriesTestSuite struct {
+	suite.Suite
+	db *gorm.DB
+	tx *gorm.DB
+}
+
+// Run each test in a transaction.
+func (s *LeaderboardQueriesTestSuite) SetupTest() {
+	s.tx = s.db.Begin()
+}
+
+// Rollback the transaction after each test.
+func (s *LeaderboardQueriesTestSuite) TearDownTest() {
+	s.tx.Rollback()
+}
+
+// createTrip creates a valid trip of the user with the given distance, made
+// at the given time.
+func (s *LeaderboardQueriesTestSuite) createTrip(
+	user models.User,
+	dist float64,
+	at time.Time,
+) {
+	s.Require().NoError(s.tx.Create(&models.Trip{
+		BaseModel: models.BaseModel{CreatedAt: at},
+		GPX:       []byte(`<gpx version="1.1"><trk></trk></gpx>`),
+		GPXHash:   []byte(random.String(32)),
+		IsValid:   true,
+		Distance:  dist,
+		UserID:    user.ID,
+	}).Error)
+}
+
+func (s *LeaderboardQueriesTestSuite) TestSnapshot() {
+	users := []models.User{
+		{Subject: random.String(30), Email: random.String(30)},
+		{Subject: random.String(30), Email: random.String(30)},
+		{Subject: random.String(30), Email: random.String(30)},
+		{Subject: random.String(30), Email: random.String(30)},
+	}
+	users[3].HideFromLeaderboards = true
+	s.Require().NoError(s.tx.Create(&users).Error)
+
+	// Only the trips made from now on are ranked, since no other users have
+	// trips in the future.
+	now := time.Now()
+	soon := now.Add(time.Minute)
+	for i, dist := range []float64{30, 30, 10, 20} {
+		s.createTrip(users[i], dist, soon)
+	}
+
+	window := LeaderboardWindow{
+		Period: LeaderboardWeek,
+		Since:  &now,
+		Metric: LeaderboardDistance,
+	}
+
+	// ---------------------------- //
+	// Ties share the same position //
+	// ---------------------------- //
+	ranked, err := Leaderboard.Snapshot(window, now, s.tx)
+	s.Require().NoError(err)
+	s.EqualValues(4, ranked)
+
+	top, err := Leaderboard.Top(users[0].ID.String(), window, 10, 0, s.tx)
+	s.Require().NoError(err)
+	s.Require().Len(top, 3)
+	s.Equal(1, top[0].Position)
+	s.Equal(1, top[1].Position)
+	s.Equal(users[2].ID, top[2].ID)
+	s.Equal(2, top[2].Position)
+
+	takenAt, err := Leaderboard.SnapshotTakenAt(window, s.tx)
+	s.Require().NoError(err)
+	s.Require().NotNil(takenAt)
+	s.WithinDuration(now, *takenAt, time.Second)
+
+	// ----------------------------------------- //
+	// Hidden users still see their own position //
+	// ----------------------------------------- //
+	entry, err := Leaderboard.PositionOf(users[3].ID.String(), window, s.tx)
+	s.Require().NoError(err)
+	s.Equal(2, entry.Position)
+
+	// -------------------------------- //
+	// Records the changes of the ranks //
+	// -------------------------------- //
+	s.createTrip(users[2], 50, soon)
+	_, err = Leaderboard.Snapshot(window, now.Add(time.Minute), s.tx)
+	s.Require().NoError(err)
+
+	entry, err = Leaderboard.PositionOf(users[2].ID.String(), window, s.tx)
+	s.Require().NoError(err)
+	s.Equal(1, entry.Position)
+	s.Equal(1, entry.PositionChange)
+
+	entry, err = Leaderboard.PositionOf(users[0].ID.String(), window, s.tx)
+	s.Require().NoError(err)
+	s.Equal(2, entry.Position)
+	s.Equal(-1, entry.PositionChange)
+
+	// The change is kept until the rank changes again.
+	_, err = Leaderboard.Snapshot(window, now.Add(2*time.Minute), s.tx)
+	s.Require().NoError(err)
+
+	entry, err = Leaderboard.PositionOf(users[2].ID.String(), window, s.tx)
+	s.Require().NoError(err)
+	s.Equal(1, entry.PositionChange)
+
+	// The entries around the user don't include hidden users.
+	around, err := Leaderboard.Around(users[2].ID.String(), window, 1, 1, s.tx)
+	s.Require().NoError(err)
+	s.Require().Len(around, 2)
+	s.Equal(users[2].ID, around[0].ID)
+	s.Equal(2, around[1].Position)
+
+	// ------------------------------------------- //
+	// Clears the changes when a new period starts //
+	// ------------------------------------------- //
+	s.Require().NoError(s.tx.Model(&users[0]).
+		Update("anonymized_at", now).Error)
+	window.Since = &soon
+	_, err = Leaderboard.Snapshot(window, now.Add(3*time.Minute), s.tx)
+	s.Require().NoError(err)
+
+	entry, err = Leaderboard.PositionOf(users[2].ID.String(), window, s.tx)
+	s.Require().NoError(err)
+	s.Equal(1, entry.Position)
+	s.Zero(entry.PositionChange)
+
+	// Users that are no longer ranked are removed.
+	var count int64
+	s.Require().NoError(s.tx.Model(&models.LeaderboardRank{}).
+		Where("user_id = ?", users[0].ID).
+		Count(&count).Error)
+	s.Zero(count)
+}
+
+func (s *LeaderboardQueriesTestSuite) TestFriendsAroundTies() {
+	users := make([]models.User, 7)
+	for i := range users {
+		users[i] = models.User{
+			Subject:   random.String(30),
+			Email:     random.String(30),
+			TotalDist: 10,
+		}
+	}
+	users[0].TotalDist = 20
+	s.Require().NoError(s.tx.Create(&users).Error)
+
+	// The user sees the leaderboard from the middle of the tied users, which
+	// are sorted by their IDs.
+	tied := users[1:]
+	sort.Slice(tied, func(i, j int) bool {
+		return tied[i].ID.String() < tied[j].ID.String()
+	})
+	viewer := tied[2]
+	for _, user := range users {
+		if user.ID == viewer.ID {
+			continue
+		}
+		s.Require().NoError(s.tx.Create(&models.Follow{
+			FollowerID: viewer.ID,
+			FolloweeID: user.ID,
+			Status:     models.FollowAccepted,
+		}).Error)
+	}
+
+	window := LeaderboardWindow{Period: LeaderboardAllTime}
+	position, err := Leaderboard.
+		FriendsPositionOf(viewer.ID.String(), window, s.tx)
+	s.Require().NoError(err)
+	s.Equal(2, position)
+
+	// Only one entry on each side of the user, although six users are tied.
+	entries, err := Leaderboard.
+		FriendsAround(viewer.ID.String(), window, position, 1, s.tx)
+	s.Require().NoError(err)
+	s.Require().Len(entries, 3)
+	s.Equal(viewer.ID, entries[1].ID)
+	for i, entry := range entries {
+		s.Equal(2, entry.Position, "failed on entry %d", i)
+	}
+	s.Equal(tied[1].ID, entries[0].ID)
+	s.Equal(tied[3].ID, entries[2].ID)
+
+	entries, err = Leaderboard.
+		FriendsAround(viewer.ID.String(), window, position, 0, s.tx)
+	s.Require().NoError(err)
+	s.Require().Len(entries, 1)
+	s.Equal(viewer.ID, entries[0].ID)
+}
+
+func TestLeaderboardQueries(t *testing.T) {
+	config, err := config.Load("../../../.env")
+	require.NoError(t, err)
+
+	db, err := database.Init(config.DbDsn())
+	require.NoError(t, err)
+
+	db.Logger = logger.Default.LogMode(logger.Silent)
+
+	suite.Run(t, &LeaderboardQueriesTestSuite{db: db})
+}
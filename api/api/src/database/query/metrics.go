@@ -1,7 +1,11 @@
 package query
 
 import (
+	"database/sql"
+
 	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/types"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -23,17 +27,14 @@ func (metrics) Platform(tx *gorm.DB) (PlatformMetrics, error) {
 		Count(&metrics.TotalInitiatives)
 
 	tx.Find(&models.Initiative{}).
-		Where("enabled = true").
-		Where(tx.
-			Where("end_date < now()").
-			Or("credits >= goal"),
-		).
+		Where("state IN ?", []models.InitiativeState{
+			models.InitiativeGoalReached,
+			models.InitiativeExpired,
+		}).
 		Count(&metrics.CompletedInitiatives)
 
 	tx.Find(&models.Initiative{}).
-		Where("enabled = true").
-		Where("end_date >= now()").
-		Where("credits < goal").
+		Where("state = ?", models.InitiativeActive).
 		Count(&metrics.OngoingInitiatives)
 
 	tx.Model(&models.User{}).
@@ -165,3 +166,399 @@ func (metrics) Trips(tx *gorm.DB) (TripMetrics, error) {
 
 	return metrics, tx.Error
 }
+
+// DailyContributions are the credits received by a set of initiatives in a
+// day, and the number of users who contributed them.
+type DailyContributions struct {
+	Date         types.Date `json:"date"`
+	Credits      float64    `json:"credits"`
+	Contributors int64      `json:"contributors"`
+}
+
+type InstitutionMetrics struct {
+	// Initiatives is the number of initiatives of the institution.
+	Initiatives int64 `json:"initiatives"`
+	// Credits is the sum of the credit scores of the initiatives, including
+	// the credits matched by sponsors.
+	Credits float64 `json:"credits"`
+	// Contributors is the number of distinct users who contributed to the
+	// initiatives.
+	Contributors int64 `json:"contributors"`
+	// Distance is the sum of the distances, in kilometers, that counted
+	// towards the initiatives, in proportion to the share of the credits of
+	// each trip allocated to them.
+	Distance float64 `json:"distance"`
+	// CO2 is the mass of CO2, in kilograms, that wasn't emitted thanks to the
+	// Distance cycled.
+	CO2 float64 `json:"co2"`
+
+	// AgeGroups maps the number of contributors in each age range.
+	AgeGroups ageGroups `json:"ageGroups"`
+	// GenderCount maps the number of contributors of each gender.
+	GenderCount genderCount `json:"genderCount"`
+
+	// Contributions is the time series of the credits received by the
+	// initiatives, by day. Days without contributions are omitted.
+	Contributions []DailyContributions `json:"contributions"`
+}
+
+// institutionContributors is a sub-query of the IDs of the users who
+// contributed to the initiatives of an institution.
+const institutionContributors = `
+	SELECT DISTINCT c.user_id
+	FROM credit_transactions c
+	JOIN initiatives i ON i.id = c.initiative_id
+	WHERE i.institution_id = @institution
+	AND c.amount > 0
+`
+
+// Institution computes the metrics of the initiatives of the institution with
+// the given ID. The time series of contributions covers the period from the
+// start of day `from` to the end of day `to`; the remaining metrics are
+// all-time.
+func (metrics) Institution(
+	institutionID string,
+	from, to types.Date,
+	db *gorm.DB,
+) (InstitutionMetrics, error) {
+	metrics := InstitutionMetrics{}
+	args := map[string]any{"institution": institutionID}
+
+	var totals struct {
+		Initiatives int64
+		Credits     float64
+	}
+	if err := db.Raw(`
+		SELECT count(*) AS initiatives, coalesce(sum(credits), 0) AS credits
+		FROM initiatives
+		WHERE institution_id = @institution
+	`, args).Scan(&totals).Error; err != nil {
+		return metrics, err
+	}
+	metrics.Initiatives = totals.Initiatives
+	metrics.Credits = totals.Credits
+
+	if err := db.Raw(`
+		SELECT count(*) FROM (`+institutionContributors+`) contributors
+	`, args).Scan(&metrics.Contributors).Error; err != nil {
+		return metrics, err
+	}
+
+	// Trips uploaded before the allocations of credits have no splits, and
+	// count entirely towards the user's initiative.
+	if err := db.Raw(`
+		SELECT coalesce(sum(distance), 0) FROM (
+			SELECT s.distance * s.percentage / 100 AS distance
+			FROM trip_splits s
+			JOIN initiatives i ON i.id = s.initiative_id
+			WHERE i.institution_id = @institution
+			UNION ALL
+			SELECT t.distance
+			FROM trips t
+			JOIN initiatives i ON i.id = t.initiative_id
+			WHERE i.institution_id = @institution
+			AND t.is_valid = true
+			AND NOT EXISTS (
+				SELECT 1 FROM trip_splits s WHERE s.trip_id = t.id
+			)
+		) distances
+	`, args).Scan(&metrics.Distance).Error; err != nil {
+		return metrics, err
+	}
+
+	ratio, err := Settings.CO2PerKilometer(db)
+	if err != nil {
+		return metrics, err
+	}
+	metrics.CO2 = metrics.Distance * float64(ratio)
+
+	if err := db.Raw(`
+		WITH user_age AS (
+			SELECT date_part('year', age(birthday)) AS age FROM users
+			WHERE id IN (`+institutionContributors+`)
+		)
+		SELECT
+			count(*) filter(WHERE age<18) AS "age_lt18",
+			count(*) filter(WHERE age>=18 AND age<25) AS "age18_to25",
+			count(*) filter(WHERE age>=25 AND age<30) AS "age25_to30",
+			count(*) filter(WHERE age>=30 AND age<40) AS "age30_to40",
+			count(*) filter(WHERE age>=40 AND age<60) AS "age40_to60",
+			count(*) filter(WHERE age>=60 AND age<75) AS "age60_to75",
+			count(*) filter(WHERE age>=75) AS "age_gte75"
+		FROM user_age
+	`, args).Scan(&metrics.AgeGroups).Error; err != nil {
+		return metrics, err
+	}
+
+	var genders []struct {
+		Gender string
+		Count  int64
+	}
+	if err := db.Raw(`
+		SELECT gender, count(gender)
+		FROM users
+		WHERE id IN (`+institutionContributors+`)
+		GROUP BY gender
+		HAVING gender IS NOT null
+	`, args).Scan(&genders).Error; err != nil {
+		return metrics, err
+	}
+	metrics.GenderCount = toGenderCount(genders)
+
+	metrics.Contributions = []DailyContributions{}
+	err = db.Raw(`
+		SELECT
+			c.created_at::date AS date,
+			sum(c.amount) AS credits,
+			count(DISTINCT c.user_id) AS contributors
+		FROM credit_transactions c
+		JOIN initiatives i ON i.id = c.initiative_id
+		WHERE i.institution_id = @institution
+		AND c.created_at >= @from
+		AND c.created_at < @to
+		GROUP BY 1
+		ORDER BY 1
+	`, periodArgs(from, to, args)).Scan(&metrics.Contributions).Error
+
+	return metrics, err
+}
+
+// MetricsInterval is the interval of the points of a time series of metrics.
+type MetricsInterval string
+
+const (
+	MetricsDaily   MetricsInterval = "day"
+	MetricsWeekly  MetricsInterval = "week"
+	MetricsMonthly MetricsInterval = "month"
+)
+
+// MetricsPoint are the metrics of the platform in an interval of a time
+// series.
+type MetricsPoint struct {
+	// Date is the first day of the interval. Weeks start on Monday.
+	Date types.Date `json:"date" example:"2023-03-27"`
+	// NewUsers is the number of users who signed up.
+	NewUsers int64 `json:"newUsers"`
+	// ActiveRiders is the number of distinct users with valid trips.
+	ActiveRiders int64 `json:"activeRiders"`
+	// ValidTrips is the number of valid trips uploaded.
+	ValidTrips int64 `json:"validTrips"`
+	// InvalidTrips is the number of invalid trips uploaded.
+	InvalidTrips int64 `json:"invalidTrips"`
+	// Distance is the total distance of the valid trips, in kilometers.
+	Distance float64 `json:"distance"`
+	// Credits is the sum of the entries of the credit ledger, including the
+	// credits matched by sponsors.
+	Credits float64 `json:"credits"`
+}
+
+// RollupStart returns the first day whose rollup may be outdated: the last
+// day rolled up, since it may have been rolled up before it ended, or the day
+// the first user signed up if there are no rollups yet. Returns an empty date
+// if there are no users.
+func (metrics) RollupStart(db *gorm.DB) (types.Date, error) {
+	var start sql.NullTime
+	err := db.Raw(`
+		SELECT coalesce(
+			(SELECT max(date) FROM daily_metrics),
+			(SELECT min(created_at)::date FROM users)
+		)
+	`).Scan(&start).Error
+	if err != nil || !start.Valid {
+		return "", err
+	}
+	return types.Date(start.Time.Format(types.DateFormat)), nil
+}
+
+// Rollup computes the daily metrics of the days from `from` to `to`,
+// inclusive, replacing the existing ones. Returns the number of days rolled
+// up.
+func (metrics) Rollup(from, to types.Date, tx *gorm.DB) (int64, error) {
+	args := periodArgs(from, to, map[string]any{"last": to.Time()})
+
+	if err := tx.Exec(`
+		DELETE FROM daily_active_riders
+		WHERE date >= CAST(@from AS date) AND date <= CAST(@last AS date)
+	`, args).Error; err != nil {
+		return 0, err
+	}
+
+	if err := tx.Exec(`
+		INSERT INTO daily_active_riders (date, user_id)
+		SELECT DISTINCT created_at::date, user_id
+		FROM trips
+		WHERE is_valid AND created_at >= @from AND created_at < @to
+	`, args).Error; err != nil {
+		return 0, err
+	}
+
+	res := tx.Exec(`
+		WITH days AS (
+			SELECT generate_series(
+				CAST(@from AS date), CAST(@last AS date), interval '1 day'
+			)::date AS date
+		),
+		new_users AS (
+			SELECT created_at::date AS date, count(*) AS new_users
+			FROM users
+			WHERE created_at >= @from AND created_at < @to
+			GROUP BY 1
+		),
+		trip_totals AS (
+			SELECT created_at::date AS date,
+				count(DISTINCT user_id) FILTER (WHERE is_valid) AS active_riders,
+				count(*) FILTER (WHERE is_valid) AS valid_trips,
+				count(*) FILTER (WHERE NOT is_valid) AS invalid_trips,
+				coalesce(sum(distance) FILTER (WHERE is_valid), 0) AS distance
+			FROM trips
+			WHERE created_at >= @from AND created_at < @to
+			GROUP BY 1
+		),
+		ledger AS (
+			SELECT created_at::date AS date, sum(amount) AS credits
+			FROM credit_transactions
+			WHERE created_at >= @from AND created_at < @to
+			GROUP BY 1
+		)
+		INSERT INTO daily_metrics (date, new_users, active_riders,
+			valid_trips, invalid_trips, distance, credits)
+		SELECT d.date,
+			coalesce(u.new_users, 0),
+			coalesce(t.active_riders, 0),
+			coalesce(t.valid_trips, 0),
+			coalesce(t.invalid_trips, 0),
+			coalesce(t.distance, 0),
+			coalesce(l.credits, 0)
+		FROM days d
+		LEFT JOIN new_users u ON u.date = d.date
+		LEFT JOIN trip_totals t ON t.date = d.date
+		LEFT JOIN ledger l ON l.date = d.date
+		ON CONFLICT (date) DO UPDATE SET
+			new_users = EXCLUDED.new_users,
+			active_riders = EXCLUDED.active_riders,
+			valid_trips = EXCLUDED.valid_trips,
+			invalid_trips = EXCLUDED.invalid_trips,
+			distance = EXCLUDED.distance,
+			credits = EXCLUDED.credits
+	`, args)
+
+	return res.RowsAffected, res.Error
+}
+
+// Series returns the time series of the platform metrics from the day `from`
+// to the day `to`, by the given interval, computed from the daily rollups.
+// The first and last intervals only include the days in the period, and
+// intervals without rollups are omitted.
+func (metrics) Series(
+	interval MetricsInterval,
+	from, to types.Date,
+	db *gorm.DB,
+) ([]MetricsPoint, error) {
+	args := map[string]any{
+		"interval": string(interval),
+		"from":     from.Time(),
+		"to":       to.Time(),
+	}
+
+	points := []MetricsPoint{}
+	err := db.Raw(`
+		WITH riders AS (
+			SELECT date_trunc(@interval, date)::date AS date,
+				count(DISTINCT user_id) AS active_riders
+			FROM daily_active_riders
+			WHERE date >= CAST(@from AS date) AND date <= CAST(@to AS date)
+			GROUP BY 1
+		)
+		SELECT date_trunc(@interval, m.date)::date AS date,
+			sum(m.new_users) AS new_users,
+			coalesce(max(r.active_riders), 0) AS active_riders,
+			sum(m.valid_trips) AS valid_trips,
+			sum(m.invalid_trips) AS invalid_trips,
+			sum(m.distance) AS distance,
+			sum(m.credits) AS credits
+		FROM daily_metrics m
+		LEFT JOIN riders r ON r.date = date_trunc(@interval, m.date)::date
+		WHERE m.date >= CAST(@from AS date) AND m.date <= CAST(@to AS date)
+		GROUP BY 1
+		ORDER BY 1
+	`, args).Scan(&points).Error
+
+	return points, err
+}
+
+// ParishMetrics are the valid trips that started or ended in a parish.
+type ParishMetrics struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name" example:"Arroios"`
+	// Departures is the number of trips that started in the parish.
+	Departures int64 `json:"departures"`
+	// Arrivals is the number of trips that ended in the parish.
+	Arrivals int64 `json:"arrivals"`
+	// Distance is the total distance, in kilometers, of the trips that
+	// started in the parish.
+	Distance float64 `json:"distance"`
+}
+
+// ParishFlow is the number of valid trips from a parish to another, or within
+// the same parish, and their total distance in kilometers.
+type ParishFlow struct {
+	OriginID      uuid.UUID `json:"originId"`
+	DestinationID uuid.UUID `json:"destinationId"`
+	Trips         int64     `json:"trips"`
+	Distance      float64   `json:"distance"`
+}
+
+type ParishStats struct {
+	// Parishes are the metrics of every parish, by name.
+	Parishes []ParishMetrics `json:"parishes"`
+	// Flows are the origin-destination counts of the trips that started and
+	// ended in a parish, by descending number of trips. Pairs of parishes
+	// without trips are omitted.
+	Flows []ParishFlow `json:"flows"`
+}
+
+// Parishes computes the metrics of the valid trips uploaded from the start of
+// day `from` to the end of day `to`, by the parishes their start and end were
+// assigned to.
+func (metrics) Parishes(
+	from, to types.Date,
+	db *gorm.DB,
+) (ParishStats, error) {
+	stats := ParishStats{Parishes: []ParishMetrics{}, Flows: []ParishFlow{}}
+	args := periodArgs(from, to, map[string]any{})
+
+	if err := db.Raw(`
+		SELECT p.id, p.name,
+			count(t.id) FILTER (WHERE t.start_parish_id = p.id) AS departures,
+			count(t.id) FILTER (WHERE t.end_parish_id = p.id) AS arrivals,
+			coalesce(sum(t.distance) FILTER (WHERE t.start_parish_id = p.id), 0)
+				AS distance
+		FROM parishes p
+		LEFT JOIN trips t
+			ON (t.start_parish_id = p.id OR t.end_parish_id = p.id)
+			AND t.is_valid = true
+			AND t.created_at >= @from
+			AND t.created_at < @to
+		GROUP BY p.id, p.name
+		ORDER BY p.name
+	`, args).Scan(&stats.Parishes).Error; err != nil {
+		return stats, err
+	}
+
+	err := db.Raw(`
+		SELECT start_parish_id AS origin_id,
+			end_parish_id AS destination_id,
+			count(*) AS trips,
+			sum(distance) AS distance
+		FROM trips
+		WHERE is_valid = true
+		AND start_parish_id IS NOT NULL
+		AND end_parish_id IS NOT NULL
+		AND created_at >= @from
+		AND created_at < @to
+		GROUP BY 1, 2
+		ORDER BY 3 DESC, 1, 2
+	`, args).Scan(&stats.Flows).Error
+
+	return stats, err
+}
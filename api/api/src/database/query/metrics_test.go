@@ -0,0 +1,278 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/config"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/types"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/latlon"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/random"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type MetricsQueriesTestSuite struct {
+	suite.Suite
+	db *gorm.DB
+	tx *gorm.DB
+}
+
+// Run each test in a transaction.
+func (s *MetricsQueriesTestSuite) SetupTest() {
+	s.tx = s.db.Begin()
+}
+
+// Rollback the transaction after each test.
+func (s *MetricsQueriesTestSuite) TearDownTest() {
+	s.tx.Rollback()
+}
+
+func (s *MetricsQueriesTestSuite) TestInstitution() {
+	institution := models.Institution{Name: random.AlphanumericString(20)}
+	s.Require().NoError(s.tx.Create(&institution).Error)
+
+	initiatives := make([]models.Initiative, 2)
+	for i := range initiatives {
+		initiatives[i] = models.Initiative{
+			Title:         random.String(50),
+			Description:   random.String(50),
+			Goal:          1000,
+			Credits:       float64(10 * (i + 1)),
+			EndDate:       "2500-01-01",
+			InstitutionID: institution.ID,
+		}
+	}
+	s.Require().NoError(s.tx.Create(&initiatives).Error)
+
+	birthday := types.Date(time.Now().AddDate(-20, 0, -1).Format(types.DateFormat))
+	users := []models.User{
+		{Subject: random.String(30), Email: random.String(30),
+			Profile: models.Profile{Gender: "F", Birthday: &birthday}},
+		{Subject: random.String(30), Email: random.String(30),
+			Profile: models.Profile{Gender: "M"}},
+	}
+	s.Require().NoError(s.tx.Create(&users).Error)
+
+	// A trip split between both initiatives, and a trip uploaded before the
+	// allocations of credits.
+	trips := []models.Trip{
+		{GPX: []byte("<gpx/>"), GPXHash: []byte(random.String(20)),
+			IsValid: true, Distance: 10, UserID: users[0].ID},
+		{GPX: []byte("<gpx/>"), GPXHash: []byte(random.String(20)),
+			IsValid: true, Distance: 5, UserID: users[1].ID,
+			InitiativeID: &initiatives[1].ID},
+	}
+	s.Require().NoError(s.tx.Create(&trips).Error)
+	s.Require().NoError(s.tx.Create(&[]models.TripSplit{
+		{TripID: trips[0].ID, InitiativeID: initiatives[0].ID,
+			Percentage: 60, Distance: 10},
+		{TripID: trips[0].ID, InitiativeID: initiatives[1].ID,
+			Percentage: 40, Distance: 8},
+	}).Error)
+
+	day := time.Date(2400, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, entry := range []models.CreditTransaction{
+		{CreatedAt: day, Amount: 6, UserID: users[0].ID,
+			InitiativeID: &initiatives[0].ID},
+		{CreatedAt: day, Amount: 3, UserID: users[0].ID,
+			InitiativeID: &initiatives[1].ID},
+		{CreatedAt: day.Add(24 * time.Hour), Amount: 5, UserID: users[1].ID,
+			InitiativeID: &initiatives[1].ID},
+		// Outside of the period.
+		{CreatedAt: day.Add(72 * time.Hour), Amount: 2, UserID: users[1].ID,
+			InitiativeID: &initiatives[1].ID},
+	} {
+		entry.Source = models.CreditSourceTrip
+		s.Require().NoError(Credits.Record(&entry, s.tx))
+	}
+
+	ratio, err := Settings.CO2PerKilometer(s.tx)
+	s.Require().NoError(err)
+
+	metrics, err := Metrics.Institution(
+		institution.ID.String(), "2400-01-01", "2400-01-02", s.tx)
+	s.Require().NoError(err)
+
+	s.Equal(int64(2), metrics.Initiatives)
+	s.InDelta(30, metrics.Credits, 1e-9)
+	s.Equal(int64(2), metrics.Contributors)
+	s.InDelta(6+3.2+5, metrics.Distance, 1e-9)
+	s.InDelta((6+3.2+5)*float64(ratio), metrics.CO2, 1e-6)
+	s.Equal(int64(1), metrics.AgeGroups.Age18To25)
+	s.Equal(genderCount{M: 1, F: 1}, metrics.GenderCount)
+	s.Equal([]DailyContributions{
+		{Date: "2400-01-01", Credits: 9, Contributors: 1},
+		{Date: "2400-01-02", Credits: 5, Contributors: 1},
+	}, metrics.Contributions)
+}
+
+func (s *MetricsQueriesTestSuite) TestSeries() {
+	// 2400-01-03 is a Monday.
+	day := time.Date(2400, 1, 3, 12, 0, 0, 0, time.UTC)
+
+	users := []models.User{
+		{Subject: random.String(30), Email: random.String(30)},
+		{Subject: random.String(30), Email: random.String(30)},
+	}
+	for i := range users {
+		users[i].CreatedAt = day
+	}
+	s.Require().NoError(s.tx.Create(&users).Error)
+
+	for _, trip := range []models.Trip{
+		{BaseModel: models.BaseModel{CreatedAt: day},
+			IsValid: true, Distance: 10, Credits: 1, UserID: users[0].ID},
+		{BaseModel: models.BaseModel{CreatedAt: day},
+			IsValid: true, Distance: 5, Credits: 1, UserID: users[0].ID},
+		{BaseModel: models.BaseModel{CreatedAt: day.Add(24 * time.Hour)},
+			IsValid: true, Distance: 2, Credits: 1, UserID: users[0].ID},
+		{BaseModel: models.BaseModel{CreatedAt: day.Add(24 * time.Hour)},
+			IsValid: false, Distance: 50, UserID: users[1].ID},
+	} {
+		trip.GPX = []byte("<gpx/>")
+		trip.GPXHash = []byte(random.String(20))
+		s.Require().NoError(s.tx.Create(&trip).Error)
+	}
+
+	s.Require().NoError(Credits.Record(&models.CreditTransaction{
+		CreatedAt: day,
+		Source:    models.CreditSourceTrip,
+		Amount:    7,
+		UserID:    users[0].ID,
+	}, s.tx))
+
+	// ---------------------------------------------- //
+	// Rolls up every day of the period, even if idle //
+	// ---------------------------------------------- //
+	days, err := Metrics.Rollup("2400-01-03", "2400-01-05", s.tx)
+	s.Require().NoError(err)
+	s.EqualValues(3, days)
+
+	start, err := Metrics.RollupStart(s.tx)
+	s.Require().NoError(err)
+	s.Equal(types.Date("2400-01-05"), start)
+
+	// Rolling up again replaces the metrics of the days.
+	_, err = Metrics.Rollup("2400-01-03", "2400-01-05", s.tx)
+	s.Require().NoError(err)
+
+	points, err := Metrics.Series(MetricsDaily, "2400-01-03", "2400-01-05", s.tx)
+	s.Require().NoError(err)
+	s.Equal([]MetricsPoint{
+		{Date: "2400-01-03", NewUsers: 2, ActiveRiders: 1, ValidTrips: 2,
+			Distance: 15, Credits: 7},
+		{Date: "2400-01-04", ActiveRiders: 1, ValidTrips: 1, InvalidTrips: 1,
+			Distance: 2},
+		{Date: "2400-01-05"},
+	}, points)
+
+	// ----------------------------------------------------- //
+	// Counts the distinct active riders of longer intervals //
+	// ----------------------------------------------------- //
+	points, err = Metrics.Series(MetricsWeekly, "2400-01-03", "2400-01-05", s.tx)
+	s.Require().NoError(err)
+	s.Equal([]MetricsPoint{
+		{Date: "2400-01-03", NewUsers: 2, ActiveRiders: 1, ValidTrips: 3,
+			InvalidTrips: 1, Distance: 17, Credits: 7},
+	}, points)
+
+	points, err = Metrics.Series(MetricsMonthly, "2400-01-04", "2400-01-31", s.tx)
+	s.Require().NoError(err)
+	s.Require().Len(points, 1)
+	s.Equal(types.Date("2400-01-01"), points[0].Date)
+	s.Equal(int64(1), points[0].ValidTrips)
+}
+
+func (s *MetricsQueriesTestSuite) TestParishes() {
+	s.Require().NoError(s.tx.Where("true").Delete(&models.Parish{}).Error)
+
+	square := func(lat, lon float64) latlon.Boundary {
+		return latlon.Boundary{{{
+			{Lat: lat, Lon: lon}, {Lat: lat, Lon: lon + 0.01},
+			{Lat: lat + 0.01, Lon: lon + 0.01}, {Lat: lat + 0.01, Lon: lon},
+		}}}
+	}
+	_, err := Parishes.Replace([]latlon.NamedBoundary{
+		{Name: "Arroios", Boundary: square(38.72, -9.14)},
+		{Name: "Belém", Boundary: square(38.69, -9.21)},
+		{Name: "Lumiar", Boundary: square(38.77, -9.16)},
+	}, s.tx)
+	s.Require().NoError(err)
+
+	var parishes []models.Parish
+	s.Require().NoError(s.tx.Order("name").Find(&parishes).Error)
+	s.Require().Len(parishes, 3)
+	arroios, belem, lumiar := parishes[0].ID, parishes[1].ID, parishes[2].ID
+
+	user := models.User{Subject: random.String(30), Email: random.String(30)}
+	s.Require().NoError(s.tx.Create(&user).Error)
+
+	day := time.Date(2400, 1, 3, 12, 0, 0, 0, time.UTC)
+	for _, trip := range []models.Trip{
+		{BaseModel: models.BaseModel{CreatedAt: day}, IsValid: true,
+			Distance: 10, StartParishID: &arroios, EndParishID: &belem},
+		{BaseModel: models.BaseModel{CreatedAt: day}, IsValid: true,
+			Distance: 4, StartParishID: &arroios, EndParishID: &belem},
+		{BaseModel: models.BaseModel{CreatedAt: day}, IsValid: true,
+			Distance: 1, StartParishID: &arroios, EndParishID: &arroios},
+		// Ends outside of the parishes.
+		{BaseModel: models.BaseModel{CreatedAt: day}, IsValid: true,
+			Distance: 7, StartParishID: &belem},
+		// Invalid, and outside of the period.
+		{BaseModel: models.BaseModel{CreatedAt: day}, IsValid: false,
+			Distance: 50, StartParishID: &arroios, EndParishID: &belem},
+		{BaseModel: models.BaseModel{CreatedAt: day.AddDate(0, 0, 2)},
+			IsValid: true, Distance: 50, StartParishID: &arroios,
+			EndParishID: &belem},
+	} {
+		trip.GPX = []byte("<gpx/>")
+		trip.GPXHash = []byte(random.String(20))
+		trip.UserID = user.ID
+		s.Require().NoError(s.tx.Create(&trip).Error)
+	}
+
+	stats, err := Metrics.Parishes("2400-01-03", "2400-01-04", s.tx)
+	s.Require().NoError(err)
+
+	s.Equal([]ParishMetrics{
+		{ID: arroios, Name: "Arroios", Departures: 3, Arrivals: 1, Distance: 15},
+		{ID: belem, Name: "Belém", Departures: 1, Arrivals: 2, Distance: 7},
+		{ID: lumiar, Name: "Lumiar"},
+	}, stats.Parishes)
+	s.Equal([]ParishFlow{
+		{OriginID: arroios, DestinationID: belem, Trips: 2, Distance: 14},
+		{OriginID: arroios, DestinationID: arroios, Trips: 1, Distance: 1},
+	}, stats.Flows)
+
+	// --------------------------------------------------- //
+	// Replacing the parishes deletes the ones not present //
+	// --------------------------------------------------- //
+	deleted, err := Parishes.Replace([]latlon.NamedBoundary{
+		{Name: "Arroios", Boundary: square(38.73, -9.14)},
+		{Name: "Arroios", Boundary: square(38.74, -9.14)},
+	}, s.tx)
+	s.Require().NoError(err)
+	s.EqualValues(2, deleted)
+
+	s.Require().NoError(s.tx.Find(&parishes).Error)
+	s.Require().Len(parishes, 1)
+	s.Equal(arroios, parishes[0].ID)
+	s.True(parishes[0].Boundary.Contains(latlon.Coords{Lat: 38.735, Lon: -9.135}))
+}
+
+func TestMetricsQueries(t *testing.T) {
+	config, err := config.Load("../../../.env")
+	require.NoError(t, err)
+
+	db, err := database.Init(config.DbDsn())
+	require.NoError(t, err)
+
+	db.Logger = logger.Default.LogMode(logger.Silent)
+
+	suite.Run(t, &MetricsQueriesTestSuite{db: db})
+}
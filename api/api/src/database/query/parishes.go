@@ -0,0 +1,47 @@
+package query
+
+import (
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/latlon"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type parishes struct{}
+
+var Parishes parishes
+
+// Replace replaces the parishes with the given boundaries, matched by name.
+// The boundaries of the existing parishes are updated, and the parishes
+// missing from the boundaries are deleted, along with their assignment to
+// trips. The boundaries must not be empty. Returns the number of parishes deleted.
+func (parishes) Replace(
+	boundaries []latlon.NamedBoundary,
+	db *gorm.DB,
+) (int64, error) {
+	// Repeated names in the boundaries are ignored.
+	seen := make(map[string]bool, len(boundaries))
+	names := make([]string, 0, len(boundaries))
+	parishes := make([]models.Parish, 0, len(boundaries))
+	for _, b := range boundaries {
+		if seen[b.Name] {
+			continue
+		}
+		seen[b.Name] = true
+		names = append(names, b.Name)
+		parishes = append(parishes,
+			models.Parish{Name: b.Name, Boundary: b.Boundary})
+	}
+
+	if err := db.
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"boundary", "updated_at"}),
+		}).
+		CreateInBatches(parishes, 10).Error; err != nil {
+		return 0, err
+	}
+
+	res := db.Where("name NOT IN ?", names).Delete(&models.Parish{})
+	return res.RowsAffected, res.Error
+}
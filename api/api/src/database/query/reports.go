@@ -0,0 +1,120 @@
+package query
+
+import (
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/types"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type reports struct{}
+
+var Reports reports
+
+// ledgerWithRatio is a sub-query of the credit ledger with the credits
+// converted to cents, using the ratio in effect when they were awarded.
+const ledgerWithRatio = `
+	SELECT
+		c.created_at,
+		c.initiative_id,
+		c.source,
+		c.sponsor_id,
+		c.amount,
+		r.ratio,
+		c.amount / r.ratio AS cents
+	FROM credit_transactions c
+	JOIN LATERAL (
+		SELECT ratio FROM credits_cents_ratios
+		WHERE valid_from <= c.created_at
+		ORDER BY valid_from DESC
+		LIMIT 1
+	) r ON true
+	WHERE c.initiative_id IS NOT NULL
+	AND c.created_at >= @from
+	AND c.created_at < @to
+`
+
+// InitiativeReportRow is the sum of the credits received by an initiative in
+// a day, with a given credits to cents ratio.
+type InitiativeReportRow struct {
+	Date    types.Date `json:"date"`
+	Ratio   float32    `json:"ratio"`
+	Credits float64    `json:"credits"`
+	Cents   float64    `json:"cents"`
+}
+
+// Initiative lists the credits received by the initiative with the given ID
+// in the period from the start of day `from` to the end of day `to`, grouped
+// by day and credits to cents ratio.
+func (reports) Initiative(
+	initiativeID string,
+	from, to types.Date,
+	db *gorm.DB,
+) ([]InitiativeReportRow, error) {
+	var rows []InitiativeReportRow
+	err := db.Raw(`
+		SELECT
+			l.created_at::date AS date,
+			l.ratio,
+			sum(l.amount) AS credits,
+			sum(l.cents) AS cents
+		FROM (`+ledgerWithRatio+`) l
+		WHERE l.initiative_id = @initiative
+		GROUP BY 1, 2
+		ORDER BY 1, 2
+	`, periodArgs(from, to, map[string]any{
+		"initiative": initiativeID,
+	})).Scan(&rows).Error
+
+	return rows, err
+}
+
+// SponsorReportRow is the sum of the credits received by an initiative
+// sponsored by an institution.
+type SponsorReportRow struct {
+	InitiativeID uuid.UUID `json:"initiativeId"`
+	Title        string    `json:"title"`
+	Credits      float64   `json:"credits"`
+	Cents        float64   `json:"cents"`
+}
+
+// Sponsor lists the credits received by each initiative sponsored by the
+// institution with the given ID, in the period from the start of day `from`
+// to the end of day `to`. The credits matched by other sponsors aren't
+// included.
+func (reports) Sponsor(
+	institutionID string,
+	from, to types.Date,
+	db *gorm.DB,
+) ([]SponsorReportRow, error) {
+	var rows []SponsorReportRow
+	err := db.Raw(`
+		SELECT
+			i.id AS initiative_id,
+			i.title,
+			coalesce(sum(l.amount), 0) AS credits,
+			coalesce(sum(l.cents), 0) AS cents
+		FROM initiatives i
+		JOIN initiative_sponsors s ON s.initiative_id = i.id
+		LEFT JOIN (`+ledgerWithRatio+`) l ON l.initiative_id = i.id
+			AND (l.source <> @match OR l.sponsor_id = @institution)
+		WHERE s.institution_id = @institution
+		GROUP BY i.id, i.title
+		ORDER BY i.title
+	`, periodArgs(from, to, map[string]any{
+		"institution": institutionID,
+		"match":       models.CreditSourceSponsorMatch,
+	})).Scan(&rows).Error
+
+	return rows, err
+}
+
+// periodArgs adds the named arguments `from` and `to` to args, the start of
+// the days `from` and `to + 1`.
+func periodArgs(from, to types.Date, args map[string]any) map[string]any {
+	args["from"] = from.Time()
+	args["to"] = to.Time().Add(24 * time.Hour)
+	return args
+}
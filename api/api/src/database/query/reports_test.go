@@ -0,0 +1,123 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/config"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/types"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/random"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type ReportQueriesTestSuite struct {
+	suite.Suite
+	db *gorm.DB
+	tx *gorm.DB
+}
+
+// Run each test in a transaction.
+func (s *ReportQueriesTestSuite) SetupTest() {
+	s.tx = s.db.Begin()
+}
+
+// Rollback the transaction after each test.
+func (s *ReportQueriesTestSuite) TearDownTest() {
+	s.tx.Rollback()
+}
+
+func (s *ReportQueriesTestSuite) TestReports() {
+	initiative := models.Initiative{
+		Title:       random.String(50),
+		Description: random.String(50),
+		Goal:        1000,
+		EndDate:     "2500-01-01",
+		Institution: models.Institution{
+			Name: random.AlphanumericString(20),
+		},
+		Sponsors: []models.Institution{
+			{Name: random.AlphanumericString(20)},
+			{Name: random.AlphanumericString(20)},
+		},
+	}
+	s.Require().NoError(s.tx.Create(&initiative).Error)
+	sponsor, other := initiative.Sponsors[0], initiative.Sponsors[1]
+
+	user := models.User{Subject: random.String(30), Email: random.String(30)}
+	s.Require().NoError(s.tx.Create(&user).Error)
+
+	day := time.Date(2400, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.tx.Create(&[]models.CreditsCentsRatio{
+		{ValidFrom: day, Ratio: 1},
+		{ValidFrom: day.Add(24 * time.Hour), Ratio: 2},
+	}).Error)
+
+	for _, entry := range []models.CreditTransaction{
+		{CreatedAt: day, Amount: 10},
+		{CreatedAt: day.Add(time.Hour), Amount: 20},
+		{CreatedAt: day.Add(25 * time.Hour), Amount: 40},
+		// Outside of the period.
+		{CreatedAt: day.Add(72 * time.Hour), Amount: 80},
+	} {
+		entry.Source = models.CreditSourceTrip
+		entry.UserID = user.ID
+		entry.InitiativeID = &initiative.ID
+		s.Require().NoError(Credits.Record(&entry, s.tx))
+	}
+
+	// The credits matched by each sponsor.
+	for _, entry := range []models.CreditTransaction{
+		{CreatedAt: day.Add(2 * time.Hour), Amount: 6, SponsorID: &sponsor.ID},
+		{CreatedAt: day.Add(3 * time.Hour), Amount: 4, SponsorID: &other.ID},
+	} {
+		entry.Source = models.CreditSourceSponsorMatch
+		entry.UserID = user.ID
+		entry.InitiativeID = &initiative.ID
+		s.Require().NoError(Credits.Record(&entry, s.tx))
+	}
+
+	from, to := types.Date("2400-01-01"), types.Date("2400-01-02")
+
+	rows, err := Reports.Initiative(initiative.ID.String(), from, to, s.tx)
+	s.Require().NoError(err)
+	s.Equal([]InitiativeReportRow{
+		{Date: "2400-01-01", Ratio: 1, Credits: 40, Cents: 40},
+		{Date: "2400-01-02", Ratio: 2, Credits: 40, Cents: 20},
+	}, rows)
+
+	// Each sponsor's report only includes the credits it matched.
+	sponsorRows, err := Reports.Sponsor(sponsor.ID.String(), from, to, s.tx)
+	s.Require().NoError(err)
+	s.Equal([]SponsorReportRow{{
+		InitiativeID: initiative.ID,
+		Title:        initiative.Title,
+		Credits:      76,
+		Cents:        56,
+	}}, sponsorRows)
+
+	sponsorRows, err = Reports.Sponsor(other.ID.String(), from, to, s.tx)
+	s.Require().NoError(err)
+	s.Equal([]SponsorReportRow{{
+		InitiativeID: initiative.ID,
+		Title:        initiative.Title,
+		Credits:      74,
+		Cents:        54,
+	}}, sponsorRows)
+}
+
+func TestReportQueries(t *testing.T) {
+	config, err := config.Load("../../../.env")
+	require.NoError(t, err)
+
+	db, err := database.Init(config.DbDsn())
+	require.NoError(t, err)
+
+	db.Logger = logger.Default.LogMode(logger.Silent)
+
+	suite.Run(t, &ReportQueriesTestSuite{db: db})
+}
@@ -16,3 +16,27 @@ func (settings) KilometersCreditsRatio(db *gorm.DB) (float32, error) {
 		First(&result).Error
 	return result, err
 }
+
+func (settings) CreditsCentsRatio(db *gorm.DB) (float32, error) {
+	var result float32
+	err := db.Model(&models.Settings{}).
+		Select("credits_cents_ratio").
+		First(&result).Error
+	return result, err
+}
+
+func (settings) RequireVerifiedEmail(db *gorm.DB) (bool, error) {
+	var result bool
+	err := db.Model(&models.Settings{}).
+		Select("require_verified_email").
+		First(&result).Error
+	return result, err
+}
+
+func (settings) CO2PerKilometer(db *gorm.DB) (float32, error) {
+	var result float32
+	err := db.Model(&models.Settings{}).
+		Select("co2_per_kilometer").
+		First(&result).Error
+	return result, err
+}
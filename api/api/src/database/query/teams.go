@@ -0,0 +1,177 @@
+package query
+
+import (
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/types"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type teams struct{}
+
+var Teams teams
+
+// membershipTrips is the join condition of the valid trips made by a member of
+// a team during their membership, for a team membership `m` and trips `tr`.
+const membershipTrips = `
+	tr.user_id = m.user_id
+	AND tr.is_valid
+	AND tr.created_at >= m.joined_at
+	AND (m.left_at IS NULL OR tr.created_at < m.left_at)
+`
+
+type TeamLeaderboardEntry struct {
+	Position    int       `json:"position"`
+	ID          uuid.UUID `json:"teamId"`
+	Name        string    `json:"name"`
+	MemberCount uint      `json:"memberCount"`
+	TripCount   uint      `json:"tripCount"`
+	TotalDist   float64   `json:"totalDist"`
+	Credits     float64   `json:"credits"`
+}
+
+// teamsRanking ranks the teams by the total distance of the valid trips made
+// by their members while they were members of the team.
+func teamsRanking(db *gorm.DB) *gorm.DB {
+	return db.Raw(`
+		SELECT t.id, t.name,
+			count(DISTINCT m.user_id) FILTER (WHERE m.left_at IS NULL)
+				AS member_count,
+			count(tr.id) AS trip_count,
+			coalesce(sum(tr.distance), 0) AS total_dist,
+			coalesce(sum(tr.credits), 0) AS credits,
+			row_number() over(
+				ORDER BY coalesce(sum(tr.distance), 0) DESC, t.created_at
+			) AS position
+		FROM teams t
+		LEFT JOIN team_memberships m ON m.team_id = t.id
+		LEFT JOIN trips tr ON ` + membershipTrips + `
+		GROUP BY t.id
+	`)
+}
+
+// Top returns the top 10 of the teams leaderboard.
+func (teams) Top(db *gorm.DB) ([]TeamLeaderboardEntry, error) {
+	var entries []TeamLeaderboardEntry
+	err := db.Table("(?) AS ranking", teamsRanking(db)).
+		Order("position").
+		Limit(10).
+		Find(&entries).Error
+
+	return entries, err
+}
+
+// PositionsOf returns the entries in the teams leaderboard of the teams the
+// user with the given ID is currently a member of.
+func (teams) PositionsOf(userID string, db *gorm.DB) ([]TeamLeaderboardEntry, error) {
+	var entries []TeamLeaderboardEntry
+	err := db.Table("(?) AS ranking", teamsRanking(db)).
+		Where(`id IN (
+			SELECT team_id
+			FROM team_memberships
+			WHERE user_id = ? AND left_at IS NULL
+		)`, userID).
+		Order("position").
+		Find(&entries).Error
+
+	return entries, err
+}
+
+// membersRanking ranks the current members of the team with the given ID by
+// the total distance of the valid trips they made as members of the team, as
+// shown to the user with the given ID.
+func membersRanking(teamID, viewerID string, db *gorm.DB) *gorm.DB {
+	return db.Raw(`
+		SELECT u.id, u.name, u.username,
+			`+leaderboardAnonymous+`,
+			count(tr.id) AS trip_count,
+			coalesce(sum(tr.distance), 0) AS total_dist,
+			coalesce(sum(tr.credits), 0) AS credits,
+			row_number() over(
+				ORDER BY coalesce(sum(tr.distance), 0) DESC, min(m.joined_at)
+			) AS position
+		FROM team_memberships m
+		JOIN users u ON u.id = m.user_id
+		LEFT JOIN trips tr ON `+membershipTrips+`
+		WHERE m.team_id = ?
+			AND `+leaderboardUsers+`
+			AND m.user_id IN (
+				SELECT user_id
+				FROM team_memberships
+				WHERE team_id = ? AND left_at IS NULL
+			)
+		GROUP BY u.id
+	`, teamID, viewerID, teamID)
+}
+
+// MembersTop returns the top 10 of the leaderboard of the members of the team
+// with the given ID, as shown to the user with the given ID.
+func (teams) MembersTop(teamID, viewerID string, db *gorm.DB) ([]LeaderboardEntry, error) {
+	var entries []LeaderboardEntry
+	err := db.Table("(?) AS ranking", membersRanking(teamID, viewerID, db)).
+		Order("position").
+		Limit(10).
+		Find(&entries).Error
+
+	maskAnonymous(entries, viewerID)
+	return entries, err
+}
+
+// MemberPositionOf returns the position of the user in the leaderboard of the
+// members of the team, or 0 if they aren't a member.
+func (teams) MemberPositionOf(teamID, userID string, db *gorm.DB) (int, error) {
+	var pos int
+	err := db.Table("(?) AS ranking", membersRanking(teamID, userID, db)).
+		Select("position").
+		Where("id = ?", userID).
+		Scan(&pos).Error
+	return pos, err
+}
+
+// TeamStatsRow is the sum of the valid trips made by a member of a team.
+type TeamStatsRow struct {
+	UserID   uuid.UUID `json:"userId"`
+	Name     string    `json:"name"`
+	Username string    `json:"username"`
+	// Anonymized is true if the member has since deleted their account.
+	Anonymized bool       `json:"anonymized"`
+	JoinedAt   types.Date `json:"joinedAt"`
+	Active     bool       `json:"active"`
+	TripCount  uint       `json:"tripCount"`
+	TotalDist  float64    `json:"totalDist"`
+	Credits    float64    `json:"credits"`
+}
+
+// Stats lists the valid trips made by each member of the team with the given
+// ID in the period from the start of day `from` to the end of day `to`, while
+// they were members of the team. Includes former members, with Active set to
+// false.
+func (teams) Stats(
+	teamID string,
+	from, to types.Date,
+	db *gorm.DB,
+) ([]TeamStatsRow, error) {
+	var rows []TeamStatsRow
+	err := db.Raw(`
+		SELECT u.id AS user_id, u.name, u.username,
+			u.anonymized_at IS NOT NULL AS anonymized,
+			min(m.joined_at)::date AS joined_at,
+			bool_or(m.left_at IS NULL) AS active,
+			count(tr.id) AS trip_count,
+			coalesce(sum(tr.distance), 0) AS total_dist,
+			coalesce(sum(tr.credits), 0) AS credits
+		FROM team_memberships m
+		JOIN users u ON u.id = m.user_id
+		LEFT JOIN trips tr ON `+membershipTrips+`
+			AND tr.created_at >= @from
+			AND tr.created_at < @to
+		WHERE m.team_id = @team
+			AND m.joined_at < @to
+			AND (m.left_at IS NULL OR m.left_at >= @from)
+		GROUP BY u.id
+		ORDER BY total_dist DESC, u.name
+	`, periodArgs(from, to, map[string]any{
+		"team": teamID,
+	})).Scan(&rows).Error
+
+	return rows, err
+}
@@ -1,6 +1,9 @@
 package query
 
 import (
+	"errors"
+	"time"
+
 	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/server/middleware"
 	"gorm.io/gorm"
@@ -10,13 +13,22 @@ type users struct{}
 
 var Users users
 
-// FromClaims retrieves the user identified by the given claims.
+var (
+	ErrSoleInstitutionOwner = errors.New(
+		"the last owner of an institution that has other members can't be deleted",
+	)
+)
+
+// FromClaims retrieves the user identified by the given claims, with the
+// user's memberships of institutions and current memberships of teams.
 func (users) FromClaims(
 	claims *middleware.Claims,
 	db *gorm.DB,
 ) (models.User, error) {
 	var user models.User
 	err := db.
+		Preload("Memberships").
+		Preload("TeamMemberships", "left_at IS NULL").
 		Where("subject = ?", claims.Sub).
 		Or("subject = ?", claims.Name).
 		First(&user).Error
@@ -30,6 +42,9 @@ func (users) ByEmail(email string, db *gorm.DB) (models.User, error) {
 	return user, err
 }
 
+// UpdateStats adds a trip to the stats of the user. The user's Credits are a
+// cache of the credit ledger, so the credits must be recorded in it within
+// the same transaction.
 func (users) UpdateStats(
 	user *models.User,
 	dist, credits float64,
@@ -42,15 +57,150 @@ func (users) UpdateStats(
 	return tx.Save(&user).Error
 }
 
+// ClearInitiative unsets the current initiative of all the users that have
+// selected the initiative with the given ID.
+// Returns the number of affected users.
+func (users) ClearInitiative(initiativeID string, db *gorm.DB) (int64, error) {
+	res := db.Model(&models.User{}).
+		Where("initiative_id = ?", initiativeID).
+		Update("initiative_id", nil)
+
+	return res.RowsAffected, res.Error
+}
+
 // InitiativeCount returns the number of unique initiatives helped by the user.
+// It's computed from the credit ledger, so every initiative that received a
+// part of the credits of a trip is counted.
 func (users) InitiativeCount(userID string, db *gorm.DB) (int64, error) {
 	var res int64
-	err := db.Model(&models.Trip{}).
-		Select("initiative_id").
-		Where("is_valid = true").
+	err := db.Model(&models.CreditTransaction{}).
 		Where("user_id = ?", userID).
-		Group("initiative_id").
+		Where("initiative_id IS NOT NULL").
+		Where("amount > 0").
+		Distinct("initiative_id").
 		Count(&res).Error
 
 	return res, err
 }
+
+// AnonymizedGPX replaces the GPX files of the trips of anonymized users.
+const AnonymizedGPX = `<?xml version="1.0" encoding="UTF-8"?><gpx version="1.1" creator="cycleforlisbon"></gpx>`
+
+// Anonymize erases the personal data of the given user, as part of the
+// deletion of their account.
+//
+// The user's profile is cleared, and their email, subject and password are
+// replaced so they can no longer log in. Their trips are stripped of the GPX
+// tracks and addresses, keeping only the aggregates, and their credits are
+// kept, so the platform's totals and the initiatives' history don't change.
+// Their current team memberships are ended, so the trips they made as members
+// still count towards the teams' totals. Institution memberships, follows,
+// achievements, FCM tokens, data exports, and email verification and password
+// reset codes are deleted.
+//
+// Returns ErrSoleInstitutionOwner if the user is the last owner of an
+// institution that has other members, who would be left without an owner.
+//
+// Files stored in S3 and the user's login sessions aren't deleted here.
+func (users) Anonymize(user *models.User, tx *gorm.DB) error {
+	id := user.ID.String()
+
+	var soleOwned int64
+	if err := tx.Model(&models.InstitutionMembership{}).
+		Where("user_id = ? AND role = ?", id, models.InstitutionOwner).
+		Where(`NOT EXISTS (
+			SELECT 1 FROM institution_memberships others
+			WHERE others.institution_id = institution_memberships.institution_id
+			AND others.user_id <> institution_memberships.user_id
+			AND others.role = ?
+		)`, models.InstitutionOwner).
+		Where(`EXISTS (
+			SELECT 1 FROM institution_memberships others
+			WHERE others.institution_id = institution_memberships.institution_id
+			AND others.user_id <> institution_memberships.user_id
+		)`).
+		Count(&soleOwned).Error; err != nil {
+		return err
+	}
+	if soleOwned > 0 {
+		return ErrSoleInstitutionOwner
+	}
+	email := user.Email
+	now := time.Now()
+
+	user.Profile = models.Profile{}
+	user.Subject = "deleted:" + id
+	user.Email = "deleted-" + id + "@anonymized.invalid"
+	user.HashedPassword = ""
+	user.Verified = false
+	user.InitiativeID = nil
+	user.Initiative = nil
+	user.Memberships = nil
+	user.TeamMemberships = nil
+	user.AnonymizedAt = &now
+
+	if err := tx.Model(user).
+		Updates(map[string]any{
+			"name":            nil,
+			"username":        nil,
+			"gender":          nil,
+			"birthday":        nil,
+			"subject":         user.Subject,
+			"email":           user.Email,
+			"hashed_password": nil,
+			"verified":        false,
+			"initiative_id":   nil,
+			"anonymized_at":   now,
+		}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.Trip{}).
+		Where("user_id = ?", id).
+		Updates(map[string]any{
+			"gpx":        AnonymizedGPX,
+			"start_lat":  0,
+			"start_lon":  0,
+			"end_lat":    0,
+			"end_lon":    0,
+			"start_addr": "",
+			"end_addr":   "",
+		}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.TripSplit{}).
+		Where("trip_id IN (?)", tx.Model(&models.Trip{}).
+			Select("id").
+			Where("user_id = ?", id)).
+		Update("area_ranges", nil).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.TeamMembership{}).
+		Where("user_id = ? AND left_at IS NULL", id).
+		Update("left_at", now).Error; err != nil {
+		return err
+	}
+
+	for _, model := range []any{
+		&models.InstitutionMembership{},
+		&models.UserAchievement{},
+		&models.FCMToken{},
+		&models.DataExport{},
+		&models.EmailVerificationCode{},
+	} {
+		if err := tx.Where("user_id = ?", id).
+			Delete(model).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Where("follower_id = ? OR followee_id = ?", id, id).
+		Delete(&models.Follow{}).Error; err != nil {
+		return err
+	}
+
+	return tx.Where("email = ?", email).
+		Delete(&models.PasswordResetCode{}).Error
+}
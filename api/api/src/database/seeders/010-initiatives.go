@@ -24,6 +24,7 @@ func (initiative) Seed(db *gorm.DB) {
 			Description: "Quia nihil deleniti esse minus sit hic.",
 			Goal:        7000,
 			EndDate:     types.Date("2050-10-10"),
+			State:       models.InitiativeActive,
 			Enabled:     true,
 			Institution: institutions[0],
 			Sponsors:    random.PickFrom(institutions),
@@ -34,6 +35,7 @@ func (initiative) Seed(db *gorm.DB) {
 			Description: "Est distinctio odit quis ratione illum.",
 			Goal:        5000,
 			EndDate:     types.Date("2036-01-10"),
+			State:       models.InitiativeActive,
 			Enabled:     true,
 			Institution: institutions[1],
 			Sponsors:    random.PickFrom(institutions),
@@ -44,6 +46,7 @@ func (initiative) Seed(db *gorm.DB) {
 			Description: "Ex cumque iure sed aut assumenda.",
 			Goal:        5000,
 			EndDate:     types.Date("2036-01-10"),
+			State:       models.InitiativeDraft,
 			Enabled:     false,
 			Institution: institutions[2],
 			Sponsors:    random.PickFrom(institutions),
@@ -0,0 +1,399 @@
+// Package exports writes the lists and metrics of the platform as tables, in
+// CSV or XLSX, for the reporting done in spreadsheets.
+package exports
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/query"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/types"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/reports"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Kind of the records of an export.
+type Kind string
+
+const (
+	Users       Kind = "users"
+	Trips       Kind = "trips"
+	Initiatives Kind = "initiatives"
+	// Contributions are the credits contributed by each user to each
+	// initiative.
+	Contributions Kind = "contributions"
+	// Metrics is the time series of the platform metrics.
+	Metrics Kind = "metrics"
+)
+
+// Filters of an export. They're the filters of the respective lists, and
+// each kind of export ignores the filters of the others.
+type Filters struct {
+	// OrderBy is the sorting order of users, trips and initiatives, with the
+	// column names in snake case. Defaults to "id asc".
+	OrderBy string
+
+	// TimeFrom and TimeTo filter the trips uploaded in the period.
+	TimeFrom, TimeTo string
+
+	// Initiatives filters the initiatives.
+	Initiatives query.InitiativeFilters
+	// IncludeDisabled initiatives in the export.
+	IncludeDisabled bool
+
+	// InitiativeID filters the contributions to the initiative.
+	InitiativeID string
+
+	// Interval, DateFrom and DateTo define the time series of metrics.
+	Interval         query.MetricsInterval
+	DateFrom, DateTo types.Date
+}
+
+func (f Filters) orderBy() string {
+	if f.OrderBy == "" {
+		return "id asc"
+	}
+	return f.OrderBy
+}
+
+// exporter writes the records of a kind of export.
+type exporter interface {
+	header() (columns []string, numeric []int)
+	count(filters Filters, db *gorm.DB) (int64, error)
+	write(tw reports.TableWriter, filters Filters, db *gorm.DB) error
+}
+
+var exporters = map[Kind]exporter{
+	Users:         usersTable,
+	Trips:         tripsTable,
+	Initiatives:   initiativesTable,
+	Contributions: contributionsTable,
+	Metrics:       metricsTable{},
+}
+
+func find(kind Kind) (exporter, error) {
+	e, ok := exporters[kind]
+	if !ok {
+		return nil, fmt.Errorf("unsupported export kind: %s", kind)
+	}
+	return e, nil
+}
+
+// Count returns the number of rows of an export, besides the header.
+func Count(kind Kind, filters Filters, db *gorm.DB) (int64, error) {
+	e, err := find(kind)
+	if err != nil {
+		return 0, err
+	}
+	return e.count(filters, db)
+}
+
+// Write writes an export to w in the given format, CSV or XLSX. The records
+// are written as they're read from the database.
+func Write(
+	w io.Writer,
+	kind Kind,
+	format string,
+	filters Filters,
+	db *gorm.DB,
+) error {
+	e, err := find(kind)
+	if err != nil {
+		return err
+	}
+
+	columns, numeric := e.header()
+	tw, err := reports.NewTableWriter(w, format, columns, numeric)
+	if err != nil {
+		return err
+	}
+	if err := e.write(tw, filters, db); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// table is the export of the records of type T selected by a query.
+type table[T any] struct {
+	columns []string
+	numeric []int
+	// filter returns the query of the records, without the selected columns.
+	filter func(filters Filters, db *gorm.DB) *gorm.DB
+	// selects are the columns of the records.
+	selects string
+	row     func(record T) []string
+}
+
+func (t table[T]) header() ([]string, []int) {
+	return t.columns, t.numeric
+}
+
+func (t table[T]) count(filters Filters, db *gorm.DB) (count int64, err error) {
+	return count, t.filter(filters, db).Count(&count).Error
+}
+
+func (t table[T]) write(
+	tw reports.TableWriter,
+	filters Filters,
+	db *gorm.DB,
+) error {
+	q := t.filter(filters, db).Select(t.selects)
+	rows, err := q.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var record T
+		if err := q.ScanRows(rows, &record); err != nil {
+			return err
+		}
+		if err := tw.Write(t.row(record)); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+type userRecord struct {
+	ID        uuid.UUID
+	Name      string
+	Username  string
+	Email     string
+	Gender    string
+	Birthday  string
+	Verified  bool
+	TripCount int64
+	TotalDist float64
+	Credits   float64
+	CreatedAt time.Time
+}
+
+var usersTable = table[userRecord]{
+	columns: []string{"ID", "Name", "Username", "Email", "Gender", "Birthday",
+		"Verified", "Trips", "Distance (km)", "Credits", "Created at"},
+	numeric: []int{7, 8, 9},
+	filter: func(filters Filters, db *gorm.DB) *gorm.DB {
+		return db.Model(&models.User{}).
+			Where("anonymized_at IS NULL").
+			Order(filters.orderBy())
+	},
+	selects: `id, coalesce(name, '') AS name,
+		coalesce(username, '') AS username, email,
+		coalesce(gender, '') AS gender,
+		coalesce(to_char(birthday, 'YYYY-MM-DD'), '') AS birthday,
+		verified, trip_count, total_dist, credits, created_at`,
+	row: func(u userRecord) []string {
+		return []string{
+			u.ID.String(),
+			u.Name,
+			u.Username,
+			u.Email,
+			u.Gender,
+			u.Birthday,
+			strconv.FormatBool(u.Verified),
+			strconv.FormatInt(u.TripCount, 10),
+			formatFloat(u.TotalDist),
+			formatFloat(u.Credits),
+			formatTime(u.CreatedAt),
+		}
+	},
+}
+
+type tripRecord struct {
+	ID             uuid.UUID
+	UserID         uuid.UUID
+	CreatedAt      time.Time
+	Distance       float64
+	Duration       float64
+	Credits        float64
+	IsValid        bool
+	NotValidReason string
+	InitiativeID   string
+	StartAddr      string
+	EndAddr        string
+}
+
+var tripsTable = table[tripRecord]{
+	columns: []string{"ID", "User ID", "Created at", "Distance (km)",
+		"Duration (s)", "Credits", "Valid", "Reason if not valid",
+		"Initiative ID", "Start address", "End address"},
+	numeric: []int{3, 4, 5},
+	filter: func(filters Filters, db *gorm.DB) *gorm.DB {
+		tx := db.Model(&models.Trip{})
+		if filters.TimeFrom != "" {
+			tx = tx.Where("created_at >= ?", filters.TimeFrom)
+		}
+		if filters.TimeTo != "" {
+			tx = tx.Where("created_at <= ?", filters.TimeTo)
+		}
+		return tx.Order(filters.orderBy())
+	},
+	selects: `id, user_id, created_at, distance, duration, credits, is_valid,
+		coalesce(not_valid_reason, '') AS not_valid_reason,
+		coalesce(initiative_id::text, '') AS initiative_id,
+		coalesce(start_addr, '') AS start_addr,
+		coalesce(end_addr, '') AS end_addr`,
+	row: func(t tripRecord) []string {
+		return []string{
+			t.ID.String(),
+			t.UserID.String(),
+			formatTime(t.CreatedAt),
+			formatFloat(t.Distance),
+			formatFloat(t.Duration),
+			formatFloat(t.Credits),
+			strconv.FormatBool(t.IsValid),
+			t.NotValidReason,
+			t.InitiativeID,
+			t.StartAddr,
+			t.EndAddr,
+		}
+	},
+}
+
+type initiativeRecord struct {
+	ID          uuid.UUID
+	Title       string
+	Institution string
+	State       string
+	Enabled     bool
+	Goal        int64
+	Credits     float64
+	StartDate   string
+	EndDate     string
+}
+
+var initiativesTable = table[initiativeRecord]{
+	columns: []string{"ID", "Title", "Institution", "State", "Enabled",
+		"Goal", "Credits", "Start date", "End date"},
+	numeric: []int{5, 6},
+	filter: func(filters Filters, db *gorm.DB) *gorm.DB {
+		tx := query.Initiatives.Filter(
+			db.Model(&models.Initiative{}),
+			filters.Initiatives,
+		)
+		if !filters.IncludeDisabled {
+			tx = tx.Where("enabled = true")
+		}
+		return tx.Order(filters.orderBy())
+	},
+	selects: `id, title,
+		(SELECT name FROM institutions
+			WHERE institutions.id = initiatives.institution_id) AS institution,
+		state, enabled, goal, credits,
+		coalesce(to_char(start_date, 'YYYY-MM-DD'), '') AS start_date,
+		to_char(end_date, 'YYYY-MM-DD') AS end_date`,
+	row: func(i initiativeRecord) []string {
+		return []string{
+			i.ID.String(),
+			i.Title,
+			i.Institution,
+			i.State,
+			strconv.FormatBool(i.Enabled),
+			strconv.FormatInt(i.Goal, 10),
+			formatFloat(i.Credits),
+			i.StartDate,
+			i.EndDate,
+		}
+	},
+}
+
+type contributionRecord struct {
+	InitiativeID uuid.UUID
+	Title        string
+	UserID       uuid.UUID
+	Username     string
+	Credits      float64
+	TripCount    int64
+	UpdatedAt    time.Time
+}
+
+var contributionsTable = table[contributionRecord]{
+	columns: []string{"Initiative ID", "Initiative", "User ID", "Username",
+		"Credits", "Trips", "Last contribution"},
+	numeric: []int{4, 5},
+	filter: func(filters Filters, db *gorm.DB) *gorm.DB {
+		tx := db.Table("initiative_contributions AS c").
+			Joins("JOIN initiatives i ON i.id = c.initiative_id").
+			Joins("JOIN users u ON u.id = c.user_id")
+		if filters.InitiativeID != "" {
+			tx = tx.Where("c.initiative_id = ?", filters.InitiativeID)
+		}
+		return tx.Order("i.title, c.credits DESC, c.user_id")
+	},
+	selects: `c.initiative_id, i.title, c.user_id,
+		coalesce(u.username, '') AS username, c.credits, c.trip_count,
+		c.updated_at`,
+	row: func(c contributionRecord) []string {
+		return []string{
+			c.InitiativeID.String(),
+			c.Title,
+			c.UserID.String(),
+			c.Username,
+			formatFloat(c.Credits),
+			strconv.FormatInt(c.TripCount, 10),
+			formatTime(c.UpdatedAt),
+		}
+	},
+}
+
+// metricsTable is the export of the time series of the platform metrics,
+// which is small enough to be computed before being written.
+type metricsTable struct{}
+
+func (metricsTable) header() ([]string, []int) {
+	return []string{"Date", "New users", "Active riders", "Valid trips",
+		"Invalid trips", "Distance (km)", "Credits"}, []int{1, 2, 3, 4, 5, 6}
+}
+
+func (metricsTable) series(
+	filters Filters,
+	db *gorm.DB,
+) ([]query.MetricsPoint, error) {
+	return query.Metrics.
+		Series(filters.Interval, filters.DateFrom, filters.DateTo, db)
+}
+
+func (t metricsTable) count(filters Filters, db *gorm.DB) (int64, error) {
+	points, err := t.series(filters, db)
+	return int64(len(points)), err
+}
+
+func (t metricsTable) write(
+	tw reports.TableWriter,
+	filters Filters,
+	db *gorm.DB,
+) error {
+	points, err := t.series(filters, db)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range points {
+		if err := tw.Write([]string{
+			string(p.Date),
+			strconv.FormatInt(p.NewUsers, 10),
+			strconv.FormatInt(p.ActiveRiders, 10),
+			strconv.FormatInt(p.ValidTrips, 10),
+			strconv.FormatInt(p.InvalidTrips, 10),
+			formatFloat(p.Distance),
+			formatFloat(p.Credits),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339)
+}
@@ -2,6 +2,9 @@ package firebase
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"strconv"
 
 	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
 	firebase "firebase.google.com/go/v4"
@@ -58,3 +61,54 @@ func NewAchievementMessage(
 		},
 	}
 }
+
+// NewAchievementMilestoneMessage creates a message notifying the user of their
+// progress towards an achievement. The milestone is the achievement's
+// completion, from 0 to 1.
+func NewAchievementMilestoneMessage(
+	token string,
+	achievement models.Achievement,
+	milestone float64,
+	host string,
+) messaging.Message {
+	percent := int(math.Round(milestone * 100))
+	return messaging.Message{
+		Token: token,
+		Notification: &messaging.Notification{
+			Title:    achievement.Name,
+			Body:     fmt.Sprintf("You're %d%% of the way there!", percent),
+			ImageURL: host + achievement.ImageURI,
+		},
+		Data: map[string]string{
+			"type":      "achievement-milestone",
+			"code":      achievement.Code,
+			"milestone": strconv.FormatFloat(milestone, 'f', -1, 64),
+		},
+	}
+}
+
+// NewInitiativeEndedMessage creates a message notifying the user that their
+// current initiative has ended, and that they should select a new one.
+func NewInitiativeEndedMessage(
+	token string,
+	initiative models.Initiative,
+) messaging.Message {
+	body := "The initiative has expired. Choose a new initiative to support!"
+	if initiative.State == models.InitiativeGoalReached {
+		body = "The initiative has reached its goal. " +
+			"Choose a new initiative to support!"
+	}
+
+	return messaging.Message{
+		Token: token,
+		Notification: &messaging.Notification{
+			Title: initiative.Title,
+			Body:  body,
+		},
+		Data: map[string]string{
+			"type":  "initiative-ended",
+			"id":    initiative.ID.String(),
+			"state": string(initiative.State),
+		},
+	}
+}
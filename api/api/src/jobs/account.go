@@ -0,0 +1,110 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/gobutil"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/worker"
+	"github.com/dexidp/dex/storage"
+	"github.com/google/uuid"
+)
+
+// Account related job names.
+const (
+	// Delete the files and login sessions of a user whose account was
+	// deleted. The personal data in the database is anonymized when the
+	// account is deleted, before this job is scheduled.
+	// Args are of type `UserErasureArgs`.
+	UserErasure = "user-erasure"
+)
+
+type UserErasureArgs struct {
+	UserID uuid.UUID
+	// Email is the email of the user before the account was anonymized,
+	// used to find the user's login sessions.
+	Email string
+}
+
+type userFilesStorage interface {
+	DeleteUserFiles(ctx context.Context, userID string) error
+}
+
+// loginSessions is the subset of the Dex storage used to revoke the sessions of
+// a user.
+type loginSessions interface {
+	ListRefreshTokens() ([]storage.RefreshToken, error)
+	DeleteRefresh(id string) error
+	DeleteOfflineSessions(userID string, connID string) error
+}
+
+func userErasure(files userFilesStorage, sessions loginSessions) *worker.Job {
+	argsCodec := gobutil.NewGobCodec[UserErasureArgs]()
+
+	return &worker.Job{
+		Name: UserErasure,
+		Handler: func(ctx context.Context, raw []byte) error {
+			args, err := argsCodec.Decode(raw)
+			if err != nil {
+				return fmt.Errorf("failed to decode args: %v", err)
+			}
+
+			if err := files.
+				DeleteUserFiles(ctx, args.UserID.String()); err != nil {
+				return fmt.Errorf("failed to delete user files: %v", err)
+			}
+
+			revoked, err := revokeLoginSessions(args, sessions)
+			if err != nil {
+				return fmt.Errorf("failed to revoke login sessions: %v", err)
+			}
+
+			log.Printf("%s: erased user %s, revoked %d refresh tokens",
+				UserErasure, args.UserID, revoked)
+			return nil
+		},
+		Retries:  4,
+		Delay:    time.Minute,
+		MaxDelay: time.Hour,
+	}
+}
+
+// revokeLoginSessions deletes the refresh tokens and offline sessions of the
+// user, so the tokens already issued can't be refreshed.
+// Returns the number of deleted refresh tokens.
+func revokeLoginSessions(
+	args UserErasureArgs,
+	sessions loginSessions,
+) (int, error) {
+	tokens, err := sessions.ListRefreshTokens()
+	if err != nil {
+		return 0, err
+	}
+
+	// Users that log in with a password are identified by their ID, and users
+	// of other connectors by their email.
+	userID := args.UserID.String()
+	revoked := 0
+	for _, token := range tokens {
+		if token.Claims.UserID != userID &&
+			(args.Email == "" || token.Claims.Email != args.Email) {
+			continue
+		}
+
+		err := sessions.DeleteRefresh(token.ID)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return revoked, err
+		}
+		if err := sessions.DeleteOfflineSessions(
+			token.Claims.UserID, token.ConnectorID,
+		); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return revoked, err
+		}
+		revoked++
+	}
+
+	return revoked, nil
+}
@@ -0,0 +1,90 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/gobutil"
+	"github.com/dexidp/dex/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockUserFilesStorage struct {
+	mock.Mock
+}
+
+func (m *mockUserFilesStorage) DeleteUserFiles(
+	_ context.Context,
+	userID string,
+) error {
+	return m.Called(userID).Error(0)
+}
+
+type mockLoginSessions struct {
+	mock.Mock
+	tokens []storage.RefreshToken
+}
+
+func (m *mockLoginSessions) ListRefreshTokens() ([]storage.RefreshToken, error) {
+	return m.tokens, nil
+}
+
+func (m *mockLoginSessions) DeleteRefresh(id string) error {
+	return m.Called(id).Error(0)
+}
+
+func (m *mockLoginSessions) DeleteOfflineSessions(userID, connID string) error {
+	return m.Called(userID, connID).Error(0)
+}
+
+func TestUserErasure(t *testing.T) {
+	userID := uuid.New()
+	email := "someone@test.org"
+	args, err := gobutil.NewGobCodec[UserErasureArgs]().
+		Encode(UserErasureArgs{UserID: userID, Email: email})
+	require.NoError(t, err)
+
+	files := &mockUserFilesStorage{}
+	sessions := &mockLoginSessions{tokens: []storage.RefreshToken{
+		{ID: "1", ConnectorID: "local", Claims: storage.Claims{
+			UserID: userID.String(), Email: email,
+		}},
+		{ID: "2", ConnectorID: "google", Claims: storage.Claims{
+			UserID: "google-sub", Email: email,
+		}},
+		{ID: "3", ConnectorID: "local", Claims: storage.Claims{
+			UserID: uuid.NewString(), Email: "other@test.org",
+		}},
+	}}
+	job := userErasure(files, sessions)
+
+	// -------------------------------------------- //
+	// Fails without revoking the sessions on error //
+	// -------------------------------------------- //
+	files.On("DeleteUserFiles", userID.String()).
+		Return(errors.New("unavailable")).Once()
+
+	assert.Error(t, job.Handler(context.Background(), args))
+	sessions.AssertNotCalled(t, "DeleteRefresh", mock.Anything)
+
+	// ------------------------------------------------- //
+	// Deletes the files and revokes the user's sessions //
+	// ------------------------------------------------- //
+	files.On("DeleteUserFiles", userID.String()).Return(nil).Once()
+	sessions.On("DeleteRefresh", "1").Return(nil).Once()
+	sessions.On("DeleteOfflineSessions", userID.String(), "local").
+		Return(nil).Once()
+	sessions.On("DeleteRefresh", "2").Return(nil).Once()
+	sessions.On("DeleteOfflineSessions", "google-sub", "google").
+		Return(storage.ErrNotFound).Once()
+
+	assert.NoError(t, job.Handler(context.Background(), args))
+
+	files.AssertExpectations(t)
+	sessions.AssertExpectations(t)
+	sessions.AssertNotCalled(t, "DeleteRefresh", "3")
+}
@@ -38,10 +38,23 @@ func updateAchievements(
 	return &worker.Job{
 		Name: UpdateAchievements,
 		Handler: func(ctx context.Context, raw []byte) error {
+			notify := func(msg messaging.Message) error {
+				raw, err := msgCodec.Encode(msg)
+				if err != nil {
+					return fmt.Errorf("failed to encode args: %v", err)
+				}
+
+				wrkr.Schedule(&worker.TaskConfig{
+					JobName: FcmNotify,
+					Args:    raw,
+				})
+				return nil
+			}
+
 			if args, err := argsCodec.Decode(raw); err != nil {
 				return fmt.Errorf("failed to decode args: %v", err)
 
-			} else if newAchs, err := achs.
+			} else if newAchs, milestones, err := achs.
 				Update(args.UserID, args.State); err != nil {
 				return fmt.Errorf("failed to update user achievements: %v", err)
 
@@ -50,19 +63,21 @@ func updateAchievements(
 				return fmt.Errorf("failed to retrieve user fcm tokens: %v", err)
 
 			} else {
-				for _, ach := range newAchs {
-					for _, token := range tokens {
-						raw, err := msgCodec.Encode(firebase.NewAchievementMessage(
+				for _, token := range tokens {
+					for _, ach := range newAchs {
+						if err := notify(firebase.NewAchievementMessage(
 							token, ach.Achievement, host,
-						))
-						if err != nil {
-							return fmt.Errorf("failed to encode args: %v", err)
+						)); err != nil {
+							return err
 						}
+					}
 
-						wrkr.Schedule(&worker.TaskConfig{
-							JobName: FcmNotify,
-							Args:    raw,
-						})
+					for _, ach := range milestones {
+						if err := notify(firebase.NewAchievementMilestoneMessage(
+							token, ach.Achievement, ach.Milestone, host,
+						)); err != nil {
+							return err
+						}
 					}
 				}
 			}
@@ -0,0 +1,241 @@
+package jobs
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/gobutil"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/worker"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Data export job names.
+const (
+	// Bundle the data of a user in a zip archive, store it in the S3 bucket,
+	// and send the user a link to download it.
+	// Args are of type `DataExportArgs`.
+	UserDataExport = "user-data-export"
+)
+
+// How long the link to download an export is valid for. It's the maximum
+// expiration of pre-signed S3 urls.
+const dataExportLinkLifetime = 7 * 24 * time.Hour
+
+type DataExportArgs struct {
+	ExportID uuid.UUID
+}
+
+type dataExportStorage interface {
+	PutDataExport(ctx context.Context, userID, exportID string, data []byte) error
+	PresignGetDataExport(userID, exportID string, expires time.Duration) (string, error)
+}
+
+type dataExportMailer interface {
+	SendDataExportEmail(email, link string, expiresAt time.Time) error
+}
+
+func userDataExport(
+	storage dataExportStorage,
+	mailer dataExportMailer,
+	db *gorm.DB,
+) *worker.Job {
+	argsCodec := gobutil.NewGobCodec[DataExportArgs]()
+
+	setStatus := func(
+		export *models.DataExport,
+		status models.DataExportStatus,
+	) error {
+		export.Status = status
+		return db.Model(export).
+			Select("status", "expires_at").
+			Updates(export).Error
+	}
+
+	export := func(ctx context.Context, export *models.DataExport) error {
+		data, err := bundleUserData(export.UserID, db)
+		if err != nil {
+			return fmt.Errorf("failed to bundle user data: %v", err)
+		}
+
+		userID, exportID := export.UserID.String(), export.ID.String()
+		if err := storage.
+			PutDataExport(ctx, userID, exportID, data); err != nil {
+			return fmt.Errorf("failed to store the archive: %v", err)
+		}
+
+		expiresAt := time.Now().Add(dataExportLinkLifetime)
+		link, err := storage.
+			PresignGetDataExport(userID, exportID, dataExportLinkLifetime)
+		if err != nil {
+			return fmt.Errorf("failed to presign the archive: %v", err)
+		}
+
+		if err := mailer.
+			SendDataExportEmail(export.User.Email, link, expiresAt); err != nil {
+			return fmt.Errorf("failed to send email: %v", err)
+		}
+
+		export.ExpiresAt = &expiresAt
+		return setStatus(export, models.DataExportReady)
+	}
+
+	return &worker.Job{
+		Name: UserDataExport,
+		Handler: func(ctx context.Context, raw []byte) error {
+			args, err := argsCodec.Decode(raw)
+			if err != nil {
+				return fmt.Errorf("failed to decode args: %v", err)
+			}
+
+			var dataExport models.DataExport
+			if err := db.Preload("User").
+				First(&dataExport, "id = ?", args.ExportID).Error; err != nil {
+				return fmt.Errorf("failed to retrieve export: %v", err)
+			}
+			if dataExport.Status == models.DataExportReady {
+				return nil
+			}
+
+			// The export is marked as failed until a retry succeeds.
+			if err := export(ctx, &dataExport); err != nil {
+				if err := setStatus(
+					&dataExport, models.DataExportFailed,
+				); err != nil {
+					log.Printf("%s: failed to update export status: %v",
+						UserDataExport, err)
+				}
+				return err
+			}
+
+			log.Printf("%s: exported the data of user %s",
+				UserDataExport, dataExport.UserID)
+			return nil
+		},
+		Retries:  4,
+		Delay:    time.Minute,
+		MaxDelay: time.Hour,
+	}
+}
+
+// fcmTokenMetadata is the metadata of a FCM token included in a data export.
+// The token itself is omitted.
+type fcmTokenMetadata struct {
+	CreatedAt    time.Time `json:"createdAt"`
+	LastActiveAt time.Time `json:"lastActiveAt"`
+}
+
+// bundleUserData creates a zip archive with the data of the user with the
+// given ID:
+//
+//   - profile.json: the user's profile;
+//   - trips/<id>.gpx and trips/<id>.json: the GPX file and metadata of each
+//     trip;
+//   - achievements.json: the user's progress in the achievements;
+//   - devices.json: the metadata of the FCM tokens of the user's devices;
+//   - credits.json: the user's entries in the credit ledger;
+//   - allocations.json: the history of the user's allocations of credits.
+func bundleUserData(userID uuid.UUID, db *gorm.DB) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	var user models.User
+	if err := db.Preload("Initiative").Preload("Memberships").
+		First(&user, "id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	if err := writeJSON(zw, "profile.json", user); err != nil {
+		return nil, err
+	}
+
+	var trips []models.Trip
+	if err := db.Preload("Splits").
+		Where("user_id = ?", userID).
+		Order("created_at").
+		Find(&trips).Error; err != nil {
+		return nil, err
+	}
+	for _, trip := range trips {
+		name := "trips/" + trip.ID.String()
+		if err := writeFile(zw, name+".gpx", trip.GPX); err != nil {
+			return nil, err
+		}
+		if err := writeJSON(zw, name+".json", trip); err != nil {
+			return nil, err
+		}
+	}
+
+	var achievements []models.UserAchievement
+	if err := db.Preload("Achievement").
+		Where("user_id = ?", userID).
+		Order("achievement_code").
+		Find(&achievements).Error; err != nil {
+		return nil, err
+	}
+	if err := writeJSON(zw, "achievements.json", achievements); err != nil {
+		return nil, err
+	}
+
+	var tokens []models.FCMToken
+	if err := db.Where("user_id = ?", userID).
+		Order("created_at").
+		Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	devices := make([]fcmTokenMetadata, len(tokens))
+	for i, token := range tokens {
+		devices[i] = fcmTokenMetadata{token.CreatedAt, token.LastActiveAt}
+	}
+	if err := writeJSON(zw, "devices.json", devices); err != nil {
+		return nil, err
+	}
+
+	var credits []models.CreditTransaction
+	if err := db.Where("user_id = ?", userID).
+		Order("created_at").
+		Find(&credits).Error; err != nil {
+		return nil, err
+	}
+	if err := writeJSON(zw, "credits.json", credits); err != nil {
+		return nil, err
+	}
+
+	var allocations []models.CreditAllocation
+	if err := db.Preload("Shares").
+		Where("user_id = ?", userID).
+		Order("created_at").
+		Find(&allocations).Error; err != nil {
+		return nil, err
+	}
+	if err := writeJSON(zw, "allocations.json", allocations); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeFile(zw *zip.Writer, name string, data []byte) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func writeJSON(zw *zip.Writer, name string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(zw, name, data)
+}
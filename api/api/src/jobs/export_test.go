@@ -0,0 +1,177 @@
+package jobs
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/config"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/gobutil"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/random"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type mockDataExportStorage struct {
+	mock.Mock
+	data []byte
+}
+
+func (m *mockDataExportStorage) PutDataExport(
+	_ context.Context,
+	userID, exportID string,
+	data []byte,
+) error {
+	m.data = data
+	return m.Called(userID, exportID).Error(0)
+}
+
+func (m *mockDataExportStorage) PresignGetDataExport(
+	userID, exportID string,
+	expires time.Duration,
+) (string, error) {
+	args := m.Called(userID, exportID, expires)
+	return args.String(0), args.Error(1)
+}
+
+type mockDataExportMailer struct {
+	mock.Mock
+}
+
+func (m *mockDataExportMailer) SendDataExportEmail(
+	email, link string,
+	_ time.Time,
+) error {
+	return m.Called(email, link).Error(0)
+}
+
+type DataExportJobsTestSuite struct {
+	suite.Suite
+	db *gorm.DB
+	tx *gorm.DB
+}
+
+// Run each test in a transaction.
+func (s *DataExportJobsTestSuite) SetupTest() {
+	s.tx = s.db.Begin()
+}
+
+// Rollback the transaction after each test.
+func (s *DataExportJobsTestSuite) TearDownTest() {
+	s.tx.Rollback()
+}
+
+func (s *DataExportJobsTestSuite) TestUserDataExport() {
+	user := models.User{
+		Email:   random.String(10),
+		Subject: random.String(10),
+	}
+	s.Require().NoError(s.tx.Create(&user).Error)
+
+	trip := models.Trip{
+		GPX:     []byte("<gpx></gpx>"),
+		GPXHash: []byte(random.String(20)),
+		UserID:  user.ID,
+	}
+	s.Require().NoError(s.tx.Create(&trip).Error)
+	s.Require().NoError(s.tx.Create(&models.FCMToken{
+		Token:  random.String(30),
+		UserID: user.ID,
+	}).Error)
+
+	export := models.DataExport{UserID: user.ID}
+	s.Require().NoError(s.tx.Create(&export).Error)
+
+	args, err := gobutil.NewGobCodec[DataExportArgs]().
+		Encode(DataExportArgs{ExportID: export.ID})
+	s.Require().NoError(err)
+
+	storage := &mockDataExportStorage{}
+	mailer := &mockDataExportMailer{}
+	job := userDataExport(storage, mailer, s.tx)
+
+	// -------------------------------------- //
+	// Marks the export as failed if it fails //
+	// -------------------------------------- //
+	storage.On("PutDataExport", user.ID.String(), export.ID.String()).
+		Return(errors.New("unavailable")).Once()
+
+	s.Error(job.Handler(context.Background(), args))
+	s.Require().NoError(s.tx.First(&export, "id = ?", export.ID).Error)
+	s.Equal(models.DataExportFailed, export.Status)
+
+	// ------------------------------------------------- //
+	// Stores the archive and sends the link to the user //
+	// ------------------------------------------------- //
+	storage.On("PutDataExport", user.ID.String(), export.ID.String()).
+		Return(nil).Once()
+	storage.On("PresignGetDataExport",
+		user.ID.String(), export.ID.String(), dataExportLinkLifetime,
+	).Return("https://download", nil).Once()
+	mailer.On("SendDataExportEmail", user.Email, "https://download").
+		Return(nil).Once()
+
+	s.NoError(job.Handler(context.Background(), args))
+	s.Require().NoError(s.tx.First(&export, "id = ?", export.ID).Error)
+	s.Equal(models.DataExportReady, export.Status)
+	s.Require().NotNil(export.ExpiresAt)
+	s.WithinDuration(time.Now().Add(dataExportLinkLifetime),
+		*export.ExpiresAt, time.Minute)
+
+	storage.AssertExpectations(s.T())
+	mailer.AssertExpectations(s.T())
+
+	zr, err := zip.NewReader(
+		bytes.NewReader(storage.data), int64(len(storage.data)))
+	s.Require().NoError(err)
+
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		s.Require().NoError(err)
+		files[f.Name], err = io.ReadAll(r)
+		s.Require().NoError(err)
+	}
+
+	s.Equal(trip.GPX, files["trips/"+trip.ID.String()+".gpx"])
+
+	var profile models.User
+	s.Require().NoError(json.Unmarshal(files["profile.json"], &profile))
+	s.Equal(user.ID, profile.ID)
+	s.Equal(user.Email, profile.Email)
+
+	var devices []map[string]any
+	s.Require().NoError(json.Unmarshal(files["devices.json"], &devices))
+	s.Require().Len(devices, 1)
+	s.NotContains(devices[0], "token")
+
+	for _, name := range []string{
+		"trips/" + trip.ID.String() + ".json",
+		"achievements.json",
+		"credits.json",
+		"allocations.json",
+	} {
+		s.Contains(files, name)
+	}
+}
+
+func TestDataExportJobs(t *testing.T) {
+	config, err := config.Load("../../.env")
+	require.NoError(t, err)
+
+	db, err := database.Init(config.DbDsn())
+	require.NoError(t, err)
+	db.Logger = logger.Default.LogMode(logger.Silent)
+
+	suite.Run(t, &DataExportJobsTestSuite{db: db})
+}
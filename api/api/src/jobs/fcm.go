@@ -12,6 +12,8 @@ import (
 	"bitbucket.org/pensarmais/cycleforlisbon/src/util/gobutil"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/worker"
 	"firebase.google.com/go/v4/messaging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"gorm.io/gorm"
 )
 
@@ -38,6 +40,15 @@ const (
 // Time between token cleanup tasks.
 const tokenCleanupPeriod = 24 * time.Hour
 
+// fcmMessages counts the messages sent to FCM, by outcome: success or
+// failure. A multicast message counts once per token.
+var fcmMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "cycleforlisbon",
+	Subsystem: "fcm",
+	Name:      "messages_total",
+	Help:      "Number of messages sent to FCM, by outcome: success or failure.",
+}, []string{"outcome"})
+
 type fcmSender interface {
 	Send(context.Context, *messaging.Message) (string, error)
 }
@@ -72,12 +83,14 @@ func fcmNotify(fcm fcmSender, db *gorm.DB) *worker.Job {
 
 			res, err := fcm.Send(ctx, &msg)
 			if err != nil {
+				fcmMessages.WithLabelValues("failure").Inc()
 				if len(msg.Token) > 0 {
 					incrementFailureCount(msg.Token, db)
 				}
 				return fmt.Errorf("error sending message: %v", err)
 			}
 
+			fcmMessages.WithLabelValues("success").Inc()
 			if len(msg.Token) > 0 {
 				resetFailureCount(msg.Token, db)
 			}
@@ -104,8 +117,12 @@ func fcmMulticast(fcm fcmMulticaster, db *gorm.DB) *worker.Job {
 
 			br, err := fcm.SendMulticast(ctx, &msg)
 			if err != nil {
+				fcmMessages.WithLabelValues("failure").
+					Add(float64(len(msg.Tokens)))
 				return fmt.Errorf("error sending message: %v", err)
 			}
+			fcmMessages.WithLabelValues("success").Add(float64(br.SuccessCount))
+			fcmMessages.WithLabelValues("failure").Add(float64(br.FailureCount))
 
 			for i, res := range br.Responses {
 				token := msg.Tokens[i]
@@ -14,6 +14,7 @@ import (
 	"bitbucket.org/pensarmais/cycleforlisbon/src/util/random"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/worker"
 	"firebase.google.com/go/v4/messaging"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
@@ -279,9 +280,16 @@ func (s *FCMJobsTestSuite) TestMulticastPartialSuccess() {
 			{Success: true},
 		},
 	}, nil)
+	successes := testutil.ToFloat64(fcmMessages.WithLabelValues("success"))
+	failures := testutil.ToFloat64(fcmMessages.WithLabelValues("failure"))
 	err = job.Handler(context.Background(), encodedMsg)
 	s.NoError(err)
 
+	s.Equal(successes+2,
+		testutil.ToFloat64(fcmMessages.WithLabelValues("success")))
+	s.Equal(failures+1,
+		testutil.ToFloat64(fcmMessages.WithLabelValues("failure")))
+
 	var dbToken models.FCMToken
 	result := s.db.Model(&models.FCMToken{}).
 		First(&dbToken, "token = ?", tokens[0])
@@ -0,0 +1,184 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/query"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/firebase"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/gobutil"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/worker"
+	"firebase.google.com/go/v4/messaging"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Initiative related job names.
+const (
+	// Move initiatives to the state they should be in, according to their
+	// start and end dates.
+	UpdateInitiatives = "initiatives-update"
+
+	// Notify the users supporting an initiative that it has ended, unset
+	// their current initiative and drop it from their allocations.
+	// Args are of type `InitiativeEndedArgs`.
+	InitiativeEnded = "initiative-ended"
+
+	// Record the daily progress of the initiatives.
+	InitiativesSnapshot = "initiatives-snapshot"
+)
+
+// Time between initiative state updates.
+const updateInitiativesPeriod = time.Hour
+
+// Time between initiative snapshots.
+const initiativesSnapshotPeriod = 24 * time.Hour
+
+type InitiativeEndedArgs struct {
+	InitiativeID uuid.UUID
+}
+
+func updateInitiatives(wrkr *worker.Worker, db *gorm.DB) *worker.Job {
+	reschedule := func() {
+		if err := wrkr.Schedule(&worker.TaskConfig{
+			JobName:     UpdateInitiatives,
+			ScheduledTo: time.Now().Add(updateInitiativesPeriod),
+		}); err != nil {
+			log.Printf("failed to reschedule initiatives update: %v", err)
+		}
+	}
+
+	argsCodec := gobutil.NewGobCodec[InitiativeEndedArgs]()
+
+	return &worker.Job{
+		Name: UpdateInitiatives,
+		Handler: func(ctx context.Context, _ []byte) error {
+			var ended []models.Initiative
+			if err := db.Transaction(func(tx *gorm.DB) (err error) {
+				ended, err = query.Initiatives.UpdateStates(time.Now(), tx)
+				return err
+			}); err != nil {
+				return fmt.Errorf("failed to update initiative states: %v", err)
+			}
+
+			for _, initiative := range ended {
+				raw, err := argsCodec.Encode(InitiativeEndedArgs{
+					InitiativeID: initiative.ID,
+				})
+				if err != nil {
+					return fmt.Errorf("failed to encode args: %v", err)
+				}
+
+				if err := wrkr.Schedule(&worker.TaskConfig{
+					JobName: InitiativeEnded,
+					Args:    raw,
+				}); err != nil {
+					return fmt.Errorf("failed to schedule %s: %v",
+						InitiativeEnded, err)
+				}
+			}
+
+			return nil
+		},
+		OnSuccess: reschedule,
+		OnFailure: reschedule,
+	}
+}
+
+func initiativeEnded(wrkr *worker.Worker, db *gorm.DB) *worker.Job {
+	argsCodec := gobutil.NewGobCodec[InitiativeEndedArgs]()
+	msgCodec := gobutil.NewGobCodec[messaging.Message]()
+
+	return &worker.Job{
+		Name: InitiativeEnded,
+		Handler: func(ctx context.Context, raw []byte) error {
+			args, err := argsCodec.Decode(raw)
+			if err != nil {
+				return fmt.Errorf("failed to decode args: %v", err)
+			}
+
+			var initiative models.Initiative
+			if err := db.First(&initiative, "id = ?", args.InitiativeID).
+				Error; err != nil {
+				return fmt.Errorf("failed to retrieve initiative: %v", err)
+			}
+
+			// The job may have been scheduled by a transaction that was
+			// rolled back.
+			if initiative.State != models.InitiativeGoalReached &&
+				initiative.State != models.InitiativeExpired {
+				log.Printf("%s: initiative %s %s, skipping",
+					InitiativeEnded, initiative.ID, initiative.State)
+				return nil
+			}
+
+			tokens, err := query.FCMTokens.
+				OfInitiative(initiative.ID.String(), db)
+			if err != nil {
+				return fmt.Errorf("failed to retrieve fcm tokens: %v", err)
+			}
+
+			var cleared int64
+			if err := db.Transaction(func(tx *gorm.DB) error {
+				rebalanced, err := query.Allocations.
+					DropInitiative(initiative.ID, tx)
+				if err != nil {
+					return err
+				}
+				cleared, err = query.Users.
+					ClearInitiative(initiative.ID.String(), tx)
+				cleared += rebalanced
+				return err
+			}); err != nil {
+				return fmt.Errorf("failed to unset users' initiative: %v", err)
+			}
+
+			for _, token := range tokens {
+				raw, err := msgCodec.Encode(
+					firebase.NewInitiativeEndedMessage(token, initiative),
+				)
+				if err != nil {
+					return fmt.Errorf("failed to encode args: %v", err)
+				}
+
+				wrkr.Schedule(&worker.TaskConfig{
+					JobName: FcmNotify,
+					Args:    raw,
+				})
+			}
+
+			log.Printf("%s: initiative %s %s, notified %d users",
+				InitiativeEnded, initiative.ID, initiative.State, cleared)
+			return nil
+		},
+	}
+}
+
+func initiativesSnapshot(wrkr *worker.Worker, db *gorm.DB) *worker.Job {
+	reschedule := func() {
+		if err := wrkr.Schedule(&worker.TaskConfig{
+			JobName:     InitiativesSnapshot,
+			ScheduledTo: time.Now().Add(initiativesSnapshotPeriod),
+		}); err != nil {
+			log.Printf("failed to reschedule initiatives snapshot: %v", err)
+		}
+	}
+
+	return &worker.Job{
+		Name: InitiativesSnapshot,
+		Handler: func(ctx context.Context, _ []byte) error {
+			taken, err := query.Initiatives.Snapshot(db)
+			if err != nil {
+				return fmt.Errorf("failed to snapshot initiatives: %v", err)
+			}
+
+			log.Printf("%s: recorded %d snapshots", InitiativesSnapshot, taken)
+			return nil
+		},
+		OnSuccess: reschedule,
+		OnFailure: reschedule,
+	}
+}
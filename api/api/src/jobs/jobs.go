@@ -4,8 +4,10 @@ package jobs
 
 import (
 	"bitbucket.org/pensarmais/cycleforlisbon/src/achievements"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/aws"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/firebase"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/worker"
+	"github.com/dexidp/dex/storage"
 	"gorm.io/gorm"
 )
 
@@ -13,6 +15,8 @@ import (
 func All(
 	wrkr *worker.Worker,
 	fbase *firebase.Client,
+	awsClient *aws.Client,
+	dexStore storage.Storage,
 	db *gorm.DB,
 	achs *achievements.Service,
 	host string,
@@ -24,6 +28,16 @@ func All(
 		fcmMulticast(fbase.Fcm, db),
 		fcmCleanup(wrkr, fbase.Fcm, db),
 		passwordResetCodeCleanup(wrkr, db),
+		verificationCodeCleanup(wrkr, db),
 		updateAchievements(achs, fbase.Fcm, wrkr, db, host),
+		updateInitiatives(wrkr, db),
+		initiativeEnded(wrkr, db),
+		initiativesSnapshot(wrkr, db),
+		leaderboardSnapshot(wrkr, db),
+		metricsRollup(wrkr, db),
+		userDataExport(awsClient.S3, awsClient.SES, db),
+		reportExport(awsClient.S3, db),
+		assignParishes(db),
+		userErasure(awsClient.S3, dexStore),
 	}
 }
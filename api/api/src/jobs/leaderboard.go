@@ -0,0 +1,67 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/query"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/worker"
+	"gorm.io/gorm"
+)
+
+// Leaderboard related job names.
+const (
+	// Take snapshots of the global leaderboards, from which they're served.
+	LeaderboardSnapshot = "leaderboard-snapshot"
+)
+
+// Time between leaderboard snapshots.
+const leaderboardSnapshotPeriod = 15 * time.Minute
+
+func leaderboardSnapshot(wrkr *worker.Worker, db *gorm.DB) *worker.Job {
+	reschedule := func() {
+		if err := wrkr.Schedule(&worker.TaskConfig{
+			JobName:     LeaderboardSnapshot,
+			ScheduledTo: time.Now().Add(leaderboardSnapshotPeriod),
+		}); err != nil {
+			log.Printf("failed to reschedule leaderboard snapshot: %v", err)
+		}
+	}
+
+	return &worker.Job{
+		Name: LeaderboardSnapshot,
+		Handler: func(ctx context.Context, _ []byte) error {
+			now := time.Now()
+
+			for _, period := range query.LeaderboardPeriods {
+				for _, metric := range query.LeaderboardMetrics {
+					window := query.LeaderboardWindow{
+						Period: period,
+						Since:  period.Start(now),
+						Metric: metric,
+					}
+
+					var ranked int64
+					if err := db.Transaction(func(tx *gorm.DB) (err error) {
+						ranked, err = query.Leaderboard.Snapshot(window, now, tx)
+						return err
+					}); err != nil {
+						return fmt.Errorf(
+							"failed to snapshot the %s leaderboard by %s: %v",
+							period, metric, err,
+						)
+					}
+
+					log.Printf("%s: ranked %d users in the %s leaderboard by %s",
+						LeaderboardSnapshot, ranked, period, metric)
+				}
+			}
+
+			return nil
+		},
+		OnSuccess: reschedule,
+		OnFailure: reschedule,
+	}
+}
@@ -0,0 +1,62 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/query"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/types"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/worker"
+	"gorm.io/gorm"
+)
+
+// Metrics related job names.
+const (
+	// Roll up the daily metrics of the platform, from which the time series
+	// of metrics are served.
+	MetricsRollup = "metrics-rollup"
+)
+
+// Time between rollups of the daily metrics.
+const metricsRollupPeriod = time.Hour
+
+func metricsRollup(wrkr *worker.Worker, db *gorm.DB) *worker.Job {
+	reschedule := func() {
+		if err := wrkr.Schedule(&worker.TaskConfig{
+			JobName:     MetricsRollup,
+			ScheduledTo: time.Now().Add(metricsRollupPeriod),
+		}); err != nil {
+			log.Printf("failed to reschedule metrics rollup: %v", err)
+		}
+	}
+
+	return &worker.Job{
+		Name: MetricsRollup,
+		Handler: func(ctx context.Context, _ []byte) error {
+			today := types.Date(time.Now().Format(types.DateFormat))
+
+			var days int64
+			if err := db.Transaction(func(tx *gorm.DB) error {
+				from, err := query.Metrics.RollupStart(tx)
+				if err != nil {
+					return err
+				}
+				if from == "" || from.Time().After(today.Time()) {
+					from = today
+				}
+
+				days, err = query.Metrics.Rollup(from, today, tx)
+				return err
+			}); err != nil {
+				return fmt.Errorf("failed to roll up daily metrics: %v", err)
+			}
+
+			log.Printf("%s: rolled up %d days", MetricsRollup, days)
+			return nil
+		},
+		OnSuccess: reschedule,
+		OnFailure: reschedule,
+	}
+}
@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/parishes"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/worker"
+	"gorm.io/gorm"
+)
+
+// Parish related job names.
+const (
+	// Assign the start and end of the trips uploaded before the boundaries of
+	// the parishes were imported, or changed, to the parishes.
+	AssignParishes = "assign-parishes"
+)
+
+func assignParishes(db *gorm.DB) *worker.Job {
+	return &worker.Job{
+		Name: AssignParishes,
+		Handler: func(ctx context.Context, _ []byte) error {
+			idx, err := parishes.Load(db)
+			if err != nil {
+				return fmt.Errorf("failed to load parishes: %v", err)
+			}
+
+			updated, err := parishes.AssignTrips(idx, db)
+			if err != nil {
+				return fmt.Errorf("failed to assign trips to parishes: %v", err)
+			}
+
+			log.Printf("%s: updated the parishes of %d trips",
+				AssignParishes, updated)
+			return nil
+		},
+		Retries:  3,
+		Delay:    time.Minute,
+		MaxDelay: time.Hour,
+	}
+}
@@ -0,0 +1,136 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/exports"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/reports"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/gobutil"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/worker"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Report export job names.
+const (
+	// Write the spreadsheet of an export of a list or the metrics of the
+	// platform, and store it in the S3 bucket.
+	// Args are of type `ReportExportArgs`.
+	ReportExport = "report-export"
+)
+
+// How long the spreadsheet of a report export is available for download.
+const reportExportLifetime = 7 * 24 * time.Hour
+
+type ReportExportArgs struct {
+	ExportID uuid.UUID
+	Filters  exports.Filters
+}
+
+type reportExportStorage interface {
+	PutReportExport(
+		ctx context.Context,
+		exportID, format, contentType string,
+		body io.Reader,
+	) error
+}
+
+func reportExport(storage reportExportStorage, db *gorm.DB) *worker.Job {
+	argsCodec := gobutil.NewGobCodec[ReportExportArgs]()
+
+	setStatus := func(
+		export *models.ReportExport,
+		status models.ReportExportStatus,
+	) error {
+		export.Status = status
+		return db.Model(export).
+			Select("status", "rows", "expires_at").
+			Updates(export).Error
+	}
+
+	// The spreadsheet is written to a temporary file, so that it doesn't have
+	// to be kept in memory.
+	export := func(
+		ctx context.Context,
+		export *models.ReportExport,
+		filters exports.Filters,
+	) error {
+		f, err := os.CreateTemp("", "report-export-*")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary file: %v", err)
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+
+		kind := exports.Kind(export.Kind)
+		if err := db.Transaction(func(tx *gorm.DB) (err error) {
+			export.Rows, err = exports.Count(kind, filters, tx)
+			if err != nil {
+				return err
+			}
+			return exports.Write(f, kind, export.Format, filters, tx)
+		}); err != nil {
+			return fmt.Errorf("failed to write the spreadsheet: %v", err)
+		}
+
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if err := storage.PutReportExport(
+			ctx,
+			export.ID.String(),
+			export.Format,
+			reports.ContentType(export.Format),
+			f,
+		); err != nil {
+			return fmt.Errorf("failed to store the spreadsheet: %v", err)
+		}
+
+		expiresAt := time.Now().Add(reportExportLifetime)
+		export.ExpiresAt = &expiresAt
+		return setStatus(export, models.ReportExportReady)
+	}
+
+	return &worker.Job{
+		Name: ReportExport,
+		Handler: func(ctx context.Context, raw []byte) error {
+			args, err := argsCodec.Decode(raw)
+			if err != nil {
+				return fmt.Errorf("failed to decode args: %v", err)
+			}
+
+			var reportExport models.ReportExport
+			if err := db.First(&reportExport, "id = ?", args.ExportID).
+				Error; err != nil {
+				return fmt.Errorf("failed to retrieve export: %v", err)
+			}
+			if reportExport.Status == models.ReportExportReady {
+				return nil
+			}
+
+			// The export is marked as failed until a retry succeeds.
+			if err := export(ctx, &reportExport, args.Filters); err != nil {
+				if err := setStatus(
+					&reportExport, models.ReportExportFailed,
+				); err != nil {
+					log.Printf("%s: failed to update export status: %v",
+						ReportExport, err)
+				}
+				return err
+			}
+
+			log.Printf("%s: exported %d %s", ReportExport,
+				reportExport.Rows, reportExport.Kind)
+			return nil
+		},
+		Retries:  4,
+		Delay:    time.Minute,
+		MaxDelay: time.Hour,
+	}
+}
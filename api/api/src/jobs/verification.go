@@ -0,0 +1,59 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/query"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/worker"
+	"gorm.io/gorm"
+)
+
+// Email verification related job names.
+const (
+	// Delete stale email verification codes from the database.
+	VerificationCodeCleanup = "verif-code-cleanup"
+)
+
+const (
+	// How long to keep email verification codes in the database for, after
+	// they expire.
+	verificationCodeRetention = 7 * 24 * time.Hour
+)
+
+// Time between cleanup tasks.
+const verificationCodeCleanupPeriod = 24 * time.Hour
+
+func verificationCodeCleanup(wrkr *worker.Worker, db *gorm.DB) *worker.Job {
+	reschedule := func() {
+		if err := wrkr.Schedule(&worker.TaskConfig{
+			JobName:     VerificationCodeCleanup,
+			ScheduledTo: time.Now().Add(verificationCodeCleanupPeriod),
+		}); err != nil {
+			log.Printf("failed to reschedule verification code cleanup: %v",
+				err)
+		}
+	}
+
+	return &worker.Job{
+		Name: VerificationCodeCleanup,
+		Handler: func(ctx context.Context, _ []byte) error {
+			deleted, err := query.EmailVerificationCodes.
+				DeleteOlderThan(verificationCodeRetention, db)
+
+			if err != nil {
+				return fmt.Errorf(
+					"failed to delete stale verification codes: %v", err,
+				)
+			}
+
+			log.Printf("%s: deleted %d verification codes",
+				VerificationCodeCleanup, deleted)
+			return nil
+		},
+		OnSuccess: reschedule,
+		OnFailure: reschedule,
+	}
+}
@@ -21,6 +21,8 @@ import (
 	"bitbucket.org/pensarmais/cycleforlisbon/src/worker"
 	"github.com/getsentry/sentry-go"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 )
 
 var (
@@ -98,7 +100,12 @@ func main() {
 	}
 	defer dexStore.Close()
 
-	achs, err := achievements.New(db, query.Achievements)
+	milestones, err := conf.AchievementMilestones()
+	if err != nil {
+		log.Fatalf("invalid achievement milestones: %v", err)
+	}
+
+	achs, err := achievements.New(db, query.Achievements, milestones...)
 	if err != nil {
 		log.Fatalf("failed to initialize achievements: %v", err)
 	}
@@ -106,13 +113,22 @@ func main() {
 	wrkr := worker.New(worker.NewDbQueue(db))
 
 	if err = wrkr.Register(
-		jobs.All(wrkr, fbase, db, achs, conf.ServerBaseURL())...,
+		jobs.All(wrkr, fbase, awsClient, dexStore, db, achs, conf.ServerBaseURL())...,
 	); err != nil {
 		log.Fatalf("error registering job: %v", err)
 	}
 
 	scheduleRecurringTasks(wrkr)
 
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatalf("error retrieving the database connection pool: %v", err)
+	}
+	prometheus.MustRegister(
+		collectors.NewDBStatsCollector(sqlDB, conf.DB_NAME),
+		worker.NewDbQueueCollector(db),
+	)
+
 	srv, err := server.New(&server.Config{
 		ApiHost:       conf.API_HOST,
 		ServerBaseURL: conf.ServerBaseURL(),
@@ -123,6 +139,7 @@ func main() {
 		Worker:        wrkr,
 		AWS:           awsClient,
 		Geocoder:      latlon.NewGeocoder(conf.GOOGLE_API_KEY),
+		MetricsToken:  conf.METRICS_TOKEN,
 	})
 	if err != nil {
 		log.Fatalf("error creating server: %v", err)
@@ -175,6 +192,13 @@ func scheduleRecurringTasks(wrkr *worker.Worker) {
 		log.Printf("failed to schedule password reset code cleanup: %v", err)
 	}
 
+	if err := wrkr.Schedule(&worker.TaskConfig{
+		JobName:     jobs.VerificationCodeCleanup,
+		ScheduledTo: time.Now().Add(13 * time.Second),
+	}); err != nil {
+		log.Printf("failed to schedule verification code cleanup: %v", err)
+	}
+
 	if err := wrkr.Schedule(&worker.TaskConfig{
 		JobName:     jobs.FetchEvents,
 		ScheduledTo: time.Now().Add(15 * time.Second),
@@ -188,6 +212,34 @@ func scheduleRecurringTasks(wrkr *worker.Worker) {
 	}); err != nil {
 		log.Printf("failed to schedule news fetching: %v", err)
 	}
+
+	if err := wrkr.Schedule(&worker.TaskConfig{
+		JobName:     jobs.UpdateInitiatives,
+		ScheduledTo: time.Now().Add(25 * time.Second),
+	}); err != nil {
+		log.Printf("failed to schedule initiatives update: %v", err)
+	}
+
+	if err := wrkr.Schedule(&worker.TaskConfig{
+		JobName:     jobs.InitiativesSnapshot,
+		ScheduledTo: time.Now().Add(30 * time.Second),
+	}); err != nil {
+		log.Printf("failed to schedule initiatives snapshot: %v", err)
+	}
+
+	if err := wrkr.Schedule(&worker.TaskConfig{
+		JobName:     jobs.LeaderboardSnapshot,
+		ScheduledTo: time.Now().Add(35 * time.Second),
+	}); err != nil {
+		log.Printf("failed to schedule leaderboard snapshot: %v", err)
+	}
+
+	if err := wrkr.Schedule(&worker.TaskConfig{
+		JobName:     jobs.MetricsRollup,
+		ScheduledTo: time.Now().Add(40 * time.Second),
+	}); err != nil {
+		log.Printf("failed to schedule metrics rollup: %v", err)
+	}
 }
 
 // handlePanic recovers form panics, reports them to Sentry and sends an
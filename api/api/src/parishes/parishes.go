@@ -0,0 +1,179 @@
+// Package parishes assigns the start and end of trips to the parishes of the
+// city that contain them, testing the coordinates against the boundaries of
+// the parishes in memory.
+package parishes
+
+import (
+	"sync"
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/latlon"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// cacheTTL is how long the boundaries are kept in memory before being read
+// again, so that each instance of the API eventually sees the boundaries
+// imported through any other.
+const cacheTTL = 10 * time.Minute
+
+// Index finds the parish that contains a point among a set of parishes.
+type Index struct {
+	parishes []parish
+}
+
+type parish struct {
+	id       uuid.UUID
+	boundary latlon.Boundary
+	// sw and ne are the corners of the bounding box of the boundary, which
+	// rule out most of the parishes before the polygons are tested.
+	sw, ne latlon.Coords
+}
+
+// NewIndex returns an index of the given parishes.
+func NewIndex(parishes []models.Parish) Index {
+	idx := Index{parishes: make([]parish, 0, len(parishes))}
+	for _, p := range parishes {
+		sw, ne := p.Boundary.Bounds()
+		idx.parishes = append(idx.parishes, parish{p.ID, p.Boundary, sw, ne})
+	}
+	return idx
+}
+
+// Locate returns the ID of the parish that contains the given coordinates,
+// or nil if none does. If the boundaries of the parishes overlap, the first
+// one to contain the coordinates is returned.
+func (idx Index) Locate(c latlon.Coords) *uuid.UUID {
+	for _, p := range idx.parishes {
+		if c.Lat < p.sw.Lat || c.Lat > p.ne.Lat ||
+			c.Lon < p.sw.Lon || c.Lon > p.ne.Lon {
+			continue
+		}
+		if p.boundary.Contains(c) {
+			id := p.id
+			return &id
+		}
+	}
+	return nil
+}
+
+// Load returns an index of the parishes stored in the database.
+func Load(db *gorm.DB) (Index, error) {
+	var parishes []models.Parish
+	if err := db.Order("name").Find(&parishes).Error; err != nil {
+		return Index{}, err
+	}
+	return NewIndex(parishes), nil
+}
+
+// Locator locates coordinates in the parishes stored in the database, which
+// are cached in memory.
+type Locator struct {
+	db *gorm.DB
+
+	mu       sync.Mutex
+	index    Index
+	loadedAt time.Time
+}
+
+// NewLocator returns a locator of the parishes stored in the database. The
+// parishes are read on the first use.
+func NewLocator(db *gorm.DB) *Locator {
+	return &Locator{db: db}
+}
+
+// Locate returns the ID of the parish that contains the given coordinates,
+// or nil if none does.
+func (l *Locator) Locate(c latlon.Coords) (*uuid.UUID, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.loadedAt.IsZero() || time.Since(l.loadedAt) > cacheTTL {
+		index, err := Load(l.db)
+		if err != nil {
+			return nil, err
+		}
+		l.index = index
+		l.loadedAt = time.Now()
+	}
+
+	return l.index.Locate(c), nil
+}
+
+// Reset discards the cached parishes, so that they're read again on the next
+// use.
+func (l *Locator) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.loadedAt = time.Time{}
+}
+
+// assignBatchSize is the number of trips updated in each transaction by
+// AssignTrips.
+const assignBatchSize = 500
+
+// AssignTrips assigns the start and end of every valid trip to the parishes
+// in the index, returning the number of trips updated. The trips outside the
+// parishes are unassigned.
+//
+// The trips of deleted users are skipped: their coordinates are erased, so
+// they keep the parishes they were assigned to before.
+func AssignTrips(idx Index, db *gorm.DB) (int64, error) {
+	var updated int64
+	var lastID uuid.UUID
+	for {
+		var trips []models.Trip
+		if err := db.
+			Select("id", "start_lat", "start_lon", "end_lat", "end_lon",
+				"start_parish_id", "end_parish_id").
+			Where("is_valid = true AND id > ?", lastID).
+			Where("user_id NOT IN (?)", db.Model(&models.User{}).
+				Select("id").
+				Where("anonymized_at IS NOT NULL")).
+			Order("id").
+			Limit(assignBatchSize).
+			Find(&trips).Error; err != nil {
+			return updated, err
+		}
+		if len(trips) == 0 {
+			return updated, nil
+		}
+		lastID = trips[len(trips)-1].ID
+
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			for _, trip := range trips {
+				start := idx.Locate(latlon.Coords{
+					Lat: trip.StartLat, Lon: trip.StartLon,
+				})
+				end := idx.Locate(latlon.Coords{
+					Lat: trip.EndLat, Lon: trip.EndLon,
+				})
+				if equal(start, trip.StartParishID) &&
+					equal(end, trip.EndParishID) {
+					continue
+				}
+
+				if err := tx.Model(&models.Trip{}).
+					Where("id = ?", trip.ID).
+					Updates(map[string]any{
+						"start_parish_id": start,
+						"end_parish_id":   end,
+					}).Error; err != nil {
+					return err
+				}
+				updated++
+			}
+			return nil
+		}); err != nil {
+			return updated, err
+		}
+	}
+}
+
+func equal(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
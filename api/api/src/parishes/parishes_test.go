@@ -0,0 +1,49 @@
+package parishes
+
+import (
+	"testing"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/latlon"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexLocate(t *testing.T) {
+	west := models.Parish{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		Name:      "West",
+		Boundary: latlon.Boundary{{{
+			{Lat: 38.70, Lon: -9.20}, {Lat: 38.70, Lon: -9.15},
+			{Lat: 38.75, Lon: -9.15}, {Lat: 38.75, Lon: -9.20},
+		}}},
+	}
+	east := models.Parish{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		Name:      "East",
+		Boundary: latlon.Boundary{{{
+			{Lat: 38.70, Lon: -9.15}, {Lat: 38.70, Lon: -9.10},
+			{Lat: 38.75, Lon: -9.12},
+		}}},
+	}
+	idx := NewIndex([]models.Parish{west, east})
+
+	assert.Equal(t, &west.ID, idx.Locate(latlon.Coords{Lat: 38.72, Lon: -9.18}))
+	assert.Equal(t, &east.ID, idx.Locate(latlon.Coords{Lat: 38.71, Lon: -9.12}))
+	// Inside the bounding box of East, but outside its boundary.
+	assert.Nil(t, idx.Locate(latlon.Coords{Lat: 38.745, Lon: -9.105}))
+	assert.Nil(t, idx.Locate(latlon.Coords{Lat: 38.80, Lon: -9.18}))
+
+	assert.Nil(t, NewIndex(nil).Locate(latlon.Coords{Lat: 38.72, Lon: -9.18}))
+}
+
+func TestEqual(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	a2 := a
+
+	assert.True(t, equal(nil, nil))
+	assert.True(t, equal(&a, &a2))
+	assert.False(t, equal(&a, &b))
+	assert.False(t, equal(&a, nil))
+	assert.False(t, equal(nil, &b))
+}
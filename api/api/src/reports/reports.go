@@ -0,0 +1,110 @@
+// Package reports renders tabular statements, such as the sponsor payout
+// reports, in CSV, XLSX and printable HTML.
+package reports
+
+import (
+	"html/template"
+	"io"
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/resources"
+)
+
+// Supported report formats.
+const (
+	CSV  = "csv"
+	HTML = "html"
+	XLSX = "xlsx"
+)
+
+// ContentType returns the MIME type of the given format.
+func ContentType(format string) string {
+	switch format {
+	case HTML:
+		return "text/html; charset=utf-8"
+	case XLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		return "text/csv; charset=utf-8"
+	}
+}
+
+var statementTemplate = template.Must(
+	template.New("statement").Parse(resources.StatementTemplate),
+)
+
+// Statement is a table of values, with a header and a row of totals.
+type Statement struct {
+	Title    string
+	Subtitle string
+	From, To string
+
+	Columns []string
+	Rows    [][]string
+	// Totals is the last row of the table. May be empty.
+	Totals []string
+	// NumericColumns are the indexes of the columns with numeric values.
+	NumericColumns []int
+
+	GeneratedAt time.Time
+}
+
+// IsNumeric returns true if the column with index i has numeric values.
+func (s Statement) IsNumeric(i int) bool {
+	for _, col := range s.NumericColumns {
+		if col == i {
+			return true
+		}
+	}
+	return false
+}
+
+// Write the statement to w in the given format.
+func (s Statement) Write(w io.Writer, format string) error {
+	switch format {
+	case HTML:
+		return s.WriteHTML(w)
+	case XLSX:
+		return s.WriteXLSX(w)
+	default:
+		return s.WriteCSV(w)
+	}
+}
+
+// WriteCSV writes the statement as CSV, with the columns in the first line and
+// the totals in the last.
+func (s Statement) WriteCSV(w io.Writer) error {
+	return s.writeTable(w, CSV)
+}
+
+// WriteXLSX writes the statement as a workbook with a single worksheet, with
+// the columns in the first row and the totals in the last. The title and
+// period of the statement are omitted.
+func (s Statement) WriteXLSX(w io.Writer) error {
+	return s.writeTable(w, XLSX)
+}
+
+// writeTable writes the statement as a table in the given format, with the
+// columns in the first row and the totals in the last.
+func (s Statement) writeTable(w io.Writer, format string) error {
+	tw, err := NewTableWriter(w, format, s.Columns, s.NumericColumns)
+	if err != nil {
+		return err
+	}
+	for _, row := range s.Rows {
+		if err := tw.Write(row); err != nil {
+			return err
+		}
+	}
+	if len(s.Totals) > 0 {
+		if err := tw.Write(s.Totals); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// WriteHTML writes the statement as a printable HTML page.
+func (s Statement) WriteHTML(w io.Writer) error {
+	return statementTemplate.Execute(w, s)
+}
@@ -0,0 +1,115 @@
+package reports
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var statement = Statement{
+	Title:          "Initiative <1>",
+	Subtitle:       "Payout statement",
+	From:           "2023-01-01",
+	To:             "2023-01-31",
+	Columns:        []string{"Date", "Credits", "Euros"},
+	Rows:           [][]string{{"2023-01-02", "10", "10.00"}, {"2023-01-03", "5", "5.00"}},
+	Totals:         []string{"Total", "15", "15.00"},
+	NumericColumns: []int{1, 2},
+	GeneratedAt:    time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC),
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, statement.Write(&buf, CSV))
+	assert.Equal(t, ""+
+		"Date,Credits,Euros\n"+
+		"2023-01-02,10,10.00\n"+
+		"2023-01-03,5,5.00\n"+
+		"Total,15,15.00\n",
+		buf.String(),
+	)
+}
+
+func TestWriteHTML(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, statement.Write(&buf, HTML))
+
+	html := buf.String()
+	assert.Contains(t, html, "<title>Initiative &lt;1&gt;</title>")
+	assert.Contains(t, html, `<td class="number">15.00</td>`)
+	assert.Contains(t, html, "<td>2023-01-03</td>")
+	assert.Equal(t, 3, strings.Count(html, "</th>"))
+}
+
+func TestWriteXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, statement.Write(&buf, XLSX))
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	var sheet []byte
+	for _, f := range zr.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			r, err := f.Open()
+			require.NoError(t, err)
+			sheet, err = io.ReadAll(r)
+			require.NoError(t, err)
+		}
+	}
+	require.NotNil(t, sheet, "missing worksheet")
+
+	xml := string(sheet)
+	assert.Contains(t, xml, `<row r="1"><c t="inlineStr" s="1"><is><t>Date</t></is></c>`)
+	assert.Contains(t, xml, `<row r="2"><c t="inlineStr"><is><t>2023-01-02</t></is></c><c><v>10</v></c><c><v>10.00</v></c></row>`)
+	assert.Contains(t, xml, `<row r="4"><c t="inlineStr"><is><t>Total</t></is></c>`)
+	assert.Equal(t, 4, strings.Count(xml, "</row>"))
+}
+
+func TestTableWriter(t *testing.T) {
+	var buf bytes.Buffer
+	tw, err := NewTableWriter(&buf, XLSX, []string{"Name", "Value"}, []int{1})
+	require.NoError(t, err)
+	// Values that aren't numbers are written as strings, and escaped.
+	require.NoError(t, tw.Write([]string{"<a & b>", "NaN"}))
+	require.NoError(t, tw.Write([]string{"", "-1.5e3"}))
+	require.NoError(t, tw.Close())
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	r, err := zr.File[len(zr.File)-1].Open()
+	require.NoError(t, err)
+	sheet, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(sheet), `<c t="inlineStr"><is><t>&lt;a &amp; b&gt;</t></is></c>`+
+		`<c t="inlineStr"><is><t>NaN</t></is></c>`)
+	assert.Contains(t, string(sheet), `<c/><c><v>-1.5e3</v></c>`)
+
+	_, err = NewTableWriter(&buf, HTML, nil, nil)
+	assert.Error(t, err)
+}
+
+func TestCSVTableWriterEscapesFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	tw, err := NewTableWriter(&buf, CSV, []string{"Name", "Value"}, []int{1})
+	require.NoError(t, err)
+	require.NoError(t, tw.Write([]string{"=HYPERLINK(\"x\")", "-1.5"}))
+	require.NoError(t, tw.Write([]string{"@SUM(A1)", "+1"}))
+	require.NoError(t, tw.Write([]string{"\tname", "-a"}))
+	require.NoError(t, tw.Close())
+
+	assert.Equal(t, ""+
+		"Name,Value\n"+
+		"\"'=HYPERLINK(\"\"x\"\")\",-1.5\n"+
+		"'@SUM(A1),'+1\n"+
+		"'\tname,'-a\n",
+		buf.String(),
+	)
+}
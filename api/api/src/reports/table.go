@@ -0,0 +1,208 @@
+package reports
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// TableWriter writes the rows of a table one at a time, so that large tables
+// don't have to be kept in memory.
+type TableWriter interface {
+	// Write a row of the table.
+	Write(row []string) error
+	// Close flushes the table. Must be called after the last row.
+	Close() error
+}
+
+// NewTableWriter writes the columns of a table to w in the given format, CSV
+// or XLSX, and returns the writer of its rows. In XLSX, the values of the
+// numeric columns are written as numbers.
+func NewTableWriter(
+	w io.Writer,
+	format string,
+	columns []string,
+	numeric []int,
+) (TableWriter, error) {
+	switch format {
+	case CSV:
+		tw := &csvTableWriter{csv.NewWriter(w)}
+		return tw, tw.Write(columns)
+	case XLSX:
+		return newXLSXTableWriter(w, columns, numeric)
+	default:
+		return nil, fmt.Errorf("unsupported table format: %s", format)
+	}
+}
+
+type csvTableWriter struct {
+	w *csv.Writer
+}
+
+func (t *csvTableWriter) Write(row []string) error {
+	escaped := make([]string, len(row))
+	for i, value := range row {
+		escaped[i] = escapeFormula(value)
+	}
+	return t.w.Write(escaped)
+}
+
+// escapeFormula prefixes the values that spreadsheet applications would
+// evaluate as formulas with a quote, so that they're shown as text. Numbers
+// are kept as they are.
+func escapeFormula(value string) string {
+	if value == "" || numberRegex.MatchString(value) {
+		return value
+	}
+	switch value[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + value
+	}
+	return value
+}
+
+func (t *csvTableWriter) Close() error {
+	t.w.Flush()
+	return t.w.Error()
+}
+
+// The parts of an XLSX workbook with a single worksheet, besides the
+// worksheet itself.
+var xlsxParts = []struct{ name, content string }{
+	{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets>` +
+		`</workbook>`},
+	{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+		`</Relationships>`},
+	// The second cell format, used in the header, is bold.
+	{"xl/styles.xml", xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<fonts count="2"><font/><font><b/></font></fonts>` +
+		`<fills count="1"><fill><patternFill patternType="none"/></fill></fills>` +
+		`<borders count="1"><border/></borders>` +
+		`<cellStyleXfs count="1"><xf/></cellStyleXfs>` +
+		`<cellXfs count="2"><xf/><xf fontId="1" applyFont="1"/></cellXfs>` +
+		`</styleSheet>`},
+}
+
+// xlsxTableWriter writes a table as the single worksheet of an XLSX workbook.
+// The worksheet is the last part of the archive, so its rows can be written
+// as they come.
+type xlsxTableWriter struct {
+	zw      *zip.Writer
+	sheet   io.Writer
+	numeric []int
+	rows    int
+}
+
+func newXLSXTableWriter(
+	w io.Writer,
+	columns []string,
+	numeric []int,
+) (*xlsxTableWriter, error) {
+	zw := zip.NewWriter(w)
+	for _, part := range xlsxParts {
+		pw, err := zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(pw, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, xml.Header+
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`+
+		`<sheetData>`); err != nil {
+		return nil, err
+	}
+
+	t := &xlsxTableWriter{zw: zw, sheet: sheet, numeric: numeric}
+	return t, t.writeRow(columns, true)
+}
+
+func (t *xlsxTableWriter) isNumeric(i int) bool {
+	for _, col := range t.numeric {
+		if col == i {
+			return true
+		}
+	}
+	return false
+}
+
+// writeRow writes a row of inline strings, or numbers in the numeric columns
+// of rows other than the header. Empty values are written as empty cells.
+func (t *xlsxTableWriter) writeRow(row []string, header bool) error {
+	t.rows++
+	if _, err := fmt.Fprintf(t.sheet, `<row r="%d">`, t.rows); err != nil {
+		return err
+	}
+
+	for i, value := range row {
+		if err := t.writeCell(i, value, header); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(t.sheet, `</row>`)
+	return err
+}
+
+// writeCell writes the value of the column with index i of a row.
+func (t *xlsxTableWriter) writeCell(i int, value string, header bool) error {
+	if value == "" {
+		_, err := io.WriteString(t.sheet, `<c/>`)
+		return err
+	}
+
+	if !header && t.isNumeric(i) && numberRegex.MatchString(value) {
+		_, err := fmt.Fprintf(t.sheet, `<c><v>%s</v></c>`, value)
+		return err
+	}
+
+	start := `<c t="inlineStr"><is><t>`
+	if header {
+		start = `<c t="inlineStr" s="1"><is><t>`
+	}
+	if _, err := io.WriteString(t.sheet, start); err != nil {
+		return err
+	}
+	if err := xml.EscapeText(t.sheet, []byte(value)); err != nil {
+		return err
+	}
+	_, err := io.WriteString(t.sheet, `</t></is></c>`)
+	return err
+}
+
+func (t *xlsxTableWriter) Write(row []string) error {
+	return t.writeRow(row, false)
+}
+
+func (t *xlsxTableWriter) Close() error {
+	if _, err := io.WriteString(t.sheet,
+		`</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	return t.zw.Close()
+}
+
+// numberRegex matches the decimal numbers that can be the value of a cell.
+var numberRegex = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"errors"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/query"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/jobs"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/gobutil"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/httputil"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/worker"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AccountController handles the deletion of user accounts. The access rules
+// are defined by the UserController.
+type AccountController struct {
+	db    *gorm.DB
+	acl   authorizer
+	tasks scheduler
+	codec *gobutil.GobCodec[jobs.UserErasureArgs]
+}
+
+// Deletes a user.
+//
+//	@Summary		Delete a user by Id
+//	@Description	The user's personal data is erased: the profile is cleared, the GPX files
+//	@Description	and addresses of the trips are deleted, and so are the profile picture,
+//	@Description	devices, achievements and data exports. The user can no longer log in.
+//	@Description
+//	@Description	The aggregates of the trips and the credits are kept, so the platform's
+//	@Description	totals and the initiatives' history don't change.
+//	@Description
+//	@Description	The last owner of an institution that has other members can't be deleted.
+//	@Tags			users
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id	path	string	true	"User Id"	Format(UUID)
+//	@Success		204
+//	@Failure		400,401,403,404,500	{object}	middleware.ApiError
+//	@Router			/users/{id} [delete]
+func (c *AccountController) Delete(id string, ctx *gin.Context) error {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return err
+	}
+
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return httputil.NewError(httputil.BadRequest, err)
+	}
+
+	if ok := c.acl.Authorize(
+		user, "delete", models.User{
+			BaseModel: models.BaseModel{ID: userID},
+		},
+	); !ok {
+		return httputil.NewErrorMsg(
+			httputil.Forbidden,
+			httputil.ForbiddenMessage,
+		)
+	}
+
+	var args jobs.UserErasureArgs
+	if err := c.db.Transaction(func(tx *gorm.DB) error {
+		var deleted models.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&deleted, "id = ?", userID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) || deleted.Anonymized() {
+			return resourceNotFoundErr("user")
+		}
+		if err != nil {
+			return err
+		}
+
+		args = jobs.UserErasureArgs{UserID: deleted.ID, Email: deleted.Email}
+		err = query.Users.Anonymize(&deleted, tx)
+		if errors.Is(err, query.ErrSoleInstitutionOwner) {
+			return httputil.NewError(httputil.BadRequest, err)
+		}
+		return err
+	}); err != nil {
+		return err
+	}
+
+	raw, err := c.codec.Encode(args)
+	if err != nil {
+		return err
+	}
+
+	return c.tasks.Schedule(&worker.TaskConfig{
+		JobName: jobs.UserErasure,
+		Args:    raw,
+	})
+}
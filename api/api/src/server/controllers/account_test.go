@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"testing"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/query"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/jobs"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/server/access"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/gobutil"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/random"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/worker"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+type AccountControllerTestSuite struct {
+	suite.Suite
+	accounts *AccountController
+	users    *UserController
+	db       *gorm.DB
+	acl      *access.ACL
+	wrkr     *MockWorker
+}
+
+// Run each test in a transaction.
+func (s *AccountControllerTestSuite) SetupTest() {
+	tx := testDb.Begin()
+	s.db = tx
+	s.wrkr = &MockWorker{}
+	s.accounts = &AccountController{
+		tx, s.acl, s.wrkr, gobutil.NewGobCodec[jobs.UserErasureArgs](),
+	}
+	s.users = &UserController{tx, s.acl, "", nil}
+}
+
+// Rollback the transaction after each test.
+func (s *AccountControllerTestSuite) TearDownTest() {
+	s.wrkr.AssertExpectations(s.T())
+	s.db.Rollback()
+}
+
+func (s *AccountControllerTestSuite) expectErasure(user models.User) {
+	codec := gobutil.NewGobCodec[jobs.UserErasureArgs]()
+	s.wrkr.On("Schedule", mock.MatchedBy(func(t *worker.TaskConfig) bool {
+		args, err := codec.Decode(t.Args)
+		return err == nil &&
+			t.JobName == jobs.UserErasure &&
+			args.UserID == user.ID &&
+			args.Email == user.Email
+	})).Return(nil).Once()
+}
+
+func (s *AccountControllerTestSuite) TestDeleteUser() {
+	user, ctx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+	other, ctx2, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+
+	trip := models.Trip{
+		GPX:       []byte(`<gpx version="1.1"><trk></trk></gpx>`),
+		GPXHash:   []byte(random.String(32)),
+		StartLat:  38.7,
+		StartLon:  -9.1,
+		StartAddr: "Rua Augusta",
+		IsValid:   true,
+		Distance:  10,
+		Credits:   5,
+		UserID:    user.ID,
+	}
+	s.Require().NoError(s.db.Create(&trip).Error)
+	s.Require().NoError(s.db.Create(&models.FCMToken{
+		Token:  random.String(50),
+		UserID: user.ID,
+	}).Error)
+	s.Require().NoError(s.db.Model(&user).Updates(models.User{
+		TripCount: 1,
+		TotalDist: 10,
+		Credits:   5,
+	}).Error)
+
+	// ------------------------------------- //
+	// Fails for the account of another user //
+	// ------------------------------------- //
+	err = s.accounts.Delete(other.ID.String(), ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Forbidden Action, "+
+		"message: the user does not have access to this operation"+
+		"}")
+
+	// ----------------------------------------- //
+	// Anonymizes the user and schedules erasure //
+	// ----------------------------------------- //
+	s.expectErasure(user)
+	err = s.accounts.Delete(user.ID.String(), ctx)
+	s.Require().NoError(err)
+
+	// Getting a user after deleting should return an error
+	result, err := s.users.Get(user.ID.String(), ctx2)
+	s.EqualError(err, "ApiError{"+
+		"code: Record Not Found, "+
+		"message: user not found"+
+		"}")
+	s.Empty(result)
+
+	var deleted models.User
+	s.Require().NoError(s.db.First(&deleted, "id = ?", user.ID).Error)
+	s.NotNil(deleted.AnonymizedAt)
+	s.Empty(deleted.Name)
+	s.Empty(deleted.HashedPassword)
+	s.NotEqual(user.Email, deleted.Email)
+	s.NotEqual(user.Subject, deleted.Subject)
+	s.Equal(uint(1), deleted.TripCount)
+	s.Equal(10.0, deleted.TotalDist)
+	s.Equal(5.0, deleted.Credits)
+
+	// The trip aggregates are kept, but not the track.
+	var stripped models.Trip
+	s.Require().NoError(s.db.First(&stripped, "id = ?", trip.ID).Error)
+	s.Equal(query.AnonymizedGPX, string(stripped.GPX))
+	s.Empty(stripped.StartAddr)
+	s.Zero(stripped.StartLat)
+	s.Equal(10.0, stripped.Distance)
+	s.Equal(5.0, stripped.Credits)
+
+	var tokens int64
+	s.Require().NoError(s.db.Model(&models.FCMToken{}).
+		Where("user_id = ?", user.ID).Count(&tokens).Error)
+	s.Zero(tokens)
+
+	// ----------------------------------------- //
+	// Fails again, the token user doesn't exist //
+	// ----------------------------------------- //
+	err = s.accounts.Delete(user.ID.String(), ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Token User Not Found, "+
+		"message: the token is valid, but the subject doesn't exist"+
+		"}")
+
+	// --------------------------------------- //
+	// Admin fails for already deleted account //
+	// --------------------------------------- //
+	_, adminCtx, err := createRandomAdmin(s.users)
+	s.Require().NoError(err)
+
+	err = s.accounts.Delete(user.ID.String(), adminCtx)
+	s.EqualError(err, "ApiError{"+
+		"code: Record Not Found, "+
+		"message: user not found"+
+		"}")
+
+	// --------------------------------- //
+	// Admin deletes the other's account //
+	// --------------------------------- //
+	s.expectErasure(other)
+	err = s.accounts.Delete(other.ID.String(), adminCtx)
+	s.NoError(err)
+}
+
+func (s *AccountControllerTestSuite) TestDeleteSoleOwner() {
+	owner, ctx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+	member, _, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+
+	institution := models.Institution{Name: random.AlphanumericString(20)}
+	s.Require().NoError(s.db.Create(&institution).Error)
+	s.Require().NoError(s.db.Create(&[]models.InstitutionMembership{
+		{
+			InstitutionID: institution.ID,
+			UserID:        owner.ID,
+			Role:          models.InstitutionOwner,
+		},
+		{
+			InstitutionID: institution.ID,
+			UserID:        member.ID,
+			Role:          models.InstitutionManager,
+		},
+	}).Error)
+
+	// ------------------------------------------------------- //
+	// Fails for the last owner of an institution with members //
+	// ------------------------------------------------------- //
+	err = s.accounts.Delete(owner.ID.String(), ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Bad Request, "+
+		"message: the last owner of an institution that has other members "+
+		"can't be deleted"+
+		"}")
+
+	var dbOwner models.User
+	s.Require().NoError(s.db.First(&dbOwner, "id = ?", owner.ID).Error)
+	s.Nil(dbOwner.AnonymizedAt)
+
+	// ----------------------------------- //
+	// Succeeds once there's another owner //
+	// ----------------------------------- //
+	s.Require().NoError(s.db.Model(&models.InstitutionMembership{}).
+		Where("institution_id = ? AND user_id = ?", institution.ID, member.ID).
+		Update("role", models.InstitutionOwner).Error)
+
+	s.expectErasure(owner)
+	err = s.accounts.Delete(owner.ID.String(), ctx)
+	s.NoError(err)
+}
+
+func TestAccountController(t *testing.T) {
+	acl := access.New()
+	registerAllRules(&UserController{}, acl)
+	suite.Run(t, &AccountControllerTestSuite{acl: acl})
+}
@@ -8,6 +8,7 @@ import (
 	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/database/query"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/jobs"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/parishes"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/server/access"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/server/middleware"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/util/gobutil"
@@ -33,19 +34,27 @@ var customValidations = map[string]validator.Func{
 }
 
 type Store struct {
-	Users           *UserController
-	Password        *PasswordController
-	Initiatives     *InitiativeController
-	SDGs            *SDGController
-	Institutions    *InstitutionController
-	Trips           *TripController
-	Achievements    *AchievementController
-	POIs            *POIController
-	Leaderboard     *LeaderboardController
-	ExternalContent *ExternalContentController
-	FCMTokens       *FCMTokenController
-	Languages       *LanguageController
-	Metrics         *MetricsController
+	Users             *UserController
+	Password          *PasswordController
+	EmailVerification *EmailVerificationController
+	Initiatives       *InitiativeController
+	SDGs              *SDGController
+	Institutions      *InstitutionController
+	Trips             *TripController
+	Achievements      *AchievementController
+	POIs              *POIController
+	Parishes          *ParishController
+	Leaderboard       *LeaderboardController
+	ExternalContent   *ExternalContentController
+	FCMTokens         *FCMTokenController
+	Languages         *LanguageController
+	Metrics           *MetricsController
+	Reports           *ReportController
+	DataExports       *DataExportController
+	Exports           *ExportController
+	Accounts          *AccountController
+	Follows           *FollowController
+	Teams             *TeamController
 }
 
 func NewStore(
@@ -76,17 +85,25 @@ func NewStore(
 	institutions := &InstitutionController{db, acl, aws.S3}
 	registerAllRules(institutions, acl)
 
+	parishLocator := parishes.NewLocator(db)
+
 	trips := &TripController{
-		db, acl, wrkr, geocoder,
+		db, acl, wrkr, geocoder, parishLocator,
 		gobutil.NewGobCodec[jobs.UpdateAchievementsArgs](),
+		gobutil.NewGobCodec[jobs.InitiativeEndedArgs](),
 	}
 	registerAllRules(trips, acl)
 
+	verification := &EmailVerificationController{db, users, aws.SES, trips}
+
 	achievements := &AchievementController{db, serverBaseURL}
 
 	pois := &POIController{db, acl}
 	registerAllRules(pois, acl)
 
+	parishes := &ParishController{db, acl, wrkr, parishLocator}
+	registerAllRules(parishes, acl)
+
 	leaderboard := &LeaderboardController{db}
 
 	external := &ExternalContentController{db, acl}
@@ -99,20 +116,51 @@ func NewStore(
 	metrics := &MetricsController{db, acl}
 	registerAllRules(metrics, acl)
 
+	reports := &ReportController{db, acl}
+	registerAllRules(reports, acl)
+
+	dataExports := &DataExportController{
+		db, acl, wrkr, gobutil.NewGobCodec[jobs.DataExportArgs](),
+	}
+	registerAllRules(dataExports, acl)
+
+	exports := &ExportController{
+		db, acl, wrkr, gobutil.NewGobCodec[jobs.ReportExportArgs](), aws.S3,
+	}
+	registerAllRules(exports, acl)
+
+	accounts := &AccountController{
+		db, acl, wrkr, gobutil.NewGobCodec[jobs.UserErasureArgs](),
+	}
+
+	follows := &FollowController{db, acl}
+	registerAllRules(follows, acl)
+
+	teams := &TeamController{db, acl}
+	registerAllRules(teams, acl)
+
 	return &Store{
-		Users:           users,
-		Password:        password,
-		Initiatives:     initiatives,
-		SDGs:            sdgs,
-		Institutions:    institutions,
-		Trips:           trips,
-		Achievements:    achievements,
-		POIs:            pois,
-		Leaderboard:     leaderboard,
-		ExternalContent: external,
-		FCMTokens:       fcm,
-		Languages:       languages,
-		Metrics:         metrics,
+		Users:             users,
+		Password:          password,
+		EmailVerification: verification,
+		Initiatives:       initiatives,
+		SDGs:              sdgs,
+		Institutions:      institutions,
+		Trips:             trips,
+		Achievements:      achievements,
+		POIs:              pois,
+		Parishes:          parishes,
+		Leaderboard:       leaderboard,
+		ExternalContent:   external,
+		FCMTokens:         fcm,
+		Languages:         languages,
+		Metrics:           metrics,
+		Reports:           reports,
+		DataExports:       dataExports,
+		Exports:           exports,
+		Accounts:          accounts,
+		Follows:           follows,
+		Teams:             teams,
 	}
 }
 
@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/jobs"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/gobutil"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/httputil"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/worker"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type DataExportController struct {
+	db    *gorm.DB
+	acl   authorizer
+	tasks scheduler
+	codec *gobutil.GobCodec[jobs.DataExportArgs]
+}
+
+// Rules returns the acl for the data export controller.
+func (DataExportController) Rules() []rule {
+	return []rule{
+		{models.User{}, models.User{},
+			"export-data,list-data-exports", func(ent, res any) bool {
+				return ent.(models.User).ID == res.(models.User).ID
+			}},
+	}
+}
+
+// dataExportInterval is the minimum time between exports of the data of a
+// user. Failed exports don't count.
+const dataExportInterval = 24 * time.Hour
+
+// Create requests an export of the user's data.
+//
+//	@Summary		Request an export of the user's data
+//	@Description	The data is bundled in a zip archive in the background, and the user is sent an
+//	@Description	email with a link to download it, which expires after 7 days. The archive
+//	@Description	includes the user's profile, trips (GPX files and metadata), achievements,
+//	@Description	devices and credits.
+//	@Description
+//	@Description	The data can only be exported once every 24 hours.
+//	@Tags			users
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id						path		string	true	"User Id"	Format(UUID)
+//	@Success		200						{object}	models.DataExport
+//	@Failure		400,401,403,404,429,500	{object}	middleware.ApiError
+//	@Router			/users/{id}/data-exports [post]
+func (c *DataExportController) Create(
+	id string,
+	ctx *gin.Context,
+) (models.DataExport, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return models.DataExport{}, err
+	}
+
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return models.DataExport{},
+			httputil.NewError(httputil.BadRequest, err)
+	}
+
+	if ok := c.acl.Authorize(user, "export-data", models.User{
+		BaseModel: models.BaseModel{ID: uid},
+	}); !ok {
+		return models.DataExport{}, httputil.NewErrorMsg(
+			httputil.Forbidden,
+			httputil.ForbiddenMessage,
+		)
+	}
+
+	export := models.DataExport{UserID: uid}
+	if err := c.db.Transaction(func(tx *gorm.DB) error {
+		// Lock the user to serialize the requests.
+		if err := tx.Model(&models.User{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&models.User{}, "id = ?", uid).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return resourceNotFoundErr("user")
+			}
+			return err
+		}
+
+		var recent int64
+		if err := tx.Model(&models.DataExport{}).
+			Where("user_id = ?", uid).
+			Where("status <> ?", models.DataExportFailed).
+			Where("created_at > ?", time.Now().Add(-dataExportInterval)).
+			Count(&recent).Error; err != nil {
+			return err
+		}
+		if recent > 0 {
+			return httputil.NewErrorMsg(
+				httputil.TooManyRequests,
+				"the data can only be exported once every 24 hours",
+			)
+		}
+
+		return tx.Create(&export).Error
+	}); err != nil {
+		return models.DataExport{}, err
+	}
+
+	args, err := c.codec.Encode(jobs.DataExportArgs{ExportID: export.ID})
+	if err == nil {
+		err = c.tasks.Schedule(&worker.TaskConfig{
+			JobName: jobs.UserDataExport,
+			Args:    args,
+		})
+	}
+	if err != nil {
+		// Failed exports don't count towards the interval, so the user can
+		// request it again.
+		if err := c.db.Model(&export).
+			Update("status", models.DataExportFailed).Error; err != nil {
+			log.Printf("failed to flag data export %s as failed: %v",
+				export.ID, err)
+		}
+		return models.DataExport{}, err
+	}
+
+	return export, nil
+}
+
+type ListDataExportsFilters struct {
+	Pagination
+}
+
+// List the user's data exports.
+//
+//	@Summary	List the user's data exports, most recent first
+//	@Tags		users
+//	@Produce	json
+//	@Security	OIDCToken
+//	@Security	AuthHeader
+//	@Param		id					path		string					true	"User Id"	Format(UUID)
+//	@Param		filters				query		ListDataExportsFilters	false	"Filters"
+//	@Success	200					{array}		models.DataExport
+//	@Failure	400,401,403,500		{object}	middleware.ApiError
+//	@Router		/users/{id}/data-exports [get]
+func (c *DataExportController) List(
+	id string,
+	filters ListDataExportsFilters,
+	ctx *gin.Context,
+) ([]models.DataExport, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return nil, err
+	}
+
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, httputil.NewError(httputil.BadRequest, err)
+	}
+
+	if ok := c.acl.Authorize(user, "list-data-exports", models.User{
+		BaseModel: models.BaseModel{ID: uid},
+	}); !ok {
+		return nil, httputil.NewErrorMsg(
+			httputil.Forbidden,
+			httputil.ForbiddenMessage,
+		)
+	}
+
+	var exports []models.DataExport
+	err = c.db.
+		Where("user_id = ?", uid).
+		Order("created_at DESC").
+		Limit(filters.Limit).
+		Offset(filters.Offset).
+		Find(&exports).Error
+
+	return exports, err
+}
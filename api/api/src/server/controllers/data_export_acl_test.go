@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"testing"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/server/access"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataExportAcl(t *testing.T) {
+	acl := access.New()
+	registerAllRules(&DataExportController{}, acl)
+
+	uid1, uid2 := uuid.New(), uuid.New()
+
+	for i, tc := range []struct {
+		ent, res models.User
+		action   string
+		exp      bool
+	}{
+		{
+			ent:    models.User{BaseModel: models.BaseModel{ID: uid1}},
+			res:    models.User{BaseModel: models.BaseModel{ID: uid1}},
+			action: "export-data",
+			exp:    true,
+		},
+		{
+			ent:    models.User{BaseModel: models.BaseModel{ID: uid1}},
+			res:    models.User{BaseModel: models.BaseModel{ID: uid2}},
+			action: "export-data",
+			exp:    false,
+		},
+		{
+			ent:    models.User{BaseModel: models.BaseModel{ID: uid1}, Admin: true},
+			res:    models.User{BaseModel: models.BaseModel{ID: uid2}},
+			action: "export-data",
+			exp:    false,
+		},
+		{
+			ent:    models.User{BaseModel: models.BaseModel{ID: uid1}},
+			res:    models.User{BaseModel: models.BaseModel{ID: uid1}},
+			action: "list-data-exports",
+			exp:    true,
+		},
+		{
+			ent:    models.User{BaseModel: models.BaseModel{ID: uid1}},
+			res:    models.User{BaseModel: models.BaseModel{ID: uid2}},
+			action: "list-data-exports",
+			exp:    false,
+		},
+	} {
+		assert.Equal(
+			t,
+			tc.exp,
+			acl.Authorize(tc.ent, tc.action, tc.res),
+			"failed on test %d", i,
+		)
+	}
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/jobs"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/server/access"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/gobutil"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/worker"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+type DataExportControllerTestSuite struct {
+	suite.Suite
+	exports *DataExportController
+	users   *UserController
+	db      *gorm.DB
+	acl     *access.ACL
+	wrkr    *MockWorker
+}
+
+// Run each test in a transaction.
+func (s *DataExportControllerTestSuite) SetupTest() {
+	tx := testDb.Begin()
+	s.db = tx
+	s.wrkr = &MockWorker{}
+	s.exports = &DataExportController{
+		tx, s.acl, s.wrkr, gobutil.NewGobCodec[jobs.DataExportArgs](),
+	}
+	s.users = &UserController{tx, s.acl, "", nil}
+}
+
+// Rollback the transaction after each test.
+func (s *DataExportControllerTestSuite) TearDownTest() {
+	s.wrkr.AssertExpectations(s.T())
+	s.db.Rollback()
+}
+
+func (s *DataExportControllerTestSuite) TestCreateDataExport() {
+	user, ctx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+	other, _, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+
+	// ---------------------------------- //
+	// Fails for the data of another user //
+	// ---------------------------------- //
+	_, err = s.exports.Create(other.ID.String(), ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Forbidden Action, "+
+		"message: the user does not have access to this operation"+
+		"}")
+
+	// ----------------------------------- //
+	// Schedules the export of user's data //
+	// ----------------------------------- //
+	s.wrkr.On("Schedule", mock.MatchedBy(func(t *worker.TaskConfig) bool {
+		return t.JobName == jobs.UserDataExport
+	})).Return(nil).Once()
+
+	export, err := s.exports.Create(user.ID.String(), ctx)
+	s.Require().NoError(err)
+	s.Equal(user.ID, export.UserID)
+	s.Equal(models.DataExportPending, export.Status)
+
+	// --------------------------------------- //
+	// Fails again within the minimum interval //
+	// --------------------------------------- //
+	_, err = s.exports.Create(user.ID.String(), ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Too Many Requests, "+
+		"message: the data can only be exported once every 24 hours"+
+		"}")
+
+	// -------------------------------------------- //
+	// Succeeds again if the previous export failed //
+	// -------------------------------------------- //
+	s.Require().NoError(s.db.Model(&export).
+		Update("status", models.DataExportFailed).Error)
+
+	s.wrkr.On("Schedule", mock.Anything).Return(nil).Once()
+	_, err = s.exports.Create(user.ID.String(), ctx)
+	s.Require().NoError(err)
+
+	exports, err := s.exports.
+		List(user.ID.String(), ListDataExportsFilters{}, ctx)
+	s.Require().NoError(err)
+	s.Len(exports, 2)
+
+	// ------------------------------------------------ //
+	// Flags the export as failed if it isn't scheduled //
+	// ------------------------------------------------ //
+	failing, otherCtx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+
+	s.wrkr.On("Schedule", mock.Anything).
+		Return(errors.New("queue unavailable")).Once()
+	_, err = s.exports.Create(failing.ID.String(), otherCtx)
+	s.EqualError(err, "queue unavailable")
+
+	exports, err = s.exports.
+		List(failing.ID.String(), ListDataExportsFilters{}, otherCtx)
+	s.Require().NoError(err)
+	s.Require().Len(exports, 1)
+	s.Equal(models.DataExportFailed, exports[0].Status)
+}
+
+func TestDataExportController(t *testing.T) {
+	acl := access.New()
+	registerAllRules(&DataExportController{}, acl)
+	registerAllRules(&UserController{}, acl)
+	suite.Run(t, &DataExportControllerTestSuite{acl: acl})
+}
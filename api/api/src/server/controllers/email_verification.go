@@ -0,0 +1,242 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/query"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/httputil"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/random"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// How long email verification codes are valid for.
+	verificationCodeLifetime = 24 * time.Hour
+	// Minimum time between verification emails sent to a user.
+	verificationResendInterval = time.Minute
+	// Maximum number of verification emails sent to a user in a day.
+	verificationDailyLimit = 5
+)
+
+type EmailVerificationController struct {
+	db      *gorm.DB
+	users   *UserController
+	emailer interface {
+		SendVerificationEmail(email, code string) error
+	}
+	// trips credits the trips held until the user verified their email.
+	trips interface {
+		creditPending(userID uuid.UUID, tx *gorm.DB) ([]uuid.UUID, error)
+		scheduleInitiativesEnded(ids []uuid.UUID)
+	}
+}
+
+// SignUp creates a user, and sends a verification code to the users that sign
+// up with a password.
+// The user is created even if the email can't be sent, as the code can be
+// sent again.
+//
+//	@Summary		Create a new user and return it
+//	@Description	Users that sign up with a password are sent an email with a code to verify
+//	@Description	their email.
+//	@Tags			users
+//	@Produce		json
+//	@Param			params		body		CreateUserParams	true	"Params"
+//	@Success		201			{object}	models.User
+//	@Failure		400,401,500	{object}	middleware.ApiError
+//	@Router			/users [post]
+func (c *EmailVerificationController) SignUp(
+	params CreateUserParams,
+	ctx *gin.Context,
+) (models.User, error) {
+	user, err := c.users.Create(params, ctx)
+	if err != nil || !user.PendingVerification() {
+		return user, err
+	}
+
+	if _, err := c.send(user, c.db); err != nil {
+		log.Printf("failed to send verification email to %s: %v",
+			user.Email, err)
+	}
+	return user, nil
+}
+
+// send creates a verification code for the user and emails it.
+func (c *EmailVerificationController) send(
+	user models.User,
+	tx *gorm.DB,
+) (models.EmailVerificationCode, error) {
+	code := models.EmailVerificationCode{
+		Code:      random.AlphanumericString(32),
+		UserID:    user.ID,
+		Email:     user.Email,
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(verificationCodeLifetime),
+	}
+	if err := tx.Omit(clause.Associations).Create(&code).Error; err != nil {
+		return code, fmt.Errorf("failed to store verification code in db: %v",
+			err)
+	}
+
+	return code, c.emailer.SendVerificationEmail(user.Email, code.Code)
+}
+
+type ResendVerificationResult struct {
+	// ExpiresAt is when the code that was sent expires.
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Resend sends a new verification code to the logged-in user.
+//
+//	@Summary		Send a new email verification code
+//	@Description	An email with a new code is sent to the user. Codes can be sent once a minute,
+//	@Description	and up to 5 times a day. Previous codes remain valid until they expire.
+//	@Tags			email-verification
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Success		200				{object}	ResendVerificationResult
+//	@Failure		400,401,429,500	{object}	middleware.ApiError
+//	@Router			/email-verification/resend [post]
+func (c *EmailVerificationController) Resend(
+	ctx *gin.Context,
+) (ResendVerificationResult, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return ResendVerificationResult{}, err
+	}
+
+	if user.Verified {
+		return ResendVerificationResult{}, httputil.NewErrorMsg(
+			httputil.EmailAlreadyVerified,
+			"the user's email has already been verified",
+		)
+	}
+
+	var result ResendVerificationResult
+	err = c.db.Transaction(func(tx *gorm.DB) error {
+		// Lock the user to serialize the requests.
+		if err := tx.Model(&models.User{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&models.User{}, "id = ?", user.ID).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		recent, err := query.EmailVerificationCodes.CountSince(
+			user.ID.String(), now.Add(-verificationResendInterval), tx)
+		if err != nil {
+			return err
+		}
+		if recent > 0 {
+			return httputil.NewErrorMsg(
+				httputil.TooManyRequests,
+				"a verification email can only be sent once a minute",
+			)
+		}
+
+		today, err := query.EmailVerificationCodes.CountSince(
+			user.ID.String(), now.Add(-24*time.Hour), tx)
+		if err != nil {
+			return err
+		}
+		if today >= verificationDailyLimit {
+			return httputil.NewErrorMsg(
+				httputil.TooManyRequests,
+				"too many verification emails sent in the last 24 hours",
+			)
+		}
+
+		code, err := c.send(user, tx)
+		if err != nil {
+			return err
+		}
+		result.ExpiresAt = code.ExpiresAt
+		return nil
+	})
+
+	return result, err
+}
+
+type ConfirmVerificationParams struct {
+	// Code provided to the user via email.
+	Code string `json:"code" binding:"required"`
+}
+
+// Confirm verifies the email of a user.
+//
+//	@Summary		Confirm the email of a user
+//	@Description	The trips uploaded while the email wasn't verified are credited.
+//	@Tags			email-verification
+//	@Produce		json
+//	@Param			params			body	ConfirmVerificationParams	true	"Params"
+//	@Success		204
+//	@Failure		400,404,410,500	{object}	middleware.ApiError
+//	@Router			/email-verification/confirm [put]
+func (c *EmailVerificationController) Confirm(
+	params ConfirmVerificationParams,
+	_ *gin.Context,
+) (int, error) {
+	var ended []uuid.UUID
+	if err := c.db.Transaction(func(tx *gorm.DB) (err error) {
+		var record models.EmailVerificationCode
+		if err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&record, "code = ?", params.Code).
+			Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return resourceNotFoundErr("verification code")
+			}
+			return err
+		}
+
+		if record.Used {
+			return httputil.NewErrorMsg(
+				httputil.EmailVerificationCodeUsed,
+				"the email verification code has already been used",
+			)
+		}
+
+		expired := httputil.NewErrorMsg(
+			httputil.EmailVerificationCodeExpired,
+			"the email verification code is no longer valid",
+		)
+		if record.IsExpired() {
+			return expired
+		}
+
+		// The code isn't valid if the user changed their email since.
+		res := tx.Model(&models.User{}).
+			Where("id = ?", record.UserID).
+			Where("email = ?", record.Email).
+			Update("verified", true)
+		if res.Error != nil {
+			return fmt.Errorf("failed to verify user: %v", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return expired
+		}
+
+		if err := tx.Model(&record).
+			Update("used", true).Error; err != nil {
+			return fmt.Errorf("failed to flag record as used: %v", err)
+		}
+
+		ended, err = c.trips.creditPending(record.UserID, tx)
+		return err
+	}); err != nil {
+		return 0, err
+	}
+
+	c.trips.scheduleInitiativesEnded(ended)
+
+	return http.StatusNoContent, nil
+}
@@ -0,0 +1,243 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/server/access"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/random"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+type MockVerificationEmailer struct {
+	mock.Mock
+}
+
+func (e *MockVerificationEmailer) SendVerificationEmail(email, code string) error {
+	return e.Called(email, code).Error(0)
+}
+
+type EmailVerificationControllerTestSuite struct {
+	suite.Suite
+	verification *EmailVerificationController
+	users        *UserController
+	emailer      *MockVerificationEmailer
+	db           *gorm.DB
+	acl          *access.ACL
+}
+
+// Run each test in a transaction.
+func (s *EmailVerificationControllerTestSuite) SetupTest() {
+	tx := testDb.Begin()
+	s.db = tx
+	s.emailer = &MockVerificationEmailer{}
+	s.users = &UserController{tx, s.acl, "", nil}
+	s.verification = &EmailVerificationController{
+		tx, s.users, s.emailer, &TripController{db: tx},
+	}
+}
+
+// Rollback the transaction after each test.
+func (s *EmailVerificationControllerTestSuite) TearDownTest() {
+	s.emailer.AssertExpectations(s.T())
+	s.db.Rollback()
+}
+
+// expectEmail expects a verification email to be sent to the given address,
+// and stores the code in the given string.
+func (s *EmailVerificationControllerTestSuite) expectEmail(
+	email string,
+	code *string,
+) {
+	s.emailer.
+		On("SendVerificationEmail", email, mock.MatchedBy(func(c string) bool {
+			*code = c
+			return true
+		})).
+		Return(nil).
+		Once()
+}
+
+func (s *EmailVerificationControllerTestSuite) TestSignUp() {
+	// ----------------------------------------------- //
+	// Sends a code to users who sign up with password //
+	// ----------------------------------------------- //
+	params := CreateUserParams{
+		Name:     random.String(5),
+		Email:    random.String(20) + "@test.org",
+		Password: "pass@word",
+	}
+	var code string
+	s.expectEmail(params.Email, &code)
+
+	user, err := s.verification.SignUp(params, nil)
+	s.Require().NoError(err)
+	s.False(user.Verified)
+
+	var record models.EmailVerificationCode
+	s.Require().NoError(s.db.First(&record, "code = ?", code).Error)
+	s.Equal(user.ID, record.UserID)
+	s.Equal(user.Email, record.Email)
+
+	// ------------------------------------------- //
+	// Doesn't send a code to users with a subject //
+	// ------------------------------------------- //
+	_, err = s.verification.SignUp(CreateUserParams{
+		Name:    random.String(5),
+		Email:   random.String(20) + "@test.org",
+		Subject: random.String(20),
+	}, nil)
+	s.Require().NoError(err)
+}
+
+func (s *EmailVerificationControllerTestSuite) TestConfirm() {
+	user, ctx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+
+	var code string
+	s.expectEmail(user.Email, &code)
+	_, err = s.verification.Resend(ctx)
+	s.Require().NoError(err)
+
+	// ------------------------------- //
+	// Fails if the code doesn't exist //
+	// ------------------------------- //
+	_, err = s.verification.Confirm(ConfirmVerificationParams{
+		Code: random.AlphanumericString(32),
+	}, nil)
+	s.EqualError(err, "ApiError{"+
+		"code: Record Not Found, "+
+		"message: verification code not found"+
+		"}")
+
+	// ------------------------- //
+	// Verifies the user's email //
+	// ------------------------- //
+	status, err := s.verification.Confirm(ConfirmVerificationParams{
+		Code: code,
+	}, nil)
+	s.Require().NoError(err)
+	s.Equal(http.StatusNoContent, status)
+
+	s.Require().NoError(s.db.First(&user, "id = ?", user.ID).Error)
+	s.True(user.Verified)
+
+	// ------------------------------- //
+	// Fails if the code has been used //
+	// ------------------------------- //
+	_, err = s.verification.Confirm(ConfirmVerificationParams{
+		Code: code,
+	}, nil)
+	s.EqualError(err, "ApiError{"+
+		"code: Email Verification Code Already Used, "+
+		"message: the email verification code has already been used"+
+		"}")
+
+	// ----------------------------- //
+	// Fails if the code has expired //
+	// ----------------------------- //
+	expired := models.EmailVerificationCode{
+		Code:      random.AlphanumericString(32),
+		UserID:    user.ID,
+		Email:     user.Email,
+		CreatedAt: time.Now().Add(-2 * verificationCodeLifetime),
+		ExpiresAt: time.Now().Add(-verificationCodeLifetime),
+	}
+	s.Require().NoError(s.db.Omit("User").Create(&expired).Error)
+
+	_, err = s.verification.Confirm(ConfirmVerificationParams{
+		Code: expired.Code,
+	}, nil)
+	s.EqualError(err, "ApiError{"+
+		"code: Email Verification Code Expired, "+
+		"message: the email verification code is no longer valid"+
+		"}")
+
+	// ------------------------------------------- //
+	// Fails if the user's email has changed since //
+	// ------------------------------------------- //
+	stale := models.EmailVerificationCode{
+		Code:      random.AlphanumericString(32),
+		UserID:    user.ID,
+		Email:     random.String(20) + "@test.org",
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(verificationCodeLifetime),
+	}
+	s.Require().NoError(s.db.Omit("User").Create(&stale).Error)
+
+	_, err = s.verification.Confirm(ConfirmVerificationParams{
+		Code: stale.Code,
+	}, nil)
+	s.EqualError(err, "ApiError{"+
+		"code: Email Verification Code Expired, "+
+		"message: the email verification code is no longer valid"+
+		"}")
+}
+
+func (s *EmailVerificationControllerTestSuite) TestResend() {
+	user, ctx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+
+	// -------------------- //
+	// Sends a code to user //
+	// -------------------- //
+	var code string
+	s.expectEmail(user.Email, &code)
+	res, err := s.verification.Resend(ctx)
+	s.Require().NoError(err)
+	s.WithinDuration(time.Now().Add(verificationCodeLifetime),
+		res.ExpiresAt, time.Minute)
+
+	// ---------------------------------- //
+	// Fails again within the same minute //
+	// ---------------------------------- //
+	_, err = s.verification.Resend(ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Too Many Requests, "+
+		"message: a verification email can only be sent once a minute"+
+		"}")
+
+	// ------------------------------------ //
+	// Fails after reaching the daily limit //
+	// ------------------------------------ //
+	s.Require().NoError(s.db.Model(&models.EmailVerificationCode{}).
+		Where("user_id = ?", user.ID).
+		Update("created_at", time.Now().Add(-time.Hour)).Error)
+	for i := 1; i < verificationDailyLimit; i++ {
+		s.Require().NoError(s.db.Omit("User").
+			Create(&models.EmailVerificationCode{
+				Code:      random.AlphanumericString(32),
+				UserID:    user.ID,
+				Email:     user.Email,
+				CreatedAt: time.Now().Add(-time.Hour),
+				ExpiresAt: time.Now().Add(verificationCodeLifetime),
+			}).Error)
+	}
+
+	_, err = s.verification.Resend(ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Too Many Requests, "+
+		"message: too many verification emails sent in the last 24 hours"+
+		"}")
+
+	// ------------------------------ //
+	// Fails if the email is verified //
+	// ------------------------------ //
+	s.Require().NoError(s.db.Model(&user).Update("verified", true).Error)
+
+	_, err = s.verification.Resend(ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Email Already Verified, "+
+		"message: the user's email has already been verified"+
+		"}")
+}
+
+func TestEmailVerificationController(t *testing.T) {
+	acl := access.New()
+	registerAllRules(&UserController{}, acl)
+	suite.Run(t, &EmailVerificationControllerTestSuite{acl: acl})
+}
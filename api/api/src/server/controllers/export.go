@@ -0,0 +1,318 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/query"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/types"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/exports"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/jobs"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/reports"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/gobutil"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/httputil"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/worker"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type reportExportPresigner interface {
+	PresignGetReportExport(
+		exportID, format, filename string,
+		expires time.Duration,
+	) (string, error)
+}
+
+type ExportController struct {
+	db        *gorm.DB
+	acl       authorizer
+	tasks     scheduler
+	codec     *gobutil.GobCodec[jobs.ReportExportArgs]
+	presigner reportExportPresigner
+}
+
+// Rules returns the acl for the export controller.
+func (ExportController) Rules() []rule {
+	return []rule{
+		{models.User{}, models.ReportExport{}, "export,get", func(ent, res any) bool {
+			return ent.(models.User).Admin
+		}},
+	}
+}
+
+// exportDownloadLimit is the maximum number of rows of an export that can be
+// downloaded directly. Larger exports must be requested as a background job.
+const exportDownloadLimit = 10_000
+
+// ExportFilters are the kind and format of an export, and the filters of the
+// respective list. Each kind of export ignores the filters of the others.
+type ExportFilters struct {
+	// Kind of the records to export: `users`, `trips`, `initiatives`,
+	// `contributions` (the credits contributed by each user to each
+	// initiative) or `metrics` (the time series of the platform metrics).
+	Kind exports.Kind `form:"kind" json:"kind" binding:"required,oneof=users trips initiatives contributions metrics" example:"trips"`
+	// Format of the spreadsheet: `csv` or `xlsx`. Defaults to csv.
+	Format string `form:"format" json:"format" binding:"omitempty,oneof=csv xlsx" example:"xlsx"`
+	// OrderBy specifies the sorting order of users, trips and initiatives.
+	// Defaults to "id asc".
+	OrderBy orderBy `form:"orderBy" json:"orderBy" binding:"omitempty,order_by_clause" example:"createdAt desc"`
+
+	// TimeFrom filters trips uploaded after this time.
+	TimeFrom string `form:"timeFrom" json:"timeFrom" binding:"omitempty,datetime=2006-01-02T15:04:05Z07:00" example:"2023-03-30T17:23:57+02:00"`
+	// TimeTo filters trips uploaded before this time.
+	TimeTo string `form:"timeTo" json:"timeTo" binding:"omitempty,datetime=2006-01-02T15:04:05Z07:00" example:"2023-03-30T17:23:57+02:00"`
+
+	// IncludeDisabled initiatives in the export.
+	IncludeDisabled bool `form:"includeDisabled" json:"includeDisabled"`
+	// SDGs filters initiatives associated with any of the given SDG codes.
+	SDGs []int `form:"sdg" json:"sdgs" binding:"omitempty,dive,min=1,max=17" example:"11"`
+	// InstitutionID filters initiatives of the institution.
+	InstitutionID string `form:"institutionId" json:"institutionId" binding:"omitempty,uuid"`
+	// SponsorID filters initiatives sponsored by the institution.
+	SponsorID string `form:"sponsorId" json:"sponsorId" binding:"omitempty,uuid"`
+	// Status filters active initiatives, active initiatives that have reached
+	// 80% of the goal, or initiatives that have reached the goal or expired.
+	Status query.InitiativeStatus `form:"status" json:"status" binding:"omitempty,oneof=active near-goal ended"`
+	// Search terms to match against the title and description of initiatives.
+	Search string `form:"q" json:"q" example:"bicicletas escola"`
+
+	// InitiativeID filters the contributions to the initiative.
+	InitiativeID string `form:"initiativeId" json:"initiativeId" binding:"omitempty,uuid"`
+
+	// Interval of the points of the time series of metrics: `day`, `week` or
+	// `month`. Defaults to day.
+	Interval query.MetricsInterval `form:"interval" json:"interval" binding:"omitempty,oneof=day week month" example:"week"`
+	// DateFrom is the first day of the time series of metrics. Defaults to 30
+	// days, 12 weeks or 12 months before DateTo, according to the interval.
+	DateFrom types.Date `form:"dateFrom" json:"dateFrom" binding:"omitempty,datetime=2006-01-02" example:"2023-03-01"`
+	// DateTo is the last day of the time series of metrics. Defaults to the
+	// current day.
+	DateTo types.Date `form:"dateTo" json:"dateTo" binding:"omitempty,datetime=2006-01-02" example:"2023-03-30"`
+}
+
+// format returns the format of the export, csv by default.
+func (f ExportFilters) format() string {
+	if f.Format == "" {
+		return reports.CSV
+	}
+	return f.Format
+}
+
+// filters converts the filters of the request to the filters of the export,
+// setting the defaults of the time series of metrics.
+func (f ExportFilters) filters() (exports.Filters, error) {
+	series, err := MetricsSeriesFilters{
+		Interval: f.Interval,
+		DateFrom: f.DateFrom,
+		DateTo:   f.DateTo,
+	}.withDefaults()
+	if err != nil && f.Kind == exports.Metrics {
+		return exports.Filters{}, err
+	}
+
+	return exports.Filters{
+		OrderBy:  f.OrderBy.ToSnakeCase(),
+		TimeFrom: f.TimeFrom,
+		TimeTo:   f.TimeTo,
+		Initiatives: query.InitiativeFilters{
+			SDGs:          f.SDGs,
+			InstitutionID: f.InstitutionID,
+			SponsorID:     f.SponsorID,
+			Status:        f.Status,
+			Search:        f.Search,
+		},
+		IncludeDisabled: f.IncludeDisabled,
+		InitiativeID:    f.InitiativeID,
+		Interval:        series.Interval,
+		DateFrom:        series.DateFrom,
+		DateTo:          series.DateTo,
+	}, nil
+}
+
+// exportFilename returns the name of the file of an export created at the
+// given time.
+func exportFilename(kind, format string, createdAt time.Time) string {
+	return fmt.Sprintf("%s_%s.%s",
+		kind, createdAt.Format(types.DateFormat), format)
+}
+
+// authorize returns the user of the request, or an error unless they're
+// allowed to perform the action on exports.
+func (c *ExportController) authorize(
+	action string,
+	ctx *gin.Context,
+) (models.User, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if ok := c.acl.Authorize(user, action, models.ReportExport{}); !ok {
+		return models.User{}, httputil.NewErrorMsg(
+			httputil.AdminAccessRequired,
+			httputil.AdminRequiredMessage,
+		)
+	}
+	return user, nil
+}
+
+// Download streams an export of a list or the metrics of the platform.
+//
+//	@Summary		Download a list or the metrics of the platform as a spreadsheet
+//	@Description	Exports the users, trips, initiatives, contributions to initiatives or the time
+//	@Description	series of the platform metrics, with the same filters as the respective lists,
+//	@Description	in CSV or XLSX. Exports with more than 10000 rows can't be downloaded directly,
+//	@Description	and must be requested with `POST /exports` instead.
+//	@Tags			exports
+//	@Produce		text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			filters			query		ExportFilters	true	"Filters"
+//	@Success		200				{file}		file
+//	@Failure		400,401,403,500	{object}	middleware.ApiError
+//	@Router			/exports/download [get]
+func (c *ExportController) Download(
+	filters ExportFilters,
+	ctx *gin.Context,
+) error {
+	if _, err := c.authorize("export", ctx); err != nil {
+		return err
+	}
+
+	exportFilters, err := filters.filters()
+	if err != nil {
+		return err
+	}
+
+	rows, err := exports.Count(filters.Kind, exportFilters, c.db)
+	if err != nil {
+		return err
+	}
+	if rows > exportDownloadLimit {
+		return httputil.NewErrorMsg(
+			httputil.ExportTooLarge,
+			fmt.Sprintf("the export has %d rows, more than the %d that can "+
+				"be downloaded directly", rows, exportDownloadLimit),
+		)
+	}
+
+	format := filters.format()
+	ctx.Header("Content-Type", reports.ContentType(format))
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"",
+		exportFilename(string(filters.Kind), format, time.Now())))
+
+	return exports.Write(ctx.Writer, filters.Kind, format, exportFilters, c.db)
+}
+
+// Create requests an export of a list or the metrics of the platform.
+//
+//	@Summary		Request an export of a list or the metrics of the platform
+//	@Description	The spreadsheet is written in the background, and is available for download for
+//	@Description	7 days once the status of the export is `ready`. Meant for exports too large to
+//	@Description	be downloaded directly.
+//	@Tags			exports
+//	@Accept			json
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			filters			body		ExportFilters	true	"Filters"
+//	@Success		201				{object}	models.ReportExport
+//	@Failure		400,401,403,500	{object}	middleware.ApiError
+//	@Router			/exports [post]
+func (c *ExportController) Create(
+	filters ExportFilters,
+	ctx *gin.Context,
+) (models.ReportExport, error) {
+	user, err := c.authorize("export", ctx)
+	if err != nil {
+		return models.ReportExport{}, err
+	}
+
+	exportFilters, err := filters.filters()
+	if err != nil {
+		return models.ReportExport{}, err
+	}
+
+	export := models.ReportExport{
+		UserID: user.ID,
+		Kind:   string(filters.Kind),
+		Format: filters.format(),
+	}
+	if err := c.db.Create(&export).Error; err != nil {
+		return models.ReportExport{}, err
+	}
+
+	args, err := c.codec.Encode(jobs.ReportExportArgs{
+		ExportID: export.ID,
+		Filters:  exportFilters,
+	})
+	if err != nil {
+		return models.ReportExport{}, err
+	}
+
+	return export, c.tasks.Schedule(&worker.TaskConfig{
+		JobName: jobs.ReportExport,
+		Args:    args,
+	})
+}
+
+// ReportExportWithLink is an export, with the link to download the
+// spreadsheet when it's ready.
+type ReportExportWithLink struct {
+	models.ReportExport
+	// URL is a pre-signed link to download the spreadsheet, present while
+	// it's available.
+	URL string `json:"url,omitempty"`
+}
+
+// Get retrieves an export.
+//
+//	@Summary		Retrieve an export of a list or the metrics of the platform
+//	@Description	Includes the link to download the spreadsheet once it's ready, until it expires.
+//	@Tags			exports
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id					path		string	true	"Export Id"	Format(UUID)
+//	@Success		200					{object}	ReportExportWithLink
+//	@Failure		400,401,403,404,500	{object}	middleware.ApiError
+//	@Router			/exports/{id} [get]
+func (c *ExportController) Get(
+	id string,
+	ctx *gin.Context,
+) (ReportExportWithLink, error) {
+	if _, err := c.authorize("get", ctx); err != nil {
+		return ReportExportWithLink{}, err
+	}
+
+	exportID, err := uuid.Parse(id)
+	if err != nil {
+		return ReportExportWithLink{}, httputil.NewError(httputil.BadRequest, err)
+	}
+
+	var export models.ReportExport
+	if err := c.db.First(&export, "id = ?", exportID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ReportExportWithLink{}, resourceNotFoundErr("export")
+		}
+		return ReportExportWithLink{}, err
+	}
+
+	result := ReportExportWithLink{ReportExport: export}
+	if export.Status != models.ReportExportReady || export.ExpiresAt == nil ||
+		time.Now().After(*export.ExpiresAt) {
+		return result, nil
+	}
+
+	url, err := c.presigner.PresignGetReportExport(
+		export.ID.String(),
+		export.Format,
+		exportFilename(export.Kind, export.Format, export.CreatedAt),
+		time.Until(*export.ExpiresAt),
+	)
+	result.URL = url
+	return result, err
+}
@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"fmt"
+	"testing"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/server/access"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportACL(t *testing.T) {
+	acl := access.New()
+	registerAllRules(&ExportController{}, acl)
+
+	testCases := []struct {
+		ent    models.User
+		res    any
+		action string
+		exp    bool
+	}{
+		{
+			ent:    models.User{Admin: true},
+			res:    models.ReportExport{},
+			action: "export",
+			exp:    true,
+		},
+		{
+			ent:    models.User{Admin: false},
+			res:    models.ReportExport{},
+			action: "export",
+			exp:    false,
+		},
+		{
+			ent:    models.User{Admin: true},
+			res:    models.ReportExport{},
+			action: "get",
+			exp:    true,
+		},
+		{
+			ent:    models.User{Admin: false},
+			res:    models.ReportExport{},
+			action: "get",
+			exp:    false,
+		},
+	}
+	for i, tC := range testCases {
+		t.Run(fmt.Sprintf("action: %s, line: %d", tC.action, i), func(t *testing.T) {
+			assert.Equal(t, tC.exp, acl.Authorize(tC.ent, tC.action, tC.res))
+		})
+	}
+}
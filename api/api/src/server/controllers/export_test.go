@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/exports"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/jobs"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/server/access"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/server/middleware"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/gobutil"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/random"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/worker"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+type MockReportExportPresigner struct {
+	mock.Mock
+}
+
+func (m *MockReportExportPresigner) PresignGetReportExport(
+	exportID, format, filename string,
+	_ time.Duration,
+) (string, error) {
+	args := m.Called(exportID, format, filename)
+	return args.String(0), args.Error(1)
+}
+
+type ExportControllerTestSuite struct {
+	suite.Suite
+	exports   *ExportController
+	users     *UserController
+	db        *gorm.DB
+	acl       *access.ACL
+	wrkr      *MockWorker
+	presigner *MockReportExportPresigner
+}
+
+// Run each test in a transaction.
+func (s *ExportControllerTestSuite) SetupTest() {
+	tx := testDb.Begin()
+	s.db = tx
+	s.wrkr = &MockWorker{}
+	s.presigner = &MockReportExportPresigner{}
+	s.exports = &ExportController{
+		tx, s.acl, s.wrkr, gobutil.NewGobCodec[jobs.ReportExportArgs](),
+		s.presigner,
+	}
+	s.users = &UserController{tx, s.acl, "", nil}
+}
+
+// Rollback the transaction after each test.
+func (s *ExportControllerTestSuite) TearDownTest() {
+	s.wrkr.AssertExpectations(s.T())
+	s.presigner.AssertExpectations(s.T())
+	s.db.Rollback()
+}
+
+// recordedContext returns a context of the user whose response is recorded.
+func recordedContext(user models.User) (*gin.Context, *httptest.ResponseRecorder) {
+	res := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(res)
+	ctx.Set(middleware.TokenClaimsKey, middleware.Claims{
+		Sub: user.ID.String(),
+	})
+	return ctx, res
+}
+
+func (s *ExportControllerTestSuite) TestDownload() {
+	admin, _, err := createRandomAdmin(s.users)
+	s.Require().NoError(err)
+	user, _, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+
+	// Only the trips uploaded from now on are exported, since no other trips
+	// were uploaded in the future.
+	at := time.Now().Add(time.Hour)
+	trip := models.Trip{
+		BaseModel: models.BaseModel{CreatedAt: at},
+		GPX:       []byte(`<gpx version="1.1"><trk></trk></gpx>`),
+		GPXHash:   []byte(random.String(32)),
+		IsValid:   true,
+		Distance:  12.5,
+		UserID:    user.ID,
+	}
+	s.Require().NoError(s.db.Create(&trip).Error)
+
+	filters := ExportFilters{
+		Kind:     exports.Trips,
+		TimeFrom: time.Now().Format(time.RFC3339),
+	}
+
+	// --------------------------------- //
+	// Fails for users that aren't admin //
+	// --------------------------------- //
+	ctx, _ := recordedContext(user)
+	err = s.exports.Download(filters, ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Admin Access Required, "+
+		"message: the user must be an administrator to perform this action"+
+		"}")
+
+	// --------------------------------- //
+	// Streams the filtered trips in CSV //
+	// --------------------------------- //
+	ctx, res := recordedContext(admin)
+	s.Require().NoError(s.exports.Download(filters, ctx))
+	s.Equal("text/csv; charset=utf-8", res.Header().Get("Content-Type"))
+	s.Contains(res.Header().Get("Content-Disposition"), "attachment")
+
+	rows, err := csv.NewReader(res.Body).ReadAll()
+	s.Require().NoError(err)
+	s.Require().Len(rows, 2)
+	s.Equal("ID", rows[0][0])
+	s.Equal(trip.ID.String(), rows[1][0])
+	s.Equal(user.ID.String(), rows[1][1])
+	s.Equal("12.5", rows[1][3])
+
+	// -------------------------------------- //
+	// Fails for an invalid period of metrics //
+	// -------------------------------------- //
+	ctx, _ = recordedContext(admin)
+	err = s.exports.Download(ExportFilters{
+		Kind:     exports.Metrics,
+		DateFrom: "2023-03-30",
+		DateTo:   "2023-03-01",
+	}, ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Bad Request, "+
+		"message: the start of the period must be before its end"+
+		"}")
+}
+
+func (s *ExportControllerTestSuite) TestCreateAndGet() {
+	admin, ctx, err := createRandomAdmin(s.users)
+	s.Require().NoError(err)
+
+	// --------------------------------------- //
+	// Schedules the export of the spreadsheet //
+	// --------------------------------------- //
+	s.wrkr.On("Schedule", mock.MatchedBy(func(t *worker.TaskConfig) bool {
+		return t.JobName == jobs.ReportExport
+	})).Return(nil).Once()
+
+	export, err := s.exports.Create(ExportFilters{
+		Kind:   exports.Users,
+		Format: "xlsx",
+	}, ctx)
+	s.Require().NoError(err)
+	s.Equal(admin.ID, export.UserID)
+	s.Equal("users", export.Kind)
+	s.Equal("xlsx", export.Format)
+	s.Equal(models.ReportExportPending, export.Status)
+
+	// ---------------------------- //
+	// Has no link until it's ready //
+	// ---------------------------- //
+	result, err := s.exports.Get(export.ID.String(), ctx)
+	s.Require().NoError(err)
+	s.Empty(result.URL)
+
+	// -------------------------- //
+	// Has a link once it's ready //
+	// -------------------------- //
+	expiresAt := time.Now().Add(time.Hour)
+	s.Require().NoError(s.db.Model(&export).Updates(models.ReportExport{
+		Status:    models.ReportExportReady,
+		ExpiresAt: &expiresAt,
+	}).Error)
+
+	s.presigner.On("PresignGetReportExport",
+		export.ID.String(),
+		"xlsx",
+		exportFilename("users", "xlsx", export.CreatedAt),
+	).Return("https://example.com/export", nil).Once()
+
+	result, err = s.exports.Get(export.ID.String(), ctx)
+	s.Require().NoError(err)
+	s.Equal(models.ReportExportReady, result.Status)
+	s.Equal("https://example.com/export", result.URL)
+
+	// ---------------------------- //
+	// Has no link after it expires //
+	// ---------------------------- //
+	s.Require().NoError(s.db.Model(&export).
+		Update("expires_at", time.Now().Add(-time.Minute)).Error)
+
+	result, err = s.exports.Get(export.ID.String(), ctx)
+	s.Require().NoError(err)
+	s.Empty(result.URL)
+
+	// --------------------- //
+	// Fails for invalid IDs //
+	// --------------------- //
+	_, err = s.exports.Get("invalid", ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Bad Request, "+
+		"message: invalid UUID length: 7"+
+		"}")
+}
+
+func TestExportController(t *testing.T) {
+	acl := access.New()
+	registerAllRules(&ExportController{}, acl)
+	registerAllRules(&UserController{}, acl)
+	suite.Run(t, &ExportControllerTestSuite{acl: acl})
+}
@@ -0,0 +1,386 @@
+package controllers
+
+import (
+	"errors"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/query"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/httputil"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type FollowController struct {
+	db  *gorm.DB
+	acl authorizer
+}
+
+// Rules returns the acl for the follow controller.
+func (FollowController) Rules() []rule {
+	return []rule{
+		{models.User{}, models.User{},
+			"manage-followers,list-follow-requests", func(ent, res any) bool {
+				user := ent.(models.User)
+				return user.ID == res.(models.User).ID || user.Admin
+			}},
+	}
+}
+
+// followUserColumns are the columns of the users shown in the lists of
+// followers and followed users.
+var followUserColumns = []string{
+	"id", "name", "username", "trip_count", "total_dist", "credits", "private",
+}
+
+// findUser retrieves the user with the given ID, ignoring deleted accounts and
+// the hidden profiles the user can't see.
+func (c *FollowController) findUser(
+	user models.User,
+	id string,
+) (models.User, error) {
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return models.User{}, httputil.NewError(httputil.BadRequest, err)
+	}
+
+	var target models.User
+	err = c.db.
+		Where("anonymized_at IS NULL").
+		First(&target, "id = ?", userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) ||
+		(err == nil && !c.acl.Authorize(user, "get", target)) {
+		return models.User{}, resourceNotFoundErr("user")
+	}
+	return target, err
+}
+
+// canView returns true if the user can see who follows the target user and who
+// they follow. The relations of private profiles are only visible to their
+// followers.
+func (c *FollowController) canView(user, target models.User) (bool, error) {
+	if !target.Private || user.ID == target.ID || user.Admin {
+		return true, nil
+	}
+	return query.Follows.IsFollowing(user.ID.String(), target.ID.String(), c.db)
+}
+
+// Follow a user.
+//
+//	@Summary		Follow a user
+//	@Description	If the user has a private profile, the follow is pending until they approve
+//	@Description	it. Following a user again returns the existing follow.
+//	@Tags			users
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id					path		string	true	"User Id"	Format(UUID)
+//	@Success		200					{object}	models.Follow
+//	@Failure		400,401,404,500		{object}	middleware.ApiError
+//	@Router			/users/{id}/follow [post]
+func (c *FollowController) Follow(
+	id string,
+	ctx *gin.Context,
+) (models.Follow, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return models.Follow{}, err
+	}
+
+	target, err := c.findUser(user, id)
+	if err != nil {
+		return models.Follow{}, err
+	}
+	if target.ID == user.ID {
+		return models.Follow{}, httputil.NewErrorMsg(
+			httputil.BadRequest,
+			"users can't follow themselves",
+		)
+	}
+
+	follow := models.Follow{
+		FollowerID: user.ID,
+		FolloweeID: target.ID,
+		Status:     models.FollowAccepted,
+	}
+	if target.Private {
+		follow.Status = models.FollowPending
+	}
+
+	if err := c.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&follow).Error; err != nil {
+			return err
+		}
+		return tx.First(&follow,
+			"follower_id = ? AND followee_id = ?", user.ID, target.ID).Error
+	}); err != nil {
+		return models.Follow{}, err
+	}
+
+	return follow, nil
+}
+
+// Unfollow a user.
+//
+//	@Summary		Unfollow a user
+//	@Description	Also cancels a pending follow request.
+//	@Tags			users
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id	path	string	true	"User Id"	Format(UUID)
+//	@Success		204
+//	@Failure		400,401,404,500	{object}	middleware.ApiError
+//	@Router			/users/{id}/follow [delete]
+func (c *FollowController) Unfollow(id string, ctx *gin.Context) error {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return err
+	}
+
+	followeeID, err := uuid.Parse(id)
+	if err != nil {
+		return httputil.NewError(httputil.BadRequest, err)
+	}
+
+	res := c.db.Delete(&models.Follow{},
+		"follower_id = ? AND followee_id = ?", user.ID, followeeID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return resourceNotFoundErr("follow")
+	}
+	return nil
+}
+
+type ListFollowsFilters struct {
+	Pagination
+	// Status of the follows. Pending follows are only visible to the user.
+	// Defaults to accepted.
+	Status models.FollowStatus `form:"status" binding:"omitempty,oneof=pending accepted"`
+}
+
+// listFollows lists the follows of the user with the given ID. If followers is
+// true, lists the users that follow them, otherwise the users they follow.
+func (c *FollowController) listFollows(
+	id string,
+	filters ListFollowsFilters,
+	followers bool,
+	ctx *gin.Context,
+) ([]models.Follow, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := c.findUser(user, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if filters.Status == "" {
+		filters.Status = models.FollowAccepted
+	}
+	if filters.Status == models.FollowPending &&
+		!c.acl.Authorize(user, "list-follow-requests", target) {
+		return nil, httputil.NewErrorMsg(
+			httputil.Forbidden,
+			httputil.ForbiddenMessage,
+		)
+	}
+
+	if ok, err := c.canView(user, target); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, httputil.NewErrorMsg(
+			httputil.Forbidden,
+			httputil.ForbiddenMessage,
+		)
+	}
+
+	key, other, preload := "followee_id", "follower_id", "Follower"
+	if !followers {
+		key, other, preload = "follower_id", "followee_id", "Followee"
+	}
+
+	q := c.db.
+		Preload(preload, func(db *gorm.DB) *gorm.DB {
+			return db.Select(followUserColumns)
+		}).
+		Where(key+" = ?", target.ID)
+	// Hidden profiles are left out of the lists, except for the follow
+	// requests they sent, which the user must see to approve them.
+	if filters.Status == models.FollowAccepted &&
+		!c.acl.Authorize(user, "list-hidden", models.User{}) {
+		q = q.Where(other+" IN (SELECT id FROM users "+
+			"WHERE NOT hidden_profile OR id = ?)", user.ID)
+	}
+
+	var follows []models.Follow
+	err = q.
+		Where("status = ?", filters.Status).
+		Order("created_at DESC").
+		Limit(filters.Limit).
+		Offset(filters.Offset).
+		Find(&follows).Error
+
+	return follows, err
+}
+
+// Followers lists the users that follow a user.
+//
+//	@Summary		List the followers of a user
+//	@Description	The followers of users with a private profile are only visible to their
+//	@Description	followers. Pending follow requests are only visible to the user. Users with a
+//	@Description	hidden profile are left out of the accepted follows.
+//	@Tags			users
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id					path		string				true	"User Id"	Format(UUID)
+//	@Param			filters				query		ListFollowsFilters	false	"Filters"
+//	@Success		200					{array}		models.Follow
+//	@Failure		400,401,403,404,500	{object}	middleware.ApiError
+//	@Router			/users/{id}/followers [get]
+func (c *FollowController) Followers(
+	id string,
+	filters ListFollowsFilters,
+	ctx *gin.Context,
+) ([]models.Follow, error) {
+	return c.listFollows(id, filters, true, ctx)
+}
+
+// Following lists the users a user follows.
+//
+//	@Summary		List the users followed by a user
+//	@Description	The users followed by users with a private profile are only visible to their
+//	@Description	followers. Pending follow requests are only visible to the user. Users with a
+//	@Description	hidden profile are left out of the accepted follows.
+//	@Tags			users
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id					path		string				true	"User Id"	Format(UUID)
+//	@Param			filters				query		ListFollowsFilters	false	"Filters"
+//	@Success		200					{array}		models.Follow
+//	@Failure		400,401,403,404,500	{object}	middleware.ApiError
+//	@Router			/users/{id}/following [get]
+func (c *FollowController) Following(
+	id string,
+	filters ListFollowsFilters,
+	ctx *gin.Context,
+) ([]models.Follow, error) {
+	return c.listFollows(id, filters, false, ctx)
+}
+
+type ApproveFollowerParams struct {
+	UserID uuid.UUID `json:"userId" binding:"required"`
+}
+
+// Approve a follow request.
+//
+//	@Summary	Approve a pending follow request
+//	@Tags		users
+//	@Produce	json
+//	@Security	OIDCToken
+//	@Security	AuthHeader
+//	@Param		id					path		string					true	"User Id"	Format(UUID)
+//	@Param		params				body		ApproveFollowerParams	true	"Params"
+//	@Success	200					{object}	models.Follow
+//	@Failure	400,401,403,404,500	{object}	middleware.ApiError
+//	@Router		/users/{id}/followers [put]
+func (c *FollowController) Approve(
+	id string,
+	params ApproveFollowerParams,
+	ctx *gin.Context,
+) (models.Follow, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return models.Follow{}, err
+	}
+
+	followeeID, err := uuid.Parse(id)
+	if err != nil {
+		return models.Follow{}, httputil.NewError(httputil.BadRequest, err)
+	}
+
+	if ok := c.acl.Authorize(user, "manage-followers", models.User{
+		BaseModel: models.BaseModel{ID: followeeID},
+	}); !ok {
+		return models.Follow{}, httputil.NewErrorMsg(
+			httputil.Forbidden,
+			httputil.ForbiddenMessage,
+		)
+	}
+
+	var follow models.Follow
+	err = c.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Follow{}).
+			Where("follower_id = ?", params.UserID).
+			Where("followee_id = ?", followeeID).
+			Update("status", models.FollowAccepted)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return resourceNotFoundErr("follow request")
+		}
+
+		return tx.First(&follow, "follower_id = ? AND followee_id = ?",
+			params.UserID, followeeID).Error
+	})
+
+	return follow, err
+}
+
+type RemoveFollowerParams struct {
+	UserID string `form:"userId" binding:"required,uuid"`
+}
+
+// RemoveFollower removes a follower or rejects a follow request.
+//
+//	@Summary	Remove a follower or reject a follow request
+//	@Tags		users
+//	@Security	OIDCToken
+//	@Security	AuthHeader
+//	@Param		id		path	string					true	"User Id"	Format(UUID)
+//	@Param		params	query	RemoveFollowerParams	true	"Params"
+//	@Success	204
+//	@Failure	400,401,403,404,500	{object}	middleware.ApiError
+//	@Router		/users/{id}/followers [delete]
+func (c *FollowController) RemoveFollower(
+	id string,
+	params RemoveFollowerParams,
+	ctx *gin.Context,
+) error {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return err
+	}
+
+	followeeID, err := uuid.Parse(id)
+	if err != nil {
+		return httputil.NewError(httputil.BadRequest, err)
+	}
+
+	if ok := c.acl.Authorize(user, "manage-followers", models.User{
+		BaseModel: models.BaseModel{ID: followeeID},
+	}); !ok {
+		return httputil.NewErrorMsg(
+			httputil.Forbidden,
+			httputil.ForbiddenMessage,
+		)
+	}
+
+	res := c.db.Delete(&models.Follow{},
+		"follower_id = ? AND followee_id = ?", params.UserID, followeeID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return resourceNotFoundErr("follower")
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package controllers
+
+import (
+	"testing"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/server/access"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+type FollowControllerTestSuite struct {
+	suite.Suite
+	follows     *FollowController
+	users       *UserController
+	leaderboard *LeaderboardController
+	db          *gorm.DB
+	acl         *access.ACL
+}
+
+// Run each test in a transaction.
+func (s *FollowControllerTestSuite) SetupTest() {
+	tx := testDb.Begin()
+	s.db = tx
+	s.follows = &FollowController{tx, s.acl}
+	s.users = &UserController{tx, s.acl, "", nil}
+	s.leaderboard = &LeaderboardController{tx}
+}
+
+// Rollback the transaction after each test.
+func (s *FollowControllerTestSuite) TearDownTest() {
+	s.db.Rollback()
+}
+
+func (s *FollowControllerTestSuite) TestFollow() {
+	user, ctx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+	other, otherCtx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+
+	// -------------------------- //
+	// Fails to follow themselves //
+	// -------------------------- //
+	_, err = s.follows.Follow(user.ID.String(), ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Bad Request, "+
+		"message: users can't follow themselves"+
+		"}")
+
+	// ------------------------ //
+	// Follows a public profile //
+	// ------------------------ //
+	follow, err := s.follows.Follow(other.ID.String(), ctx)
+	s.Require().NoError(err)
+	s.Equal(models.FollowAccepted, follow.Status)
+
+	// Following again returns the existing follow.
+	again, err := s.follows.Follow(other.ID.String(), ctx)
+	s.Require().NoError(err)
+	s.Equal(follow.CreatedAt.Unix(), again.CreatedAt.Unix())
+
+	followers, err := s.follows.
+		Followers(other.ID.String(), ListFollowsFilters{}, otherCtx)
+	s.Require().NoError(err)
+	s.Require().Len(followers, 1)
+	s.Equal(user.ID, followers[0].Follower.ID)
+	s.Empty(followers[0].Follower.Email)
+
+	following, err := s.follows.
+		Following(user.ID.String(), ListFollowsFilters{}, otherCtx)
+	s.Require().NoError(err)
+	s.Require().Len(following, 1)
+	s.Equal(other.ID, following[0].Followee.ID)
+
+	// ---------------- //
+	// Unfollows a user //
+	// ---------------- //
+	s.Require().NoError(s.follows.Unfollow(other.ID.String(), ctx))
+
+	err = s.follows.Unfollow(other.ID.String(), ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Record Not Found, "+
+		"message: follow not found"+
+		"}")
+}
+
+func (s *FollowControllerTestSuite) TestFollowPrivate() {
+	user, ctx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+	private, privateCtx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+	_, err = s.users.Update(private.ID.String(), UpdateUserParams{
+		Private: &[]bool{true}[0],
+	}, privateCtx)
+	s.Require().NoError(err)
+
+	// ------------------------------------ //
+	// Requests to follow a private profile //
+	// ------------------------------------ //
+	follow, err := s.follows.Follow(private.ID.String(), ctx)
+	s.Require().NoError(err)
+	s.Equal(models.FollowPending, follow.Status)
+
+	// The followers aren't visible until the request is approved.
+	_, err = s.follows.
+		Followers(private.ID.String(), ListFollowsFilters{}, ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Forbidden Action, "+
+		"message: the user does not have access to this operation"+
+		"}")
+
+	// ------------------------------------------------ //
+	// Only the user can see and approve their requests //
+	// ------------------------------------------------ //
+	_, err = s.follows.Followers(private.ID.String(), ListFollowsFilters{
+		Status: models.FollowPending,
+	}, ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Forbidden Action, "+
+		"message: the user does not have access to this operation"+
+		"}")
+
+	_, err = s.follows.Approve(private.ID.String(), ApproveFollowerParams{
+		UserID: user.ID,
+	}, ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Forbidden Action, "+
+		"message: the user does not have access to this operation"+
+		"}")
+
+	requests, err := s.follows.Followers(private.ID.String(), ListFollowsFilters{
+		Status: models.FollowPending,
+	}, privateCtx)
+	s.Require().NoError(err)
+	s.Require().Len(requests, 1)
+	s.Equal(user.ID, requests[0].FollowerID)
+
+	follow, err = s.follows.Approve(private.ID.String(), ApproveFollowerParams{
+		UserID: user.ID,
+	}, privateCtx)
+	s.Require().NoError(err)
+	s.Equal(models.FollowAccepted, follow.Status)
+
+	followers, err := s.follows.
+		Followers(private.ID.String(), ListFollowsFilters{}, ctx)
+	s.Require().NoError(err)
+	s.Len(followers, 1)
+
+	// ------------------ //
+	// Removes a follower //
+	// ------------------ //
+	err = s.follows.RemoveFollower(private.ID.String(), RemoveFollowerParams{
+		UserID: user.ID.String(),
+	}, privateCtx)
+	s.Require().NoError(err)
+
+	err = s.follows.RemoveFollower(private.ID.String(), RemoveFollowerParams{
+		UserID: user.ID.String(),
+	}, privateCtx)
+	s.EqualError(err, "ApiError{"+
+		"code: Record Not Found, "+
+		"message: follower not found"+
+		"}")
+
+	// ------------------------------------------------ //
+	// Accepts pending requests when going public again //
+	// ------------------------------------------------ //
+	_, err = s.follows.Follow(private.ID.String(), ctx)
+	s.Require().NoError(err)
+	_, err = s.users.Update(private.ID.String(), UpdateUserParams{
+		Private: &[]bool{false}[0],
+	}, privateCtx)
+	s.Require().NoError(err)
+
+	followers, err = s.follows.
+		Followers(private.ID.String(), ListFollowsFilters{}, ctx)
+	s.Require().NoError(err)
+	s.Len(followers, 1)
+}
+
+func (s *FollowControllerTestSuite) TestFollowHidden() {
+	user, ctx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+	hidden, hiddenCtx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+	_, err = s.follows.Follow(user.ID.String(), hiddenCtx)
+	s.Require().NoError(err)
+	_, err = s.users.Update(hidden.ID.String(), UpdateUserParams{
+		HiddenProfile: &[]bool{true}[0],
+	}, hiddenCtx)
+	s.Require().NoError(err)
+
+	// Can't follow nor look up hidden profiles.
+	_, err = s.follows.Follow(hidden.ID.String(), ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Record Not Found, "+
+		"message: user not found"+
+		"}")
+	_, err = s.follows.
+		Following(hidden.ID.String(), ListFollowsFilters{}, ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Record Not Found, "+
+		"message: user not found"+
+		"}")
+
+	// Hidden followers aren't listed.
+	followers, err := s.follows.
+		Followers(user.ID.String(), ListFollowsFilters{}, ctx)
+	s.Require().NoError(err)
+	s.Empty(followers)
+
+	// But the user and the admins still see them.
+	following, err := s.follows.
+		Following(hidden.ID.String(), ListFollowsFilters{}, hiddenCtx)
+	s.Require().NoError(err)
+	s.Len(following, 1)
+
+	_, adminCtx, err := createRandomAdmin(s.users)
+	s.Require().NoError(err)
+	followers, err = s.follows.
+		Followers(user.ID.String(), ListFollowsFilters{}, adminCtx)
+	s.Require().NoError(err)
+	s.Require().Len(followers, 1)
+	s.Equal(hidden.ID, followers[0].Follower.ID)
+}
+
+func (s *FollowControllerTestSuite) TestFriendsLeaderboard() {
+	users := make([]models.User, 4)
+	for i := range users {
+		var err error
+		users[i], _, err = createRandomUser(s.users)
+		s.Require().NoError(err)
+		s.Require().NoError(s.db.Model(&users[i]).
+			Update("total_dist", float64(10*(i+1))).Error)
+	}
+	_, ctx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+
+	// Follow users 0 and 2, and request to follow 3, which is private.
+	s.Require().NoError(s.db.Model(&users[3]).
+		Update("private", true).Error)
+	for _, i := range []int{0, 2, 3} {
+		_, err := s.follows.Follow(users[i].ID.String(), ctx)
+		s.Require().NoError(err)
+	}
+
+	res, err := s.leaderboard.Friends(LeaderboardFilters{}, ctx)
+	s.Require().NoError(err)
+	s.Require().Len(res.Entries, 3)
+	s.Equal(users[2].ID, res.Entries[0].ID)
+	s.Equal(1, res.Entries[0].Position)
+	s.Equal(users[0].ID, res.Entries[1].ID)
+	s.Equal(3, res.UserPosition)
+}
+
+func TestFollowController(t *testing.T) {
+	acl := access.New()
+	registerAllRules(&FollowController{}, acl)
+	registerAllRules(&UserController{}, acl)
+	suite.Run(t, &FollowControllerTestSuite{acl: acl})
+}
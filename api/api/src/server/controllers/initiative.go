@@ -1,18 +1,28 @@
 package controllers
 
 import (
+	"database/sql"
 	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
 
 	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/database/query"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/database/types"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/util/httputil"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/latlon"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
+var duplicateInitiativeTitleRegex = regexp.MustCompile(
+	"duplicate key value violates unique constraint \"initiatives_title_key\"",
+)
+
 type initiativeImgPresigner interface {
 	institutionLogoPresigner
 	PresignGetInitiativeImg(initiativeID string) (string, string, error)
@@ -30,12 +40,39 @@ type InitiativeController struct {
 func (InitiativeController) Rules() []rule {
 	return []rule{
 		{models.User{}, models.Initiative{},
-			"create,update,change-state,delete", func(ent, _ any) bool {
+			"create,change-state,delete,update-sponsorship", func(ent, _ any) bool {
 				return ent.(models.User).Admin
 			}},
+		// Managers of the initiative's institution can edit it.
 		{models.User{}, models.Initiative{},
-			"update-img,delete-img", func(ent, _ any) bool {
-				return ent.(models.User).Admin
+			"update,update-img,delete-img", func(ent, res any) bool {
+				user := ent.(models.User)
+				return user.Admin || user.HasRole(
+					res.(models.Initiative).InstitutionID,
+					models.InstitutionManager,
+				)
+			},
+		},
+		// Disabled initiatives are only visible to the admins and the members
+		// of the initiative's institution.
+		{models.User{}, models.Initiative{},
+			"get", func(ent, res any) bool {
+				user := ent.(models.User)
+				initiative := res.(models.Initiative)
+				return user.Admin || initiative.Enabled || user.HasRole(
+					initiative.InstitutionID,
+					models.InstitutionViewer,
+				)
+			},
+		},
+		// Any member of the initiative's institution can see its contributors.
+		{models.User{}, models.Initiative{},
+			"list-contributors", func(ent, res any) bool {
+				user := ent.(models.User)
+				return user.Admin || user.HasRole(
+					res.(models.Initiative).InstitutionID,
+					models.InstitutionViewer,
+				)
 			},
 		},
 	}
@@ -46,6 +83,29 @@ type InitiativeWithImage struct {
 	PresignedImgURL      string                 `json:"presignedImageURL,omitempty"`
 	InstitutionWithImage InstitutionWithImage   `json:"institution"`
 	SponsorsWithImage    []InstitutionWithImage `json:"sponsors,omitempty"`
+	// Sponsorships are the terms of the sponsorships, with the remaining
+	// budget of each sponsor. Only included in the initiative's details.
+	Sponsorships []SponsorshipWithBudget `json:"sponsorships,omitempty"`
+}
+
+type SponsorshipWithBudget struct {
+	models.InitiativeSponsor
+	// Remaining is the amount, in euros, the sponsor can still commit. It's
+	// omitted if there's no cap.
+	Remaining *float64 `json:"remaining,omitempty" example:"250"`
+}
+
+func sponsorshipsWithBudget(
+	sponsorships []models.InitiativeSponsor,
+) []SponsorshipWithBudget {
+	res := make([]SponsorshipWithBudget, len(sponsorships))
+	for i, sponsorship := range sponsorships {
+		res[i] = SponsorshipWithBudget{
+			InitiativeSponsor: sponsorship,
+			Remaining:         sponsorship.Remaining(),
+		}
+	}
+	return res
 }
 
 // initiativeWithImage enriches the given initiative with a pre-signed URL to
@@ -84,6 +144,18 @@ type ListInitiativesFilters struct {
 	// IncludeDisabled initiatives in the result. This value is ignored for
 	// non-admin users, which only receive enabled initiatives.
 	IncludeDisabled bool `form:"includeDisabled"`
+	// SDGs filters initiatives associated with any of the given SDG codes.
+	SDGs []int `form:"sdg" binding:"omitempty,dive,min=1,max=17" example:"11"`
+	// InstitutionID filters initiatives of the institution.
+	InstitutionID string `form:"institutionId" binding:"omitempty,uuid"`
+	// SponsorID filters initiatives sponsored by the institution.
+	SponsorID string `form:"sponsorId" binding:"omitempty,uuid"`
+	// Status filters active initiatives, active initiatives that have reached
+	// 80% of the goal, or initiatives that have reached the goal or expired.
+	Status query.InitiativeStatus `form:"status" binding:"omitempty,oneof=active near-goal ended"`
+	// Search terms to match against the title and description. When present,
+	// initiatives are sorted by relevance before the `orderBy` clause.
+	Search string `form:"q" example:"bicicletas escola"`
 }
 
 // List all initiatives.
@@ -107,7 +179,16 @@ func (c *InitiativeController) List(
 		return nil, err
 	}
 
-	tx := query.Initiatives.WithAssociations(c.db)
+	tx := query.Initiatives.Filter(
+		query.Initiatives.WithAssociations(c.db),
+		query.InitiativeFilters{
+			SDGs:          filters.SDGs,
+			InstitutionID: filters.InstitutionID,
+			SponsorID:     filters.SponsorID,
+			Status:        filters.Status,
+			Search:        filters.Search,
+		},
+	)
 
 	if !user.Admin || !filters.IncludeDisabled {
 		tx = tx.Where("enabled = true")
@@ -125,35 +206,72 @@ func (c *InitiativeController) List(
 
 // Get retrieves an initiative.
 //
-//	@Summary	Retrieve an initiative by ID
-//	@Tags		initiatives
-//	@Produce	json
-//	@Security	OIDCToken
-//	@Security	AuthHeader
-//	@Param		id				path		string	true	"Initiative Id"	Format(UUID)
-//	@Success	200				{object}	InitiativeWithImage
-//	@Failure	400,401,404,500	{object}	middleware.ApiError
-//	@Router		/initiatives/{id} [get]
+//	@Summary		Retrieve an initiative by ID
+//	@Description	Includes the terms of the sponsorships and the remaining budget of each sponsor.
+//	@Description	Disabled initiatives are only found by admins and the members of their
+//	@Description	institution.
+//	@Tags			initiatives
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id				path		string	true	"Initiative Id"	Format(UUID)
+//	@Success		200				{object}	InitiativeWithImage
+//	@Failure		400,401,404,500	{object}	middleware.ApiError
+//	@Router			/initiatives/{id} [get]
 func (c *InitiativeController) Get(id string, ctx *gin.Context) (InitiativeWithImage, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return InitiativeWithImage{}, err
+	}
+
 	var initiative models.Initiative
-	err := query.Initiatives.WithAssociations(c.db).
+	err = query.Initiatives.WithAssociations(c.db).
 		First(&initiative, "id = ?", id).Error
 
-	if errors.Is(err, gorm.ErrRecordNotFound) {
+	if errors.Is(err, gorm.ErrRecordNotFound) ||
+		(err == nil && !c.acl.Authorize(user, "get", initiative)) {
 		return InitiativeWithImage{}, resourceNotFoundErr("initiative")
 	}
+	if err != nil {
+		return InitiativeWithImage{}, err
+	}
 
-	return initiativeWithImage(initiative, c.presigner), err
+	sponsorships, err := query.Initiatives.Sponsorships(id, c.db)
+	if err != nil {
+		return InitiativeWithImage{}, err
+	}
+
+	res := initiativeWithImage(initiative, c.presigner)
+	res.Sponsorships = sponsorshipsWithBudget(sponsorships)
+	return res, nil
+}
+
+// checkInstitution returns a not found error if there's no institution with
+// the given ID.
+func checkInstitution(id uuid.UUID, db *gorm.DB) error {
+	var count int64
+	if err := db.Model(&models.Institution{}).
+		Where("id = ?", id).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return resourceNotFoundErr("institution")
+	}
+	return nil
 }
 
 type CreateInitiativeParams struct {
 	Title         string               `json:"title" binding:"required"`
 	Description   string               `json:"description" binding:"required"`
 	Goal          uint32               `json:"goal" binding:"required"`
+	StartDate     *types.Date          `json:"startDate,omitempty" binding:"omitempty,datetime=2006-01-02" example:"2023-03-01"`
 	EndDate       types.Date           `form:"endDate" binding:"required,datetime=2006-01-02" example:"2023-03-30"`
 	InstitutionID uuid.UUID            `json:"institutionId" binding:"required"`
 	Sponsors      []models.Institution `json:"sponsors,omitempty"`
 	SDGs          []models.SDG         `json:"sdgs" binding:"omitempty,dive"`
+	// Area restricts the initiative to a polygon or a circle.
+	Area *latlon.Area `json:"area,omitempty"`
 }
 
 // Create an initiative.
@@ -164,8 +282,8 @@ type CreateInitiativeParams struct {
 //	@Security	OIDCToken
 //	@Security	AuthHeader
 //	@Param		params		body		CreateInitiativeParams	true	"Params"
-//	@Success	201			{object}	models.Initiative
-//	@Failure	400,401,500	{object}	middleware.ApiError
+//	@Success	201				{object}	models.Initiative
+//	@Failure	400,401,404,500	{object}	middleware.ApiError
 //	@Router		/initiatives [post]
 func (c *InitiativeController) Create(
 	params CreateInitiativeParams,
@@ -180,10 +298,12 @@ func (c *InitiativeController) Create(
 		Title:         params.Title,
 		Description:   params.Description,
 		Goal:          params.Goal,
+		StartDate:     params.StartDate,
 		EndDate:       params.EndDate,
 		InstitutionID: params.InstitutionID,
 		Sponsors:      params.Sponsors,
 		SDGs:          params.SDGs,
+		Area:          params.Area,
 	}
 
 	if ok := c.acl.Authorize(
@@ -195,8 +315,24 @@ func (c *InitiativeController) Create(
 		)
 	}
 
+	if params.Area != nil {
+		if err := params.Area.Validate(); err != nil {
+			return models.Initiative{}, httputil.NewError(httputil.BadRequest, err)
+		}
+	}
+
+	if err := checkInstitution(params.InstitutionID, c.db); err != nil {
+		return models.Initiative{}, err
+	}
+
 	err = c.db.Create(&initiative).Error
 	if err != nil {
+		if duplicateInitiativeTitleRegex.MatchString(err.Error()) {
+			return models.Initiative{}, httputil.NewErrorMsg(
+				httputil.BadRequest,
+				"an initiative with the given title already exists",
+			)
+		}
 		return models.Initiative{}, err
 	}
 
@@ -204,6 +340,580 @@ func (c *InitiativeController) Create(
 	return res.Initiative, err
 }
 
+type UpdateInitiativeParams struct {
+	Title       *string     `json:"title,omitempty" binding:"omitempty,min=1"`
+	Description *string     `json:"description,omitempty" binding:"omitempty,min=1"`
+	Goal        *uint32     `json:"goal,omitempty" binding:"omitempty,gt=0"`
+	StartDate   *types.Date `json:"startDate,omitempty" binding:"omitempty,datetime=2006-01-02" example:"2023-03-01"`
+	EndDate     *types.Date `json:"endDate,omitempty" binding:"omitempty,datetime=2006-01-02" example:"2023-03-30"`
+
+	InstitutionID *uuid.UUID `json:"institutionId,omitempty"`
+	// Sponsors replaces the initiative's sponsors, when present. An empty
+	// list removes all sponsors. Only admins can change the sponsors.
+	Sponsors *[]models.Institution `json:"sponsors,omitempty"`
+	// SDGs replaces the initiative's SDGs, when present. An empty list
+	// removes all SDGs.
+	SDGs *[]models.SDG `json:"sdgs,omitempty" binding:"omitempty,dive"`
+	// Area replaces the initiative's area, when present. An empty object
+	// removes the restriction.
+	Area *latlon.Area `json:"area,omitempty"`
+}
+
+// Update an initiative.
+//
+//	@Summary		Update an initiative by Id and return it
+//	@Description	Only the given fields are updated.
+//	@Description
+//	@Description	The goal must be greater than the initiative's current credits, and the end date
+//	@Description	can't be in the past. Changes to the goal and dates are recorded, and the state of
+//	@Description	enabled initiatives is updated accordingly. For example, extending the end date of
+//	@Description	an `expired` initiative makes it `active` again.
+//	@Tags			initiatives
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id					path		string					true	"Initiative Id"	Format(UUID)
+//	@Param			params				body		UpdateInitiativeParams	true	"Params"
+//	@Success		200					{object}	models.Initiative
+//	@Failure		400,401,403,404,500	{object}	middleware.ApiError
+//	@Router			/initiatives/{id} [put]
+func (c *InitiativeController) Update(
+	id string,
+	params UpdateInitiativeParams,
+	ctx *gin.Context,
+) (models.Initiative, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return models.Initiative{}, err
+	}
+
+	if _, err := uuid.Parse(id); err != nil {
+		return models.Initiative{},
+			httputil.NewError(httputil.BadRequest, err)
+	}
+
+	if err := c.db.Transaction(func(tx *gorm.DB) error {
+		var initiative models.Initiative
+		if err := tx.Model(&models.Initiative{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&initiative, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return resourceNotFoundErr("initiative")
+			}
+			return err
+		}
+
+		// Managers can only move the initiative to another institution they
+		// manage.
+		authorized := c.acl.Authorize(user, "update", initiative)
+		if authorized && params.InstitutionID != nil {
+			authorized = c.acl.Authorize(user, "update", models.Initiative{
+				InstitutionID: *params.InstitutionID,
+			})
+		}
+		// The sponsors, and the terms of their sponsorships, are managed by
+		// admins.
+		if authorized && params.Sponsors != nil {
+			authorized = c.acl.Authorize(user, "update-sponsorship", initiative)
+		}
+		if !authorized {
+			return httputil.NewErrorMsg(
+				httputil.Forbidden,
+				httputil.ForbiddenMessage,
+			)
+		}
+
+		if params.InstitutionID != nil {
+			if err := checkInstitution(*params.InstitutionID, tx); err != nil {
+				return err
+			}
+		}
+
+		changes, err := applyInitiativeUpdate(&initiative, params, user)
+		if err != nil {
+			return err
+		}
+
+		if initiative.Enabled {
+			if err := initiative.
+				TransitionTo(initiative.StateAt(time.Now())); err != nil {
+				return httputil.NewError(httputil.InvalidStateTransition, err)
+			}
+		}
+
+		if err := tx.Omit(clause.Associations).
+			Save(&initiative).Error; err != nil {
+			if duplicateInitiativeTitleRegex.MatchString(err.Error()) {
+				return httputil.NewErrorMsg(
+					httputil.BadRequest,
+					"an initiative with the given title already exists",
+				)
+			}
+			return err
+		}
+
+		if len(changes) > 0 {
+			if err := tx.Create(&changes).Error; err != nil {
+				return err
+			}
+		}
+
+		if params.Sponsors != nil {
+			if err := tx.Model(&initiative).
+				Association("Sponsors").
+				Replace(*params.Sponsors); err != nil {
+				return err
+			}
+		}
+
+		if params.SDGs != nil {
+			if err := tx.Model(&initiative).
+				Association("SDGs").
+				Replace(*params.SDGs); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return models.Initiative{}, err
+	}
+
+	res, err := c.Get(id, ctx)
+	return res.Initiative, err
+}
+
+// applyInitiativeUpdate sets the given params on the initiative, and returns
+// the audit log entries of the changes to its goal and dates.
+//
+// The new goal and end date are validated against the initiative's current
+// credits and the current date.
+func applyInitiativeUpdate(
+	initiative *models.Initiative,
+	params UpdateInitiativeParams,
+	user models.User,
+) ([]models.InitiativeChange, error) {
+	var changes []models.InitiativeChange
+	record := func(field, oldValue, newValue string) {
+		if oldValue != newValue {
+			changes = append(changes, models.InitiativeChange{
+				InitiativeID: initiative.ID,
+				UserID:       &user.ID,
+				Field:        field,
+				OldValue:     oldValue,
+				NewValue:     newValue,
+			})
+		}
+	}
+
+	if params.Title != nil {
+		initiative.Title = *params.Title
+	}
+	if params.Description != nil {
+		initiative.Description = *params.Description
+	}
+	if params.InstitutionID != nil {
+		initiative.InstitutionID = *params.InstitutionID
+	}
+
+	if params.Area != nil {
+		if len(params.Area.Polygon) == 0 && params.Area.Center == nil {
+			initiative.Area = nil
+		} else if err := params.Area.Validate(); err != nil {
+			return nil, httputil.NewError(httputil.BadRequest, err)
+		} else {
+			initiative.Area = params.Area
+		}
+	}
+
+	if params.Goal != nil {
+		if float64(*params.Goal) <= initiative.Credits {
+			return nil, httputil.NewErrorMsg(
+				httputil.BadRequest,
+				fmt.Sprintf("the goal must be greater than the current credits (%v)",
+					initiative.Credits),
+			)
+		}
+		record("goal",
+			strconv.FormatUint(uint64(initiative.Goal), 10),
+			strconv.FormatUint(uint64(*params.Goal), 10),
+		)
+		initiative.Goal = *params.Goal
+	}
+
+	if params.StartDate != nil {
+		oldValue := ""
+		if initiative.StartDate != nil {
+			oldValue = string(*initiative.StartDate)
+		}
+		record("startDate", oldValue, string(*params.StartDate))
+		initiative.StartDate = params.StartDate
+	}
+
+	if params.EndDate != nil {
+		today := time.Now().Truncate(24 * time.Hour)
+		if *params.EndDate != initiative.EndDate &&
+			params.EndDate.Time().Before(today) {
+			return nil, httputil.NewErrorMsg(
+				httputil.BadRequest,
+				"the end date can't be in the past",
+			)
+		}
+		record("endDate", string(initiative.EndDate), string(*params.EndDate))
+		initiative.EndDate = *params.EndDate
+	}
+
+	if initiative.StartDate != nil &&
+		initiative.StartDate.Time().After(initiative.EndDate.Time()) {
+		return nil, httputil.NewErrorMsg(
+			httputil.BadRequest,
+			"the start date must be before the end date",
+		)
+	}
+
+	return changes, nil
+}
+
+type UpdateSponsorshipParams struct {
+	InstitutionID uuid.UUID `json:"institutionId" binding:"required"`
+	// Multiplier applied to the credits earned by users. With a multiplier of
+	// 2, the sponsor adds one credit for each credit earned.
+	Multiplier float64 `json:"multiplier" binding:"required,gte=1" example:"2"`
+	// Cap is the maximum amount, in euros, the sponsor commits to match.
+	// Omit for no limit.
+	Cap *float64 `json:"cap,omitempty" binding:"omitempty,gt=0" example:"500"`
+	// ValidFrom is the first day in which credits are matched.
+	ValidFrom *types.Date `json:"validFrom,omitempty" binding:"omitempty,datetime=2006-01-02" example:"2023-03-01"`
+	// ValidUntil is the last day in which credits are matched.
+	ValidUntil *types.Date `json:"validUntil,omitempty" binding:"omitempty,datetime=2006-01-02" example:"2023-03-30"`
+}
+
+// UpdateSponsorship sets the terms of a sponsorship of an initiative.
+//
+//	@Summary		Set the terms of a sponsorship of an initiative
+//	@Description	The institution is added to the initiative's sponsors if it isn't one already.
+//	@Description	The credits earned by users for the initiative are matched by the sponsor,
+//	@Description	according to the multiplier, within the validity window and until the value of
+//	@Description	the matched credits reaches the cap. Changing the terms keeps the amount already
+//	@Description	committed by the sponsor.
+//	@Tags			initiatives
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id					path		string					true	"Initiative Id"	Format(UUID)
+//	@Param			params				body		UpdateSponsorshipParams	true	"Params"
+//	@Success		200					{array}		SponsorshipWithBudget
+//	@Failure		400,401,403,404,500	{object}	middleware.ApiError
+//	@Router			/initiatives/{id}/sponsors [put]
+func (c *InitiativeController) UpdateSponsorship(
+	id string,
+	params UpdateSponsorshipParams,
+	ctx *gin.Context,
+) ([]SponsorshipWithBudget, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok := c.acl.Authorize(
+		user, "update-sponsorship", models.Initiative{},
+	); !ok {
+		return nil, httputil.NewErrorMsg(
+			httputil.AdminAccessRequired,
+			httputil.AdminRequiredMessage,
+		)
+	}
+
+	if params.ValidFrom != nil && params.ValidUntil != nil &&
+		params.ValidFrom.Time().After(params.ValidUntil.Time()) {
+		return nil, httputil.NewErrorMsg(
+			httputil.BadRequest,
+			"the start of the validity window must be before its end",
+		)
+	}
+
+	var initiative models.Initiative
+	if err := c.db.First(&initiative, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, resourceNotFoundErr("initiative")
+		}
+		return nil, err
+	}
+
+	var institution models.Institution
+	if err := c.db.
+		First(&institution, "id = ?", params.InstitutionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, resourceNotFoundErr("institution")
+		}
+		return nil, err
+	}
+
+	sponsorship := models.InitiativeSponsor{
+		InitiativeID:  initiative.ID,
+		InstitutionID: institution.ID,
+		Multiplier:    params.Multiplier,
+		Cap:           params.Cap,
+		ValidFrom:     params.ValidFrom,
+		ValidUntil:    params.ValidUntil,
+	}
+	if err := c.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{
+			{Name: "initiative_id"}, {Name: "institution_id"},
+		},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"multiplier", "cap", "valid_from", "valid_until",
+		}),
+	}).Create(&sponsorship).Error; err != nil {
+		return nil, err
+	}
+
+	sponsorships, err := query.Initiatives.Sponsorships(id, c.db)
+	return sponsorshipsWithBudget(sponsorships), err
+}
+
+// ListChanges lists the changes made to an initiative's goal and dates.
+//
+//	@Summary	List the changes made to an initiative's goal and dates
+//	@Tags		initiatives
+//	@Produce	json
+//	@Security	OIDCToken
+//	@Security	AuthHeader
+//	@Param		id					path		string	true	"Initiative Id"	Format(UUID)
+//	@Success	200					{array}		models.InitiativeChange
+//	@Failure	400,401,403,404,500	{object}	middleware.ApiError
+//	@Router		/initiatives/{id}/changes [get]
+func (c *InitiativeController) ListChanges(
+	id string,
+	ctx *gin.Context,
+) ([]models.InitiativeChange, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return nil, err
+	}
+
+	initiative, err := c.find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok := c.acl.Authorize(user, "update", initiative); !ok {
+		return nil, httputil.NewErrorMsg(
+			httputil.Forbidden,
+			httputil.ForbiddenMessage,
+		)
+	}
+
+	var changes []models.InitiativeChange
+	err = c.db.
+		Where("initiative_id = ?", id).
+		Order("created_at DESC").
+		Find(&changes).Error
+
+	return changes, err
+}
+
+type ListContributorsFilters struct {
+	Pagination
+}
+
+// Contributors lists the users who contributed to an initiative.
+//
+//	@Summary		List the contributors of an initiative
+//	@Description	Users are sorted by the amount of credits contributed, computed from the credit
+//	@Description	ledger. Users that chose to be hidden from the leaderboards aren't listed, and
+//	@Description	the identity of anonymous users or users with a hidden profile is omitted.
+//	@Tags			initiatives
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id					path		string					true	"Initiative Id"	Format(UUID)
+//	@Param			filters				query		ListContributorsFilters	false	"Filters"
+//	@Success		200					{array}		query.Contributor
+//	@Failure		400,401,403,500		{object}	middleware.ApiError
+//	@Router			/initiatives/{id}/contributors [get]
+func (c *InitiativeController) Contributors(
+	id string,
+	filters ListContributorsFilters,
+	ctx *gin.Context,
+) ([]query.Contributor, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return nil, err
+	}
+
+	initiative, err := c.find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok := c.acl.Authorize(user, "list-contributors", initiative); !ok {
+		return nil, httputil.NewErrorMsg(
+			httputil.Forbidden,
+			httputil.ForbiddenMessage,
+		)
+	}
+
+	return query.Credits.ContributorsOf(id, user.ID.String(),
+		filters.Limit, filters.Offset, c.db)
+}
+
+// Leaderboard lists the top contributors of an initiative.
+//
+//	@Summary		List the top contributors in the leaderboard of an initiative
+//	@Description	Users are ranked by the credits contributed to the initiative. Besides the top,
+//	@Description	the entries around the user's own position are also returned. Users that chose
+//	@Description	to be hidden from the leaderboards aren't ranked, and the identity of anonymous
+//	@Description	users isn't shown, except to the users themselves. The entries don't include
+//	@Description	the distance.
+//	@Tags			initiatives
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id				path		string			true	"Initiative Id"	Format(UUID)
+//	@Param			filters			query		LeaderboardPage	false	"Filters"
+//	@Success		200				{object}	LeaderboardResult
+//	@Failure		400,401,404,500	{object}	middleware.ApiError
+//	@Router			/initiatives/{id}/leaderboard [get]
+func (c *InitiativeController) Leaderboard(
+	id string,
+	filters LeaderboardPage,
+	ctx *gin.Context,
+) (LeaderboardResult, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return LeaderboardResult{}, err
+	}
+
+	initiative, err := c.find(id)
+	if err != nil {
+		return LeaderboardResult{}, err
+	}
+
+	filters.setDefaults()
+	initiativeID, userID := initiative.ID.String(), user.ID.String()
+	res := LeaderboardResult{}
+
+	err = c.db.Transaction(func(tx *gorm.DB) error {
+		top, err := query.Leaderboard.InitiativeTop(initiativeID, userID,
+			filters.Limit, filters.Offset, tx)
+		if err != nil {
+			return err
+		}
+		res.Entries = top
+
+		userPosition, err := query.Leaderboard.
+			InitiativePositionOf(initiativeID, userID, tx)
+		if err != nil || userPosition == 0 {
+			return err
+		}
+		res.UserPosition = userPosition
+
+		res.Around, err = query.Leaderboard.InitiativeAround(initiativeID,
+			userID, userPosition, filters.Around, tx)
+		return err
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+
+	return res, err
+}
+
+// progressRateWindow is the period used to compute the recent credit rate of
+// an initiative.
+const progressRateWindow = 14 * 24 * time.Hour
+
+type InitiativeProgressFilters struct {
+	// DateFrom filters snapshots taken on or after this date.
+	DateFrom types.Date `form:"dateFrom" binding:"omitempty,datetime=2006-01-02" example:"2023-03-01"`
+	// DateTo filters snapshots taken on or before this date.
+	DateTo types.Date `form:"dateTo" binding:"omitempty,datetime=2006-01-02" example:"2023-03-30"`
+}
+
+type InitiativeProgress struct {
+	Snapshots []models.InitiativeSnapshot `json:"snapshots"`
+	// Credits is the current credit score.
+	Credits float64 `json:"credits"`
+	// Rate is the average number of credits per day received in the last 14
+	// days.
+	Rate float64 `json:"rate"`
+	// ProjectedCompletion is the date at which the goal is expected to be
+	// reached at the current rate. It's omitted if the goal won't be reached.
+	ProjectedCompletion *types.Date `json:"projectedCompletion,omitempty" example:"2023-06-15"`
+	// OnTrack indicates whether the goal is expected to be reached before the
+	// end date.
+	OnTrack bool `json:"onTrack"`
+}
+
+// Progress retrieves the progress history of an initiative.
+//
+//	@Summary		Retrieve the progress history of an initiative
+//	@Description	Includes the daily snapshots of the initiative's credits and contributors, and
+//	@Description	the projected date of completion of the goal, based on the credits received in
+//	@Description	the last 14 days. Disabled initiatives are only found by admins and the members
+//	@Description	of their institution.
+//	@Tags			initiatives
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id				path		string						true	"Initiative Id"	Format(UUID)
+//	@Param			filters			query		InitiativeProgressFilters	false	"Filters"
+//	@Success		200				{object}	InitiativeProgress
+//	@Failure		400,401,404,500	{object}	middleware.ApiError
+//	@Router			/initiatives/{id}/progress [get]
+func (c *InitiativeController) Progress(
+	id string,
+	filters InitiativeProgressFilters,
+	ctx *gin.Context,
+) (InitiativeProgress, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return InitiativeProgress{}, err
+	}
+
+	var initiative models.Initiative
+	err = c.db.First(&initiative, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) ||
+		(err == nil && !c.acl.Authorize(user, "get", initiative)) {
+		return InitiativeProgress{}, resourceNotFoundErr("initiative")
+	}
+	if err != nil {
+		return InitiativeProgress{}, err
+	}
+
+	now := time.Now()
+	recent, err := query.Initiatives.Snapshots(
+		id,
+		types.Date(now.Add(-progressRateWindow).Format(types.DateFormat)),
+		"",
+		c.db,
+	)
+	if err != nil {
+		return InitiativeProgress{}, err
+	}
+
+	snapshots, err := query.Initiatives.
+		Snapshots(id, filters.DateFrom, filters.DateTo, c.db)
+	if err != nil {
+		return InitiativeProgress{}, err
+	}
+
+	rate := query.Initiatives.
+		CreditRate(initiative, recent, progressRateWindow, now)
+	completion := query.Initiatives.ProjectCompletion(initiative, rate, now)
+
+	return InitiativeProgress{
+		Snapshots:           snapshots,
+		Credits:             initiative.Credits,
+		Rate:                rate,
+		ProjectedCompletion: completion,
+		OnTrack: completion != nil &&
+			!completion.Time().After(initiative.EndDate.Time()),
+	}, nil
+}
+
+// setInitiativeState enables or disables an initiative.
+//
+// Enabled initiatives are moved to the state they should be in according to
+// their dates and credits, and disabled initiatives are archived.
 func (c *InitiativeController) setInitiativeState(
 	id string,
 	enabled bool,
@@ -222,7 +932,6 @@ func (c *InitiativeController) setInitiativeState(
 
 	initiative := models.Initiative{
 		BaseModel: models.BaseModel{ID: uid},
-		Enabled:   enabled,
 	}
 
 	if ok := c.acl.Authorize(
@@ -234,24 +943,43 @@ func (c *InitiativeController) setInitiativeState(
 		)
 	}
 
-	result := c.db.
-		Clauses(clause.Returning{}).
-		Where("id = ?", id).
-		Select("enabled").
-		Updates(&initiative)
+	err = c.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Initiative{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&initiative, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return resourceNotFoundErr("initiative")
+			}
+			return err
+		}
 
-	if result.RowsAffected == 0 {
-		return models.Initiative{},
-			resourceNotFoundErr("initiative")
-	}
+		if initiative.Enabled == enabled {
+			return nil
+		}
+
+		state := models.InitiativeArchived
+		if enabled {
+			state = initiative.StateAt(time.Now())
+		}
+
+		if err := initiative.TransitionTo(state); err != nil {
+			return httputil.NewError(httputil.InvalidStateTransition, err)
+		}
 
-	return initiative, result.Error
+		return tx.Model(&initiative).
+			Select("state", "enabled").
+			Updates(&initiative).Error
+	})
+
+	return initiative, err
 }
 
 // Enable an initiative.
 //
-//	@Summary	Enable an initiative
-//	@Tags		initiatives
+//	@Summary		Enable an initiative
+//	@Description	The initiative is moved to the `scheduled`, `active`, `goal-reached` or `expired`
+//	@Description	state, according to its dates and credits.
+//	@Tags			initiatives
 //	@Produce	json
 //	@Security	OIDCToken
 //	@Security	AuthHeader
@@ -268,8 +996,9 @@ func (c *InitiativeController) Enable(
 
 // Disable an initiative.
 //
-//	@Summary	Disable an initiative
-//	@Tags		initiatives
+//	@Summary		Disable an initiative
+//	@Description	Enabled initiatives are moved to the `archived` state.
+//	@Tags			initiatives
 //	@Produce	json
 //	@Security	OIDCToken
 //	@Security	AuthHeader
@@ -284,6 +1013,19 @@ func (c *InitiativeController) Disable(
 	return c.setInitiativeState(id, false, ctx)
 }
 
+// find retrieves the initiative with the given ID, to authorize operations
+// that depend on its institution.
+func (c *InitiativeController) find(id string) (models.Initiative, error) {
+	var initiative models.Initiative
+	if err := c.db.First(&initiative, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return initiative, resourceNotFoundErr("initiative")
+		}
+		return initiative, err
+	}
+	return initiative, nil
+}
+
 // GetImageURL generates a pre-signed url to retrieve the initiatives's banner image.
 //
 //	@Summary		Generate a pre-signed url to retrieve the initiative's banner image
@@ -325,9 +1067,12 @@ func (c *InitiativeController) PutImageURL(
 		return PresignedResponse{}, err
 	}
 
-	if ok := c.acl.Authorize(
-		user, "update-img", models.Initiative{},
-	); !ok {
+	initiative, err := c.find(id)
+	if err != nil {
+		return PresignedResponse{}, err
+	}
+
+	if ok := c.acl.Authorize(user, "update-img", initiative); !ok {
 		return PresignedResponse{}, httputil.NewErrorMsg(
 			httputil.Forbidden,
 			httputil.ForbiddenMessage,
@@ -358,9 +1103,12 @@ func (c *InitiativeController) DeleteImageURL(
 		return PresignedResponse{}, err
 	}
 
-	if ok := c.acl.Authorize(
-		user, "delete-img", models.Initiative{},
-	); !ok {
+	initiative, err := c.find(id)
+	if err != nil {
+		return PresignedResponse{}, err
+	}
+
+	if ok := c.acl.Authorize(user, "delete-img", initiative); !ok {
 		return PresignedResponse{}, httputil.NewErrorMsg(
 			httputil.Forbidden,
 			httputil.ForbiddenMessage,
@@ -5,6 +5,7 @@ import (
 
 	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/server/access"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -12,6 +13,15 @@ func TestInitiativeAcl(t *testing.T) {
 	acl := access.New()
 	registerAllRules(&InitiativeController{}, acl)
 
+	institution := uuid.New()
+	initiative := models.Initiative{InstitutionID: institution}
+	manager := models.User{Memberships: []models.InstitutionMembership{
+		{InstitutionID: institution, Role: models.InstitutionManager},
+	}}
+	viewer := models.User{Memberships: []models.InstitutionMembership{
+		{InstitutionID: institution, Role: models.InstitutionViewer},
+	}}
+
 	testcases := []struct {
 		ent, res any
 		action   string
@@ -65,6 +75,18 @@ func TestInitiativeAcl(t *testing.T) {
 			action: "delete",
 			exp:    false,
 		},
+		{
+			ent:    models.User{Admin: true},
+			res:    models.Initiative{},
+			action: "list-contributors",
+			exp:    true,
+		},
+		{
+			ent:    models.User{Admin: false},
+			res:    models.Initiative{},
+			action: "list-contributors",
+			exp:    false,
+		},
 		{
 			ent:    models.User{Admin: true},
 			res:    models.Initiative{},
@@ -89,6 +111,150 @@ func TestInitiativeAcl(t *testing.T) {
 			action: "delete-img",
 			exp:    false,
 		},
+		{
+			ent:    models.User{Admin: true},
+			res:    models.Initiative{},
+			action: "update-sponsorship",
+			exp:    true,
+		},
+		{
+			ent:    models.User{Admin: false},
+			res:    models.Initiative{},
+			action: "update-sponsorship",
+			exp:    false,
+		},
+		{
+			ent:    manager,
+			res:    initiative,
+			action: "update",
+			exp:    true,
+		},
+		{
+			ent:    viewer,
+			res:    initiative,
+			action: "update",
+			exp:    false,
+		},
+		{
+			ent:    manager,
+			res:    initiative,
+			action: "update-img",
+			exp:    true,
+		},
+		{
+			ent:    viewer,
+			res:    initiative,
+			action: "update-img",
+			exp:    false,
+		},
+		{
+			ent:    manager,
+			res:    initiative,
+			action: "delete-img",
+			exp:    true,
+		},
+		{
+			ent:    viewer,
+			res:    initiative,
+			action: "delete-img",
+			exp:    false,
+		},
+		{
+			ent:    manager,
+			res:    initiative,
+			action: "list-contributors",
+			exp:    true,
+		},
+		{
+			ent:    viewer,
+			res:    initiative,
+			action: "list-contributors",
+			exp:    true,
+		},
+		{
+			ent:    manager,
+			res:    initiative,
+			action: "create",
+			exp:    false,
+		},
+		{
+			ent:    viewer,
+			res:    initiative,
+			action: "create",
+			exp:    false,
+		},
+		{
+			ent:    manager,
+			res:    initiative,
+			action: "change-state",
+			exp:    false,
+		},
+		{
+			ent:    viewer,
+			res:    initiative,
+			action: "change-state",
+			exp:    false,
+		},
+		{
+			ent:    manager,
+			res:    initiative,
+			action: "delete",
+			exp:    false,
+		},
+		{
+			ent:    viewer,
+			res:    initiative,
+			action: "delete",
+			exp:    false,
+		},
+		{
+			ent:    manager,
+			res:    initiative,
+			action: "update-sponsorship",
+			exp:    false,
+		},
+		{
+			ent:    viewer,
+			res:    initiative,
+			action: "update-sponsorship",
+			exp:    false,
+		},
+		{
+			ent:    models.User{Admin: true},
+			res:    models.Initiative{},
+			action: "get",
+			exp:    true,
+		},
+		{
+			ent:    models.User{Admin: false},
+			res:    models.Initiative{},
+			action: "get",
+			exp:    false,
+		},
+		{
+			ent:    models.User{Admin: false},
+			res:    models.Initiative{Enabled: true},
+			action: "get",
+			exp:    true,
+		},
+		{
+			ent:    viewer,
+			res:    initiative,
+			action: "get",
+			exp:    true,
+		},
+		{
+			ent:    manager,
+			res:    models.Initiative{InstitutionID: uuid.New()},
+			action: "update",
+			exp:    false,
+		},
+		{
+			ent:    viewer,
+			res:    models.Initiative{InstitutionID: uuid.New()},
+			action: "list-contributors",
+			exp:    false,
+		},
 	}
 
 	for i, tc := range testcases {
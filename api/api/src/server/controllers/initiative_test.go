@@ -4,8 +4,12 @@ import (
 	"testing"
 
 	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/query"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/types"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/server/access"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/util/random"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 	"gorm.io/gorm"
@@ -126,6 +130,113 @@ func (s *InitiativeControllerTestSuite) TestListInitiatives() {
 	s.Equal("pre-signed url 1", result[1].PresignedImgURL)
 }
 
+func (s *InitiativeControllerTestSuite) TestListInitiativesFilters() {
+	err := s.db.Exec("delete from initiatives").Error
+	s.Require().NoError(err)
+
+	sponsor := models.Institution{Name: random.AlphanumericString(20)}
+	err = s.db.Create(&sponsor).Error
+	s.Require().NoError(err)
+
+	initiatives := []models.Initiative{
+		{
+			Title:       "Bicicletas para a escola",
+			Description: "Oferecer bicicletas aos alunos da escola.",
+			Goal:        1000,
+			Credits:     900,
+			EndDate:     "2050-01-01",
+			State:       models.InitiativeActive,
+			Enabled:     true,
+			Institution: models.Institution{
+				Name: random.AlphanumericString(20),
+			},
+			Sponsors: []models.Institution{sponsor},
+			SDGs:     []models.SDG{{Code: 4}},
+		},
+		{
+			Title:       "Árvores no bairro",
+			Description: "Plantar árvores nas ruas do bairro.",
+			Goal:        1000,
+			Credits:     100,
+			EndDate:     "2050-01-01",
+			State:       models.InitiativeActive,
+			Enabled:     true,
+			Institution: models.Institution{
+				Name: random.AlphanumericString(20),
+			},
+			SDGs: []models.SDG{{Code: 11}, {Code: 15}},
+		},
+		{
+			Title:       "Ciclovia segura",
+			Description: "Melhorar a segurança das ciclovias.",
+			Goal:        1000,
+			Credits:     1000,
+			EndDate:     "2050-01-01",
+			State:       models.InitiativeGoalReached,
+			Enabled:     true,
+			Institution: models.Institution{
+				Name: random.AlphanumericString(20),
+			},
+			SDGs: []models.SDG{{Code: 11}},
+		},
+	}
+	err = s.db.Create(&initiatives).Error
+	s.Require().NoError(err)
+
+	s.presigner.On("PresignGetInitiativeImg", mock.Anything).Return(
+		"pre-signed url", "GET", nil,
+	)
+	s.presigner.On("PresignGetInstitutionLogo", mock.Anything).Return(
+		"pre-signed url", "GET", nil,
+	)
+
+	_, ctx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+
+	for i, tc := range []struct {
+		filters ListInitiativesFilters
+		exp     []string
+	}{
+		{ListInitiativesFilters{SDGs: []int{11}}, []string{
+			initiatives[1].Title, initiatives[2].Title,
+		}},
+		{ListInitiativesFilters{SDGs: []int{4, 15}}, []string{
+			initiatives[0].Title, initiatives[1].Title,
+		}},
+		{ListInitiativesFilters{
+			InstitutionID: initiatives[1].InstitutionID.String(),
+		}, []string{initiatives[1].Title}},
+		{ListInitiativesFilters{
+			SponsorID: sponsor.ID.String(),
+		}, []string{initiatives[0].Title}},
+		{ListInitiativesFilters{Status: query.InitiativeStatusActive}, []string{
+			initiatives[0].Title, initiatives[1].Title,
+		}},
+		{ListInitiativesFilters{Status: query.InitiativeStatusNearGoal}, []string{
+			initiatives[0].Title,
+		}},
+		{ListInitiativesFilters{Status: query.InitiativeStatusEnded}, []string{
+			initiatives[2].Title,
+		}},
+		{ListInitiativesFilters{Search: "bicicleta"}, []string{
+			initiatives[0].Title,
+		}},
+		{ListInitiativesFilters{Search: "árvore"}, []string{
+			initiatives[1].Title,
+		}},
+	} {
+		tc.filters.Sort = Sort{"title asc"}
+		result, err := s.initiatives.List(tc.filters, ctx)
+		s.Require().NoError(err, "failed test case %d", i)
+
+		var titles []string
+		for _, initiative := range result {
+			titles = append(titles, initiative.Title)
+		}
+		s.ElementsMatch(tc.exp, titles, "failed test case %d", i)
+	}
+}
+
 func (s *InitiativeControllerTestSuite) TestCreateInitiative() {
 	institution := models.Institution{
 		Name:        random.String(20),
@@ -161,6 +272,14 @@ func (s *InitiativeControllerTestSuite) TestCreateInitiative() {
 	_, ctx, err = createRandomAdmin(s.users)
 	s.Require().NoError(err)
 
+	unknown := params
+	unknown.InstitutionID = uuid.New()
+	_, err = s.initiatives.Create(unknown, ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Record Not Found, "+
+		"message: institution not found"+
+		"}")
+
 	s.presigner.On("PresignGetInitiativeImg", mock.AnythingOfType("string")).Return(
 		"pre-signed-url", "GET", nil,
 	)
@@ -235,8 +354,312 @@ func (s *InitiativeControllerTestSuite) TestEnableInitiative() {
 	s.False(dbInitiative2.Enabled)
 }
 
+func (s *InitiativeControllerTestSuite) TestGetDisabledInitiative() {
+	initiative := models.Initiative{
+		Title:       random.String(20),
+		Description: random.String(50),
+		Goal:        7_000,
+		EndDate:     "2050-01-01",
+		Institution: models.Institution{
+			Name: random.AlphanumericString(20),
+		},
+	}
+	err := s.db.Create(&initiative).Error
+	s.Require().NoError(err)
+
+	// ----------------------- //
+	// Fails for regular users //
+	// ----------------------- //
+	_, ctx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+
+	_, err = s.initiatives.Get(initiative.ID.String(), ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Record Not Found, "+
+		"message: initiative not found"+
+		"}")
+
+	_, err = s.initiatives.Progress(
+		initiative.ID.String(),
+		InitiativeProgressFilters{},
+		ctx,
+	)
+	s.EqualError(err, "ApiError{"+
+		"code: Record Not Found, "+
+		"message: initiative not found"+
+		"}")
+
+	// -------------------------------------- //
+	// Succeeds for the institution's members //
+	// -------------------------------------- //
+	member, ctx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+
+	err = s.db.Create(&models.InstitutionMembership{
+		UserID:        member.ID,
+		InstitutionID: initiative.InstitutionID,
+		Role:          models.InstitutionViewer,
+	}).Error
+	s.Require().NoError(err)
+
+	result, err := s.initiatives.Get(initiative.ID.String(), ctx)
+	s.NoError(err)
+	s.Equal(initiative.ID, result.ID)
+
+	_, err = s.initiatives.Progress(
+		initiative.ID.String(),
+		InitiativeProgressFilters{},
+		ctx,
+	)
+	s.NoError(err)
+
+	// ------------------- //
+	// Succeeds for admins //
+	// ------------------- //
+	_, ctx, err = createRandomAdmin(s.users)
+	s.Require().NoError(err)
+
+	result, err = s.initiatives.Get(initiative.ID.String(), ctx)
+	s.NoError(err)
+	s.Equal(initiative.ID, result.ID)
+
+	_, err = s.initiatives.Progress(
+		initiative.ID.String(),
+		InitiativeProgressFilters{},
+		ctx,
+	)
+	s.NoError(err)
+}
+
 func (s *InitiativeControllerTestSuite) TestUpdateInitiative() {
-	// TODO
+	initiative := models.Initiative{
+		Title:       random.String(50),
+		Description: random.String(50),
+		Goal:        100,
+		Credits:     120,
+		EndDate:     "2050-01-01",
+		State:       models.InitiativeGoalReached,
+		Enabled:     true,
+		Institution: models.Institution{
+			Name: random.AlphanumericString(20),
+		},
+	}
+	err := s.db.Create(&initiative).Error
+	s.Require().NoError(err)
+
+	s.presigner.On("PresignGetInitiativeImg", initiative.ID.String()).Return(
+		"pre-signed url 0", "GET", nil,
+	)
+	s.presigner.On("PresignGetInstitutionLogo", initiative.Institution.ID.String()).Return(
+		"pre-signed url 1", "GET", nil,
+	)
+
+	title := random.String(50)
+	goal := uint32(200)
+	params := UpdateInitiativeParams{
+		Title: &title,
+		Goal:  &goal,
+		SDGs:  &[]models.SDG{{Code: 3}, {Code: 11}},
+	}
+
+	// ----------------------- //
+	// Fails for regular users //
+	// ----------------------- //
+	user, ctx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+
+	_, err = s.initiatives.Update(initiative.ID.String(), params, ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Forbidden Action, "+
+		"message: the user does not have access to this operation"+
+		"}")
+
+	// ------------------------------------ //
+	// Fails for viewers of the institution //
+	// ------------------------------------ //
+	membership := models.InstitutionMembership{
+		InstitutionID: initiative.InstitutionID,
+		UserID:        user.ID,
+		Role:          models.InstitutionViewer,
+	}
+	s.Require().NoError(s.db.Create(&membership).Error)
+
+	_, err = s.initiatives.Update(initiative.ID.String(), params, ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Forbidden Action, "+
+		"message: the user does not have access to this operation"+
+		"}")
+
+	// ---------------------------------------------------------------- //
+	// Fails for managers moving it to an institution they don't manage //
+	// ---------------------------------------------------------------- //
+	s.Require().NoError(s.db.Model(&membership).
+		Update("role", models.InstitutionManager).Error)
+
+	other := models.Institution{Name: random.AlphanumericString(20)}
+	s.Require().NoError(s.db.Create(&other).Error)
+
+	_, err = s.initiatives.Update(initiative.ID.String(), UpdateInitiativeParams{
+		InstitutionID: &other.ID,
+	}, ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Forbidden Action, "+
+		"message: the user does not have access to this operation"+
+		"}")
+
+	// ------------------------------------- //
+	// Fails for managers replacing sponsors //
+	// ------------------------------------- //
+	_, err = s.initiatives.Update(initiative.ID.String(), UpdateInitiativeParams{
+		Sponsors: &[]models.Institution{other},
+	}, ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Forbidden Action, "+
+		"message: the user does not have access to this operation"+
+		"}")
+
+	// ---------------------------------------- //
+	// Succeeds for managers of the institution //
+	// ---------------------------------------- //
+	description := random.String(50)
+	result, err := s.initiatives.Update(initiative.ID.String(), UpdateInitiativeParams{
+		Description: &description,
+	}, ctx)
+	s.Require().NoError(err)
+	s.Equal(description, result.Description)
+	initiative.Description = description
+
+	// ------------------------------------------- //
+	// Fails if the goal is below the credit score //
+	// ------------------------------------------- //
+	admin, ctx, err := createRandomAdmin(s.users)
+	s.Require().NoError(err)
+
+	lowGoal := uint32(50)
+	_, err = s.initiatives.Update(initiative.ID.String(), UpdateInitiativeParams{
+		Goal: &lowGoal,
+	}, ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Bad Request, "+
+		"message: the goal must be greater than the current credits (120)"+
+		"}")
+
+	pastDate := types.Date("2000-01-01")
+	_, err = s.initiatives.Update(initiative.ID.String(), UpdateInitiativeParams{
+		EndDate: &pastDate,
+	}, ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Bad Request, "+
+		"message: the end date can't be in the past"+
+		"}")
+
+	// ------------------- //
+	// Succeeds for admins //
+	// ------------------- //
+	result, err = s.initiatives.Update(initiative.ID.String(), params, ctx)
+	s.Require().NoError(err)
+	s.Equal(title, result.Title)
+	s.Equal(initiative.Description, result.Description)
+	s.Equal(goal, result.Goal)
+	s.Equal(models.InitiativeActive, result.State)
+	s.Len(result.SDGs, 2)
+
+	changes, err := s.initiatives.ListChanges(initiative.ID.String(), ctx)
+	s.Require().NoError(err)
+	s.Require().Len(changes, 1)
+	s.Equal("goal", changes[0].Field)
+	s.Equal("100", changes[0].OldValue)
+	s.Equal("200", changes[0].NewValue)
+	s.Equal(admin.ID, *changes[0].UserID)
+
+	// --------------------------- //
+	// Fails if the title is taken //
+	// --------------------------- //
+	taken := models.Initiative{
+		Title:         random.String(50),
+		Description:   random.String(50),
+		Goal:          100,
+		EndDate:       "2050-01-01",
+		InstitutionID: initiative.InstitutionID,
+	}
+	s.Require().NoError(s.db.Create(&taken).Error)
+
+	_, err = s.initiatives.Update(initiative.ID.String(), UpdateInitiativeParams{
+		Title: &taken.Title,
+	}, ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Bad Request, "+
+		"message: an initiative with the given title already exists"+
+		"}")
+}
+
+func (s *InitiativeControllerTestSuite) TestUpdateSponsorship() {
+	initiative := models.Initiative{
+		Title:       random.String(50),
+		Description: random.String(50),
+		Goal:        100,
+		EndDate:     "2050-01-01",
+		Institution: models.Institution{
+			Name: random.AlphanumericString(20),
+		},
+	}
+	err := s.db.Create(&initiative).Error
+	s.Require().NoError(err)
+
+	sponsor := models.Institution{Name: random.AlphanumericString(20)}
+	err = s.db.Create(&sponsor).Error
+	s.Require().NoError(err)
+
+	cap := 500.0
+	params := UpdateSponsorshipParams{
+		InstitutionID: sponsor.ID,
+		Multiplier:    2,
+		Cap:           &cap,
+	}
+
+	// ----------------------- //
+	// Fails for regular users //
+	// ----------------------- //
+	_, ctx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+
+	_, err = s.initiatives.UpdateSponsorship(initiative.ID.String(), params, ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Admin Access Required, "+
+		"message: the user must be an administrator to perform this action"+
+		"}")
+
+	// ------------------- //
+	// Succeeds for admins //
+	// ------------------- //
+	_, ctx, err = createRandomAdmin(s.users)
+	s.Require().NoError(err)
+
+	result, err := s.initiatives.UpdateSponsorship(initiative.ID.String(), params, ctx)
+	s.Require().NoError(err)
+	s.Require().Len(result, 1)
+	s.Equal(sponsor.ID, result[0].InstitutionID)
+	s.Equal(2.0, result[0].Multiplier)
+	s.Equal(500.0, *result[0].Remaining)
+
+	// Changing the terms keeps the committed amount.
+	err = s.db.Model(&models.InitiativeSponsor{}).
+		Where("initiative_id = ?", initiative.ID).
+		Update("committed", 100).Error
+	s.Require().NoError(err)
+
+	result, err = s.initiatives.UpdateSponsorship(initiative.ID.String(),
+		UpdateSponsorshipParams{InstitutionID: sponsor.ID, Multiplier: 3}, ctx)
+	s.Require().NoError(err)
+	s.Require().Len(result, 1)
+	s.Equal(3.0, result[0].Multiplier)
+	s.Equal(100.0, result[0].Committed)
+	s.Nil(result[0].Remaining)
+
+	var dbInitiative models.Initiative
+	err = s.db.Preload("Sponsors").First(&dbInitiative, "id = ?", initiative.ID).Error
+	s.Require().NoError(err)
+	s.Len(dbInitiative.Sponsors, 1)
 }
 
 func (s *InitiativeControllerTestSuite) TestDeleteInitiative() {
@@ -280,6 +703,90 @@ func (s *InitiativeControllerTestSuite) TestDeleteInitiative() {
 		"}")
 }
 
+func (s *InitiativeControllerTestSuite) TestLeaderboard() {
+	initiative := models.Initiative{
+		Title:       random.String(20),
+		Description: random.String(50),
+		Goal:        7_000,
+		EndDate:     "2050-01-01",
+		Institution: models.Institution{
+			Name: random.AlphanumericString(20),
+		},
+	}
+	s.Require().NoError(s.db.Create(&initiative).Error)
+
+	users := make([]models.User, 3)
+	ctxs := make([]*gin.Context, 3)
+	for i := range users {
+		var err error
+		users[i], ctxs[i], err = createRandomUser(s.users)
+		s.Require().NoError(err)
+	}
+	_, ctx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+
+	for _, entry := range []models.CreditTransaction{
+		{Amount: 10, UserID: users[0].ID, InitiativeID: &initiative.ID},
+		{Amount: 25, UserID: users[1].ID, InitiativeID: &initiative.ID},
+		{Amount: 20, UserID: users[0].ID, InitiativeID: &initiative.ID},
+		{Amount: 5, UserID: users[2].ID, InitiativeID: &initiative.ID},
+		{Amount: 100, UserID: users[2].ID},
+	} {
+		entry.Source = models.CreditSourceTrip
+		s.Require().NoError(query.Credits.Record(&entry, s.db))
+	}
+
+	// -------------------------------------- //
+	// Ranks the users by credits contributed //
+	// -------------------------------------- //
+	res, err := s.initiatives.Leaderboard(initiative.ID.String(),
+		LeaderboardPage{Around: 1}, ctxs[2])
+	s.Require().NoError(err)
+	s.Require().Len(res.Entries, 3)
+	s.Equal(users[0].ID, res.Entries[0].ID)
+	s.Equal(30.0, res.Entries[0].Credits)
+	s.Equal(users[1].ID, res.Entries[1].ID)
+	s.Equal(users[2].ID, res.Entries[2].ID)
+	s.Equal(5.0, res.Entries[2].Credits)
+	s.Equal(3, res.UserPosition)
+	s.Len(res.Around, 2)
+
+	// ----------------------------------------- //
+	// Users who didn't contribute aren't ranked //
+	// ----------------------------------------- //
+	res, err = s.initiatives.Leaderboard(initiative.ID.String(),
+		LeaderboardPage{}, ctx)
+	s.Require().NoError(err)
+	s.Zero(res.UserPosition)
+	s.Empty(res.Around)
+
+	// -------------------------------------------- //
+	// Users can opt out of appearing by their name //
+	// -------------------------------------------- //
+	_, err = s.users.Update(users[0].ID.String(), UpdateUserParams{
+		LeaderboardAnonymous: &[]bool{true}[0],
+	}, ctxs[0])
+	s.Require().NoError(err)
+
+	res, err = s.initiatives.Leaderboard(initiative.ID.String(),
+		LeaderboardPage{Limit: 1}, ctx)
+	s.Require().NoError(err)
+	s.Require().Len(res.Entries, 1)
+	s.True(res.Entries[0].Anonymous)
+	s.Empty(res.Entries[0].Name)
+	s.Equal(30.0, res.Entries[0].Credits)
+
+	// ------------------------------------- //
+	// Fails if the initiative doesn't exist //
+	// ------------------------------------- //
+	_, err = s.initiatives.Leaderboard(users[0].ID.String(),
+		LeaderboardPage{}, ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Record Not Found, "+
+		"message: initiative not found"+
+		"}")
+}
+
 func TestInitiativeController(t *testing.T) {
 	acl := access.New()
 	registerAllRules(&InitiativeController{}, acl)
@@ -2,12 +2,14 @@ package controllers
 
 import (
 	"errors"
+	"regexp"
 
 	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/util/httputil"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type institutionLogoPresigner interface {
@@ -16,6 +18,10 @@ type institutionLogoPresigner interface {
 	PresignDeleteInstitutionLogo(institutionID string) (string, string, error)
 }
 
+var duplicateInstitutionNameRegex = regexp.MustCompile(
+	"duplicate key value violates unique constraint \"institutions_name_key\"",
+)
+
 type InstitutionController struct {
 	db        *gorm.DB
 	acl       authorizer
@@ -26,13 +32,19 @@ type InstitutionController struct {
 func (InstitutionController) Rules() []rule {
 	return []rule{
 		{models.User{}, models.Institution{},
-			"create,update,delete", func(ent, _ any) bool {
+			"create,delete", func(ent, _ any) bool {
 				return ent.(models.User).Admin
 			},
 		},
+		// Owners of the institution can edit it and manage its members.
 		{models.User{}, models.Institution{},
-			"update-logo,delete-logo", func(ent, _ any) bool {
-				return ent.(models.User).Admin
+			"update,update-logo,delete-logo,list-members,update-members",
+			func(ent, res any) bool {
+				user := ent.(models.User)
+				return user.Admin || user.HasRole(
+					res.(models.Institution).ID,
+					models.InstitutionOwner,
+				)
 			},
 		},
 	}
@@ -163,6 +175,95 @@ func (c *InstitutionController) Create(
 	return institution, err
 }
 
+type UpdateInstitutionParams struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+}
+
+// Update an institution.
+//
+//	@Summary		Update an institution by Id and return it
+//	@Description	Only the given fields are updated.
+//	@Tags			institutions
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id					path		string					true	"Institution Id"	Format(UUID)
+//	@Param			params				body		UpdateInstitutionParams	true	"Params"
+//	@Success		200					{object}	models.Institution
+//	@Failure		400,401,403,404,500	{object}	middleware.ApiError
+//	@Router			/institutions/{id} [put]
+func (c *InstitutionController) Update(
+	id string,
+	params UpdateInstitutionParams,
+	ctx *gin.Context,
+) (models.Institution, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return models.Institution{}, err
+	}
+
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return models.Institution{},
+			httputil.NewError(httputil.BadRequest, err)
+	}
+
+	institution := models.Institution{
+		BaseModel: models.BaseModel{ID: uid},
+	}
+
+	if ok := c.acl.Authorize(
+		user, "update", institution,
+	); !ok {
+		return models.Institution{}, httputil.NewErrorMsg(
+			httputil.Forbidden,
+			httputil.ForbiddenMessage,
+		)
+	}
+
+	updates := map[string]any{}
+	if params.Name != nil {
+		updates["name"] = *params.Name
+	}
+	if params.Description != nil {
+		updates["description"] = *params.Description
+	}
+
+	if len(updates) > 0 {
+		if err := c.db.Model(&institution).
+			Updates(updates).Error; err != nil {
+			if duplicateInstitutionNameRegex.MatchString(err.Error()) {
+				return models.Institution{}, httputil.NewErrorMsg(
+					httputil.BadRequest,
+					"an institution with the given name already exists",
+				)
+			}
+			return models.Institution{}, err
+		}
+	}
+
+	if err := c.db.First(&institution, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Institution{}, resourceNotFoundErr("institution")
+		}
+		return models.Institution{}, err
+	}
+
+	return institution, nil
+}
+
+// institutionRef returns a reference to the institution with the given ID, to
+// authorize operations that depend on it.
+func institutionRef(id string) (models.Institution, error) {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return models.Institution{},
+			httputil.NewError(httputil.BadRequest, err)
+	}
+	return models.Institution{BaseModel: models.BaseModel{ID: uid}}, nil
+}
+
 // GetLogoURL generates a pre-signed url to retrieve the institution's logo.
 //
 //	@Summary		Generate a pre-signed url to retrieve the institution's logo
@@ -204,9 +305,12 @@ func (c *InstitutionController) PutLogoURL(
 		return PresignedResponse{}, err
 	}
 
-	if ok := c.acl.Authorize(
-		user, "update-logo", models.Institution{},
-	); !ok {
+	institution, err := institutionRef(id)
+	if err != nil {
+		return PresignedResponse{}, err
+	}
+
+	if ok := c.acl.Authorize(user, "update-logo", institution); !ok {
 		return PresignedResponse{}, httputil.NewErrorMsg(
 			httputil.Forbidden,
 			httputil.ForbiddenMessage,
@@ -237,9 +341,12 @@ func (c *InstitutionController) DeleteLogoURL(
 		return PresignedResponse{}, err
 	}
 
-	if ok := c.acl.Authorize(
-		user, "delete-logo", models.Institution{},
-	); !ok {
+	institution, err := institutionRef(id)
+	if err != nil {
+		return PresignedResponse{}, err
+	}
+
+	if ok := c.acl.Authorize(user, "delete-logo", institution); !ok {
 		return PresignedResponse{}, httputil.NewErrorMsg(
 			httputil.Forbidden,
 			httputil.ForbiddenMessage,
@@ -249,6 +356,198 @@ func (c *InstitutionController) DeleteLogoURL(
 	return PresignedResponse{url, method}, err
 }
 
+// institutionMemberColumns are the columns of the users shown in the lists of
+// members of an institution.
+var institutionMemberColumns = []string{
+	"id", "name", "username", "hidden_profile",
+}
+
+type ListMembersFilters struct {
+	Pagination
+}
+
+// ListMembers lists the members of an institution.
+//
+//	@Summary		List the members of an institution
+//	@Description	Members are sorted by the date in which they joined the institution. The
+//	@Description	profiles of users with a hidden profile are omitted.
+//	@Tags			institutions
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id					path		string				true	"Institution Id"	Format(UUID)
+//	@Param			filters				query		ListMembersFilters	false	"Filters"
+//	@Success		200					{array}		models.InstitutionMembership
+//	@Failure		400,401,403,500		{object}	middleware.ApiError
+//	@Router			/institutions/{id}/members [get]
+func (c *InstitutionController) ListMembers(
+	id string,
+	filters ListMembersFilters,
+	ctx *gin.Context,
+) ([]models.InstitutionMembership, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return nil, err
+	}
+
+	institution, err := institutionRef(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok := c.acl.Authorize(user, "list-members", institution); !ok {
+		return nil, httputil.NewErrorMsg(
+			httputil.Forbidden,
+			httputil.ForbiddenMessage,
+		)
+	}
+
+	var members []models.InstitutionMembership
+	err = c.db.
+		Preload("User", func(db *gorm.DB) *gorm.DB {
+			return db.Select(institutionMemberColumns)
+		}).
+		Where("institution_id = ?", id).
+		Limit(filters.Limit).
+		Offset(filters.Offset).
+		Order("created_at, user_id").
+		Find(&members).Error
+	for i := range members {
+		members[i].User = hideProfile(c.acl, user, members[i].User)
+	}
+
+	return members, err
+}
+
+type SetMemberParams struct {
+	UserID uuid.UUID              `json:"userId" binding:"required"`
+	Role   models.InstitutionRole `json:"role" binding:"required,oneof=owner manager viewer" example:"manager"`
+}
+
+// SetMember adds a member to an institution, or changes the role of an
+// existing member.
+//
+//	@Summary		Add a member to an institution or change their role
+//	@Description	Owners can edit the institution and manage its members, managers can also edit
+//	@Description	the institution's initiatives and their images, and viewers can see the
+//	@Description	contributors and reports of the institution's initiatives, as well as the
+//	@Description	institution's sponsor reports. Each role includes the permissions of the
+//	@Description	following ones.
+//	@Tags			institutions
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id					path		string			true	"Institution Id"	Format(UUID)
+//	@Param			params				body		SetMemberParams	true	"Params"
+//	@Success		200					{object}	models.InstitutionMembership
+//	@Failure		400,401,403,404,500	{object}	middleware.ApiError
+//	@Router			/institutions/{id}/members [put]
+func (c *InstitutionController) SetMember(
+	id string,
+	params SetMemberParams,
+	ctx *gin.Context,
+) (models.InstitutionMembership, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return models.InstitutionMembership{}, err
+	}
+
+	institution, err := institutionRef(id)
+	if err != nil {
+		return models.InstitutionMembership{}, err
+	}
+
+	if ok := c.acl.Authorize(user, "update-members", institution); !ok {
+		return models.InstitutionMembership{}, httputil.NewErrorMsg(
+			httputil.Forbidden,
+			httputil.ForbiddenMessage,
+		)
+	}
+
+	if err := c.db.First(&institution, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.InstitutionMembership{},
+				resourceNotFoundErr("institution")
+		}
+		return models.InstitutionMembership{}, err
+	}
+
+	var member models.User
+	if err := c.db.First(&member, "id = ?", params.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.InstitutionMembership{}, resourceNotFoundErr("user")
+		}
+		return models.InstitutionMembership{}, err
+	}
+
+	membership := models.InstitutionMembership{
+		InstitutionID: institution.ID,
+		UserID:        member.ID,
+		Role:          params.Role,
+	}
+	if err := c.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{
+			{Name: "institution_id"}, {Name: "user_id"},
+		},
+		DoUpdates: clause.AssignmentColumns([]string{"role", "updated_at"}),
+	}).Create(&membership).Error; err != nil {
+		return models.InstitutionMembership{}, err
+	}
+
+	err = c.db.
+		Preload("User", func(db *gorm.DB) *gorm.DB {
+			return db.Select(institutionMemberColumns)
+		}).
+		First(&membership, "institution_id = ? AND user_id = ?",
+			institution.ID, member.ID).Error
+	return membership, err
+}
+
+type RemoveMemberParams struct {
+	UserID string `form:"userId" binding:"required,uuid"`
+}
+
+// RemoveMember removes a member from an institution.
+//
+//	@Summary	Remove a member from an institution
+//	@Tags		institutions
+//	@Security	OIDCToken
+//	@Security	AuthHeader
+//	@Param		id		path	string				true	"Institution Id"	Format(UUID)
+//	@Param		params	query	RemoveMemberParams	true	"Params"
+//	@Success	204
+//	@Failure	400,401,403,404,500	{object}	middleware.ApiError
+//	@Router		/institutions/{id}/members [delete]
+func (c *InstitutionController) RemoveMember(
+	id string,
+	params RemoveMemberParams,
+	ctx *gin.Context,
+) error {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return err
+	}
+
+	institution, err := institutionRef(id)
+	if err != nil {
+		return err
+	}
+
+	if ok := c.acl.Authorize(user, "update-members", institution); !ok {
+		return httputil.NewErrorMsg(
+			httputil.Forbidden,
+			httputil.ForbiddenMessage,
+		)
+	}
+
+	result := c.db.Delete(&models.InstitutionMembership{},
+		"institution_id = ? AND user_id = ?", id, params.UserID)
+	if result.RowsAffected == 0 {
+		return resourceNotFoundErr("member")
+	}
+	return result.Error
+}
+
 // Delete a institution.
 //
 //	@Summary	Delete a institution by Id
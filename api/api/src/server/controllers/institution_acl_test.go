@@ -6,6 +6,7 @@ import (
 
 	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/server/access"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -13,6 +14,16 @@ func TestInstitutionACL(t *testing.T) {
 	acl := access.New()
 	registerAllRules(&InstitutionController{}, acl)
 
+	institution := models.Institution{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+	}
+	owner := models.User{Memberships: []models.InstitutionMembership{
+		{InstitutionID: institution.ID, Role: models.InstitutionOwner},
+	}}
+	manager := models.User{Memberships: []models.InstitutionMembership{
+		{InstitutionID: institution.ID, Role: models.InstitutionManager},
+	}}
+
 	testCases := []struct {
 		ent    models.User
 		res    models.Institution
@@ -79,6 +90,120 @@ func TestInstitutionACL(t *testing.T) {
 			action: "delete-logo",
 			exp:    false,
 		},
+		{
+			ent:    models.User{Admin: true},
+			res:    models.Institution{},
+			action: "list-members",
+			exp:    true,
+		},
+		{
+			ent:    models.User{Admin: false},
+			res:    models.Institution{},
+			action: "list-members",
+			exp:    false,
+		},
+		{
+			ent:    models.User{Admin: true},
+			res:    models.Institution{},
+			action: "update-members",
+			exp:    true,
+		},
+		{
+			ent:    models.User{Admin: false},
+			res:    models.Institution{},
+			action: "update-members",
+			exp:    false,
+		},
+		{
+			ent:    owner,
+			res:    institution,
+			action: "update",
+			exp:    true,
+		},
+		{
+			ent:    manager,
+			res:    institution,
+			action: "update",
+			exp:    false,
+		},
+		{
+			ent:    owner,
+			res:    institution,
+			action: "update-logo",
+			exp:    true,
+		},
+		{
+			ent:    manager,
+			res:    institution,
+			action: "update-logo",
+			exp:    false,
+		},
+		{
+			ent:    owner,
+			res:    institution,
+			action: "delete-logo",
+			exp:    true,
+		},
+		{
+			ent:    manager,
+			res:    institution,
+			action: "delete-logo",
+			exp:    false,
+		},
+		{
+			ent:    owner,
+			res:    institution,
+			action: "list-members",
+			exp:    true,
+		},
+		{
+			ent:    manager,
+			res:    institution,
+			action: "list-members",
+			exp:    false,
+		},
+		{
+			ent:    owner,
+			res:    institution,
+			action: "update-members",
+			exp:    true,
+		},
+		{
+			ent:    manager,
+			res:    institution,
+			action: "update-members",
+			exp:    false,
+		},
+		{
+			ent:    owner,
+			res:    institution,
+			action: "create",
+			exp:    false,
+		},
+		{
+			ent:    manager,
+			res:    institution,
+			action: "create",
+			exp:    false,
+		},
+		{
+			ent:    owner,
+			res:    institution,
+			action: "delete",
+			exp:    false,
+		},
+		{
+			ent:    manager,
+			res:    institution,
+			action: "delete",
+			exp:    false,
+		},
+		{
+			ent:    owner,
+			res:    models.Institution{},
+			action: "update",
+			exp:    false,
+		},
 	}
 	for i, tC := range testCases {
 		t.Run(fmt.Sprintf("action: %s, line: %d", tC.action, i), func(t *testing.T) {
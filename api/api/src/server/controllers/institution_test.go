@@ -6,6 +6,7 @@ import (
 	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/server/access"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/util/random"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/suite"
 	"gorm.io/gorm"
 )
@@ -121,6 +122,172 @@ func (s *InstitutionControllerTestSuite) TestCreateInstitution() {
 	s.Equal(params.Description, result.Description)
 }
 
+func (s *InstitutionControllerTestSuite) TestUpdateInstitution() {
+	institution := models.Institution{
+		Name:        random.AlphanumericString(10),
+		Description: random.AlphanumericString(50),
+	}
+	err := s.db.Create(&institution).Error
+	s.Require().NoError(err)
+
+	name := random.AlphanumericString(10)
+	params := UpdateInstitutionParams{Name: &name}
+
+	// ----------------------- //
+	// Fails for regular users //
+	// ----------------------- //
+	_, ctx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+
+	_, err = s.institutions.Update(institution.ID.String(), params, ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Forbidden Action, "+
+		"message: the user does not have access to this operation"+
+		"}")
+
+	// ------------------- //
+	// Succeeds for admins //
+	// ------------------- //
+	_, ctx, err = createRandomAdmin(s.users)
+	s.Require().NoError(err)
+
+	result, err := s.institutions.Update(institution.ID.String(), params, ctx)
+	s.NoError(err)
+	s.Equal(name, result.Name)
+	s.Equal(institution.Description, result.Description)
+
+	uid, err := uuid.NewRandom()
+	s.Require().NoError(err)
+	_, err = s.institutions.Update(uid.String(), params, ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Record Not Found, "+
+		"message: institution not found"+
+		"}")
+}
+
+func (s *InstitutionControllerTestSuite) TestInstitutionMembers() {
+	institution := models.Institution{
+		Name:        random.AlphanumericString(10),
+		Description: random.AlphanumericString(50),
+	}
+	err := s.db.Create(&institution).Error
+	s.Require().NoError(err)
+	id := institution.ID.String()
+
+	owner, ownerCtx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+	member, memberCtx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+
+	// ----------------------- //
+	// Fails for regular users //
+	// ----------------------- //
+	_, err = s.institutions.SetMember(id, SetMemberParams{
+		UserID: owner.ID,
+		Role:   models.InstitutionOwner,
+	}, ownerCtx)
+	s.EqualError(err, "ApiError{"+
+		"code: Forbidden Action, "+
+		"message: the user does not have access to this operation"+
+		"}")
+
+	// ------------------- //
+	// Succeeds for admins //
+	// ------------------- //
+	_, adminCtx, err := createRandomAdmin(s.users)
+	s.Require().NoError(err)
+
+	result, err := s.institutions.SetMember(id, SetMemberParams{
+		UserID: owner.ID,
+		Role:   models.InstitutionOwner,
+	}, adminCtx)
+	s.Require().NoError(err)
+	s.Equal(owner.ID, result.UserID)
+	s.Equal(models.InstitutionOwner, result.Role)
+
+	_, err = s.institutions.SetMember(id, SetMemberParams{
+		UserID: uuid.New(),
+		Role:   models.InstitutionViewer,
+	}, adminCtx)
+	s.EqualError(err, "ApiError{"+
+		"code: Record Not Found, "+
+		"message: user not found"+
+		"}")
+
+	// ------------------------------------- //
+	// Owners manage the institution members //
+	// ------------------------------------- //
+	_, err = s.institutions.SetMember(id, SetMemberParams{
+		UserID: member.ID,
+		Role:   models.InstitutionViewer,
+	}, ownerCtx)
+	s.Require().NoError(err)
+
+	result, err = s.institutions.SetMember(id, SetMemberParams{
+		UserID: member.ID,
+		Role:   models.InstitutionManager,
+	}, ownerCtx)
+	s.Require().NoError(err)
+	s.Equal(models.InstitutionManager, result.Role)
+	s.Require().NotNil(result.User)
+	s.Equal(member.Name, result.User.Name)
+	s.Empty(result.User.Email)
+	s.Empty(result.User.Subject)
+
+	members, err := s.institutions.ListMembers(id, ListMembersFilters{}, ownerCtx)
+	s.Require().NoError(err)
+	s.Require().Len(members, 2)
+	s.Equal(owner.ID, members[0].UserID)
+	s.Equal(member.ID, members[1].UserID)
+	s.Equal(models.InstitutionManager, members[1].Role)
+	s.Require().NotNil(members[1].User)
+	s.Equal(member.Name, members[1].User.Name)
+	s.Empty(members[1].User.Email)
+	s.Empty(members[1].User.Subject)
+
+	// The profiles of hidden members are omitted.
+	_, err = s.users.Update(member.ID.String(), UpdateUserParams{
+		HiddenProfile: &[]bool{true}[0],
+	}, memberCtx)
+	s.Require().NoError(err)
+	members, err = s.institutions.ListMembers(id, ListMembersFilters{}, ownerCtx)
+	s.Require().NoError(err)
+	s.Require().Len(members, 2)
+	s.NotNil(members[0].User)
+	s.Nil(members[1].User)
+
+	// Managers can't manage the members, nor edit the institution.
+	_, err = s.institutions.ListMembers(id, ListMembersFilters{}, memberCtx)
+	s.EqualError(err, "ApiError{"+
+		"code: Forbidden Action, "+
+		"message: the user does not have access to this operation"+
+		"}")
+
+	name := random.AlphanumericString(10)
+	_, err = s.institutions.Update(id, UpdateInstitutionParams{Name: &name}, memberCtx)
+	s.EqualError(err, "ApiError{"+
+		"code: Forbidden Action, "+
+		"message: the user does not have access to this operation"+
+		"}")
+
+	updated, err := s.institutions.Update(id, UpdateInstitutionParams{Name: &name}, ownerCtx)
+	s.Require().NoError(err)
+	s.Equal(name, updated.Name)
+
+	err = s.institutions.RemoveMember(id, RemoveMemberParams{
+		UserID: member.ID.String(),
+	}, ownerCtx)
+	s.NoError(err)
+
+	err = s.institutions.RemoveMember(id, RemoveMemberParams{
+		UserID: member.ID.String(),
+	}, ownerCtx)
+	s.EqualError(err, "ApiError{"+
+		"code: Record Not Found, "+
+		"message: member not found"+
+		"}")
+}
+
 func (s *InstitutionControllerTestSuite) TestDeleteInstitution() {
 	institution := models.Institution{
 		Name:        random.AlphanumericString(10),
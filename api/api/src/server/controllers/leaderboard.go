@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"database/sql"
+	"time"
 
 	"bitbucket.org/pensarmais/cycleforlisbon/src/database/query"
 	"github.com/gin-gonic/gin"
@@ -15,35 +16,205 @@ type LeaderboardController struct {
 type LeaderboardResult struct {
 	Entries      []query.LeaderboardEntry `json:"entries"`
 	UserPosition int                      `json:"userPosition"`
+	// UserPositionChange is the number of places the user moved up the last
+	// time their position changed, or down if negative.
+	UserPositionChange int `json:"userPositionChange,omitempty"`
+	// Around are the entries around the user's own position.
+	Around []query.LeaderboardEntry `json:"around,omitempty"`
+	// UpdatedAt is the time of the snapshot the leaderboard was served from.
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
+
+// LeaderboardPage selects the entries of a leaderboard that are returned.
+type LeaderboardPage struct {
+	// Limit is the number of entries of the top. Defaults to 10.
+	Limit int `form:"limit,default=10" binding:"omitempty,min=1,max=100" default:"10"`
+	// Offset is the number of entries of the top to skip.
+	Offset int `form:"offset" binding:"omitempty,min=0"`
+	// Around is the number of entries before and after the user's own
+	// position. Defaults to 2.
+	Around int `form:"around,default=2" binding:"omitempty,min=0,max=50" default:"2"`
+}
+
+// setDefaults sets the default limit if it isn't set.
+func (p *LeaderboardPage) setDefaults() {
+	if p.Limit <= 0 {
+		p.Limit = 10
+	}
+}
+
+type LeaderboardFilters struct {
+	LeaderboardPage
+	// Period of the trips considered: `all` time, or the current `week`,
+	// `month` or `season`. Weeks start on Monday, and seasons on the first day
+	// of March, June, September and December.
+	Period query.LeaderboardPeriod `form:"period,default=all" binding:"omitempty,oneof=all week month season" default:"all"`
+	// Metric by which users are ranked: the total `distance`, the `credits`
+	// earned or the number of `trips`.
+	Metric query.LeaderboardMetric `form:"metric,default=distance" binding:"omitempty,oneof=distance credits trips" default:"distance"`
+}
+
+// window returns the window of the leaderboard defined by the filters, and
+// sets the defaults of the filters that aren't set.
+func (f *LeaderboardFilters) window() query.LeaderboardWindow {
+	f.setDefaults()
+	if f.Period == "" {
+		f.Period = query.LeaderboardAllTime
+	}
+	if f.Metric == "" {
+		f.Metric = query.LeaderboardDistance
+	}
+
+	return query.LeaderboardWindow{
+		Period: f.Period,
+		Since:  f.Period.Start(time.Now()),
+		Metric: f.Metric,
+	}
 }
 
 // List users in the leaderboard.
 //
-//	@Summary	List top 10 users in the leaderboard
-//	@Tags		leaderboard
-//	@Produce	json
-//	@Security	OIDCToken
-//	@Security	AuthHeader
-//	@Success	200			{object}	LeaderboardResult
-//	@Failure	400,401,500	{object}	middleware.ApiError
-//	@Router		/leaderboard  [get]
-func (c *LeaderboardController) List(ctx *gin.Context) (LeaderboardResult, error) {
+//	@Summary		List the top users in the leaderboard
+//	@Description	Users are ranked by the given metric over the given period. The ranking of a
+//	@Description	period only includes the users with valid trips in the period. Besides the top,
+//	@Description	the entries around the user's own position are also returned.
+//	@Description	The leaderboard is served from the last of the snapshots taken periodically, so
+//	@Description	it may not include the latest trips. Users with the same value share the same
+//	@Description	position, and the next value is ranked right after them.
+//	@Description	Users that chose to be hidden from the leaderboards aren't ranked, and the
+//	@Description	identity of anonymous users isn't shown, except to the users themselves.
+//	@Tags			leaderboard
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			filters		query		LeaderboardFilters	false	"Filters"
+//	@Success		200			{object}	LeaderboardResult
+//	@Failure		400,401,500	{object}	middleware.ApiError
+//	@Router			/leaderboard  [get]
+func (c *LeaderboardController) List(
+	filters LeaderboardFilters,
+	ctx *gin.Context,
+) (LeaderboardResult, error) {
 	user, err := tokenUser(ctx, c.db)
 	if err != nil {
 		return LeaderboardResult{}, err
 	}
 
+	window := filters.window()
 	res := LeaderboardResult{}
 
 	err = c.db.Transaction(func(tx *gorm.DB) error {
-		top, err := query.Leaderboard.Top(tx)
+		top, err := query.Leaderboard.Top(user.ID.String(), window,
+			filters.Limit, filters.Offset, tx)
 		if err != nil {
 			return err
 		}
 		res.Entries = top
 
-		userPosition, err := query.Leaderboard.PositionOf(user.ID.String(), tx)
+		res.UpdatedAt, err = query.Leaderboard.SnapshotTakenAt(window, tx)
+		if err != nil {
+			return err
+		}
+
+		entry, err := query.Leaderboard.
+			PositionOf(user.ID.String(), window, tx)
+		if err != nil || entry.Position == 0 {
+			return err
+		}
+		res.UserPosition = entry.Position
+		res.UserPositionChange = entry.PositionChange
+
+		res.Around, err = query.Leaderboard.Around(user.ID.String(), window,
+			entry.Position, filters.Around, tx)
+		return err
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+
+	return res, err
+}
+
+// Friends lists the leaderboard of the users followed by the logged-in user.
+//
+//	@Summary		List the top users in the leaderboard of the followed users
+//	@Description	The leaderboard includes the logged-in user and the users they follow, ranked
+//	@Description	like in the global leaderboard.
+//	@Tags			leaderboard
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			filters		query		LeaderboardFilters	false	"Filters"
+//	@Success		200			{object}	LeaderboardResult
+//	@Failure		400,401,500	{object}	middleware.ApiError
+//	@Router			/leaderboard/friends  [get]
+func (c *LeaderboardController) Friends(
+	filters LeaderboardFilters,
+	ctx *gin.Context,
+) (LeaderboardResult, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return LeaderboardResult{}, err
+	}
+
+	window := filters.window()
+	res := LeaderboardResult{}
+
+	err = c.db.Transaction(func(tx *gorm.DB) error {
+		top, err := query.Leaderboard.FriendsTop(user.ID.String(), window,
+			filters.Limit, filters.Offset, tx)
+		if err != nil {
+			return err
+		}
+		res.Entries = top
+
+		userPosition, err := query.Leaderboard.
+			FriendsPositionOf(user.ID.String(), window, tx)
+		if err != nil || userPosition == 0 {
+			return err
+		}
 		res.UserPosition = userPosition
+
+		res.Around, err = query.Leaderboard.FriendsAround(user.ID.String(),
+			window, userPosition, filters.Around, tx)
+		return err
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+
+	return res, err
+}
+
+type TeamLeaderboardResult struct {
+	Entries []query.TeamLeaderboardEntry `json:"entries"`
+	// UserTeams are the entries of the teams the user is a member of.
+	UserTeams []query.TeamLeaderboardEntry `json:"userTeams"`
+}
+
+// Teams lists the teams in the teams leaderboard.
+//
+//	@Summary		List top 10 teams in the leaderboard
+//	@Description	Teams are ranked by the distance of the valid trips made by their members while
+//	@Description	they were members of the team.
+//	@Tags			leaderboard
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Success		200			{object}	TeamLeaderboardResult
+//	@Failure		400,401,500	{object}	middleware.ApiError
+//	@Router			/leaderboard/teams  [get]
+func (c *LeaderboardController) Teams(ctx *gin.Context) (TeamLeaderboardResult, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return TeamLeaderboardResult{}, err
+	}
+
+	res := TeamLeaderboardResult{}
+
+	err = c.db.Transaction(func(tx *gorm.DB) error {
+		top, err := query.Teams.Top(tx)
+		if err != nil {
+			return err
+		}
+		res.Entries = top
+
+		userTeams, err := query.Teams.PositionsOf(user.ID.String(), tx)
+		res.UserTeams = userTeams
 		return err
 	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
 
@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/query"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/server/access"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/random"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+type LeaderboardControllerTestSuite struct {
+	suite.Suite
+	leaderboard *LeaderboardController
+	users       *UserController
+	db          *gorm.DB
+	acl         *access.ACL
+}
+
+// Run each test in a transaction.
+func (s *LeaderboardControllerTestSuite) SetupTest() {
+	tx := testDb.Begin()
+	s.db = tx
+	s.leaderboard = &LeaderboardController{tx}
+	s.users = &UserController{tx, s.acl, "", nil}
+}
+
+// Rollback the transaction after each test.
+func (s *LeaderboardControllerTestSuite) TearDownTest() {
+	s.db.Rollback()
+}
+
+func (s *LeaderboardControllerTestSuite) TestPeriodAndMetric() {
+	users := make([]models.User, 3)
+	ctxs := make([]*gin.Context, 3)
+	for i := range users {
+		var err error
+		users[i], ctxs[i], err = createRandomUser(s.users)
+		s.Require().NoError(err)
+	}
+
+	// Only this week's trips of the users are ranked, since no other users
+	// have trips in the future.
+	soon := time.Now().Add(time.Minute)
+	for i, trip := range []struct {
+		user     int
+		dist     float64
+		credits  float64
+		at       time.Time
+		isValid  bool
+		numTrips int
+	}{
+		{user: 0, dist: 10, credits: 1, at: soon, isValid: true, numTrips: 3},
+		{user: 1, dist: 50, credits: 5, at: soon, isValid: true, numTrips: 1},
+		{user: 2, dist: 500, credits: 50, at: soon, isValid: false, numTrips: 1},
+		{user: 2, dist: 5, credits: 10, at: soon, isValid: true, numTrips: 1},
+	} {
+		for j := 0; j < trip.numTrips; j++ {
+			s.Require().NoError(s.db.Create(&models.Trip{
+				BaseModel: models.BaseModel{CreatedAt: trip.at},
+				GPX:       []byte(`<gpx version="1.1"><trk></trk></gpx>`),
+				GPXHash:   []byte(random.String(32)),
+				IsValid:   trip.isValid,
+				Distance:  trip.dist,
+				Credits:   trip.credits,
+				UserID:    users[trip.user].ID,
+			}).Error, "failed on trip %d", i)
+		}
+	}
+
+	// Rank the trips made from now on.
+	window := query.LeaderboardWindow{
+		Period: query.LeaderboardWeek,
+		Since:  &[]time.Time{time.Now()}[0],
+		Metric: query.LeaderboardDistance,
+	}
+	snapshot := func() {
+		_, err := query.Leaderboard.Snapshot(window, time.Now(), s.db)
+		s.Require().NoError(err)
+	}
+
+	// ----------------- //
+	// Ranks by distance //
+	// ----------------- //
+	snapshot()
+	top, err := query.Leaderboard.Top(users[0].ID.String(), window, 10, 0, s.db)
+	s.Require().NoError(err)
+	s.Require().Len(top, 3)
+	s.Equal(users[1].ID, top[0].ID)
+	s.Equal(users[0].ID, top[1].ID)
+	s.Equal(30.0, top[1].TotalDist)
+	s.Equal(uint(3), top[1].TripCount)
+	s.Equal(users[2].ID, top[2].ID)
+
+	// ---------------- //
+	// Ranks by credits //
+	// ---------------- //
+	window.Metric = query.LeaderboardCredits
+	snapshot()
+	top, err = query.Leaderboard.Top(users[0].ID.String(), window, 10, 0, s.db)
+	s.Require().NoError(err)
+	s.Require().Len(top, 3)
+	s.Equal(users[2].ID, top[0].ID)
+	s.Equal(10.0, top[0].Credits)
+
+	// ------------------------ //
+	// Ranks by number of trips //
+	// ------------------------ //
+	window.Metric = query.LeaderboardTrips
+	snapshot()
+	entry, err := query.Leaderboard.PositionOf(users[0].ID.String(), window, s.db)
+	s.Require().NoError(err)
+	s.Equal(1, entry.Position)
+
+	// ----------------------------------- //
+	// Returns the entries around the user //
+	// ----------------------------------- //
+	window.Metric = query.LeaderboardDistance
+	around, err := query.Leaderboard.
+		Around(users[0].ID.String(), window, 2, 1, s.db)
+	s.Require().NoError(err)
+	s.Require().Len(around, 3)
+	s.Equal(users[0].ID, around[1].ID)
+
+	top, err = query.Leaderboard.Top(users[0].ID.String(), window, 1, 1, s.db)
+	s.Require().NoError(err)
+	s.Require().Len(top, 1)
+	s.Equal(2, top[0].Position)
+}
+
+func (s *LeaderboardControllerTestSuite) TestList() {
+	user, ctx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+
+	// ----------------------------------------------- //
+	// Users without trips in the period aren't ranked //
+	// ----------------------------------------------- //
+	res, err := s.leaderboard.List(LeaderboardFilters{Period: "week"}, ctx)
+	s.Require().NoError(err)
+	s.Zero(res.UserPosition)
+	s.Empty(res.Around)
+
+	// ------------------------------------------- //
+	// Returns the top and the entries around them //
+	// ------------------------------------------- //
+	_, err = query.Leaderboard.Snapshot(query.LeaderboardWindow{
+		Period: query.LeaderboardAllTime,
+		Metric: query.LeaderboardDistance,
+	}, time.Now(), s.db)
+	s.Require().NoError(err)
+
+	res, err = s.leaderboard.List(LeaderboardFilters{
+		LeaderboardPage: LeaderboardPage{Limit: 5, Around: 1},
+	}, ctx)
+	s.Require().NoError(err)
+	s.LessOrEqual(len(res.Entries), 5)
+	s.NotZero(res.UserPosition)
+	s.NotNil(res.UpdatedAt)
+	s.Require().NotEmpty(res.Around)
+	s.Contains(res.Around, query.LeaderboardEntry{
+		Position: res.UserPosition,
+		ID:       user.ID,
+		Name:     user.Name,
+	})
+}
+
+func TestLeaderboardController(t *testing.T) {
+	acl := access.New()
+	registerAllRules(&UserController{}, acl)
+	suite.Run(t, &LeaderboardControllerTestSuite{acl: acl})
+}
@@ -1,8 +1,12 @@
 package controllers
 
 import (
+	"errors"
+	"time"
+
 	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/database/query"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/types"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/util/httputil"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -18,6 +22,12 @@ func (MetricsController) Rules() []rule {
 		{models.User{}, Metrics{}, "get", func(ent, res any) bool {
 			return ent.(models.User).Admin
 		}},
+		// The metrics of an institution are only available to admins for now.
+		// The institution is the resource, so that its members can be granted
+		// access according to their role.
+		{models.User{}, models.Institution{}, "get-metrics", func(ent, res any) bool {
+			return ent.(models.User).Admin
+		}},
 	}
 }
 
@@ -70,3 +80,214 @@ func (c *MetricsController) Get(ctx *gin.Context) (Metrics, error) {
 
 	return metrics, err
 }
+
+type MetricsSeriesFilters struct {
+	// Interval of the points of the series: `day`, `week` or `month`. Weeks
+	// start on Monday. Defaults to day.
+	Interval query.MetricsInterval `form:"interval,default=day" binding:"omitempty,oneof=day week month" default:"day"`
+	// DateFrom is the first day of the series. Defaults to 30 days, 12 weeks
+	// or 12 months before DateTo, according to the interval.
+	DateFrom types.Date `form:"dateFrom" binding:"omitempty,datetime=2006-01-02" example:"2023-03-01"`
+	// DateTo is the last day of the series. Defaults to the current day.
+	DateTo types.Date `form:"dateTo" binding:"omitempty,datetime=2006-01-02" example:"2023-03-30"`
+}
+
+// withDefaults returns the filters with the default interval and period set,
+// or an error if the period is invalid.
+func (f MetricsSeriesFilters) withDefaults() (MetricsSeriesFilters, error) {
+	if f.Interval == "" {
+		f.Interval = query.MetricsDaily
+	}
+	if f.DateTo == "" {
+		f.DateTo = types.Date(time.Now().Format(types.DateFormat))
+	}
+	if f.DateFrom == "" {
+		to := f.DateTo.Time()
+		from := to.AddDate(0, 0, -30)
+		switch f.Interval {
+		case query.MetricsWeekly:
+			from = to.AddDate(0, 0, -12*7)
+		case query.MetricsMonthly:
+			from = to.AddDate(0, -12, 0)
+		}
+		f.DateFrom = types.Date(from.Format(types.DateFormat))
+	}
+	if f.DateFrom.Time().After(f.DateTo.Time()) {
+		return f, httputil.NewErrorMsg(
+			httputil.BadRequest,
+			"the start of the period must be before its end",
+		)
+	}
+	return f, nil
+}
+
+// Timeseries retrieves the time series of the platform metrics.
+//
+//	@Summary		Retrieve the time series of the platform metrics
+//	@Description	Includes the new users, active riders, valid and invalid trips, distance and
+//	@Description	credits of each day, week or month of the period. The series is computed from
+//	@Description	daily rollups updated every hour, so the metrics of the current day may be
+//	@Description	outdated.
+//	@Tags			metrics
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			filters				query		MetricsSeriesFilters	false	"Filters"
+//	@Success		200					{array}		query.MetricsPoint
+//	@Failure		400,401,403,500		{object}	middleware.ApiError
+//	@Router			/metrics/timeseries [get]
+func (c *MetricsController) Timeseries(
+	filters MetricsSeriesFilters,
+	ctx *gin.Context,
+) ([]query.MetricsPoint, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok := c.acl.Authorize(user, "get", Metrics{}); !ok {
+		return nil, httputil.NewErrorMsg(
+			httputil.AdminAccessRequired,
+			httputil.AdminRequiredMessage,
+		)
+	}
+
+	filters, err = filters.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	return query.Metrics.
+		Series(filters.Interval, filters.DateFrom, filters.DateTo, c.db)
+}
+
+// institutionMetricsDays is the default period of the time series of the
+// metrics of an institution.
+const institutionMetricsDays = 30
+
+type InstitutionMetricsFilters struct {
+	// DateFrom is the first day of the time series of contributions. Defaults
+	// to 30 days before DateTo.
+	DateFrom types.Date `form:"dateFrom" binding:"omitempty,datetime=2006-01-02" example:"2023-03-01"`
+	// DateTo is the last day of the time series of contributions. Defaults to
+	// the current day.
+	DateTo types.Date `form:"dateTo" binding:"omitempty,datetime=2006-01-02" example:"2023-03-30"`
+}
+
+// Institution retrieves the metrics of the initiatives of an institution.
+//
+//	@Summary		Retrieve the metrics of the initiatives of an institution
+//	@Description	Includes the total credits, contributors, distance and CO2 saved, the age groups
+//	@Description	and genders of the contributors, and the time series of the contributions.
+//	@Description	The time series covers the given period, while the remaining metrics are
+//	@Description	all-time.
+//	@Tags			institutions
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id					path		string						true	"Institution Id"	Format(UUID)
+//	@Param			filters				query		InstitutionMetricsFilters	false	"Filters"
+//	@Success		200					{object}	query.InstitutionMetrics
+//	@Failure		400,401,403,404,500	{object}	middleware.ApiError
+//	@Router			/institutions/{id}/metrics [get]
+func (c *MetricsController) Institution(
+	id string,
+	filters InstitutionMetricsFilters,
+	ctx *gin.Context,
+) (query.InstitutionMetrics, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return query.InstitutionMetrics{}, err
+	}
+
+	var institution models.Institution
+	if err := c.db.First(&institution, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return query.InstitutionMetrics{}, resourceNotFoundErr("institution")
+		}
+		return query.InstitutionMetrics{}, err
+	}
+
+	if ok := c.acl.Authorize(user, "get-metrics", institution); !ok {
+		return query.InstitutionMetrics{}, httputil.NewErrorMsg(
+			httputil.Forbidden,
+			httputil.ForbiddenMessage,
+		)
+	}
+
+	if filters.DateTo == "" {
+		filters.DateTo = types.Date(time.Now().Format(types.DateFormat))
+	}
+	if filters.DateFrom == "" {
+		filters.DateFrom = types.Date(filters.DateTo.Time().
+			AddDate(0, 0, -institutionMetricsDays).Format(types.DateFormat))
+	}
+	if filters.DateFrom.Time().After(filters.DateTo.Time()) {
+		return query.InstitutionMetrics{}, httputil.NewErrorMsg(
+			httputil.BadRequest,
+			"the start of the period must be before its end",
+		)
+	}
+
+	return query.Metrics.
+		Institution(id, filters.DateFrom, filters.DateTo, c.db)
+}
+
+// parishMetricsDays is the default period of the metrics by parish.
+const parishMetricsDays = 30
+
+type ParishMetricsFilters struct {
+	// DateFrom is the first day of the period of the trips. Defaults to 30
+	// days before DateTo.
+	DateFrom types.Date `form:"dateFrom" binding:"omitempty,datetime=2006-01-02" example:"2023-03-01"`
+	// DateTo is the last day of the period of the trips. Defaults to the
+	// current day.
+	DateTo types.Date `form:"dateTo" binding:"omitempty,datetime=2006-01-02" example:"2023-03-30"`
+}
+
+// Parishes retrieves the metrics of the trips by parish.
+//
+//	@Summary		Retrieve the metrics of the trips by parish
+//	@Description	Includes the trips that started and ended in each parish, the distance of the
+//	@Description	trips that started in it, and the origin-destination counts of the trips
+//	@Description	between parishes. Only the valid trips uploaded in the period are counted.
+//	@Tags			metrics
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			filters				query		ParishMetricsFilters	false	"Filters"
+//	@Success		200					{object}	query.ParishStats
+//	@Failure		400,401,403,500		{object}	middleware.ApiError
+//	@Router			/metrics/parishes [get]
+func (c *MetricsController) Parishes(
+	filters ParishMetricsFilters,
+	ctx *gin.Context,
+) (query.ParishStats, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return query.ParishStats{}, err
+	}
+
+	if ok := c.acl.Authorize(user, "get", Metrics{}); !ok {
+		return query.ParishStats{}, httputil.NewErrorMsg(
+			httputil.AdminAccessRequired,
+			httputil.AdminRequiredMessage,
+		)
+	}
+
+	if filters.DateTo == "" {
+		filters.DateTo = types.Date(time.Now().Format(types.DateFormat))
+	}
+	if filters.DateFrom == "" {
+		filters.DateFrom = types.Date(filters.DateTo.Time().
+			AddDate(0, 0, -parishMetricsDays).Format(types.DateFormat))
+	}
+	if filters.DateFrom.Time().After(filters.DateTo.Time()) {
+		return query.ParishStats{}, httputil.NewErrorMsg(
+			httputil.BadRequest,
+			"the start of the period must be before its end",
+		)
+	}
+
+	return query.Metrics.Parishes(filters.DateFrom, filters.DateTo, c.db)
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"fmt"
+	"testing"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/server/access"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsACL(t *testing.T) {
+	acl := access.New()
+	registerAllRules(&MetricsController{}, acl)
+
+	institution := models.Institution{}
+	owner := models.User{Memberships: []models.InstitutionMembership{
+		{InstitutionID: institution.ID, Role: models.InstitutionOwner},
+	}}
+
+	testCases := []struct {
+		ent    models.User
+		res    any
+		action string
+		exp    bool
+	}{
+		{
+			ent:    models.User{Admin: true},
+			res:    Metrics{},
+			action: "get",
+			exp:    true,
+		},
+		{
+			ent:    models.User{Admin: false},
+			res:    Metrics{},
+			action: "get",
+			exp:    false,
+		},
+		{
+			ent:    models.User{Admin: true},
+			res:    institution,
+			action: "get-metrics",
+			exp:    true,
+		},
+		{
+			ent:    models.User{Admin: false},
+			res:    institution,
+			action: "get-metrics",
+			exp:    false,
+		},
+		{
+			ent:    owner,
+			res:    institution,
+			action: "get-metrics",
+			exp:    false,
+		},
+	}
+	for i, tC := range testCases {
+		t.Run(fmt.Sprintf("action: %s, line: %d", tC.action, i), func(t *testing.T) {
+			assert.Equal(t, tC.exp, acl.Authorize(tC.ent, tC.action, tC.res))
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/query"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/jobs"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/httputil"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/latlon"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/worker"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// parishLocator locates coordinates in the parishes, which may be cached.
+//
+// This interface allows replacing the locator during testing.
+type parishLocator interface {
+	Locate(coords latlon.Coords) (*uuid.UUID, error)
+	// Reset discards the cached parishes.
+	Reset()
+}
+
+type ParishController struct {
+	db      *gorm.DB
+	acl     authorizer
+	tasks   scheduler
+	locator parishLocator
+}
+
+func (ParishController) Rules() []rule {
+	return []rule{
+		{models.User{}, models.Parish{}, "import", func(ent, res any) bool {
+			return ent.(models.User).Admin
+		}},
+	}
+}
+
+// List all parishes.
+//
+//	@Summary		List all parishes
+//	@Description	The boundaries of the parishes are omitted.
+//	@Tags			parishes
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Success		200			{array}		models.Parish
+//	@Failure		400,401,500	{object}	middleware.ApiError
+//	@Router			/parishes [get]
+func (c *ParishController) List(ctx *gin.Context) ([]models.Parish, error) {
+	parishes := []models.Parish{}
+	err := c.db.
+		Omit("boundary").
+		Order("name").
+		Find(&parishes).Error
+
+	return parishes, err
+}
+
+type ImportParishesQuery struct {
+	// NameProperty is the property of the features with the name of the
+	// parish. Defaults to "name".
+	NameProperty string `form:"nameProperty,default=name" example:"NOME" default:"name"`
+}
+
+type ImportParishesResponse struct {
+	// Imported is the number of parishes in the file.
+	Imported int `json:"imported"`
+	// Deleted is the number of parishes deleted because they were missing
+	// from the file.
+	Deleted int64 `json:"deleted"`
+}
+
+// Import a GeoJSON file of the boundaries of the parishes.
+//
+//	@Summary		Import the boundaries of the parishes
+//	@Description	The file is a GeoJSON FeatureCollection, with a Polygon or MultiPolygon feature
+//	@Description	for each parish. The parishes replace the existing ones, matched by name, and
+//	@Description	the parishes missing from the file are deleted. The trips uploaded before are
+//	@Description	assigned to the new boundaries in the background.
+//	@Tags			parishes
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			params			query		ImportParishesQuery	false	"Query"
+//	@Param			file			formData	file				true	"GeoJSON file"
+//	@Success		200				{object}	ImportParishesResponse
+//	@Failure		400,401,403,500	{object}	middleware.ApiError
+//	@Router			/parishes [post]
+func (c *ParishController) Import(
+	data []byte,
+	ctx *gin.Context,
+) (ImportParishesResponse, error) {
+	var params ImportParishesQuery
+	if err := ctx.ShouldBindQuery(&params); err != nil {
+		return ImportParishesResponse{}, httputil.NewError(
+			httputil.BadRequest, err)
+	}
+
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return ImportParishesResponse{}, err
+	}
+
+	if !c.acl.Authorize(user, "import", models.Parish{}) {
+		return ImportParishesResponse{}, httputil.NewErrorMsg(
+			httputil.AdminAccessRequired,
+			httputil.AdminRequiredMessage,
+		)
+	}
+
+	boundaries, err := latlon.ParseGeoJSON(data, params.NameProperty)
+	if err != nil {
+		return ImportParishesResponse{}, httputil.NewError(
+			httputil.ImportInvalidValue, err)
+	}
+	if len(boundaries) == 0 {
+		return ImportParishesResponse{}, httputil.NewErrorMsg(
+			httputil.ImportInvalidValue,
+			"the file has no features",
+		)
+	}
+
+	res := ImportParishesResponse{Imported: len(boundaries)}
+	err = c.db.Transaction(func(tx *gorm.DB) error {
+		res.Deleted, err = query.Parishes.Replace(boundaries, tx)
+		return err
+	})
+	if err != nil {
+		return ImportParishesResponse{}, err
+	}
+	c.locator.Reset()
+
+	// The job is scheduled after the parishes are committed, as the worker
+	// uses its own connection.
+	if err := c.tasks.Schedule(&worker.TaskConfig{
+		JobName: jobs.AssignParishes,
+	}); err != nil {
+		return ImportParishesResponse{}, err
+	}
+
+	return res, nil
+}
@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"fmt"
+	"testing"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/server/access"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParishACL(t *testing.T) {
+	acl := access.New()
+	registerAllRules(&ParishController{}, acl)
+
+	testCases := []struct {
+		ent    models.User
+		res    any
+		action string
+		exp    bool
+	}{
+		{
+			ent:    models.User{Admin: true},
+			res:    models.Parish{},
+			action: "import",
+			exp:    true,
+		},
+		{
+			ent:    models.User{Admin: false},
+			res:    models.Parish{},
+			action: "import",
+			exp:    false,
+		},
+	}
+	for i, tC := range testCases {
+		t.Run(fmt.Sprintf("action: %s, line: %d", tC.action, i), func(t *testing.T) {
+			assert.Equal(t, tC.exp, acl.Authorize(tC.ent, tC.action, tC.res))
+		})
+	}
+}
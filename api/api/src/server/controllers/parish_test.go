@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/parishes"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/server/access"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/httputil"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/latlon"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/random"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+type ParishControllerTestSuite struct {
+	suite.Suite
+	users    *UserController
+	parishes *ParishController
+	db       *gorm.DB
+	acl      *access.ACL
+	wrkr     *MockWorker
+}
+
+// Run each test in a transaction.
+func (s *ParishControllerTestSuite) SetupTest() {
+	tx := testDb.Begin()
+	s.db = tx
+	s.users = &UserController{tx, s.acl, "", nil}
+	s.parishes = &ParishController{tx, s.acl, s.wrkr, parishes.NewLocator(tx)}
+}
+
+// Rollback the transaction after each test.
+func (s *ParishControllerTestSuite) TearDownTest() {
+	s.db.Rollback()
+}
+
+const parishesGeoJSON = `{
+	"type": "FeatureCollection",
+	"features": [
+		{
+			"type": "Feature",
+			"properties": {"NOME": "Arroios"},
+			"geometry": {
+				"type": "Polygon",
+				"coordinates": [
+					[[-9.14, 38.72], [-9.13, 38.72], [-9.13, 38.73], [-9.14, 38.73]]
+				]
+			}
+		},
+		{
+			"type": "Feature",
+			"properties": {"NOME": "Belém"},
+			"geometry": {
+				"type": "MultiPolygon",
+				"coordinates": [
+					[[[-9.22, 38.69], [-9.20, 38.69], [-9.20, 38.70], [-9.22, 38.70]]]
+				]
+			}
+		}
+	]
+}`
+
+func (s *ParishControllerTestSuite) TestImport() {
+	s.Require().NoError(s.db.Where("true").Delete(&models.Parish{}).Error)
+
+	_, ctx, err := createRandomAdmin(s.users)
+	s.Require().NoError(err)
+	s.wrkr.On("Schedule", mock.AnythingOfType("*worker.TaskConfig")).Return(nil)
+
+	ctx.Request = httptest.NewRequest("POST", "/api/parishes?nameProperty=NOME", nil)
+	res, err := s.parishes.Import([]byte(parishesGeoJSON), ctx)
+	s.Require().NoError(err)
+	s.Equal(ImportParishesResponse{Imported: 2}, res)
+
+	list, err := s.parishes.List(ctx)
+	s.Require().NoError(err)
+	s.Require().Len(list, 2)
+	s.Equal("Arroios", list[0].Name)
+	s.Nil(list[0].Boundary)
+
+	var belem models.Parish
+	s.Require().NoError(s.db.First(&belem, "name = ?", "Belém").Error)
+	s.True(belem.Boundary.Contains(latlon.Coords{Lat: 38.695, Lon: -9.21}))
+
+	// ------------------------------------------------ //
+	// Deletes the parishes missing from the new import //
+	// ------------------------------------------------ //
+	user, _, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+	trip := models.Trip{
+		GPX:           []byte("<gpx/>"),
+		GPXHash:       []byte(random.String(20)),
+		IsValid:       true,
+		UserID:        user.ID,
+		StartParishID: &belem.ID,
+	}
+	s.Require().NoError(s.db.Create(&trip).Error)
+
+	ctx.Request = httptest.NewRequest("POST", "/api/parishes?nameProperty=NOME", nil)
+	res, err = s.parishes.Import([]byte(`{
+		"type": "FeatureCollection",
+		"features": [{
+			"properties": {"NOME": "Arroios"},
+			"geometry": {
+				"type": "Polygon",
+				"coordinates": [[[-9.15, 38.72], [-9.13, 38.72], [-9.13, 38.73]]]
+			}
+		}]
+	}`), ctx)
+	s.Require().NoError(err)
+	s.Equal(ImportParishesResponse{Imported: 1, Deleted: 1}, res)
+
+	s.Require().NoError(s.db.First(&trip, "id = ?", trip.ID).Error)
+	s.Nil(trip.StartParishID)
+
+	// --------------------------------------------- //
+	// Rejects files without the property with names //
+	// --------------------------------------------- //
+	ctx.Request = httptest.NewRequest("POST", "/api/parishes", nil)
+	_, err = s.parishes.Import([]byte(parishesGeoJSON), ctx)
+	var httpErr httputil.Error
+	s.Require().ErrorAs(err, &httpErr)
+	s.Equal(httputil.NewErrorMsg(httputil.ImportInvalidValue, "").Code,
+		httpErr.Code)
+}
+
+func (s *ParishControllerTestSuite) TestImportNotAdmin() {
+	_, ctx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+
+	ctx.Request = httptest.NewRequest("POST", "/api/parishes?nameProperty=NOME", nil)
+	_, err = s.parishes.Import([]byte(parishesGeoJSON), ctx)
+	s.Equal(httputil.NewErrorMsg(
+		httputil.AdminAccessRequired,
+		httputil.AdminRequiredMessage,
+	), err)
+}
+
+func TestParishController(t *testing.T) {
+	acl := access.New()
+	registerAllRules(&UserController{}, acl)
+	registerAllRules(&ParishController{}, acl)
+	suite.Run(t, &ParishControllerTestSuite{acl: acl, wrkr: &MockWorker{}})
+}
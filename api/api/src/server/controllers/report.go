@@ -0,0 +1,253 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/query"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/types"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/reports"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/httputil"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ReportController struct {
+	db  *gorm.DB
+	acl authorizer
+}
+
+// Rules returns the acl for the report controller.
+func (ReportController) Rules() []rule {
+	return []rule{
+		// Any member of the initiative's institution can see its reports.
+		{models.User{}, models.Initiative{}, "get-report", func(ent, res any) bool {
+			user := ent.(models.User)
+			return user.Admin || user.HasRole(
+				res.(models.Initiative).InstitutionID,
+				models.InstitutionViewer,
+			)
+		}},
+		// Any member of a sponsor can see its reports.
+		{models.User{}, models.Institution{}, "get-report", func(ent, res any) bool {
+			user := ent.(models.User)
+			return user.Admin || user.HasRole(
+				res.(models.Institution).ID,
+				models.InstitutionViewer,
+			)
+		}},
+	}
+}
+
+type ReportFilters struct {
+	// DateFrom is the first day of the period.
+	DateFrom types.Date `form:"dateFrom" binding:"required,datetime=2006-01-02" example:"2023-01-01"`
+	// DateTo is the last day of the period.
+	DateTo types.Date `form:"dateTo" binding:"required,datetime=2006-01-02" example:"2023-01-31"`
+	// Format of the report: `csv`, `xlsx` or a printable `html` page.
+	Format string `form:"format,default=csv" binding:"omitempty,oneof=csv xlsx html" default:"csv"`
+}
+
+// validate returns an error if the period of the filters is invalid.
+func (f ReportFilters) validate() error {
+	if f.DateFrom.Time().After(f.DateTo.Time()) {
+		return httputil.NewErrorMsg(
+			httputil.BadRequest,
+			"the start of the period must be before its end",
+		)
+	}
+	return nil
+}
+
+// formatCents formats an amount of cents in euros.
+func formatCents(cents float64) string {
+	return strconv.FormatFloat(cents/100, 'f', 2, 64)
+}
+
+// formatCredits formats an amount of credits, with up to two decimal places.
+func formatCredits(credits float64) string {
+	return strconv.FormatFloat(credits, 'f', -1, 64)
+}
+
+// writeStatement renders the statement in the format given by the filters,
+// and sets the filename of the response.
+func writeStatement(
+	statement reports.Statement,
+	filters ReportFilters,
+	filename string,
+	ctx *gin.Context,
+) ([]byte, string, error) {
+	var buf bytes.Buffer
+	if err := statement.Write(&buf, filters.Format); err != nil {
+		return nil, "", err
+	}
+
+	disposition := "attachment"
+	if filters.Format == reports.HTML {
+		disposition = "inline"
+	}
+	ctx.Header("Content-Disposition", fmt.Sprintf("%s; filename=\"%s_%s_%s.%s\"",
+		disposition, filename, filters.DateFrom, filters.DateTo, filters.Format))
+
+	return buf.Bytes(), reports.ContentType(filters.Format), nil
+}
+
+// Initiative generates the payout report of an initiative.
+//
+//	@Summary		Generate the payout report of an initiative
+//	@Description	Lists the credits received by the initiative in the given period, by day, and
+//	@Description	their value in euros. Credits are converted with the credits to cents ratio in
+//	@Description	effect when they were awarded.
+//	@Tags			reports
+//	@Produce		text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet,text/html
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id					path		string			true	"Initiative Id"	Format(UUID)
+//	@Param			filters				query		ReportFilters	true	"Filters"
+//	@Success		200					{file}		file
+//	@Failure		400,401,403,404,500	{object}	middleware.ApiError
+//	@Router			/initiatives/{id}/report [get]
+func (c *ReportController) Initiative(
+	id string,
+	filters ReportFilters,
+	ctx *gin.Context,
+) ([]byte, string, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := filters.validate(); err != nil {
+		return nil, "", err
+	}
+
+	var initiative models.Initiative
+	if err := c.db.First(&initiative, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", resourceNotFoundErr("initiative")
+		}
+		return nil, "", err
+	}
+
+	if ok := c.acl.Authorize(user, "get-report", initiative); !ok {
+		return nil, "", httputil.NewErrorMsg(
+			httputil.Forbidden,
+			httputil.ForbiddenMessage,
+		)
+	}
+
+	rows, err := query.Reports.
+		Initiative(id, filters.DateFrom, filters.DateTo, c.db)
+	if err != nil {
+		return nil, "", err
+	}
+
+	statement := reports.Statement{
+		Title:          initiative.Title,
+		Subtitle:       "Initiative payout statement",
+		From:           string(filters.DateFrom),
+		To:             string(filters.DateTo),
+		Columns:        []string{"Date", "Credits", "Credits/cents ratio", "Euros"},
+		NumericColumns: []int{1, 2, 3},
+		GeneratedAt:    time.Now(),
+	}
+
+	var credits, cents float64
+	for _, row := range rows {
+		statement.Rows = append(statement.Rows, []string{
+			string(row.Date),
+			formatCredits(row.Credits),
+			strconv.FormatFloat(float64(row.Ratio), 'f', -1, 32),
+			formatCents(row.Cents),
+		})
+		credits += row.Credits
+		cents += row.Cents
+	}
+	statement.Totals = []string{
+		"Total", formatCredits(credits), "", formatCents(cents),
+	}
+
+	return writeStatement(statement, filters, "initiative", ctx)
+}
+
+// Sponsor generates the payout report of a sponsor.
+//
+//	@Summary		Generate the payout report of a sponsor
+//	@Description	Lists the credits received in the given period by each initiative sponsored by the
+//	@Description	institution, and their value in euros. Credits are converted with the credits to
+//	@Description	cents ratio in effect when they were awarded. The credits matched by the other
+//	@Description	sponsors of the initiatives aren't included.
+//	@Tags			reports
+//	@Produce		text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet,text/html
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id					path		string			true	"Institution Id"	Format(UUID)
+//	@Param			filters				query		ReportFilters	true	"Filters"
+//	@Success		200					{file}		file
+//	@Failure		400,401,403,404,500	{object}	middleware.ApiError
+//	@Router			/institutions/{id}/report [get]
+func (c *ReportController) Sponsor(
+	id string,
+	filters ReportFilters,
+	ctx *gin.Context,
+) ([]byte, string, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := filters.validate(); err != nil {
+		return nil, "", err
+	}
+
+	var institution models.Institution
+	if err := c.db.First(&institution, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", resourceNotFoundErr("institution")
+		}
+		return nil, "", err
+	}
+
+	if ok := c.acl.Authorize(user, "get-report", institution); !ok {
+		return nil, "", httputil.NewErrorMsg(
+			httputil.Forbidden,
+			httputil.ForbiddenMessage,
+		)
+	}
+
+	rows, err := query.Reports.
+		Sponsor(id, filters.DateFrom, filters.DateTo, c.db)
+	if err != nil {
+		return nil, "", err
+	}
+
+	statement := reports.Statement{
+		Title:          institution.Name,
+		Subtitle:       "Sponsor payout statement",
+		From:           string(filters.DateFrom),
+		To:             string(filters.DateTo),
+		Columns:        []string{"Initiative", "Credits", "Euros"},
+		NumericColumns: []int{1, 2},
+		GeneratedAt:    time.Now(),
+	}
+
+	var credits, cents float64
+	for _, row := range rows {
+		statement.Rows = append(statement.Rows, []string{
+			row.Title,
+			formatCredits(row.Credits),
+			formatCents(row.Cents),
+		})
+		credits += row.Credits
+		cents += row.Cents
+	}
+	statement.Totals = []string{
+		"Total", formatCredits(credits), formatCents(cents),
+	}
+
+	return writeStatement(statement, filters, "sponsor", ctx)
+}
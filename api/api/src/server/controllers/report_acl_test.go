@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"fmt"
+	"testing"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/server/access"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportACL(t *testing.T) {
+	acl := access.New()
+	registerAllRules(&ReportController{}, acl)
+
+	institution := uuid.New()
+	viewer := models.User{Memberships: []models.InstitutionMembership{
+		{InstitutionID: institution, Role: models.InstitutionViewer},
+	}}
+
+	testCases := []struct {
+		ent    models.User
+		res    any
+		action string
+		exp    bool
+	}{
+		{
+			ent:    models.User{Admin: true},
+			res:    models.Initiative{},
+			action: "get-report",
+			exp:    true,
+		},
+		{
+			ent:    models.User{Admin: false},
+			res:    models.Initiative{},
+			action: "get-report",
+			exp:    false,
+		},
+		{
+			ent:    models.User{Admin: true},
+			res:    models.Institution{},
+			action: "get-report",
+			exp:    true,
+		},
+		{
+			ent:    models.User{Admin: false},
+			res:    models.Institution{},
+			action: "get-report",
+			exp:    false,
+		},
+		{
+			ent:    viewer,
+			res:    models.Initiative{InstitutionID: institution},
+			action: "get-report",
+			exp:    true,
+		},
+		{
+			ent:    viewer,
+			res:    models.Initiative{InstitutionID: uuid.New()},
+			action: "get-report",
+			exp:    false,
+		},
+		{
+			ent:    viewer,
+			res:    models.Institution{BaseModel: models.BaseModel{ID: institution}},
+			action: "get-report",
+			exp:    true,
+		},
+		{
+			ent:    viewer,
+			res:    models.Institution{},
+			action: "get-report",
+			exp:    false,
+		},
+	}
+	for i, tC := range testCases {
+		t.Run(fmt.Sprintf("action: %s, line: %d", tC.action, i), func(t *testing.T) {
+			assert.Equal(t, tC.exp, acl.Authorize(tC.ent, tC.action, tC.res))
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportFiltersValidate(t *testing.T) {
+	filters := ReportFilters{DateFrom: "2023-01-01", DateTo: "2023-01-31"}
+	assert.NoError(t, filters.validate())
+
+	filters = ReportFilters{DateFrom: "2023-01-31", DateTo: "2023-01-31"}
+	assert.NoError(t, filters.validate())
+
+	filters = ReportFilters{DateFrom: "2023-02-01", DateTo: "2023-01-31"}
+	assert.EqualError(t, filters.validate(), "ApiError{"+
+		"code: Bad Request, "+
+		"message: the start of the period must be before its end"+
+		"}")
+}
@@ -0,0 +1,813 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/query"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/reports"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/httputil"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/random"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Length of the codes used to join teams.
+const teamJoinCodeLength = 8
+
+// Number of join codes generated before giving up on finding one that isn't
+// taken by another team.
+const teamJoinCodeAttempts = 5
+
+var duplicateJoinCodeRegex = regexp.MustCompile(
+	"duplicate key value violates unique constraint \"teams_join_code_key\"",
+)
+var duplicateTeamMembershipRegex = regexp.MustCompile(
+	"duplicate key value violates unique constraint \"idx_team_memberships_active\"",
+)
+
+type TeamController struct {
+	db  *gorm.DB
+	acl authorizer
+}
+
+// Rules returns the acl for the team controller.
+func (TeamController) Rules() []rule {
+	return []rule{
+		// Admins of the team can edit it, manage its members and export their
+		// stats.
+		{models.User{}, models.Team{},
+			"update,delete,get-join-code,update-join-code,update-members,get-stats",
+			func(ent, res any) bool {
+				user := ent.(models.User)
+				return user.Admin ||
+					user.HasTeamRole(res.(models.Team).ID, models.TeamAdmin)
+			},
+		},
+		// Any member of the team can see its members and their leaderboard.
+		{models.User{}, models.Team{},
+			"list-members,get-leaderboard", func(ent, res any) bool {
+				user := ent.(models.User)
+				return user.Admin ||
+					user.HasTeamRole(res.(models.Team).ID, models.TeamMember)
+			},
+		},
+	}
+}
+
+// teamMemberColumns are the columns of the users shown in the lists of
+// members of a team.
+var teamMemberColumns = []string{
+	"id", "name", "username", "trip_count", "total_dist", "credits",
+	"hidden_profile",
+}
+
+// withJoinCode runs fn in a transaction with a new join code, generating
+// another one while the code is taken by another team.
+func withJoinCode(db *gorm.DB, fn func(code string, tx *gorm.DB) error) error {
+	var err error
+	for i := 0; i < teamJoinCodeAttempts; i++ {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			return fn(random.CodeString(teamJoinCodeLength), tx)
+		})
+		if err == nil || !duplicateJoinCodeRegex.MatchString(err.Error()) {
+			return err
+		}
+	}
+	return err
+}
+
+// teamRef returns a reference to the team with the given ID, to authorize
+// operations that depend on it.
+func teamRef(id string) (models.Team, error) {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return models.Team{}, httputil.NewError(httputil.BadRequest, err)
+	}
+	return models.Team{BaseModel: models.BaseModel{ID: uid}}, nil
+}
+
+// authorizeTeam returns a reference to the team with the given ID if the user
+// can perform the action on it.
+func (c *TeamController) authorizeTeam(
+	user models.User,
+	action string,
+	id string,
+) (models.Team, error) {
+	team, err := teamRef(id)
+	if err != nil {
+		return team, err
+	}
+
+	if ok := c.acl.Authorize(user, action, team); !ok {
+		return team, httputil.NewErrorMsg(
+			httputil.Forbidden,
+			httputil.ForbiddenMessage,
+		)
+	}
+	return team, nil
+}
+
+// findTeam retrieves the team with the given ID. The join code is only
+// included if the user can see it.
+func (c *TeamController) findTeam(
+	user models.User,
+	id string,
+	tx *gorm.DB,
+) (models.Team, error) {
+	var team models.Team
+	if err := tx.First(&team, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return team, resourceNotFoundErr("team")
+		}
+		return team, err
+	}
+
+	if !c.acl.Authorize(user, "get-join-code", team) {
+		team.JoinCode = ""
+	}
+	return team, nil
+}
+
+type ListTeamsFilters struct {
+	Pagination
+}
+
+// List the teams of the logged-in user.
+//
+//	@Summary		List the teams the user is a member of
+//	@Description	The join codes are only included in the teams the user is an admin of.
+//	@Tags			teams
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			filters		query		ListTeamsFilters	false	"Filters"
+//	@Success		200			{array}		models.Team
+//	@Failure		400,401,500	{object}	middleware.ApiError
+//	@Router			/teams [get]
+func (c *TeamController) List(
+	filters ListTeamsFilters,
+	ctx *gin.Context,
+) ([]models.Team, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return nil, err
+	}
+
+	var teams []models.Team
+	err = c.db.
+		Where(`id IN (
+			SELECT team_id
+			FROM team_memberships
+			WHERE user_id = ? AND left_at IS NULL
+		)`, user.ID).
+		Order("name, id").
+		Limit(filters.Limit).
+		Offset(filters.Offset).
+		Find(&teams).Error
+
+	for i := range teams {
+		if !c.acl.Authorize(user, "get-join-code", teams[i]) {
+			teams[i].JoinCode = ""
+		}
+	}
+
+	return teams, err
+}
+
+// Get retrieves a team.
+//
+//	@Summary		Retrieve a team by ID
+//	@Description	The join code is only included for the admins of the team.
+//	@Tags			teams
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id				path		string	true	"Team Id"	Format(UUID)
+//	@Success		200				{object}	models.Team
+//	@Failure		400,401,404,500	{object}	middleware.ApiError
+//	@Router			/teams/{id} [get]
+func (c *TeamController) Get(id string, ctx *gin.Context) (models.Team, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return models.Team{}, err
+	}
+
+	if _, err := teamRef(id); err != nil {
+		return models.Team{}, err
+	}
+
+	return c.findTeam(user, id, c.db)
+}
+
+type CreateTeamParams struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+// Create a team.
+//
+//	@Summary		Create a new team and return it
+//	@Description	The user that creates the team becomes its admin.
+//	@Tags			teams
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			params		body		CreateTeamParams	true	"Params"
+//	@Success		201			{object}	models.Team
+//	@Failure		400,401,500	{object}	middleware.ApiError
+//	@Router			/teams [post]
+func (c *TeamController) Create(
+	params CreateTeamParams,
+	ctx *gin.Context,
+) (models.Team, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return models.Team{}, err
+	}
+
+	team := models.Team{
+		Name:        params.Name,
+		Description: params.Description,
+	}
+
+	err = c.db.Transaction(func(tx *gorm.DB) error {
+		if err := withJoinCode(tx, func(code string, tx *gorm.DB) error {
+			team.JoinCode = code
+			return tx.Create(&team).Error
+		}); err != nil {
+			return err
+		}
+
+		return tx.Omit(clause.Associations).Create(&models.TeamMembership{
+			TeamID:   team.ID,
+			UserID:   user.ID,
+			Role:     models.TeamAdmin,
+			JoinedAt: time.Now(),
+		}).Error
+	})
+
+	return team, err
+}
+
+type UpdateTeamParams struct {
+	Name        *string `json:"name,omitempty" binding:"omitempty,min=1"`
+	Description *string `json:"description,omitempty"`
+}
+
+// Update a team.
+//
+//	@Summary		Update a team by Id and return it
+//	@Description	Only the given fields are updated.
+//	@Tags			teams
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id					path		string				true	"Team Id"	Format(UUID)
+//	@Param			params				body		UpdateTeamParams	true	"Params"
+//	@Success		200					{object}	models.Team
+//	@Failure		400,401,403,404,500	{object}	middleware.ApiError
+//	@Router			/teams/{id} [put]
+func (c *TeamController) Update(
+	id string,
+	params UpdateTeamParams,
+	ctx *gin.Context,
+) (models.Team, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return models.Team{}, err
+	}
+
+	team, err := c.authorizeTeam(user, "update", id)
+	if err != nil {
+		return models.Team{}, err
+	}
+
+	updates := map[string]any{}
+	if params.Name != nil {
+		updates["name"] = *params.Name
+	}
+	if params.Description != nil {
+		updates["description"] = *params.Description
+	}
+
+	if len(updates) > 0 {
+		if err := c.db.Model(&team).Updates(updates).Error; err != nil {
+			return models.Team{}, err
+		}
+	}
+
+	return c.findTeam(user, id, c.db)
+}
+
+// Delete a team.
+//
+//	@Summary		Delete a team by Id
+//	@Description	The memberships of the team are also deleted.
+//	@Tags			teams
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id	path	string	true	"Team Id"	Format(UUID)
+//	@Success		204
+//	@Failure		400,401,403,404,500	{object}	middleware.ApiError
+//	@Router			/teams/{id} [delete]
+func (c *TeamController) Delete(id string, ctx *gin.Context) error {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.authorizeTeam(user, "delete", id); err != nil {
+		return err
+	}
+
+	result := c.db.Delete(&models.Team{}, "id = ?", id)
+	if result.RowsAffected == 0 {
+		return resourceNotFoundErr("team")
+	}
+	return result.Error
+}
+
+// RegenerateJoinCode replaces the join code of a team.
+//
+//	@Summary		Replace the join code of a team
+//	@Description	The previous code can no longer be used to join the team.
+//	@Tags			teams
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id					path		string	true	"Team Id"	Format(UUID)
+//	@Success		200					{object}	models.Team
+//	@Failure		400,401,403,404,500	{object}	middleware.ApiError
+//	@Router			/teams/{id}/join-code [post]
+func (c *TeamController) RegenerateJoinCode(
+	id string,
+	ctx *gin.Context,
+) (models.Team, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return models.Team{}, err
+	}
+
+	team, err := c.authorizeTeam(user, "update-join-code", id)
+	if err != nil {
+		return models.Team{}, err
+	}
+
+	if err := withJoinCode(c.db, func(code string, tx *gorm.DB) error {
+		res := tx.Model(&team).Update("join_code", code)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return resourceNotFoundErr("team")
+		}
+		return nil
+	}); err != nil {
+		return models.Team{}, err
+	}
+
+	return c.findTeam(user, id, c.db)
+}
+
+type JoinTeamParams struct {
+	// Code shared by the admins of the team.
+	Code string `json:"code" binding:"required"`
+}
+
+// Join a team.
+//
+//	@Summary	Join a team with its join code
+//	@Tags		teams
+//	@Produce	json
+//	@Security	OIDCToken
+//	@Security	AuthHeader
+//	@Param		params			body		JoinTeamParams	true	"Params"
+//	@Success	201				{object}	models.TeamMembership
+//	@Failure	400,401,404,500	{object}	middleware.ApiError
+//	@Router		/teams/join [post]
+func (c *TeamController) Join(
+	params JoinTeamParams,
+	ctx *gin.Context,
+) (models.TeamMembership, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return models.TeamMembership{}, err
+	}
+
+	var team models.Team
+	if err := c.db.First(&team, "join_code = ?",
+		strings.ToUpper(strings.TrimSpace(params.Code))).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.TeamMembership{}, resourceNotFoundErr("team")
+		}
+		return models.TeamMembership{}, err
+	}
+
+	if user.HasTeamRole(team.ID, models.TeamMember) {
+		return models.TeamMembership{}, httputil.NewErrorMsg(
+			httputil.BadRequest,
+			"the user is already a member of the team",
+		)
+	}
+
+	membership := models.TeamMembership{
+		TeamID:   team.ID,
+		UserID:   user.ID,
+		Role:     models.TeamMember,
+		JoinedAt: time.Now(),
+	}
+	if err := c.db.Omit(clause.Associations).
+		Create(&membership).Error; err != nil {
+		// The user joined the team concurrently.
+		if duplicateTeamMembershipRegex.MatchString(err.Error()) {
+			return models.TeamMembership{}, httputil.NewErrorMsg(
+				httputil.BadRequest,
+				"the user is already a member of the team",
+			)
+		}
+		return models.TeamMembership{}, err
+	}
+
+	team.JoinCode = ""
+	membership.Team = &team
+	return membership, nil
+}
+
+// leaveTeam ends the current membership of the user with the given ID in the
+// team. The last admin of a team can't leave it while it has other members.
+func leaveTeam(teamID uuid.UUID, userID string, tx *gorm.DB) error {
+	var membership models.TeamMembership
+	if err := tx.
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&membership, "team_id = ? AND user_id = ? AND left_at IS NULL",
+			teamID, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return resourceNotFoundErr("member")
+		}
+		return err
+	}
+
+	if membership.Role == models.TeamAdmin {
+		var others []models.TeamMembership
+		if err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("team_id = ? AND left_at IS NULL", teamID).
+			Where("user_id <> ?", userID).
+			Find(&others).Error; err != nil {
+			return err
+		}
+
+		admins := 0
+		for _, other := range others {
+			if other.Role == models.TeamAdmin {
+				admins++
+			}
+		}
+		if len(others) > 0 && admins == 0 {
+			return httputil.NewErrorMsg(
+				httputil.BadRequest,
+				"the last admin can't leave a team that has other members",
+			)
+		}
+	}
+
+	return tx.Model(&membership).Update("left_at", time.Now()).Error
+}
+
+// Leave a team.
+//
+//	@Summary		Leave a team
+//	@Description	The trips made while the user was a member still count towards the team's
+//	@Description	totals. The last admin of a team can't leave it while it has other members.
+//	@Tags			teams
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id	path	string	true	"Team Id"	Format(UUID)
+//	@Success		204
+//	@Failure		400,401,404,500	{object}	middleware.ApiError
+//	@Router			/teams/{id}/membership [delete]
+func (c *TeamController) Leave(id string, ctx *gin.Context) error {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return err
+	}
+
+	team, err := teamRef(id)
+	if err != nil {
+		return err
+	}
+
+	return c.db.Transaction(func(tx *gorm.DB) error {
+		return leaveTeam(team.ID, user.ID.String(), tx)
+	})
+}
+
+type ListTeamMembersFilters struct {
+	Pagination
+	// Former includes the past memberships of the team's members.
+	Former bool `form:"former"`
+}
+
+// ListMembers lists the members of a team.
+//
+//	@Summary		List the members of a team
+//	@Description	Members are sorted by the date in which they joined the team. Past memberships
+//	@Description	are only included if requested. The profiles of users with a hidden profile are
+//	@Description	omitted.
+//	@Tags			teams
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id				path		string					true	"Team Id"	Format(UUID)
+//	@Param			filters			query		ListTeamMembersFilters	false	"Filters"
+//	@Success		200				{array}		models.TeamMembership
+//	@Failure		400,401,403,500	{object}	middleware.ApiError
+//	@Router			/teams/{id}/members [get]
+func (c *TeamController) ListMembers(
+	id string,
+	filters ListTeamMembersFilters,
+	ctx *gin.Context,
+) ([]models.TeamMembership, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return nil, err
+	}
+
+	team, err := c.authorizeTeam(user, "list-members", id)
+	if err != nil {
+		return nil, err
+	}
+
+	q := c.db.
+		Preload("User", func(db *gorm.DB) *gorm.DB {
+			return db.Select(teamMemberColumns)
+		}).
+		Where("team_id = ?", team.ID)
+	if !filters.Former {
+		q = q.Where("left_at IS NULL")
+	}
+
+	var members []models.TeamMembership
+	err = q.
+		Limit(filters.Limit).
+		Offset(filters.Offset).
+		Order("joined_at, user_id").
+		Find(&members).Error
+	for i := range members {
+		members[i].User = hideProfile(c.acl, user, members[i].User)
+	}
+
+	return members, err
+}
+
+type SetTeamMemberParams struct {
+	UserID uuid.UUID       `json:"userId" binding:"required"`
+	Role   models.TeamRole `json:"role" binding:"required,oneof=member admin" example:"admin"`
+}
+
+// SetMemberRole changes the role of a member of a team.
+//
+//	@Summary		Change the role of a member of a team
+//	@Description	Admins can edit the team, manage its members and join code, and export the
+//	@Description	stats of its members. The last admin of a team can't be demoted.
+//	@Tags			teams
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id					path		string				true	"Team Id"	Format(UUID)
+//	@Param			params				body		SetTeamMemberParams	true	"Params"
+//	@Success		200					{object}	models.TeamMembership
+//	@Failure		400,401,403,404,500	{object}	middleware.ApiError
+//	@Router			/teams/{id}/members [put]
+func (c *TeamController) SetMemberRole(
+	id string,
+	params SetTeamMemberParams,
+	ctx *gin.Context,
+) (models.TeamMembership, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return models.TeamMembership{}, err
+	}
+
+	team, err := c.authorizeTeam(user, "update-members", id)
+	if err != nil {
+		return models.TeamMembership{}, err
+	}
+
+	var membership models.TeamMembership
+	err = c.db.Transaction(func(tx *gorm.DB) error {
+		if params.Role != models.TeamAdmin {
+			var admins []models.TeamMembership
+			if err := tx.
+				Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("team_id = ? AND left_at IS NULL", team.ID).
+				Where("role = ?", models.TeamAdmin).
+				Find(&admins).Error; err != nil {
+				return err
+			}
+			if len(admins) == 1 && admins[0].UserID == params.UserID {
+				return httputil.NewErrorMsg(
+					httputil.BadRequest,
+					"the last admin of a team can't be demoted",
+				)
+			}
+		}
+
+		res := tx.Model(&models.TeamMembership{}).
+			Where("team_id = ? AND user_id = ?", team.ID, params.UserID).
+			Where("left_at IS NULL").
+			Update("role", params.Role)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return resourceNotFoundErr("member")
+		}
+
+		return tx.
+			Preload("User", func(db *gorm.DB) *gorm.DB {
+				return db.Select(teamMemberColumns)
+			}).
+			First(&membership, "team_id = ? AND user_id = ? AND left_at IS NULL",
+				team.ID, params.UserID).Error
+	})
+
+	return membership, err
+}
+
+type RemoveTeamMemberParams struct {
+	UserID string `form:"userId" binding:"required,uuid"`
+}
+
+// RemoveMember removes a member from a team.
+//
+//	@Summary		Remove a member from a team
+//	@Description	The trips made while the user was a member still count towards the team's
+//	@Description	totals.
+//	@Tags			teams
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id		path	string					true	"Team Id"	Format(UUID)
+//	@Param			params	query	RemoveTeamMemberParams	true	"Params"
+//	@Success		204
+//	@Failure		400,401,403,404,500	{object}	middleware.ApiError
+//	@Router			/teams/{id}/members [delete]
+func (c *TeamController) RemoveMember(
+	id string,
+	params RemoveTeamMemberParams,
+	ctx *gin.Context,
+) error {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return err
+	}
+
+	team, err := c.authorizeTeam(user, "update-members", id)
+	if err != nil {
+		return err
+	}
+
+	return c.db.Transaction(func(tx *gorm.DB) error {
+		return leaveTeam(team.ID, params.UserID, tx)
+	})
+}
+
+// Leaderboard lists the members of a team in the leaderboard of the team.
+//
+//	@Summary		List top 10 members in the leaderboard of a team
+//	@Description	Members are ranked by the distance of the valid trips they made while they were
+//	@Description	members of the team.
+//	@Tags			teams
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id					path		string	true	"Team Id"	Format(UUID)
+//	@Success		200					{object}	LeaderboardResult
+//	@Failure		400,401,403,500		{object}	middleware.ApiError
+//	@Router			/teams/{id}/leaderboard [get]
+func (c *TeamController) Leaderboard(
+	id string,
+	ctx *gin.Context,
+) (LeaderboardResult, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return LeaderboardResult{}, err
+	}
+
+	team, err := c.authorizeTeam(user, "get-leaderboard", id)
+	if err != nil {
+		return LeaderboardResult{}, err
+	}
+
+	res := LeaderboardResult{}
+
+	err = c.db.Transaction(func(tx *gorm.DB) error {
+		top, err := query.Teams.
+			MembersTop(team.ID.String(), user.ID.String(), tx)
+		if err != nil {
+			return err
+		}
+		res.Entries = top
+
+		userPosition, err := query.Teams.
+			MemberPositionOf(team.ID.String(), user.ID.String(), tx)
+		res.UserPosition = userPosition
+		return err
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+
+	return res, err
+}
+
+// Stats exports the aggregated stats of the members of a team.
+//
+//	@Summary		Export the stats of the members of a team
+//	@Description	Lists the valid trips made in the given period by each member of the team,
+//	@Description	including former members, while they were members of the team.
+//	@Tags			teams
+//	@Produce		text/csv,text/html
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id					path		string			true	"Team Id"	Format(UUID)
+//	@Param			filters				query		ReportFilters	true	"Filters"
+//	@Success		200					{file}		file
+//	@Failure		400,401,403,404,500	{object}	middleware.ApiError
+//	@Router			/teams/{id}/stats [get]
+func (c *TeamController) Stats(
+	id string,
+	filters ReportFilters,
+	ctx *gin.Context,
+) ([]byte, string, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if _, err := c.authorizeTeam(user, "get-stats", id); err != nil {
+		return nil, "", err
+	}
+
+	team, err := c.findTeam(user, id, c.db)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rows, err := query.Teams.Stats(id, filters.DateFrom, filters.DateTo, c.db)
+	if err != nil {
+		return nil, "", err
+	}
+
+	statement := reports.Statement{
+		Title:    team.Name,
+		Subtitle: "Team members statement",
+		From:     string(filters.DateFrom),
+		To:       string(filters.DateTo),
+		Columns: []string{
+			"Name", "Username", "Joined", "Current member",
+			"Trips", "Distance (km)", "Credits",
+		},
+		NumericColumns: []int{4, 5, 6},
+		GeneratedAt:    time.Now(),
+	}
+
+	var trips uint
+	var dist, credits float64
+	for _, row := range rows {
+		name := row.Name
+		if row.Anonymized {
+			name = "Deleted account"
+		}
+		current := "No"
+		if row.Active {
+			current = "Yes"
+		}
+
+		statement.Rows = append(statement.Rows, []string{
+			name,
+			row.Username,
+			string(row.JoinedAt),
+			current,
+			strconv.FormatUint(uint64(row.TripCount), 10),
+			strconv.FormatFloat(row.TotalDist, 'f', 2, 64),
+			formatCredits(row.Credits),
+		})
+		trips += row.TripCount
+		dist += row.TotalDist
+		credits += row.Credits
+	}
+	statement.Totals = []string{
+		"Total", "", "", "",
+		strconv.FormatUint(uint64(trips), 10),
+		strconv.FormatFloat(dist, 'f', 2, 64),
+		formatCredits(credits),
+	}
+
+	return writeStatement(statement, filters, "team", ctx)
+}
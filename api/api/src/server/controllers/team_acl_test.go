@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/server/access"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTeamACL(t *testing.T) {
+	acl := access.New()
+	registerAllRules(&TeamController{}, acl)
+
+	team := models.Team{BaseModel: models.BaseModel{ID: uuid.New()}}
+	admin := models.User{TeamMemberships: []models.TeamMembership{
+		{TeamID: team.ID, Role: models.TeamAdmin},
+	}}
+	member := models.User{TeamMemberships: []models.TeamMembership{
+		{TeamID: team.ID, Role: models.TeamMember},
+	}}
+	now := time.Now()
+	former := models.User{TeamMemberships: []models.TeamMembership{
+		{TeamID: team.ID, Role: models.TeamAdmin, LeftAt: &now},
+	}}
+	other := models.User{TeamMemberships: []models.TeamMembership{
+		{TeamID: uuid.New(), Role: models.TeamAdmin},
+	}}
+
+	for i, tc := range []struct {
+		ent    models.User
+		action string
+		exp    bool
+	}{
+		{ent: models.User{Admin: true}, action: "update", exp: true},
+		{ent: admin, action: "update", exp: true},
+		{ent: member, action: "update", exp: false},
+		{ent: former, action: "update", exp: false},
+		{ent: other, action: "update", exp: false},
+		{ent: admin, action: "delete", exp: true},
+		{ent: member, action: "delete", exp: false},
+		{ent: admin, action: "get-join-code", exp: true},
+		{ent: member, action: "get-join-code", exp: false},
+		{ent: admin, action: "update-join-code", exp: true},
+		{ent: member, action: "update-join-code", exp: false},
+		{ent: admin, action: "update-members", exp: true},
+		{ent: member, action: "update-members", exp: false},
+		{ent: admin, action: "get-stats", exp: true},
+		{ent: member, action: "get-stats", exp: false},
+		{ent: models.User{Admin: true}, action: "list-members", exp: true},
+		{ent: admin, action: "list-members", exp: true},
+		{ent: member, action: "list-members", exp: true},
+		{ent: former, action: "list-members", exp: false},
+		{ent: other, action: "list-members", exp: false},
+		{ent: member, action: "get-leaderboard", exp: true},
+		{ent: other, action: "get-leaderboard", exp: false},
+	} {
+		assert.Equal(
+			t,
+			tc.exp,
+			acl.Authorize(tc.ent, tc.action, team),
+			"failed on test %d", i,
+		)
+	}
+}
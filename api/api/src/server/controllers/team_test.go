@@ -0,0 +1,380 @@
+package controllers
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/types"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/server/access"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/random"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+type TeamControllerTestSuite struct {
+	suite.Suite
+	teams       *TeamController
+	users       *UserController
+	leaderboard *LeaderboardController
+	db          *gorm.DB
+	acl         *access.ACL
+}
+
+// Run each test in a transaction.
+func (s *TeamControllerTestSuite) SetupTest() {
+	tx := testDb.Begin()
+	s.db = tx
+	s.teams = &TeamController{tx, s.acl}
+	s.users = &UserController{tx, s.acl, "", nil}
+	s.leaderboard = &LeaderboardController{tx}
+}
+
+// Rollback the transaction after each test.
+func (s *TeamControllerTestSuite) TearDownTest() {
+	s.db.Rollback()
+}
+
+// createTrip creates a valid trip of the user at the given time.
+func (s *TeamControllerTestSuite) createTrip(
+	userID uuid.UUID,
+	dist float64,
+	at time.Time,
+) {
+	s.Require().NoError(s.db.Create(&models.Trip{
+		BaseModel: models.BaseModel{CreatedAt: at},
+		GPX:       []byte(`<gpx version="1.1"><trk></trk></gpx>`),
+		GPXHash:   []byte(random.String(32)),
+		IsValid:   true,
+		Distance:  dist,
+		Credits:   dist / 2,
+		UserID:    userID,
+	}).Error)
+}
+
+// join adds the user to the team at the given time.
+func (s *TeamControllerTestSuite) join(
+	team models.Team,
+	ctx *gin.Context,
+	at time.Time,
+) models.TeamMembership {
+	membership, err := s.teams.Join(JoinTeamParams{Code: team.JoinCode}, ctx)
+	s.Require().NoError(err)
+	s.Require().NoError(s.db.Model(&membership).
+		Update("joined_at", at).Error)
+	return membership
+}
+
+func (s *TeamControllerTestSuite) TestCreateAndJoin() {
+	admin, adminCtx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+	_, ctx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+
+	// ------------------------------------- //
+	// Creator becomes the admin of the team //
+	// ------------------------------------- //
+	team, err := s.teams.Create(CreateTeamParams{Name: "Pensar Mais"}, adminCtx)
+	s.Require().NoError(err)
+	s.Len(team.JoinCode, teamJoinCodeLength)
+
+	members, err := s.teams.ListMembers(team.ID.String(),
+		ListTeamMembersFilters{}, adminCtx)
+	s.Require().NoError(err)
+	s.Require().Len(members, 1)
+	s.Equal(admin.ID, members[0].UserID)
+	s.Equal(models.TeamAdmin, members[0].Role)
+
+	// --------------------------------------- //
+	// Join code is only visible to the admins //
+	// --------------------------------------- //
+	res, err := s.teams.Get(team.ID.String(), ctx)
+	s.Require().NoError(err)
+	s.Empty(res.JoinCode)
+
+	// ------------------------- //
+	// Fails with a unknown code //
+	// ------------------------- //
+	_, err = s.teams.Join(JoinTeamParams{Code: "UNKNOWN"}, ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Record Not Found, "+
+		"message: team not found"+
+		"}")
+
+	// ------------------------ //
+	// Joins a team by its code //
+	// ------------------------ //
+	membership, err := s.teams.Join(JoinTeamParams{
+		Code: strings.ToLower(team.JoinCode),
+	}, ctx)
+	s.Require().NoError(err)
+	s.Equal(models.TeamMember, membership.Role)
+	s.Nil(membership.LeftAt)
+
+	_, err = s.teams.Join(JoinTeamParams{Code: team.JoinCode}, ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Bad Request, "+
+		"message: the user is already a member of the team"+
+		"}")
+
+	teams, err := s.teams.List(ListTeamsFilters{}, ctx)
+	s.Require().NoError(err)
+	s.Require().Len(teams, 1)
+	s.Equal(team.ID, teams[0].ID)
+
+	// ------------------------------------ //
+	// Regenerating the code invalidates it //
+	// ------------------------------------ //
+	updated, err := s.teams.RegenerateJoinCode(team.ID.String(), adminCtx)
+	s.Require().NoError(err)
+	s.NotEqual(team.JoinCode, updated.JoinCode)
+
+	_, err = s.teams.RegenerateJoinCode(team.ID.String(), ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Forbidden Action, "+
+		"message: the user does not have access to this operation"+
+		"}")
+}
+
+func (s *TeamControllerTestSuite) TestJoinCodeCollision() {
+	var codes []string
+	err := withJoinCode(s.db, func(code string, tx *gorm.DB) error {
+		codes = append(codes, code)
+		if len(codes) < 3 {
+			return errors.New("ERROR: duplicate key value violates " +
+				"unique constraint \"teams_join_code_key\" (SQLSTATE 23505)")
+		}
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Len(codes, 3)
+
+	// Gives up after a few attempts.
+	codes = nil
+	err = withJoinCode(s.db, func(code string, tx *gorm.DB) error {
+		codes = append(codes, code)
+		return errors.New("ERROR: duplicate key value violates " +
+			"unique constraint \"teams_join_code_key\" (SQLSTATE 23505)")
+	})
+	s.Error(err)
+	s.Len(codes, teamJoinCodeAttempts)
+}
+
+func (s *TeamControllerTestSuite) TestMembers() {
+	admin, adminCtx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+	member, ctx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+
+	team, err := s.teams.Create(CreateTeamParams{Name: "School"}, adminCtx)
+	s.Require().NoError(err)
+	_, err = s.teams.Join(JoinTeamParams{Code: team.JoinCode}, ctx)
+	s.Require().NoError(err)
+
+	// ---------------------------------------------- //
+	// The last admin can't leave while others remain //
+	// ---------------------------------------------- //
+	err = s.teams.Leave(team.ID.String(), adminCtx)
+	s.EqualError(err, "ApiError{"+
+		"code: Bad Request, "+
+		"message: the last admin can't leave a team that has other members"+
+		"}")
+
+	// ------------------------------- //
+	// The last admin can't be demoted //
+	// ------------------------------- //
+	_, err = s.teams.SetMemberRole(team.ID.String(), SetTeamMemberParams{
+		UserID: admin.ID,
+		Role:   models.TeamMember,
+	}, adminCtx)
+	s.EqualError(err, "ApiError{"+
+		"code: Bad Request, "+
+		"message: the last admin of a team can't be demoted"+
+		"}")
+
+	// ---------------------------- //
+	// Only admins can change roles //
+	// ---------------------------- //
+	_, err = s.teams.SetMemberRole(team.ID.String(), SetTeamMemberParams{
+		UserID: member.ID,
+		Role:   models.TeamAdmin,
+	}, ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Forbidden Action, "+
+		"message: the user does not have access to this operation"+
+		"}")
+
+	membership, err := s.teams.SetMemberRole(team.ID.String(),
+		SetTeamMemberParams{
+			UserID: member.ID,
+			Role:   models.TeamAdmin,
+		}, adminCtx)
+	s.Require().NoError(err)
+	s.Equal(models.TeamAdmin, membership.Role)
+
+	// Admins can be demoted while there are other admins.
+	membership, err = s.teams.SetMemberRole(team.ID.String(),
+		SetTeamMemberParams{
+			UserID: admin.ID,
+			Role:   models.TeamMember,
+		}, adminCtx)
+	s.Require().NoError(err)
+	s.Equal(models.TeamMember, membership.Role)
+
+	s.Require().NoError(s.teams.Leave(team.ID.String(), adminCtx))
+
+	// -------------------------------------- //
+	// Former members are kept in the history //
+	// -------------------------------------- //
+	members, err := s.teams.ListMembers(team.ID.String(),
+		ListTeamMembersFilters{}, ctx)
+	s.Require().NoError(err)
+	s.Require().Len(members, 1)
+	s.Equal(member.ID, members[0].UserID)
+
+	members, err = s.teams.ListMembers(team.ID.String(),
+		ListTeamMembersFilters{Former: true}, ctx)
+	s.Require().NoError(err)
+	s.Require().Len(members, 2)
+	s.Equal(admin.ID, members[0].UserID)
+	s.NotNil(members[0].LeftAt)
+
+	// ------------------------------------ //
+	// Omits the profiles of hidden members //
+	// ------------------------------------ //
+	_, err = s.users.Update(admin.ID.String(), UpdateUserParams{
+		HiddenProfile: &[]bool{true}[0],
+	}, adminCtx)
+	s.Require().NoError(err)
+
+	members, err = s.teams.ListMembers(team.ID.String(),
+		ListTeamMembersFilters{Former: true}, ctx)
+	s.Require().NoError(err)
+	s.Require().Len(members, 2)
+	s.Equal(admin.ID, members[0].UserID)
+	s.Nil(members[0].User)
+	s.Require().NotNil(members[1].User)
+	s.Equal(member.ID, members[1].User.ID)
+
+	// ---------------------------- //
+	// Fails to remove a non-member //
+	// ---------------------------- //
+	err = s.teams.RemoveMember(team.ID.String(), RemoveTeamMemberParams{
+		UserID: admin.ID.String(),
+	}, ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Record Not Found, "+
+		"message: member not found"+
+		"}")
+}
+
+func (s *TeamControllerTestSuite) TestLeaderboard() {
+	users := make([]models.User, 3)
+	ctxs := make([]*gin.Context, 3)
+	for i := range users {
+		var err error
+		users[i], ctxs[i], err = createRandomUser(s.users)
+		s.Require().NoError(err)
+	}
+
+	now := time.Now()
+	team, err := s.teams.Create(CreateTeamParams{Name: "Company"}, ctxs[0])
+	s.Require().NoError(err)
+	s.Require().NoError(s.db.Model(&models.TeamMembership{}).
+		Where("team_id = ?", team.ID).
+		Update("joined_at", now.Add(-10*24*time.Hour)).Error)
+	s.join(team, ctxs[1], now.Add(-5*24*time.Hour))
+
+	other, err := s.teams.Create(CreateTeamParams{Name: "Other"}, ctxs[2])
+	s.Require().NoError(err)
+	s.Require().NoError(s.db.Model(&models.TeamMembership{}).
+		Where("team_id = ?", other.ID).
+		Update("joined_at", now.Add(-10*24*time.Hour)).Error)
+
+	// Only the trips made during each membership count.
+	s.createTrip(users[0].ID, 10, now.Add(-20*24*time.Hour))
+	s.createTrip(users[0].ID, 5, now.Add(-2*24*time.Hour))
+	s.createTrip(users[1].ID, 100, now.Add(-6*24*time.Hour))
+	s.createTrip(users[1].ID, 7, now.Add(-24*time.Hour))
+	s.createTrip(users[2].ID, 15, now.Add(-24*time.Hour))
+
+	// ----------------------------- //
+	// Ranks the members of the team //
+	// ----------------------------- //
+	res, err := s.teams.Leaderboard(team.ID.String(), ctxs[0])
+	s.Require().NoError(err)
+	s.Require().Len(res.Entries, 2)
+	s.Equal(users[1].ID, res.Entries[0].ID)
+	s.Equal(7.0, res.Entries[0].TotalDist)
+	s.Equal(5.0, res.Entries[1].TotalDist)
+	s.Equal(2, res.UserPosition)
+
+	_, err = s.teams.Leaderboard(team.ID.String(), ctxs[2])
+	s.EqualError(err, "ApiError{"+
+		"code: Forbidden Action, "+
+		"message: the user does not have access to this operation"+
+		"}")
+
+	// ----------------------------------------------- //
+	// Trips of former members still count to the team //
+	// ----------------------------------------------- //
+	s.Require().NoError(s.teams.Leave(team.ID.String(), ctxs[1]))
+	s.createTrip(users[1].ID, 50, now.Add(time.Minute))
+
+	teams, err := s.leaderboard.Teams(ctxs[0])
+	s.Require().NoError(err)
+	s.Require().Len(teams.UserTeams, 1)
+	s.Equal(team.ID, teams.UserTeams[0].ID)
+	s.Equal(12.0, teams.UserTeams[0].TotalDist)
+	s.Equal(uint(2), teams.UserTeams[0].TripCount)
+	s.Equal(uint(1), teams.UserTeams[0].MemberCount)
+
+	s.Require().NotEmpty(teams.Entries)
+	s.Equal(other.ID, teams.Entries[0].ID)
+}
+
+func (s *TeamControllerTestSuite) TestStats() {
+	admin, adminCtx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+	member, ctx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+
+	team, err := s.teams.Create(CreateTeamParams{Name: "Stats"}, adminCtx)
+	s.Require().NoError(err)
+	s.join(team, ctx, time.Now().Add(-time.Hour))
+	s.createTrip(member.ID, 12.5, time.Now())
+	s.createTrip(admin.ID, 3, time.Now())
+
+	today := types.Date(time.Now().Format(types.DateFormat))
+	filters := ReportFilters{DateFrom: today, DateTo: today, Format: "csv"}
+
+	// ------------------------------------------- //
+	// Only the admins can export the team's stats //
+	// ------------------------------------------- //
+	_, _, err = s.teams.Stats(team.ID.String(), filters, ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Forbidden Action, "+
+		"message: the user does not have access to this operation"+
+		"}")
+
+	// --------------------------------- //
+	// Exports the totals of each member //
+	// --------------------------------- //
+	data, contentType, err := s.teams.Stats(team.ID.String(), filters, adminCtx)
+	s.Require().NoError(err)
+	s.Contains(contentType, "text/csv")
+	s.Contains(string(data), member.Name+","+member.Username)
+	s.Contains(string(data), "12.50")
+	s.Contains(string(data), "Total,,,,2,15.50,7.75")
+}
+
+func TestTeamController(t *testing.T) {
+	acl := access.New()
+	registerAllRules(&TeamController{}, acl)
+	registerAllRules(&UserController{}, acl)
+	suite.Run(t, &TeamControllerTestSuite{acl: acl})
+}
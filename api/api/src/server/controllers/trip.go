@@ -3,6 +3,7 @@ package controllers
 import (
 	"crypto/sha256"
 	"errors"
+	"fmt"
 	"log"
 	"math"
 	"regexp"
@@ -17,7 +18,9 @@ import (
 	"bitbucket.org/pensarmais/cycleforlisbon/src/util/latlon"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/worker"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TripController struct {
@@ -27,7 +30,10 @@ type TripController struct {
 	geocoder interface {
 		ReverseAddr(coords latlon.Coords) string
 	}
+	parishes parishLocator
 	jobCodec *gobutil.GobCodec[jobs.UpdateAchievementsArgs]
+
+	initiativeCodec *gobutil.GobCodec[jobs.InitiativeEndedArgs]
 }
 
 func (TripController) Rules() []rule {
@@ -124,6 +130,7 @@ func (c *TripController) Get(id string, ctx *gin.Context) (models.Trip, error) {
 	var trip models.Trip
 	if err = tx.Model(&models.Trip{}).
 		Joins("Initiative").
+		Preload("Splits").
 		First(&trip, "trips.id = ?", id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return models.Trip{}, resourceNotFoundErr("trip")
@@ -191,32 +198,175 @@ func (c *TripController) addAddresses(trip *models.Trip, gpxTrip *gpx.GPX) {
 	)
 }
 
-// updateStats credits the user's current initiative and updates the user's
-// stats.
-func updateStats(trip *models.Trip, user *models.User, tx *gorm.DB) error {
+// addParishes assigns the start and end point of the trip to the parishes
+// that contain them. The trip is uploaded regardless of failures to read the
+// parishes, as it can be assigned to them later.
+func (c *TripController) addParishes(trip *models.Trip) {
+	var err error
+	trip.StartParishID, err = c.parishes.Locate(
+		latlon.Coords{Lat: trip.StartLat, Lon: trip.StartLon},
+	)
+	if err != nil {
+		log.Printf("failed to locate the start of the trip in the parishes: %v", err)
+		return
+	}
+	trip.EndParishID, err = c.parishes.Locate(
+		latlon.Coords{Lat: trip.EndLat, Lon: trip.EndLon},
+	)
+	if err != nil {
+		log.Printf("failed to locate the end of the trip in the parishes: %v", err)
+	}
+}
+
+// updateStats credits the initiatives in the user's allocation, records the
+// credits in the ledger and updates the user's stats.
+//
+// The credits of each initiative are recorded in the ledger as they're
+// credited, along with the credits matched by its sponsors.
+//
+// Each initiative is credited with its share of the credits of the trip. If
+// the initiative is restricted to an area, the share is of the distance of the
+// trip inside it. The credits that aren't awarded to any initiative are
+// recorded separately. Returns the IDs of the initiatives that reached their
+// goal, whose supporters must be notified once the transaction is committed.
+func (c *TripController) updateStats(
+	trip *models.Trip,
+	gpxTrip *gpx.GPX,
+	user *models.User,
+	tx *gorm.DB,
+) ([]uuid.UUID, error) {
 	ratio, err := query.Settings.KilometersCreditsRatio(tx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	trip.Credits = math.Floor(trip.Distance / float64(ratio))
-	if err = tx.Save(&trip).Error; err != nil {
-		return err
+
+	allocation, err := query.Allocations.Current(user.ID.String(), tx)
+	if err != nil {
+		return nil, err
+	}
+	if allocation.ID != uuid.Nil {
+		trip.AllocationID = &allocation.ID
 	}
 
-	if trip.InitiativeID != nil {
-		if err = query.Initiatives.
-			Credit(trip.InitiativeID.String(), trip.Credits, tx); err != nil {
-			// Don't return an error if the initiative has ended.
-			if errors.Is(err, query.ErrInitiativeEnded) {
-				log.Println("not crediting selected initiative because it has ended")
-			} else {
-				return err
-			}
+	var allocated float64
+	var ended []uuid.UUID
+	splits := make([]models.TripSplit, 0, len(allocation.Shares))
+	for _, share := range allocation.Shares {
+		split, reached, err := c.creditInitiative(
+			trip, gpxTrip, share, ratio, tx)
+		if err != nil {
+			return nil, err
+		}
+		if reached {
+			ended = append(ended, share.InitiativeID)
 		}
+		splits = append(splits, split)
+		allocated += split.Credits
 	}
 
-	return query.Users.UpdateStats(user, trip.Distance, trip.Credits, tx)
+	if err = tx.Omit(clause.Associations).Save(&trip).Error; err != nil {
+		return nil, err
+	}
+	if len(splits) > 0 {
+		if err = tx.Create(&splits).Error; err != nil {
+			return nil, err
+		}
+	}
+	trip.Splits = splits
+
+	if remaining := trip.Credits - allocated; remaining > 0 || allocated == 0 {
+		if err = query.Credits.Record(&models.CreditTransaction{
+			Source: models.CreditSourceTrip,
+			Amount: remaining,
+			UserID: user.ID,
+			TripID: &trip.ID,
+		}, tx); err != nil {
+			return nil, err
+		}
+	}
+
+	return ended, query.Users.UpdateStats(user, trip.Distance, trip.Credits, tx)
+}
+
+// creditInitiative credits an initiative with its share of the credits of the
+// trip, and returns the resulting split and whether the initiative reached its
+// goal.
+func (c *TripController) creditInitiative(
+	trip *models.Trip,
+	gpxTrip *gpx.GPX,
+	share models.AllocationShare,
+	ratio float32,
+	tx *gorm.DB,
+) (models.TripSplit, bool, error) {
+	split := models.TripSplit{
+		TripID:       trip.ID,
+		InitiativeID: share.InitiativeID,
+		Percentage:   share.Percentage,
+	}
+
+	var target models.Initiative
+	if err := tx.Model(&models.Initiative{}).
+		Select("area").
+		First(&target, "id = ?", share.InitiativeID).Error; err != nil {
+		return split, false, err
+	}
+
+	dist := trip.Distance
+	var ranges [][2]int
+	if target.Area != nil {
+		dist, ranges = gpxTrip.DistanceWithin(*target.Area)
+		dist = math.Min(dist, trip.Distance)
+	}
+
+	credits := math.Floor(dist/float64(ratio)) *
+		float64(share.Percentage) / 100
+	if credits <= 0 {
+		return split, false, nil
+	}
+
+	initiative, err := query.Initiatives.Credit(models.CreditTransaction{
+		Source:       models.CreditSourceTrip,
+		Amount:       credits,
+		UserID:       trip.UserID,
+		TripID:       &trip.ID,
+		InitiativeID: &share.InitiativeID,
+	}, tx)
+	if err != nil {
+		// Don't return an error if the initiative has ended.
+		if errors.Is(err, query.ErrInitiativeEnded) {
+			log.Println("not crediting allocated initiative because it has ended")
+			return split, false, nil
+		}
+		return split, false, err
+	}
+	split.Distance = dist
+	split.Credits = credits
+	split.AreaRanges = ranges
+
+	return split, initiative.State == models.InitiativeGoalReached, nil
+}
+
+// scheduleInitiativesEnded notifies the supporters of the initiatives that
+// reached their goal. It must be called after the credits are committed, as
+// the job only notifies the supporters of initiatives that have ended.
+func (c *TripController) scheduleInitiativesEnded(ids []uuid.UUID) {
+	for _, id := range ids {
+		args, err := c.initiativeCodec.Encode(jobs.InitiativeEndedArgs{
+			InitiativeID: id,
+		})
+		if err == nil {
+			err = c.tasks.Schedule(&worker.TaskConfig{
+				JobName: jobs.InitiativeEnded,
+				Args:    args,
+			})
+		}
+		if err != nil {
+			log.Printf("failed to schedule %s for initiative %s: %v",
+				jobs.InitiativeEnded, id, err)
+		}
+	}
 }
 
 func (c *TripController) scheduleAchievmentsUpdate(user models.User, tx *gorm.DB) error {
@@ -244,17 +394,64 @@ func (c *TripController) scheduleAchievmentsUpdate(user models.User, tx *gorm.DB
 	})
 }
 
+// creditPending credits the trips of the user with the given ID that were
+// held until they verified their email, in the order they were uploaded, and
+// counts them in the user's stats. The credits are split according to the
+// user's current allocation.
+func (c *TripController) creditPending(
+	userID uuid.UUID,
+	tx *gorm.DB,
+) ([]uuid.UUID, error) {
+	var trips []models.Trip
+	if err := tx.
+		Where("user_id = ? AND credits_pending = true", userID).
+		Order("created_at").
+		Find(&trips).Error; err != nil {
+		return nil, err
+	}
+	if len(trips) == 0 {
+		return nil, nil
+	}
+
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&user, "id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+
+	var ended []uuid.UUID
+	for i := range trips {
+		trip := &trips[i]
+		gpxTrip := new(gpx.GPX)
+		if err := gpxTrip.Unmarshal(trip.GPX); err != nil {
+			return nil, fmt.Errorf("failed to parse the GPX of trip %s: %v",
+				trip.ID, err)
+		}
+
+		trip.CreditsPending = false
+		reached, err := c.updateStats(trip, gpxTrip, &user, tx)
+		if err != nil {
+			return nil, err
+		}
+		ended = append(ended, reached...)
+	}
+
+	return ended, c.scheduleAchievmentsUpdate(user, tx)
+}
+
 // Upload trip (gpx file).
 //
-//	@Summary	Upload trip (gpx file)
-//	@Tags		trips
-//	@Produce	json
-//	@Security	OIDCToken
-//	@Security	AuthHeader
-//	@Param		file		formData	file	true	"Params"
-//	@Success	200			{object}	models.Trip
-//	@Failure	400,401,500	{object}	middleware.ApiError
-//	@Router		/trips [post]
+//	@Summary		Upload trip (gpx file)
+//	@Description	The trips of users that must verify their email are held, with
+//	@Description	`creditsPending`, and credited once they verify it.
+//	@Tags			trips
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			file		formData	file	true	"Params"
+//	@Success		200			{object}	models.Trip
+//	@Failure		400,401,500	{object}	middleware.ApiError
+//	@Router			/trips [post]
 func (c *TripController) Upload(
 	data []byte,
 	ctx *gin.Context,
@@ -289,10 +486,20 @@ func (c *TripController) Upload(
 		InitiativeID:     user.InitiativeID,
 	}
 
+	if trip.IsValid && user.PendingVerification() {
+		required, err := query.Settings.RequireVerifiedEmail(c.db)
+		if err != nil {
+			return models.Trip{}, err
+		}
+		trip.CreditsPending = required
+	}
+
 	if trip.IsValid {
 		c.addAddresses(trip, gpxTrip)
+		c.addParishes(trip)
 	}
 
+	var ended []uuid.UUID
 	err = c.db.Transaction(func(tx *gorm.DB) error {
 		if err = tx.Create(trip).Error; err != nil {
 			if duplicateGPXRegex.MatchString(err.Error()) {
@@ -310,13 +517,21 @@ func (c *TripController) Upload(
 				trip.NotValidReason)
 			return nil
 		}
+		if trip.CreditsPending {
+			// The trip is credited once the user verifies their email.
+			return nil
+		}
 
-		if err = updateStats(trip, &user, tx); err != nil {
+		if ended, err = c.updateStats(trip, gpxTrip, &user, tx); err != nil {
 			return err
 		}
 
 		return c.scheduleAchievmentsUpdate(user, tx)
 	})
+	if err != nil {
+		return *trip, err
+	}
 
-	return *trip, err
+	c.scheduleInitiativesEnded(ended)
+	return *trip, nil
 }
@@ -7,7 +7,9 @@ import (
 	"testing"
 
 	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/query"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/jobs"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/parishes"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/server/access"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/util/gobutil"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/util/latlon"
@@ -52,8 +54,11 @@ func (g *MockGeocoder) ReverseAddr(coords latlon.Coords) string {
 func (s *TripControllerTestSuite) SetupTest() {
 	tx := testDb.Begin()
 	s.db = tx
-	codec := gobutil.NewGobCodec[jobs.UpdateAchievementsArgs]()
-	s.trips = &TripController{tx, s.acl, s.wrkr, s.geocoder, codec}
+	s.trips = &TripController{
+		tx, s.acl, s.wrkr, s.geocoder, parishes.NewLocator(tx),
+		gobutil.NewGobCodec[jobs.UpdateAchievementsArgs](),
+		gobutil.NewGobCodec[jobs.InitiativeEndedArgs](),
+	}
 	s.initiatives = &InitiativeController{tx, s.acl, s.presigner}
 	s.users = &UserController{tx, s.acl, "", nil}
 }
@@ -185,10 +190,220 @@ func (s *TripControllerTestSuite) TestUpload() {
 	s.Truef(math.Abs(dbUser.TotalDist-26.2) < 0.01,
 		"distance '%v' not in margin of error", dbUser.TotalDist)
 
+	history, err := s.users.Credits(user.ID.String(), ListCreditsFilters{
+		Pagination{Limit: 10},
+	}, ctx)
+	s.Require().NoError(err)
+	s.Require().Len(history, 1)
+	s.Equal(models.CreditSourceTrip, history[0].Source)
+	s.Equal(res.Credits, history[0].Amount)
+	s.Equal(res.ID, *history[0].TripID)
+	s.Equal(initiative.ID, *history[0].InitiativeID)
+
 	s.wrkr.AssertExpectations(s.T())
 	s.geocoder.AssertExpectations(s.T())
 }
 
+func (s *TripControllerTestSuite) TestUploadWithinArea() {
+	initiative := models.Initiative{
+		Title:       "abc",
+		Description: random.String(50),
+		Goal:        100_000,
+		EndDate:     "2500-01-01",
+		Enabled:     true,
+		Institution: models.Institution{
+			Name:        random.AlphanumericString(20),
+			Description: random.AlphanumericString(50),
+		},
+		// Around the starting point.
+		Area: &latlon.Area{
+			Center: &latlon.Coords{Lat: 48.699449859559536, Lon: -3.789234794676304},
+			Radius: 5,
+		},
+	}
+	err := s.db.Create(&initiative).Error
+	s.Require().NoError(err)
+
+	user, ctx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+	_, err = s.users.Update(user.ID.String(), UpdateUserParams{
+		InitiativeID: &initiative.ID,
+	}, ctx)
+	s.Require().NoError(err)
+
+	s.wrkr.On("Schedule", mock.AnythingOfType("")).Return(nil)
+	s.geocoder.On("ReverseAddr", mock.Anything).Return("addr")
+
+	data, err := os.ReadFile("./testdata/parcours-morlaix-plougasnou.gpx")
+	s.Require().NoError(err)
+	res, err := s.trips.Upload(data, ctx)
+	s.Require().NoError(err)
+	s.Require().Len(res.Splits, 1)
+	split := res.Splits[0]
+	s.Greater(split.Distance, 0.0)
+	s.Less(split.Distance, res.Distance)
+	s.Equal(math.Floor(split.Distance), split.Credits)
+	s.Less(split.Credits, res.Credits)
+	s.Require().NotEmpty(split.AreaRanges)
+	s.Equal(0, split.AreaRanges[0][0])
+
+	var dbInitiative models.Initiative
+	err = s.db.First(&dbInitiative, "id = ?", initiative.ID).Error
+	s.Require().NoError(err)
+	s.Equal(split.Credits, dbInitiative.Credits)
+	s.Equal(initiative.Area, dbInitiative.Area)
+
+	history, err := s.users.Credits(user.ID.String(), ListCreditsFilters{
+		Pagination{Limit: 10},
+	}, ctx)
+	s.Require().NoError(err)
+	s.Require().Len(history, 2)
+	var total float64
+	for _, entry := range history {
+		total += entry.Amount
+		if entry.InitiativeID != nil {
+			s.Equal(split.Credits, entry.Amount)
+		}
+	}
+	s.Equal(res.Credits, total)
+
+	// The distance and area of the initiative aren't set if it has ended.
+	s.Require().NoError(s.db.Model(&initiative).
+		Update("state", models.InitiativeExpired).Error)
+	data, err = os.ReadFile("./testdata/1_Roscoff_Morlaix_A_parcours.gpx")
+	s.Require().NoError(err)
+	res, err = s.trips.Upload(data, ctx)
+	s.Require().NoError(err)
+	s.Require().Len(res.Splits, 1)
+	s.Zero(res.Splits[0].Credits)
+	s.Zero(res.Splits[0].Distance)
+	s.Nil(res.Splits[0].AreaRanges)
+}
+
+func (s *TripControllerTestSuite) TestUploadWithAllocation() {
+	initiatives := make([]models.Initiative, 2)
+	for i := range initiatives {
+		initiatives[i] = models.Initiative{
+			Title:       random.String(50),
+			Description: random.String(50),
+			Goal:        100_000,
+			EndDate:     "2500-01-01",
+			Enabled:     true,
+			Institution: models.Institution{
+				Name: random.AlphanumericString(20),
+			},
+		}
+		s.Require().NoError(s.db.Create(&initiatives[i]).Error)
+	}
+
+	user, ctx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+	allocation, err := s.users.Allocate(user.ID.String(), UpdateAllocationParams{
+		Shares: []AllocationShareParams{
+			{InitiativeID: initiatives[0].ID, Percentage: 75},
+			{InitiativeID: initiatives[1].ID, Percentage: 25},
+		},
+	}, ctx)
+	s.Require().NoError(err)
+
+	s.wrkr.On("Schedule", mock.AnythingOfType("")).Return(nil)
+	s.geocoder.On("ReverseAddr", mock.Anything).Return("addr")
+
+	data, err := os.ReadFile("./testdata/parcours-morlaix-plougasnou.gpx")
+	s.Require().NoError(err)
+	res, err := s.trips.Upload(data, ctx)
+	s.Require().NoError(err)
+	s.Equal(initiatives[0].ID, *res.InitiativeID)
+	s.Equal(allocation.ID, *res.AllocationID)
+
+	trip, err := s.trips.Get(res.ID.String(), ctx)
+	s.Require().NoError(err)
+	s.Require().Len(trip.Splits, 2)
+
+	for i, percentage := range []float64{75, 25} {
+		var dbInitiative models.Initiative
+		err = s.db.First(&dbInitiative, "id = ?", initiatives[i].ID).Error
+		s.Require().NoError(err)
+		s.Equal(res.Credits*percentage/100, dbInitiative.Credits)
+	}
+
+	initiativeCount, err := query.Users.InitiativeCount(user.ID.String(), s.db)
+	s.Require().NoError(err)
+	s.Equal(int64(2), initiativeCount)
+}
+
+func (s *TripControllerTestSuite) TestUploadParishes() {
+	// A parish around the start of the trip, which ends outside of it.
+	parish := models.Parish{
+		Name: random.AlphanumericString(20),
+		Boundary: latlon.Boundary{{{
+			{Lat: 48.69, Lon: -3.80}, {Lat: 48.69, Lon: -3.78},
+			{Lat: 48.71, Lon: -3.78}, {Lat: 48.71, Lon: -3.80},
+		}}},
+	}
+	s.Require().NoError(s.db.Create(&parish).Error)
+
+	_, ctx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+
+	s.wrkr.On("Schedule", mock.AnythingOfType("")).Return(nil)
+	s.geocoder.On("ReverseAddr", mock.Anything).Return("addr")
+
+	data, err := os.ReadFile("./testdata/parcours-morlaix-plougasnou.gpx")
+	s.Require().NoError(err)
+
+	res, err := s.trips.Upload(data, ctx)
+	s.Require().NoError(err)
+	s.Require().NotNil(res.StartParishID)
+	s.Equal(parish.ID, *res.StartParishID)
+	s.Nil(res.EndParishID)
+}
+
+func (s *TripControllerTestSuite) TestUploadUnverified() {
+	s.Require().NoError(s.db.Model(&models.Settings{}).
+		Where("1 = 1").
+		Update("require_verified_email", true).Error)
+
+	user, ctx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+
+	s.wrkr.On("Schedule", mock.AnythingOfType("")).Return(nil)
+	s.geocoder.On("ReverseAddr", mock.Anything).Return("addr")
+
+	data, err := os.ReadFile("./testdata/parcours-morlaix-plougasnou.gpx")
+	s.Require().NoError(err)
+
+	// ---------------------------------------------- //
+	// Holds the credits of trips of unverified users //
+	// ---------------------------------------------- //
+	res, err := s.trips.Upload(data, ctx)
+	s.Require().NoError(err)
+	s.True(res.IsValid)
+	s.True(res.CreditsPending)
+	s.Zero(res.Credits)
+
+	s.Require().NoError(s.db.First(&user, "id = ?", user.ID).Error)
+	s.Zero(user.TripCount)
+	s.Zero(user.Credits)
+
+	// --------------------------------------- //
+	// Credits them once the email is verified //
+	// --------------------------------------- //
+	ended, err := s.trips.creditPending(user.ID, s.db)
+	s.Require().NoError(err)
+	s.Empty(ended)
+
+	var trip models.Trip
+	s.Require().NoError(s.db.First(&trip, "id = ?", res.ID).Error)
+	s.False(trip.CreditsPending)
+	s.Equal(26.0, trip.Credits)
+
+	s.Require().NoError(s.db.First(&user, "id = ?", user.ID).Error)
+	s.EqualValues(1, user.TripCount)
+	s.Equal(26.0, user.Credits)
+	s.InDelta(res.Distance, user.TotalDist, 1e-9)
+}
+
 func TestTripController(t *testing.T) {
 	acl := access.New()
 	registerAllRules(&TripController{}, acl)
@@ -2,7 +2,9 @@ package controllers
 
 import (
 	"errors"
+	"fmt"
 	"regexp"
+	"time"
 
 	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/database/query"
@@ -35,14 +37,36 @@ func (UserController) Rules() []rule {
 				params := res.(models.User)
 				return user.ID == params.ID
 			}},
-		{models.User{}, models.User{}, "delete", func(ent, res any) bool {
+		{models.User{}, models.User{}, "delete,list-credits", func(ent, res any) bool {
 			user := ent.(models.User)
 			params := res.(models.User)
 			return user.ID == params.ID || user.Admin
 		}},
+		// Hidden profiles are only visible to the user and the admins.
+		{models.User{}, models.User{}, "get", func(ent, res any) bool {
+			user := ent.(models.User)
+			target := res.(models.User)
+			return !target.HiddenProfile || user.ID == target.ID || user.Admin
+		}},
+		{models.User{}, models.User{}, "list-hidden", func(ent, _ any) bool {
+			return ent.(models.User).Admin
+		}},
 	}
 }
 
+// hideProfile returns the user, or nil if their profile is hidden from the
+// viewer, so that lists of members only show the ID of hidden users.
+func hideProfile(
+	acl authorizer,
+	viewer models.User,
+	user *models.User,
+) *models.User {
+	if user == nil || acl.Authorize(viewer, "get", *user) {
+		return user
+	}
+	return nil
+}
+
 var duplicateEmailRegex = regexp.MustCompile(
 	"duplicate key value violates unique constraint \"users_email_key\"",
 )
@@ -57,23 +81,34 @@ type ListUsersFilters struct {
 
 // Lists all users.
 //
-//	@Summary	List all users
-//	@Tags		users
-//	@Produce	json
-//	@Security	OIDCToken
-//	@Security	AuthHeader
-//	@Param		filters		query		ListUsersFilters	false	"Filters"
-//	@Success	200			{array}		models.User
-//	@Failure	400,401,500	{object}	middleware.ApiError
-//	@Router		/users  [get]
+//	@Summary		List all users
+//	@Description	Users with a hidden profile are only listed to admins.
+//	@Tags			users
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			filters		query		ListUsersFilters	false	"Filters"
+//	@Success		200			{array}		models.User
+//	@Failure		400,401,500	{object}	middleware.ApiError
+//	@Router			/users  [get]
 func (c *UserController) List(
 	filters ListUsersFilters,
-	_ *gin.Context,
+	ctx *gin.Context,
 ) ([]models.User, error) {
-	var users []models.User
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return nil, err
+	}
 
-	err := c.db.Model(&models.User{}).
+	q := c.db.Model(&models.User{}).
 		Joins("Initiative").
+		Where("users.anonymized_at IS NULL")
+	if !c.acl.Authorize(user, "list-hidden", models.User{}) {
+		q = q.Where("NOT users.hidden_profile OR users.id = ?", user.ID)
+	}
+
+	var users []models.User
+	err = q.
 		Limit(filters.Limit).
 		Offset(filters.Offset).
 		Order(filters.OrderBy.ToSnakeCase()).
@@ -84,22 +119,31 @@ func (c *UserController) List(
 
 // Get retrieves a user.
 //
-//	@Summary	Retrieve a user by Id
-//	@Tags		users
-//	@Produce	json
-//	@Security	OIDCToken
-//	@Security	AuthHeader
-//	@Param		id				path		string	true	"User Id"	Format(UUID)
-//	@Success	200				{object}	models.User
-//	@Failure	400,401,404,500	{object}	middleware.ApiError
-//	@Router		/users/{id} [get]
+//	@Summary		Retrieve a user by Id
+//	@Description	Users with a hidden profile are only visible to themselves and to admins, and
+//	@Description	are not found by other users.
+//	@Tags			users
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id				path		string	true	"User Id"	Format(UUID)
+//	@Success		200				{object}	models.User
+//	@Failure		400,401,404,500	{object}	middleware.ApiError
+//	@Router			/users/{id} [get]
 func (c *UserController) Get(id string, ctx *gin.Context) (models.User, error) {
+	viewer, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return models.User{}, err
+	}
+
 	var user models.User
-	err := c.db.Model(&models.User{}).
+	err = c.db.Model(&models.User{}).
 		Joins("Initiative").
+		Where("users.anonymized_at IS NULL").
 		First(&user, "users.id = ?", id).Error
 
-	if errors.Is(err, gorm.ErrRecordNotFound) {
+	if errors.Is(err, gorm.ErrRecordNotFound) ||
+		err == nil && !c.acl.Authorize(viewer, "get", user) {
 		return models.User{}, resourceNotFoundErr("user")
 	}
 
@@ -187,15 +231,8 @@ func (c *UserController) Achievements(
 	return userAchievementsWithImage(achs, c.serverBaseURL), nil
 }
 
-// Creates a user.
-//
-//	@Summary	Create a new user and return it
-//	@Tags		users
-//	@Produce	json
-//	@Param		params		body		CreateUserParams	true	"Params"
-//	@Success	201			{object}	models.User
-//	@Failure	400,401,500	{object}	middleware.ApiError
-//	@Router		/users [post]
+// Create creates a user. The route is handled by
+// EmailVerificationController.SignUp, which also sends the verification code.
 func (c *UserController) Create(
 	params CreateUserParams,
 	_ *gin.Context,
@@ -249,6 +286,16 @@ type UpdateUserParams struct {
 	models.Profile
 	Email        string     `json:"email" binding:"omitempty,email"`
 	InitiativeID *uuid.UUID `json:"initiativeId,omitempty"`
+	// Private requires the user to approve who follows them.
+	Private *bool `json:"private,omitempty"`
+
+	// HideFromLeaderboards excludes the user from the leaderboards shown to
+	// other users.
+	HideFromLeaderboards *bool `json:"hideFromLeaderboards,omitempty"`
+	// LeaderboardAnonymous hides the user's identity in the leaderboards.
+	LeaderboardAnonymous *bool `json:"leaderboardAnonymous,omitempty"`
+	// HiddenProfile hides the user's profile from other users.
+	HiddenProfile *bool `json:"hiddenProfile,omitempty"`
 }
 
 // Updates a user.
@@ -297,6 +344,16 @@ func (c *UserController) Update(
 	}
 
 	if err := c.db.Transaction(func(tx *gorm.DB) error {
+		// A new email must be verified again.
+		if params.Email != "" {
+			if err := tx.Model(&models.User{}).
+				Where("id = ?", id).
+				Where("email <> ?", params.Email).
+				Update("verified", false).Error; err != nil {
+				return err
+			}
+		}
+
 		res := tx.Model(&userParams).
 			Omit(clause.Associations).
 			Where("id = ?", id).
@@ -323,9 +380,63 @@ func (c *UserController) Update(
 			return resourceNotFoundErr("user")
 		}
 
-		return tx.Model(&userParams).
+		if err := tx.Model(&userParams).
 			Update("initiative_id", userParams.InitiativeID).
-			Error
+			Error; err != nil {
+			return err
+		}
+
+		if params.Private != nil {
+			if err := tx.Model(&userParams).
+				Update("private", *params.Private).Error; err != nil {
+				return err
+			}
+			// Pending requests are accepted when the profile becomes public.
+			if !*params.Private {
+				if _, err := query.Follows.
+					AcceptPending(id, tx); err != nil {
+					return err
+				}
+			}
+		}
+
+		privacy := map[string]any{}
+		if params.HideFromLeaderboards != nil {
+			privacy["hide_from_leaderboards"] = *params.HideFromLeaderboards
+		}
+		if params.LeaderboardAnonymous != nil {
+			privacy["leaderboard_anonymous"] = *params.LeaderboardAnonymous
+		}
+		if params.HiddenProfile != nil {
+			privacy["hidden_profile"] = *params.HiddenProfile
+		}
+		if len(privacy) > 0 {
+			if err := tx.Model(&userParams).
+				Updates(privacy).Error; err != nil {
+				return err
+			}
+		}
+
+		// Selecting a single initiative allocates all the credits to it.
+		current, err := query.Allocations.Current(id, tx)
+		if err != nil {
+			return err
+		}
+		main := current.MainInitiative()
+		if main == nil && params.InitiativeID == nil ||
+			main != nil && params.InitiativeID != nil &&
+				*main == *params.InitiativeID {
+			return nil
+		}
+
+		allocation := models.CreditAllocation{UserID: userID}
+		if params.InitiativeID != nil {
+			allocation.Shares = []models.AllocationShare{{
+				InitiativeID: *params.InitiativeID,
+				Percentage:   100,
+			}}
+		}
+		return query.Allocations.Record(&allocation, tx)
 	}); err != nil {
 		return models.User{}, err
 	}
@@ -333,21 +444,251 @@ func (c *UserController) Update(
 	return c.Get(id, ctx)
 }
 
+type AllocationShareParams struct {
+	InitiativeID uuid.UUID `json:"initiativeId" binding:"required"`
+	Percentage   uint8     `json:"percentage" binding:"required,min=1,max=100" example:"50"`
+}
+
+type UpdateAllocationParams struct {
+	// Shares of the credits allocated to each initiative. The percentages
+	// must add up to 100. An empty list stops supporting any initiative.
+	Shares []AllocationShareParams `json:"shares" binding:"unique=InitiativeID,dive"`
+}
+
+// Allocate splits the future credits of a user across initiatives.
+//
+//	@Summary		Split the future credits of a user across initiatives
+//	@Description	The credits of the trips uploaded from now on are split across up to 5 active
+//	@Description	initiatives, according to the given percentages. The previous allocations are
+//	@Description	kept in the user's history, and each trip records the split applied to it.
+//	@Description
+//	@Description	The initiative with the largest share becomes the user's `initiativeId`.
+//	@Tags			users
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id					path		string					true	"User Id"	Format(UUID)
+//	@Param			params				body		UpdateAllocationParams	true	"Params"
+//	@Success		200					{object}	models.CreditAllocation
+//	@Failure		400,401,403,404,500	{object}	middleware.ApiError
+//	@Router			/users/{id}/allocation [put]
+func (c *UserController) Allocate(
+	id string,
+	params UpdateAllocationParams,
+	ctx *gin.Context,
+) (models.CreditAllocation, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return models.CreditAllocation{}, err
+	}
+
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return models.CreditAllocation{},
+			httputil.NewError(httputil.BadRequest, err)
+	}
+
+	if ok := c.acl.Authorize(
+		user, "update", models.User{BaseModel: models.BaseModel{ID: userID}},
+	); !ok {
+		return models.CreditAllocation{}, httputil.NewErrorMsg(
+			httputil.Forbidden,
+			httputil.ForbiddenMessage,
+		)
+	}
+
+	if len(params.Shares) > models.MaxAllocationShares {
+		return models.CreditAllocation{}, httputil.NewErrorMsg(
+			httputil.BadRequest,
+			fmt.Sprintf("the credits can be split across at most %d initiatives",
+				models.MaxAllocationShares),
+		)
+	}
+
+	allocation := models.CreditAllocation{
+		UserID: userID,
+		Shares: make([]models.AllocationShare, len(params.Shares)),
+	}
+
+	var total uint
+	ids := make([]uuid.UUID, len(params.Shares))
+	for i, share := range params.Shares {
+		allocation.Shares[i] = models.AllocationShare{
+			InitiativeID: share.InitiativeID,
+			Percentage:   share.Percentage,
+		}
+		ids[i] = share.InitiativeID
+		total += uint(share.Percentage)
+	}
+
+	if len(params.Shares) > 0 && total != 100 {
+		return models.CreditAllocation{}, httputil.NewErrorMsg(
+			httputil.BadRequest,
+			fmt.Sprintf("the percentages must add up to 100, not %d", total),
+		)
+	}
+
+	if err := c.db.Transaction(func(tx *gorm.DB) error {
+		var initiatives []models.Initiative
+		if err := tx.Model(&models.Initiative{}).
+			Where("id IN ?", ids).
+			Find(&initiatives).Error; err != nil {
+			return err
+		}
+		if len(initiatives) != len(ids) {
+			return resourceNotFoundErr("initiative")
+		}
+
+		now := time.Now()
+		for _, initiative := range initiatives {
+			if !initiative.Enabled ||
+				initiative.StateAt(now) != models.InitiativeActive {
+				return httputil.NewErrorMsg(
+					httputil.BadRequest,
+					fmt.Sprintf("the initiative %s isn't active", initiative.ID),
+				)
+			}
+		}
+
+		return query.Allocations.Record(&allocation, tx)
+	}); err != nil {
+		return models.CreditAllocation{}, err
+	}
+
+	return allocation, nil
+}
+
+type ListAllocationsFilters struct {
+	Pagination
+}
+
+// Allocations lists the history of allocations of a user.
+//
+//	@Summary		List the history of allocations of a user
+//	@Description	Most recent first. The first allocation is the one in effect. Regular users only
+//	@Description	have access to their own history.
+//	@Tags			users
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id				path		string					true	"User Id"	Format(UUID)
+//	@Param			filters			query		ListAllocationsFilters	false	"Filters"
+//	@Success		200				{array}		models.CreditAllocation
+//	@Failure		400,401,403,500	{object}	middleware.ApiError
+//	@Router			/users/{id}/allocations [get]
+func (c *UserController) Allocations(
+	id string,
+	filters ListAllocationsFilters,
+	ctx *gin.Context,
+) ([]models.CreditAllocation, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, httputil.NewError(httputil.BadRequest, err)
+	}
+
+	if ok := c.acl.Authorize(
+		user, "list-credits", models.User{BaseModel: models.BaseModel{ID: userID}},
+	); !ok {
+		return nil, httputil.NewErrorMsg(
+			httputil.Forbidden,
+			httputil.ForbiddenMessage,
+		)
+	}
+
+	return query.Allocations.
+		HistoryOf(id, filters.Limit, filters.Offset, c.db)
+}
+
+type ListCreditsFilters struct {
+	Pagination
+}
+
+// Credits lists the credit history of a user.
+//
+//	@Summary		List the credit history of a user
+//	@Description	Lists the entries of the credit ledger that belong to the user, most recent first.
+//	@Description	Regular users only have access to their own history.
+//	@Tags			users
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id				path		string				true	"User Id"	Format(UUID)
+//	@Param			filters			query		ListCreditsFilters	false	"Filters"
+//	@Success		200				{array}		models.CreditTransaction
+//	@Failure		400,401,403,500	{object}	middleware.ApiError
+//	@Router			/users/{id}/credits [get]
+func (c *UserController) Credits(
+	id string,
+	filters ListCreditsFilters,
+	ctx *gin.Context,
+) ([]models.CreditTransaction, error) {
+	user, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, httputil.NewError(httputil.BadRequest, err)
+	}
+
+	if ok := c.acl.Authorize(
+		user, "list-credits", models.User{BaseModel: models.BaseModel{ID: userID}},
+	); !ok {
+		return nil, httputil.NewErrorMsg(
+			httputil.Forbidden,
+			httputil.ForbiddenMessage,
+		)
+	}
+
+	return query.Credits.HistoryOf(id, filters.Limit, filters.Offset, c.db)
+}
+
 // GetPictureURL generates a pre-signed url to retrieve the user's profile picture.
 //
-//	@Summary	Generate a pre-signed url to retrieve the user's profile picture
-//	@Tags		users
-//	@Produce	json
-//	@Security	OIDCToken
-//	@Security	AuthHeader
-//	@Param		id				path		string	true	"User Id"	Format(UUID)
-//	@Success	200				{object}	PresignedResponse
-//	@Failure	400,401,404,500	{object}	middleware.ApiError
-//	@Router		/users/{id}/picture-get-url [get]
+//	@Summary		Generate a pre-signed url to retrieve the user's profile picture
+//	@Description	The pictures of users with a hidden profile are only visible to themselves
+//	@Description	and to admins, and are not found by other users.
+//	@Tags			users
+//	@Produce		json
+//	@Security		OIDCToken
+//	@Security		AuthHeader
+//	@Param			id				path		string	true	"User Id"	Format(UUID)
+//	@Success		200				{object}	PresignedResponse
+//	@Failure		400,401,404,500	{object}	middleware.ApiError
+//	@Router			/users/{id}/picture-get-url [get]
 func (c *UserController) GetPictureURL(
 	id string,
-	_ *gin.Context,
+	ctx *gin.Context,
 ) (PresignedResponse, error) {
+	viewer, err := tokenUser(ctx, c.db)
+	if err != nil {
+		return PresignedResponse{}, err
+	}
+
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return PresignedResponse{}, httputil.NewError(httputil.BadRequest, err)
+	}
+
+	var user models.User
+	err = c.db.Select("id", "hidden_profile").
+		Where("anonymized_at IS NULL").
+		First(&user, "id = ?", userID).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) ||
+		err == nil && !c.acl.Authorize(viewer, "get", user) {
+		return PresignedResponse{}, resourceNotFoundErr("user")
+	}
+	if err != nil {
+		return PresignedResponse{}, err
+	}
+
 	url, method, err := c.presigner.PresignGetProfilePicture(id)
 	return PresignedResponse{url, method}, err
 }
@@ -433,42 +774,3 @@ func (c *UserController) DeletePictureURL(
 	url, method, err := c.presigner.PresignDeleteProfilePicture(id)
 	return PresignedResponse{url, method}, err
 }
-
-// Deletes a user.
-//
-//	@Summary	Delete a user by Id
-//	@Tags		users
-//	@Security	OIDCToken
-//	@Security	AuthHeader
-//	@Param		id	path	string	true	"User Id"	Format(UUID)
-//	@Success	204
-//	@Failure	400,401,403,404,500	{object}	middleware.ApiError
-//	@Router		/users/{id} [delete]
-func (c *UserController) Delete(id string, ctx *gin.Context) error {
-	user, err := tokenUser(ctx, c.db)
-	if err != nil {
-		return err
-	}
-
-	userID, err := uuid.Parse(id)
-	if err != nil {
-		return httputil.NewError(httputil.BadRequest, err)
-	}
-
-	if ok := c.acl.Authorize(
-		user, "delete", models.User{
-			BaseModel: models.BaseModel{ID: userID},
-		},
-	); !ok {
-		return httputil.NewErrorMsg(
-			httputil.Forbidden,
-			httputil.ForbiddenMessage,
-		)
-	}
-
-	result := c.db.Delete(&models.User{}, "id = ?", id)
-	if result.RowsAffected == 0 {
-		return resourceNotFoundErr("user")
-	}
-	return result.Error
-}
@@ -54,6 +54,24 @@ func TestUsersAcl(t *testing.T) {
 			action: "delete",
 			exp:    true,
 		},
+		{
+			ent:    models.User{BaseModel: models.BaseModel{ID: uid1}},
+			res:    models.User{BaseModel: models.BaseModel{ID: uid1}},
+			action: "list-credits",
+			exp:    true,
+		},
+		{
+			ent:    models.User{BaseModel: models.BaseModel{ID: uid1}},
+			res:    models.User{BaseModel: models.BaseModel{ID: uid2}},
+			action: "list-credits",
+			exp:    false,
+		},
+		{
+			ent:    models.User{Admin: true},
+			res:    models.User{BaseModel: models.BaseModel{ID: uid2}},
+			action: "list-credits",
+			exp:    true,
+		},
 		{
 			ent:    models.User{BaseModel: models.BaseModel{ID: uid1}},
 			res:    models.User{BaseModel: models.BaseModel{ID: uid1}},
@@ -78,6 +96,51 @@ func TestUsersAcl(t *testing.T) {
 			action: "delete-picture",
 			exp:    false,
 		},
+		{
+			ent:    models.User{BaseModel: models.BaseModel{ID: uid1}},
+			res:    models.User{BaseModel: models.BaseModel{ID: uid2}},
+			action: "get",
+			exp:    true,
+		},
+		{
+			ent: models.User{BaseModel: models.BaseModel{ID: uid1}},
+			res: models.User{
+				BaseModel: models.BaseModel{ID: uid2},
+				Privacy:   models.Privacy{HiddenProfile: true},
+			},
+			action: "get",
+			exp:    false,
+		},
+		{
+			ent: models.User{BaseModel: models.BaseModel{ID: uid2}},
+			res: models.User{
+				BaseModel: models.BaseModel{ID: uid2},
+				Privacy:   models.Privacy{HiddenProfile: true},
+			},
+			action: "get",
+			exp:    true,
+		},
+		{
+			ent: models.User{BaseModel: models.BaseModel{ID: uid1}, Admin: true},
+			res: models.User{
+				BaseModel: models.BaseModel{ID: uid2},
+				Privacy:   models.Privacy{HiddenProfile: true},
+			},
+			action: "get",
+			exp:    true,
+		},
+		{
+			ent:    models.User{BaseModel: models.BaseModel{ID: uid1}},
+			res:    models.User{},
+			action: "list-hidden",
+			exp:    false,
+		},
+		{
+			ent:    models.User{BaseModel: models.BaseModel{ID: uid1}, Admin: true},
+			res:    models.User{},
+			action: "list-hidden",
+			exp:    true,
+		},
 	} {
 		assert.Equal(
 			t,
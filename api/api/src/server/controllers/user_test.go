@@ -4,13 +4,31 @@ import (
 	"testing"
 
 	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/database/query"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/server/access"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/util/random"
+	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/suite"
 	"gorm.io/gorm"
 )
 
+func (m *MockPresigner) PresignGetProfilePicture(userID string) (string, string, error) {
+	args := m.Called(userID)
+	return args.String(0), args.String(1), args.Error(2)
+}
+
+func (m *MockPresigner) PresignPutProfilePicture(userID string) (string, string, error) {
+	args := m.Called(userID)
+	return args.String(0), args.String(1), args.Error(2)
+}
+
+func (m *MockPresigner) PresignDeleteProfilePicture(userID string) (string, string, error) {
+	args := m.Called(userID)
+	return args.String(0), args.String(1), args.Error(2)
+}
+
 type UserControllerTestSuite struct {
 	suite.Suite
 	users *UserController
@@ -238,17 +256,149 @@ func (s *UserControllerTestSuite) TestUpdateUser() {
 	s.False(result.Verified)
 }
 
+func (s *UserControllerTestSuite) TestAllocate() {
+	user, ctx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+
+	initiatives := []models.Initiative{{
+		Title:       random.String(50),
+		Description: random.String(50),
+		Goal:        1000,
+		EndDate:     "2040-01-01",
+		Enabled:     true,
+	}, {
+		Title:       random.String(50),
+		Description: random.String(50),
+		Goal:        1000,
+		EndDate:     "2040-01-01",
+		Enabled:     true,
+	}, {
+		Title:       random.String(50),
+		Description: random.String(50),
+		Goal:        1000,
+		EndDate:     "2000-01-01",
+		Enabled:     true,
+	}}
+	for i := range initiatives {
+		initiatives[i].Institution = models.Institution{
+			Name: random.AlphanumericString(20),
+		}
+		s.Require().NoError(s.db.Create(&initiatives[i]).Error)
+	}
+
+	// ------------------------------------- //
+	// Fails if the percentages don't add up //
+	// ------------------------------------- //
+	_, err = s.users.Allocate(user.ID.String(), UpdateAllocationParams{
+		Shares: []AllocationShareParams{
+			{InitiativeID: initiatives[0].ID, Percentage: 50},
+			{InitiativeID: initiatives[1].ID, Percentage: 20},
+		},
+	}, ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Bad Request, "+
+		"message: the percentages must add up to 100, not 70"+
+		"}")
+
+	// --------------------------------------- //
+	// Fails if there are too many initiatives //
+	// --------------------------------------- //
+	shares := make([]AllocationShareParams, models.MaxAllocationShares+1)
+	for i := range shares {
+		shares[i] = AllocationShareParams{InitiativeID: uuid.New(), Percentage: 10}
+	}
+	_, err = s.users.Allocate(user.ID.String(), UpdateAllocationParams{
+		Shares: shares,
+	}, ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Bad Request, "+
+		"message: the credits can be split across at most 5 initiatives"+
+		"}")
+
+	// ----------------------------------- //
+	// Fails if an initiative isn't active //
+	// ----------------------------------- //
+	_, err = s.users.Allocate(user.ID.String(), UpdateAllocationParams{
+		Shares: []AllocationShareParams{
+			{InitiativeID: initiatives[0].ID, Percentage: 50},
+			{InitiativeID: initiatives[2].ID, Percentage: 50},
+		},
+	}, ctx)
+	s.EqualError(err, "ApiError{"+
+		"code: Bad Request, "+
+		"message: the initiative "+initiatives[2].ID.String()+" isn't active"+
+		"}")
+
+	// -------- //
+	// Succeeds //
+	// -------- //
+	_, err = s.users.Allocate(user.ID.String(), UpdateAllocationParams{
+		Shares: []AllocationShareParams{
+			{InitiativeID: initiatives[0].ID, Percentage: 40},
+			{InitiativeID: initiatives[1].ID, Percentage: 60},
+		},
+	}, ctx)
+	s.Require().NoError(err)
+
+	result, err := s.users.Get(user.ID.String(), ctx)
+	s.Require().NoError(err)
+	s.Equal(initiatives[1].ID, *result.InitiativeID)
+
+	// Selecting a single initiative replaces the allocation.
+	_, err = s.users.Update(user.ID.String(), UpdateUserParams{
+		InitiativeID: &initiatives[0].ID,
+	}, ctx)
+	s.Require().NoError(err)
+
+	history, err := s.users.Allocations(user.ID.String(), ListAllocationsFilters{
+		Pagination{Limit: 10},
+	}, ctx)
+	s.Require().NoError(err)
+	s.Require().Len(history, 2)
+	s.Require().Len(history[0].Shares, 1)
+	s.Equal(initiatives[0].ID, history[0].Shares[0].InitiativeID)
+	s.Equal(uint8(100), history[0].Shares[0].Percentage)
+	s.Len(history[1].Shares, 2)
+
+	// -------------------------------------------------- //
+	// Drops the initiatives that end from the allocation //
+	// -------------------------------------------------- //
+	_, err = s.users.Allocate(user.ID.String(), UpdateAllocationParams{
+		Shares: []AllocationShareParams{
+			{InitiativeID: initiatives[0].ID, Percentage: 70},
+			{InitiativeID: initiatives[1].ID, Percentage: 30},
+		},
+	}, ctx)
+	s.Require().NoError(err)
+
+	dropped, err := query.Allocations.DropInitiative(initiatives[0].ID, s.db)
+	s.Require().NoError(err)
+	s.Equal(int64(1), dropped)
+
+	current, err := query.Allocations.Current(user.ID.String(), s.db)
+	s.Require().NoError(err)
+	s.Require().Len(current.Shares, 1)
+	s.Equal(initiatives[1].ID, current.Shares[0].InitiativeID)
+	s.Equal(uint8(100), current.Shares[0].Percentage)
+
+	result, err = s.users.Get(user.ID.String(), ctx)
+	s.Require().NoError(err)
+	s.Equal(initiatives[1].ID, *result.InitiativeID)
+}
+
 func (s *UserControllerTestSuite) TestListUsers() {
 	for i := 0; i < 10; i++ {
 		createRandomUser(s.users)
 	}
+	_, ctx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
 
 	filters := ListUsersFilters{Pagination: Pagination{
 		Limit:  5,
 		Offset: 3,
 	}}
 
-	users, err := s.users.List(filters, nil)
+	users, err := s.users.List(filters, ctx)
 	s.NoError(err)
 	s.Len(users, 5)
 
@@ -260,7 +410,7 @@ func (s *UserControllerTestSuite) TestListUsers() {
 		Pagination: Pagination{10, 0},
 		Sort:       Sort{"email asc"},
 	}
-	users, err = s.users.List(filters, nil)
+	users, err = s.users.List(filters, ctx)
 	s.NoError(err)
 	s.Len(users, 10)
 
@@ -271,31 +421,120 @@ func (s *UserControllerTestSuite) TestListUsers() {
 	}
 }
 
-func (s *UserControllerTestSuite) TestDeleteUser() {
-	user, ctx, err := createRandomUser(s.users)
-	s.NotEmpty(user)
-	s.NoError(err)
-
-	err = s.users.Delete(user.ID.String(), ctx)
-	s.NoError(err)
+func (s *UserControllerTestSuite) TestPrivacy() {
+	hidden, hiddenCtx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
+	_, ctx, err := createRandomUser(s.users)
+	s.Require().NoError(err)
 
-	_, ctx2, err := createRandomUser(s.users)
-	s.NoError(err)
+	_, err = s.users.Update(hidden.ID.String(), UpdateUserParams{
+		HiddenProfile: &[]bool{true}[0],
+	}, hiddenCtx)
+	s.Require().NoError(err)
 
-	// Getting a user after deleting should return an error
-	result, err := s.users.Get(user.ID.String(), ctx2)
+	// ------------------------------------------- //
+	// Hidden profiles aren't found by other users //
+	// ------------------------------------------- //
+	_, err = s.users.Get(hidden.ID.String(), ctx)
 	s.EqualError(err, "ApiError{"+
 		"code: Record Not Found, "+
 		"message: user not found"+
 		"}")
-	s.Empty(result)
 
-	// Trying to delete the same user should return an error
-	err = s.users.Delete(user.ID.String(), ctx)
+	user, err := s.users.Get(hidden.ID.String(), hiddenCtx)
+	s.Require().NoError(err)
+	s.True(user.HiddenProfile)
+
+	users, err := s.users.List(ListUsersFilters{
+		Pagination: Pagination{Limit: -1},
+	}, ctx)
+	s.Require().NoError(err)
+	for _, u := range users {
+		s.NotEqual(hidden.ID, u.ID)
+	}
+
+	// ------------------------------------------------------ //
+	// The pictures of hidden profiles aren't found by others //
+	// ------------------------------------------------------ //
+	presigner := &MockPresigner{}
+	defer presigner.AssertExpectations(s.T())
+	s.users.presigner = presigner
+
+	_, err = s.users.GetPictureURL(hidden.ID.String(), ctx)
 	s.EqualError(err, "ApiError{"+
-		"code: Token User Not Found, "+
-		"message: the token is valid, but the subject doesn't exist"+
+		"code: Record Not Found, "+
+		"message: user not found"+
 		"}")
+
+	presigner.On("PresignGetProfilePicture", hidden.ID.String()).Return(
+		"pre-signed url", "GET", nil,
+	).Twice()
+
+	res, err := s.users.GetPictureURL(hidden.ID.String(), hiddenCtx)
+	s.Require().NoError(err)
+	s.Equal(PresignedResponse{"pre-signed url", "GET"}, res)
+
+	_, adminCtx, err := createRandomAdmin(s.users)
+	s.Require().NoError(err)
+
+	res, err = s.users.GetPictureURL(hidden.ID.String(), adminCtx)
+	s.Require().NoError(err)
+	s.Equal(PresignedResponse{"pre-signed url", "GET"}, res)
+}
+
+func (s *UserControllerTestSuite) TestLeaderboardPrivacy() {
+	leaderboard := &LeaderboardController{s.db}
+
+	// Push the users to the top of the leaderboard.
+	users := make([]models.User, 3)
+	ctxs := make([]*gin.Context, 3)
+	for i := range users {
+		var err error
+		users[i], ctxs[i], err = createRandomUser(s.users)
+		s.Require().NoError(err)
+		s.Require().NoError(s.db.Model(&users[i]).
+			Update("total_dist", 1e9-float64(i)).Error)
+	}
+
+	_, err := s.users.Update(users[0].ID.String(), UpdateUserParams{
+		LeaderboardAnonymous: &[]bool{true}[0],
+	}, ctxs[0])
+	s.Require().NoError(err)
+	_, err = s.users.Update(users[1].ID.String(), UpdateUserParams{
+		HideFromLeaderboards: &[]bool{true}[0],
+	}, ctxs[1])
+	s.Require().NoError(err)
+
+	// ------------------------------------------------ //
+	// Anonymous users are shown without their identity //
+	// ------------------------------------------------ //
+	res, err := leaderboard.List(LeaderboardFilters{}, ctxs[2])
+	s.Require().NoError(err)
+	s.Require().GreaterOrEqual(len(res.Entries), 2)
+	s.True(res.Entries[0].Anonymous)
+	s.Equal(uuid.Nil, res.Entries[0].ID)
+	s.Empty(res.Entries[0].Name)
+
+	// ---------------------------------------- //
+	// Hidden users are excluded from the ranks //
+	// ---------------------------------------- //
+	s.Equal(users[2].ID, res.Entries[1].ID)
+	s.Equal(2, res.Entries[1].Position)
+	s.Equal(2, res.UserPosition)
+
+	// ------------------------------------------- //
+	// Users still see their own position and name //
+	// ------------------------------------------- //
+	res, err = leaderboard.List(LeaderboardFilters{}, ctxs[1])
+	s.Require().NoError(err)
+	s.Equal(2, res.UserPosition)
+	s.Equal(users[1].ID, res.Entries[1].ID)
+
+	res, err = leaderboard.List(LeaderboardFilters{}, ctxs[0])
+	s.Require().NoError(err)
+	s.Equal(1, res.UserPosition)
+	s.Equal(users[0].ID, res.Entries[0].ID)
+	s.Equal(users[0].Name, res.Entries[0].Name)
 }
 
 func TestUserController(t *testing.T) {
@@ -61,6 +61,28 @@ func WrapRetrieve[T any](
 	}
 }
 
+// WrapQuery wraps a handler that takes the query parameters of type K as an
+// argument and returns a value of type T.
+func WrapQuery[K, T any](
+	query func(params K, c *gin.Context) (T, error),
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var params K
+		if err := c.ShouldBindQuery(&params); err != nil {
+			c.Error(httputil.NewError(httputil.BadRequest, err))
+			return
+		}
+
+		result, err := query(params, c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
 // WrapPut wraps a handler that takes an argument of type T and returns no
 // values.
 // The response status code is defined in the handler.
@@ -3,6 +3,7 @@ package handle
 import (
 	"net/http"
 
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/httputil"
 	"github.com/gin-gonic/gin"
 )
 
@@ -30,3 +31,30 @@ func WrapDelete(delete func(string, *gin.Context) error) gin.HandlerFunc {
 		c.Status(http.StatusNoContent)
 	}
 }
+
+// WrapDeleteOf wraps a handler that deletes a resource related to the resource
+// with the ID given in the path, identified by the query parameters of type K.
+func WrapDeleteOf[K any](
+	delete func(id string, params K, c *gin.Context) error,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := BindID(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		var params K
+		if err := c.ShouldBindQuery(&params); err != nil {
+			c.Error(httputil.NewError(httputil.BadRequest, err))
+			return
+		}
+
+		if err := delete(id, params, c); err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -86,3 +86,41 @@ func (s *HandlersTestSuite) TestDeleteHandlerError() {
 
 	s.controller.AssertExpectations(s.T())
 }
+
+func (m *MockController) DeleteOf(id string, params TestQuery, _ *gin.Context) error {
+	args := m.Called(id, params)
+	return args.Error(0)
+}
+
+// The `WrapDeleteOf` handler calls the wrapped function with the ID and the
+// query parameters.
+func (s *HandlersTestSuite) TestDeleteOfHandler() {
+	id := uuid.NewString()
+	params := TestQuery{Required: "abc"}
+
+	s.controller.On("DeleteOf", id, params).Return(nil)
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("DELETE", "/test/"+id+"/related?required=abc", nil)
+
+	s.router.ServeHTTP(res, req)
+
+	s.Equal(http.StatusNoContent, res.Result().StatusCode)
+	s.controller.AssertExpectations(s.T())
+}
+
+// The `WrapDeleteOf` handler validates the query parameters.
+func (s *HandlersTestSuite) TestDeleteOfHandlerQueryValidation() {
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("DELETE", "/test/"+uuid.NewString()+"/related", nil)
+
+	s.router.ServeHTTP(res, req)
+
+	s.Equal(http.StatusBadRequest, res.Result().StatusCode)
+
+	var resBody ErrorResponse
+	if err := json.Unmarshal(res.Body.Bytes(), &resBody); err != nil {
+		s.FailNow(err.Error())
+	}
+	s.Equal("Bad Request", resBody.Code)
+}
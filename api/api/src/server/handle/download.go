@@ -3,6 +3,7 @@ package handle
 import (
 	"net/http"
 
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/httputil"
 	"github.com/gin-gonic/gin"
 )
 
@@ -33,3 +34,31 @@ func WrapDownload(
 		c.Data(http.StatusOK, contentType, data)
 	}
 }
+
+// WrapDownloadOf wraps a handler that generates a file related to the resource
+// with the ID given in the path, according to the query parameters of type K.
+func WrapDownloadOf[K any](
+	download func(id string, filters K, c *gin.Context) ([]byte, string, error),
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := BindID(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		var filters K
+		if err := c.ShouldBindQuery(&filters); err != nil {
+			c.Error(httputil.NewError(httputil.BadRequest, err))
+			return
+		}
+
+		data, contentType, err := download(id, filters, c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.Data(http.StatusOK, contentType, data)
+	}
+}
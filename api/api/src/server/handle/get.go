@@ -3,6 +3,7 @@ package handle
 import (
 	"net/http"
 
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/httputil"
 	"github.com/gin-gonic/gin"
 )
 
@@ -31,3 +32,30 @@ func WrapGet[T any](get func(string, *gin.Context) (T, error)) gin.HandlerFunc {
 		c.JSON(http.StatusOK, result)
 	}
 }
+
+// WrapGetOf wraps a handler that retrieves a value related to the resource
+// with the ID given in the path, according to the query parameters of type K.
+func WrapGetOf[K, T any](
+	get func(string, K, *gin.Context) (T, error),
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := BindID(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		var filters K
+		if err := c.ShouldBindQuery(&filters); err != nil {
+			c.Error(httputil.NewError(httputil.BadRequest, err))
+			return
+		}
+
+		result, err := get(id, filters, c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
@@ -43,9 +43,12 @@ func TestHandlers(t *testing.T) {
 
 	router.GET("/test", List[TestQuery, TestType](controller))
 	router.GET("/test/:id", Get[TestType](controller))
+	router.GET("/test/:id/list", WrapListOf(controller.ListOf))
 	router.POST("/test", Create[TestType, TestType](controller))
 	router.PUT("/test/:id", Update[TestType, TestType](controller))
 	router.DELETE("/test/:id", Delete(controller))
+	router.DELETE("/test/:id/related", WrapDeleteOf(controller.DeleteOf))
+	router.GET("/stream", WrapStream(controller.Stream))
 
 	suite.Run(t, &HandlersTestSuite{
 		router:     router,
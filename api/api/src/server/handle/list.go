@@ -32,3 +32,11 @@ func WrapList[K, T any](list func(K, *gin.Context) ([]T, error)) gin.HandlerFunc
 		c.JSON(http.StatusOK, result)
 	}
 }
+
+// WrapListOf wraps a handler that lists the sub-resources of the resource with
+// the ID given in the path, filtered by the query parameters of type K.
+func WrapListOf[K, T any](
+	list func(string, K, *gin.Context) ([]T, error),
+) gin.HandlerFunc {
+	return WrapGetOf(list)
+}
@@ -103,3 +103,59 @@ func (s *HandlersTestSuite) TestListHandlerError() {
 
 	s.controller.AssertExpectations(s.T())
 }
+
+func (m *MockController) ListOf(
+	id string,
+	params TestQuery,
+	c *gin.Context,
+) ([]TestType, error) {
+	args := m.Called(id, params)
+	return args.Get(0).([]TestType), args.Error(1)
+}
+
+// The `WrapListOf` handler calls the wrapped function with the ID and the
+// query parameters.
+func (s *HandlersTestSuite) TestListOfHandler() {
+	id := "d3a1c6e2-5c57-4a1b-8d3e-1f2b3c4d5e6f"
+	args := TestQuery{
+		Pagination: controllers.Pagination{
+			Limit:  2,
+			Offset: 1,
+		},
+		Required: "abc",
+	}
+
+	s.controller.On("ListOf", id, args).Return(make([]TestType, args.Limit), nil)
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("GET",
+		"/test/"+id+"/list?limit=2&offset=1&required=abc", nil)
+
+	s.router.ServeHTTP(res, req)
+
+	s.Equal(http.StatusOK, res.Result().StatusCode)
+
+	resBody := []TestType{}
+	if err := json.Unmarshal(res.Body.Bytes(), &resBody); err != nil {
+		s.FailNow(err.Error())
+	}
+	s.Equal(make([]TestType, args.Limit), resBody)
+
+	s.controller.AssertExpectations(s.T())
+}
+
+// The `WrapListOf` handler validates the ID.
+func (s *HandlersTestSuite) TestListOfHandlerIdValidation() {
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/test/abc/list?required=abc", nil)
+
+	s.router.ServeHTTP(res, req)
+
+	s.Equal(http.StatusBadRequest, res.Result().StatusCode)
+
+	var resBody ErrorResponse
+	if err := json.Unmarshal(res.Body.Bytes(), &resBody); err != nil {
+		s.FailNow(err.Error())
+	}
+	s.Equal("Invalid UUID", resBody.Code)
+}
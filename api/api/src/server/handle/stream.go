@@ -0,0 +1,35 @@
+package handle
+
+import (
+	"log"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/httputil"
+	"github.com/gin-gonic/gin"
+)
+
+// WrapStream wraps a handler that writes a file to the response as it's
+// generated, according to the query parameters of type K.
+//
+// Errors returned before the handler writes to the response are handled as
+// usual. Once the response has started, errors can no longer be sent to the
+// client, so they're logged and the response is cut short.
+func WrapStream[K any](
+	stream func(params K, c *gin.Context) error,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var params K
+		if err := c.ShouldBindQuery(&params); err != nil {
+			c.Error(httputil.NewError(httputil.BadRequest, err))
+			return
+		}
+
+		if err := stream(params, c); err != nil {
+			if c.Writer.Written() {
+				log.Printf("failed to stream %s: %v", c.FullPath(), err)
+				c.Abort()
+				return
+			}
+			c.Error(err)
+		}
+	}
+}
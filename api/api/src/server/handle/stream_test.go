@@ -0,0 +1,75 @@
+package handle
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/httputil"
+	"github.com/gin-gonic/gin"
+)
+
+// Stream writes the first return value to the response before returning the
+// error.
+func (m *MockController) Stream(params TestQuery, c *gin.Context) error {
+	args := m.Called(params)
+	if data := args.String(0); data != "" {
+		c.Writer.WriteString(data)
+	}
+	return args.Error(1)
+}
+
+// The `WrapStream` handler calls the wrapped function with the query
+// parameters, which writes the response.
+func (s *HandlersTestSuite) TestStreamHandler() {
+	args := TestQuery{Required: "stream"}
+	s.controller.On("Stream", args).Return("a,b\n1,2\n", nil)
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/stream?required=stream", nil)
+
+	s.router.ServeHTTP(res, req)
+
+	s.Equal(http.StatusOK, res.Result().StatusCode)
+	s.Equal("a,b\n1,2\n", res.Body.String())
+
+	s.controller.AssertExpectations(s.T())
+}
+
+// Errors returned before the response is written are sent to the client.
+func (s *HandlersTestSuite) TestStreamHandlerError() {
+	args := TestQuery{Required: "error"}
+	s.controller.On("Stream", args).Return("", httputil.NewErrorMsg(
+		httputil.ExportTooLarge,
+		"too large",
+	))
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/stream?required=error", nil)
+
+	s.router.ServeHTTP(res, req)
+
+	s.Equal(http.StatusBadRequest, res.Result().StatusCode)
+
+	var resBody ErrorResponse
+	if err := json.Unmarshal(res.Body.Bytes(), &resBody); err != nil {
+		s.FailNow(err.Error())
+	}
+	s.Equal("Export Too Large", resBody.Code)
+}
+
+// Errors returned after the response has started don't append the error to
+// the response.
+func (s *HandlersTestSuite) TestStreamHandlerErrorAfterWrite() {
+	args := TestQuery{Required: "partial"}
+	s.controller.On("Stream", args).Return("a,b\n", errors.New("failed"))
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/stream?required=partial", nil)
+
+	s.router.ServeHTTP(res, req)
+
+	s.Equal(http.StatusOK, res.Result().StatusCode)
+	s.Equal("a,b\n", res.Body.String())
+}
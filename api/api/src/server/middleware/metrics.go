@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "cycleforlisbon",
+	Subsystem: "http",
+	Name:      "request_duration_seconds",
+	Help:      "Latency of the HTTP requests to the API, by route and status.",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"method", "route", "status"})
+
+// Metrics middleware records the latency and status of the requests in the
+// Prometheus metrics. Requests are labeled by the route pattern, such as
+// `/api/users/:id`, so the number of series is bounded. Requests that don't
+// match a route share the `unmatched` label.
+//
+// Must be registered before the Error middleware, to record the final status
+// of the response.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		requestDuration.WithLabelValues(
+			c.Request.Method,
+			route,
+			strconv.Itoa(c.Writer.Status()),
+		).Observe(time.Since(start).Seconds())
+	}
+}
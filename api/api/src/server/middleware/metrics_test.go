@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/httputil"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	router := gin.New()
+	router.Use(Metrics(), Error())
+	router.GET("/metrics-test/:id", func(c *gin.Context) {
+		c.Error(httputil.NewErrorMsg(httputil.RecordNotFound, "not found"))
+	})
+
+	request := func(path string) {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest("GET", path, nil))
+	}
+
+	series := testutil.CollectAndCount(requestDuration)
+
+	// Requests are labeled by the route pattern and the final status.
+	request("/metrics-test/1")
+	request("/metrics-test/2")
+	assert.Equal(t, series+1, testutil.CollectAndCount(requestDuration))
+	assert.Contains(t, labels(t), map[string]string{
+		"method": http.MethodGet,
+		"route":  "/metrics-test/:id",
+		"status": "404",
+	})
+
+	// Requests that don't match a route share a label.
+	request("/unknown/1")
+	request("/unknown/2")
+	assert.Equal(t, series+2, testutil.CollectAndCount(requestDuration))
+	assert.Contains(t, labels(t), map[string]string{
+		"method": http.MethodGet,
+		"route":  "unmatched",
+		"status": "404",
+	})
+}
+
+// labels returns the labels of each series of the request duration metric.
+func labels(t *testing.T) []map[string]string {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(requestDuration)
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	require.Len(t, families, 1)
+
+	var result []map[string]string
+	for _, metric := range families[0].GetMetric() {
+		labels := map[string]string{}
+		for _, pair := range metric.GetLabel() {
+			labels[pair.GetName()] = pair.GetValue()
+		}
+		result = append(result, labels)
+	}
+	return result
+}
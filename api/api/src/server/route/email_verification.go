@@ -0,0 +1,44 @@
+package route
+
+import (
+	"net/http"
+	"net/url"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/server/controllers"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/server/handle"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/httputil"
+	"github.com/gin-gonic/gin"
+)
+
+func EmailVerification(
+	router *gin.RouterGroup,
+	auth gin.HandlerFunc,
+	store *controllers.Store,
+) {
+	verification := router.Group("/email-verification")
+	{
+		verification.PUT("/confirm", handle.WrapPut(store.EmailVerification.Confirm))
+		verification.GET("/redirect", handleVerificationRedirect)
+
+		private := verification.Group("", auth)
+		{
+			private.POST("/resend", handle.WrapRetrieve(store.EmailVerification.Resend))
+		}
+	}
+}
+
+type verificationRedirectParams struct {
+	Code string `form:"code" binding:"required"`
+}
+
+func handleVerificationRedirect(c *gin.Context) {
+	var params verificationRedirectParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		c.Error(httputil.NewError(httputil.BadRequest, err))
+		return
+	}
+
+	query := url.Values{"code": {params.Code}}
+	c.Redirect(http.StatusFound,
+		"cfl://email-verification?"+query.Encode())
+}
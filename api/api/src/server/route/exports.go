@@ -0,0 +1,20 @@
+package route
+
+import (
+	"bitbucket.org/pensarmais/cycleforlisbon/src/server/controllers"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/server/handle"
+	"github.com/gin-gonic/gin"
+)
+
+func Exports(
+	router *gin.RouterGroup,
+	auth gin.HandlerFunc,
+	store *controllers.Store,
+) {
+	exports := router.Group("/exports", auth)
+	{
+		exports.GET("/download", handle.WrapStream(store.Exports.Download))
+		exports.POST("", handle.WrapCreate(store.Exports.Create))
+		exports.GET("/:id", handle.WrapGet(store.Exports.Get))
+	}
+}
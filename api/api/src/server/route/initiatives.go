@@ -26,6 +26,19 @@ func Initiatives(
 			models.Initiative,
 		](store.Initiatives))
 
+		initiatives.PUT("/:id", handle.Update[
+			controllers.UpdateInitiativeParams,
+			models.Initiative,
+		](store.Initiatives))
+
+		initiatives.PUT("/:id/sponsors", handle.WrapUpdate(store.Initiatives.UpdateSponsorship))
+
+		initiatives.GET("/:id/changes", handle.WrapGet(store.Initiatives.ListChanges))
+		initiatives.GET("/:id/contributors", handle.WrapListOf(store.Initiatives.Contributors))
+		initiatives.GET("/:id/leaderboard", handle.WrapGetOf(store.Initiatives.Leaderboard))
+		initiatives.GET("/:id/progress", handle.WrapGetOf(store.Initiatives.Progress))
+		initiatives.GET("/:id/report", handle.WrapDownloadOf(store.Reports.Initiative))
+
 		initiatives.GET("/:id/img-get-url", handle.WrapGet(store.Initiatives.GetImageURL))
 		initiatives.GET("/:id/img-put-url", handle.WrapGet(store.Initiatives.PutImageURL))
 		initiatives.GET("/:id/img-delete-url", handle.WrapGet(store.Initiatives.DeleteImageURL))
@@ -26,10 +26,22 @@ func Institutions(
 			models.Institution,
 		](store.Institutions))
 
+		institutions.PUT("/:id", handle.Update[
+			controllers.UpdateInstitutionParams,
+			models.Institution,
+		](store.Institutions))
+
+		institutions.GET("/:id/report", handle.WrapDownloadOf(store.Reports.Sponsor))
+		institutions.GET("/:id/metrics", handle.WrapGetOf(store.Metrics.Institution))
+
 		institutions.GET("/:id/logo-get-url", handle.WrapGet(store.Institutions.GetLogoURL))
 		institutions.GET("/:id/logo-put-url", handle.WrapGet(store.Institutions.PutLogoURL))
 		institutions.GET("/:id/logo-delete-url", handle.WrapGet(store.Institutions.DeleteLogoURL))
 
+		institutions.GET("/:id/members", handle.WrapListOf(store.Institutions.ListMembers))
+		institutions.PUT("/:id/members", handle.WrapUpdate(store.Institutions.SetMember))
+		institutions.DELETE("/:id/members", handle.WrapDeleteOf(store.Institutions.RemoveMember))
+
 		institutions.DELETE("/:id", handle.Delete(store.Institutions))
 	}
 }
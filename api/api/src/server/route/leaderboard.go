@@ -13,6 +13,8 @@ func Leaderboard(
 ) {
 	leaderboard := router.Group("/leaderboard", auth)
 	{
-		leaderboard.GET("", handle.WrapRetrieve(store.Leaderboard.List))
+		leaderboard.GET("", handle.WrapQuery(store.Leaderboard.List))
+		leaderboard.GET("/friends", handle.WrapQuery(store.Leaderboard.Friends))
+		leaderboard.GET("/teams", handle.WrapRetrieve(store.Leaderboard.Teams))
 	}
 }
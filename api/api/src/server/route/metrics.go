@@ -14,5 +14,7 @@ func Metrics(
 	metrics := router.Group("/metrics", auth)
 	{
 		metrics.GET("", handle.WrapRetrieve(store.Metrics.Get))
+		metrics.GET("/timeseries", handle.WrapQuery(store.Metrics.Timeseries))
+		metrics.GET("/parishes", handle.WrapQuery(store.Metrics.Parishes))
 	}
 }
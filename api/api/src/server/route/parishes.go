@@ -0,0 +1,19 @@
+package route
+
+import (
+	"bitbucket.org/pensarmais/cycleforlisbon/src/server/controllers"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/server/handle"
+	"github.com/gin-gonic/gin"
+)
+
+func Parishes(
+	router *gin.RouterGroup,
+	auth gin.HandlerFunc,
+	store *controllers.Store,
+) {
+	parishes := router.Group("/parishes", auth)
+	{
+		parishes.GET("", handle.WrapRetrieve(store.Parishes.List))
+		parishes.POST("", handle.WrapUpload(store.Parishes.Import))
+	}
+}
@@ -129,16 +129,20 @@ func TestPrivateEndpoints(t *testing.T) {
 
 	Users(api, auth, store)
 	Password(api, auth, store)
+	EmailVerification(api, auth, store)
 	Initiatives(api, auth, store)
 	SDGs(api, auth, store)
 	Institutions(api, auth, store)
 	Trips(api, auth, store)
 	Achievements(api, auth, store)
 	POIs(api, auth, store)
+	Parishes(api, auth, store)
 	Leaderboard(api, auth, store)
+	Teams(api, auth, store)
 	ExternalContent(api, auth, store)
 	FCM(api, auth, store)
 	Metrics(api, auth, store)
+	Exports(api, auth, store)
 
 	user, err := createRandomUser(store)
 	require.NotEmpty(t, user)
@@ -155,6 +159,17 @@ func TestPrivateEndpoints(t *testing.T) {
 		httptest.NewRequest("GET", "/users/current", nil),
 		httptest.NewRequest("GET", "/users/achievements", nil),
 		httptest.NewRequest("GET", "/users/"+user.ID.String(), nil),
+		httptest.NewRequest("GET", "/users/"+user.ID.String()+"/credits", nil),
+		httptest.NewRequest("PUT", "/users/"+user.ID.String()+"/allocation", nil),
+		httptest.NewRequest("GET", "/users/"+user.ID.String()+"/allocations", nil),
+		httptest.NewRequest("POST", "/users/"+user.ID.String()+"/data-exports", nil),
+		httptest.NewRequest("GET", "/users/"+user.ID.String()+"/data-exports", nil),
+		httptest.NewRequest("POST", "/users/"+user.ID.String()+"/follow", nil),
+		httptest.NewRequest("DELETE", "/users/"+user.ID.String()+"/follow", nil),
+		httptest.NewRequest("GET", "/users/"+user.ID.String()+"/followers", nil),
+		httptest.NewRequest("PUT", "/users/"+user.ID.String()+"/followers", nil),
+		httptest.NewRequest("DELETE", "/users/"+user.ID.String()+"/followers", nil),
+		httptest.NewRequest("GET", "/users/"+user.ID.String()+"/following", nil),
 		httptest.NewRequest("PUT", "/users/"+user.ID.String(), bytes.NewReader(userData)),
 		httptest.NewRequest("DELETE", "/users/"+user.ID.String(), nil),
 
@@ -164,9 +179,18 @@ func TestPrivateEndpoints(t *testing.T) {
 
 		httptest.NewRequest("PUT", "/password", nil),
 
+		httptest.NewRequest("POST", "/email-verification/resend", nil),
+
 		httptest.NewRequest("GET", "/initiatives", nil),
 		httptest.NewRequest("GET", "/initiatives/"+uid.String(), nil),
 		httptest.NewRequest("POST", "/initiatives", nil),
+		httptest.NewRequest("PUT", "/initiatives/"+uid.String(), nil),
+		httptest.NewRequest("GET", "/initiatives/"+uid.String()+"/changes", nil),
+		httptest.NewRequest("GET", "/initiatives/"+uid.String()+"/contributors", nil),
+		httptest.NewRequest("GET", "/initiatives/"+uid.String()+"/leaderboard", nil),
+		httptest.NewRequest("GET", "/initiatives/"+uid.String()+"/progress", nil),
+		httptest.NewRequest("PUT", "/initiatives/"+uid.String()+"/sponsors", nil),
+		httptest.NewRequest("GET", "/initiatives/"+uid.String()+"/report", nil),
 		httptest.NewRequest("PUT", "/initiatives/"+uid.String()+"/enable", nil),
 		httptest.NewRequest("PUT", "/initiatives/"+uid.String()+"/disable", nil),
 		httptest.NewRequest("DELETE", "/initiatives/"+uid.String(), nil),
@@ -179,7 +203,13 @@ func TestPrivateEndpoints(t *testing.T) {
 		httptest.NewRequest("GET", "/institutions", nil),
 		httptest.NewRequest("GET", "/institutions/"+uid.String(), nil),
 		httptest.NewRequest("POST", "/institutions", nil),
+		httptest.NewRequest("PUT", "/institutions/"+uid.String(), nil),
+		httptest.NewRequest("GET", "/institutions/"+uid.String()+"/report", nil),
+		httptest.NewRequest("GET", "/institutions/"+uid.String()+"/metrics", nil),
 		httptest.NewRequest("DELETE", "/institutions/"+uid.String(), nil),
+		httptest.NewRequest("GET", "/institutions/"+uid.String()+"/members", nil),
+		httptest.NewRequest("PUT", "/institutions/"+uid.String()+"/members", nil),
+		httptest.NewRequest("DELETE", "/institutions/"+uid.String()+"/members", nil),
 		httptest.NewRequest("GET", "/institutions/"+user.ID.String()+"/logo-get-url", nil),
 		httptest.NewRequest("GET", "/institutions/"+user.ID.String()+"/logo-put-url", nil),
 		httptest.NewRequest("GET", "/institutions/"+user.ID.String()+"/logo-delete-url", nil),
@@ -194,7 +224,26 @@ func TestPrivateEndpoints(t *testing.T) {
 		httptest.NewRequest("GET", "/pois", nil),
 		httptest.NewRequest("POST", "/pois", nil),
 
+		httptest.NewRequest("GET", "/parishes", nil),
+		httptest.NewRequest("POST", "/parishes", nil),
+
 		httptest.NewRequest("GET", "/leaderboard", nil),
+		httptest.NewRequest("GET", "/leaderboard/friends", nil),
+		httptest.NewRequest("GET", "/leaderboard/teams", nil),
+
+		httptest.NewRequest("GET", "/teams", nil),
+		httptest.NewRequest("GET", "/teams/"+uid.String(), nil),
+		httptest.NewRequest("POST", "/teams", nil),
+		httptest.NewRequest("PUT", "/teams/"+uid.String(), nil),
+		httptest.NewRequest("DELETE", "/teams/"+uid.String(), nil),
+		httptest.NewRequest("POST", "/teams/join", nil),
+		httptest.NewRequest("POST", "/teams/"+uid.String()+"/join-code", nil),
+		httptest.NewRequest("DELETE", "/teams/"+uid.String()+"/membership", nil),
+		httptest.NewRequest("GET", "/teams/"+uid.String()+"/members", nil),
+		httptest.NewRequest("PUT", "/teams/"+uid.String()+"/members", nil),
+		httptest.NewRequest("DELETE", "/teams/"+uid.String()+"/members", nil),
+		httptest.NewRequest("GET", "/teams/"+uid.String()+"/leaderboard", nil),
+		httptest.NewRequest("GET", "/teams/"+uid.String()+"/stats", nil),
 
 		httptest.NewRequest("GET", "/external", nil),
 		httptest.NewRequest("PUT", "/external/"+uid.String()+"/approve", nil),
@@ -203,6 +252,12 @@ func TestPrivateEndpoints(t *testing.T) {
 		httptest.NewRequest("POST", "/fcm/register", nil),
 
 		httptest.NewRequest("GET", "/metrics", nil),
+		httptest.NewRequest("GET", "/metrics/timeseries", nil),
+		httptest.NewRequest("GET", "/metrics/parishes", nil),
+
+		httptest.NewRequest("GET", "/exports/download", nil),
+		httptest.NewRequest("POST", "/exports", nil),
+		httptest.NewRequest("GET", "/exports/"+uid.String(), nil),
 	}
 
 	for i, tc := range testcases {
@@ -278,6 +333,23 @@ func TestOrderByClauseValidation(t *testing.T) {
 
 // Connect to the database to test the routes.
 // Each route test suite should run inside a transaction, to prevent side effects.
+func TestVerificationRedirect(t *testing.T) {
+	router := gin.New()
+	router.GET("/redirect", handleVerificationRedirect)
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet,
+		"/redirect?code=123%26next%3Dhttps%3A%2F%2Fevil.example", nil)
+	router.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusFound, res.Code)
+	redirect, err := res.Result().Location()
+	require.NoError(t, err)
+	assert.Equal(t, "cfl", redirect.Scheme)
+	assert.Equal(t, "123&next=https://evil.example", redirect.Query().Get("code"))
+	assert.Empty(t, redirect.Query().Get("next"))
+}
+
 func TestMain(m *testing.M) {
 	config, err := config.Load("../../../.env")
 	if err != nil {
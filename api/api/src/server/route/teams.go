@@ -0,0 +1,33 @@
+package route
+
+import (
+	"bitbucket.org/pensarmais/cycleforlisbon/src/server/controllers"
+	"bitbucket.org/pensarmais/cycleforlisbon/src/server/handle"
+	"github.com/gin-gonic/gin"
+)
+
+func Teams(
+	router *gin.RouterGroup,
+	auth gin.HandlerFunc,
+	store *controllers.Store,
+) {
+	teams := router.Group("/teams", auth)
+	{
+		teams.GET("", handle.WrapList(store.Teams.List))
+		teams.GET("/:id", handle.WrapGet(store.Teams.Get))
+		teams.POST("", handle.WrapCreate(store.Teams.Create))
+		teams.PUT("/:id", handle.WrapUpdate(store.Teams.Update))
+		teams.DELETE("/:id", handle.WrapDelete(store.Teams.Delete))
+
+		teams.POST("/join", handle.WrapCreate(store.Teams.Join))
+		teams.POST("/:id/join-code", handle.WrapAction(store.Teams.RegenerateJoinCode))
+		teams.DELETE("/:id/membership", handle.WrapDelete(store.Teams.Leave))
+
+		teams.GET("/:id/members", handle.WrapListOf(store.Teams.ListMembers))
+		teams.PUT("/:id/members", handle.WrapUpdate(store.Teams.SetMemberRole))
+		teams.DELETE("/:id/members", handle.WrapDeleteOf(store.Teams.RemoveMember))
+
+		teams.GET("/:id/leaderboard", handle.WrapGet(store.Teams.Leaderboard))
+		teams.GET("/:id/stats", handle.WrapDownloadOf(store.Teams.Stats))
+	}
+}
@@ -14,10 +14,7 @@ func Users(
 ) {
 	users := router.Group("/users")
 	{
-		users.POST("", handle.Create[
-			controllers.CreateUserParams,
-			models.User,
-		](store.Users))
+		users.POST("", handle.WrapCreate(store.EmailVerification.SignUp))
 
 		private := users.Group("", auth)
 		{
@@ -31,6 +28,17 @@ func Users(
 			private.GET("/achievements", handle.WrapRetrieve(store.Users.Achievements))
 
 			private.GET("/:id", handle.Get[models.User](store.Users))
+			private.GET("/:id/credits", handle.WrapListOf(store.Users.Credits))
+			private.PUT("/:id/allocation", handle.WrapUpdate(store.Users.Allocate))
+			private.GET("/:id/allocations", handle.WrapListOf(store.Users.Allocations))
+			private.POST("/:id/data-exports", handle.WrapAction(store.DataExports.Create))
+			private.GET("/:id/data-exports", handle.WrapListOf(store.DataExports.List))
+			private.POST("/:id/follow", handle.WrapAction(store.Follows.Follow))
+			private.DELETE("/:id/follow", handle.WrapDelete(store.Follows.Unfollow))
+			private.GET("/:id/followers", handle.WrapListOf(store.Follows.Followers))
+			private.PUT("/:id/followers", handle.WrapUpdate(store.Follows.Approve))
+			private.DELETE("/:id/followers", handle.WrapDeleteOf(store.Follows.RemoveFollower))
+			private.GET("/:id/following", handle.WrapListOf(store.Follows.Following))
 			private.GET("/:id/picture-get-url", handle.WrapGet(store.Users.GetPictureURL))
 			private.GET("/:id/picture-put-url", handle.WrapGet(store.Users.PutPictureURL))
 			private.GET("/:id/picture-delete-url", handle.WrapGet(store.Users.DeletePictureURL))
@@ -40,7 +48,7 @@ func Users(
 				models.User,
 			](store.Users))
 
-			private.DELETE("/:id", handle.Delete(store.Users))
+			private.DELETE("/:id", handle.Delete(store.Accounts))
 		}
 	}
 }
@@ -1,7 +1,9 @@
 package server
 
 import (
+	"crypto/subtle"
 	"fmt"
+	"log"
 	"net/http"
 
 	"bitbucket.org/pensarmais/cycleforlisbon/docs"
@@ -16,6 +18,7 @@ import (
 	"bitbucket.org/pensarmais/cycleforlisbon/src/worker"
 	"github.com/dexidp/dex/storage"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	"gorm.io/gorm"
@@ -51,6 +54,7 @@ type Config struct {
 	Worker        *worker.Worker
 	AWS           *aws.Client
 	Geocoder      *latlon.Geocoder
+	MetricsToken  string
 }
 
 // New initializes both Dex and Api handlers in a multiplexer.
@@ -79,6 +83,13 @@ func New(config *Config) (*http.Server, error) {
 	srvMux.Handle("/dex", dexHandler)
 	srvMux.Handle("/dex/", dexHandler)
 
+	// The metrics are only served when a token is set.
+	if config.MetricsToken != "" {
+		srvMux.Handle("/metrics", metricsHandler(config.MetricsToken))
+	} else {
+		log.Println("METRICS_TOKEN is not set, not serving /metrics")
+	}
+
 	srv := &http.Server{
 		Addr:    ":8080",
 		Handler: srvMux,
@@ -114,6 +125,7 @@ func initApi(
 		gin.Recovery(),
 	)
 
+	router.Use(middleware.Metrics())
 	router.Use(middleware.Sentry())
 	router.Use(middleware.Error())
 	router.Use(middleware.CORS())
@@ -137,17 +149,21 @@ func initApi(
 
 		route.Users(api, auth, store)
 		route.Password(api, auth, store)
+		route.EmailVerification(api, auth, store)
 		route.Initiatives(api, auth, store)
 		route.SDGs(api, auth, store)
 		route.Institutions(api, auth, store)
 		route.Trips(api, auth, store)
 		route.Achievements(api, auth, store)
 		route.POIs(api, auth, store)
+		route.Parishes(api, auth, store)
 		route.Leaderboard(api, auth, store)
+		route.Teams(api, auth, store)
 		route.ExternalContent(api, auth, store)
 		route.FCM(api, auth, store)
 		route.Languages(api, store)
 		route.Metrics(api, auth, store)
+		route.Exports(api, auth, store)
 	}
 
 	// Serve docs
@@ -182,6 +198,21 @@ func healthCheck(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
+// metricsHandler serves the Prometheus metrics to the requests with the token
+// in the authorization header, as a bearer token.
+func metricsHandler(token string) http.Handler {
+	handler := promhttp.Handler()
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(auth, expected) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
 func clientIds(clients []storage.Client) []string {
 	result := make([]string, len(clients))
 	for i, client := range clients {
@@ -10,6 +10,8 @@ import (
 	"encoding/xml"
 	"sort"
 	"time"
+
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/latlon"
 )
 
 // IdleSpeedThreshold is the minimum average speed (km/h) of an interval for it
@@ -42,6 +44,11 @@ type Point struct {
 	Time *time.Time `xml:"time"`
 }
 
+// Coords returns the coordinates of the point.
+func (p Point) Coords() latlon.Coords {
+	return latlon.Coords{Lat: p.Lat, Lon: p.Lon}
+}
+
 func (gpx *GPX) Unmarshal(data []byte) error {
 	err := xml.Unmarshal(data, gpx)
 	sort.SliceStable(gpx.Track.Segment, func(i, j int) bool {
@@ -66,6 +73,73 @@ func (gpx *GPX) Distance() float64 {
 	return dist
 }
 
+// boundaryIterations is the number of bisections used to find the point where
+// the track crosses the boundary of an area.
+const boundaryIterations = 20
+
+// DistanceWithin calculates the distance in kilometers of the track segment
+// that falls inside the given area.
+//
+// The points of the track are expected to be close enough for the track not
+// to leave and re-enter the area between two consecutive points. The
+// intervals that cross the boundary are partially counted, up to the
+// crossing point.
+//
+// It also returns the ranges of indexes of consecutive points inside the area,
+// with the first and last index of each range.
+func (gpx *GPX) DistanceWithin(
+	area interface{ Contains(latlon.Coords) bool },
+) (dist float64, ranges [][2]int) {
+	pts := gpx.Track.Segment
+	inside := make([]bool, len(pts))
+	for i, pt := range pts {
+		inside[i] = area.Contains(pt.Coords())
+	}
+
+	for i := 0; i < len(pts); i++ {
+		if !inside[i] {
+			continue
+		}
+
+		if len(ranges) > 0 && ranges[len(ranges)-1][1] == i-1 {
+			ranges[len(ranges)-1][1] = i
+		} else {
+			ranges = append(ranges, [2]int{i, i})
+		}
+	}
+
+	for i := 0; i < len(pts)-1; i++ {
+		a, b := pts[i], pts[i+1]
+		switch {
+		case inside[i] && inside[i+1]:
+			dist += distance(a, b)
+		case inside[i]:
+			dist += distance(a, boundary(a, b, area))
+		case inside[i+1]:
+			dist += distance(b, boundary(b, a, area))
+		}
+	}
+
+	return dist, ranges
+}
+
+// boundary finds the point where the line from a point inside the area to a
+// point outside of it crosses the area's boundary.
+func boundary(
+	in, out Point,
+	area interface{ Contains(latlon.Coords) bool },
+) Point {
+	for i := 0; i < boundaryIterations; i++ {
+		mid := Point{Lat: (in.Lat + out.Lat) / 2, Lon: (in.Lon + out.Lon) / 2}
+		if area.Contains(mid.Coords()) {
+			in = mid
+		} else {
+			out = mid
+		}
+	}
+	return in
+}
+
 // Duration calculates both the total and in motion time durations.
 // An interval is considered idle if the speed is less than the
 // IdleTimeThreshold.
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"bitbucket.org/pensarmais/cycleforlisbon/src/util/latlon"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -126,3 +127,28 @@ func TestGPX(t *testing.T) {
 		})
 	}
 }
+
+func TestDistanceWithin(t *testing.T) {
+	gpx := GPX{Track: Track{Segment: []Point{
+		{Lat: 0.00}, {Lat: 0.01}, {Lat: 0.02}, {Lat: 0.03}, {Lat: 0.04},
+	}}}
+
+	area := latlon.Area{Polygon: []latlon.Coords{
+		{Lat: 0.015, Lon: -1}, {Lat: 0.015, Lon: 1},
+		{Lat: 0.035, Lon: 1}, {Lat: 0.035, Lon: -1},
+	}}
+	dist, ranges := gpx.DistanceWithin(area)
+	expected := latlon.Dist(latlon.Coords{Lat: 0.015}, latlon.Coords{Lat: 0.035})
+	assert.InDelta(t, expected, dist, 0.001)
+	assert.Equal(t, [][2]int{{2, 3}}, ranges)
+
+	area = latlon.Area{Center: &latlon.Coords{Lat: 0.02}, Radius: 100}
+	dist, ranges = gpx.DistanceWithin(area)
+	assert.InDelta(t, gpx.Distance(), dist, 1e-9)
+	assert.Equal(t, [][2]int{{0, 4}}, ranges)
+
+	area = latlon.Area{Center: &latlon.Coords{Lat: 1}, Radius: 1}
+	dist, ranges = gpx.DistanceWithin(area)
+	assert.Zero(t, dist)
+	assert.Empty(t, ranges)
+}
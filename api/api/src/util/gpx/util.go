@@ -15,11 +15,5 @@ func Speed(dst float64, t time.Duration) float64 {
 }
 
 func distance(a, b Point) float64 {
-	return latlon.Dist(latlon.Coords{
-		Lat: a.Lat,
-		Lon: a.Lon,
-	}, latlon.Coords{
-		Lat: b.Lat,
-		Lon: b.Lon,
-	})
+	return latlon.Dist(a.Coords(), b.Coords())
 }
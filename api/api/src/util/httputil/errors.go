@@ -80,6 +80,18 @@ var (
 		"Password Reset Code Already Used",
 		http.StatusGone,
 	}
+	EmailVerificationCodeExpired = ErrorCode{
+		"Email Verification Code Expired",
+		http.StatusGone,
+	}
+	EmailVerificationCodeUsed = ErrorCode{
+		"Email Verification Code Already Used",
+		http.StatusGone,
+	}
+	EmailAlreadyVerified = ErrorCode{
+		"Email Already Verified",
+		http.StatusBadRequest,
+	}
 	IncorrectPassword = ErrorCode{
 		"Incorrect Password",
 		http.StatusForbidden,
@@ -112,6 +124,18 @@ var (
 		"Import Invalid Value",
 		http.StatusBadRequest,
 	}
+	InvalidStateTransition = ErrorCode{
+		"Invalid State Transition",
+		http.StatusConflict,
+	}
+	TooManyRequests = ErrorCode{
+		"Too Many Requests",
+		http.StatusTooManyRequests,
+	}
+	ExportTooLarge = ErrorCode{
+		"Export Too Large",
+		http.StatusBadRequest,
+	}
 )
 
 const (
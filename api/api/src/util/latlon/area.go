@@ -0,0 +1,60 @@
+package latlon
+
+import "errors"
+
+// Area is a geographic area, defined either by a polygon or by a circle.
+type Area struct {
+	// Polygon is the list of vertices of the area, in order. The last vertex
+	// is connected to the first.
+	Polygon []Coords `json:"polygon,omitempty"`
+
+	// Center of the circular area, when there's no Polygon.
+	Center *Coords `json:"center,omitempty"`
+	// Radius of the circular area, in kilometers.
+	Radius float64 `json:"radius,omitempty" example:"0.5"`
+}
+
+var (
+	ErrInvalidPolygon = errors.New("the polygon must have at least 3 vertices")
+	ErrInvalidCircle  = errors.New("the circle must have a center and a positive radius")
+)
+
+// Validate returns an error if the area is neither a polygon nor a circle.
+func (a Area) Validate() error {
+	if len(a.Polygon) > 0 {
+		if len(a.Polygon) < 3 {
+			return ErrInvalidPolygon
+		}
+		return nil
+	}
+
+	if a.Center == nil || a.Radius <= 0 {
+		return ErrInvalidCircle
+	}
+	return nil
+}
+
+// Contains returns true if the given coordinates are inside the area.
+func (a Area) Contains(c Coords) bool {
+	if len(a.Polygon) > 0 {
+		return polygonContains(a.Polygon, c)
+	}
+	if a.Center != nil {
+		return Dist(*a.Center, c) <= a.Radius
+	}
+	return false
+}
+
+// polygonContains implements the ray casting algorithm, treating the
+// coordinates as planar, which is accurate enough for small areas.
+func polygonContains(polygon []Coords, c Coords) bool {
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[i], polygon[j]
+		if (a.Lat > c.Lat) != (b.Lat > c.Lat) &&
+			c.Lon < (b.Lon-a.Lon)*(c.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lon {
+			inside = !inside
+		}
+	}
+	return inside
+}
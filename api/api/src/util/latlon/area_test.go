@@ -0,0 +1,40 @@
+package latlon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAreaContains(t *testing.T) {
+	polygon := Area{Polygon: []Coords{
+		{38.70, -9.20}, {38.70, -9.10}, {38.75, -9.10}, {38.75, -9.20},
+	}}
+	circle := Area{Center: &Coords{38.72, -9.14}, Radius: 1}
+
+	for i, tc := range []struct {
+		area   Area
+		coords Coords
+		exp    bool
+	}{
+		{polygon, Coords{38.72, -9.14}, true},
+		{polygon, Coords{38.69, -9.14}, false},
+		{polygon, Coords{38.72, -9.21}, false},
+		{circle, Coords{38.72, -9.14}, true},
+		{circle, Coords{38.725, -9.14}, true},
+		{circle, Coords{38.74, -9.14}, false},
+		{Area{}, Coords{38.72, -9.14}, false},
+	} {
+		assert.Equal(t, tc.exp, tc.area.Contains(tc.coords),
+			"failed test case %d", i)
+	}
+}
+
+func TestAreaValidate(t *testing.T) {
+	assert.NoError(t, Area{Polygon: []Coords{{0, 0}, {0, 1}, {1, 1}}}.Validate())
+	assert.ErrorIs(t, Area{Polygon: []Coords{{0, 0}, {0, 1}}}.Validate(),
+		ErrInvalidPolygon)
+	assert.NoError(t, Area{Center: &Coords{0, 0}, Radius: 1}.Validate())
+	assert.ErrorIs(t, Area{Center: &Coords{0, 0}}.Validate(), ErrInvalidCircle)
+	assert.ErrorIs(t, Area{}.Validate(), ErrInvalidCircle)
+}
@@ -0,0 +1,176 @@
+package latlon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Boundary of an administrative region, made of one or more polygons. The
+// first ring of each polygon is its exterior, and the remaining rings are
+// holes in it.
+type Boundary [][][]Coords
+
+// Contains returns true if the given coordinates are inside any of the
+// polygons of the boundary, and not inside any of its holes.
+func (b Boundary) Contains(c Coords) bool {
+	for _, polygon := range b {
+		if len(polygon) == 0 || !polygonContains(polygon[0], c) {
+			continue
+		}
+
+		inHole := false
+		for _, hole := range polygon[1:] {
+			if polygonContains(hole, c) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+	return false
+}
+
+// Bounds returns the south-west and north-east corners of the smallest
+// rectangle that contains the boundary.
+func (b Boundary) Bounds() (sw, ne Coords) {
+	first := true
+	for _, polygon := range b {
+		if len(polygon) == 0 {
+			continue
+		}
+		for _, c := range polygon[0] {
+			if first {
+				sw, ne = c, c
+				first = false
+				continue
+			}
+			sw.Lat = min(sw.Lat, c.Lat)
+			sw.Lon = min(sw.Lon, c.Lon)
+			ne.Lat = max(ne.Lat, c.Lat)
+			ne.Lon = max(ne.Lon, c.Lon)
+		}
+	}
+	return sw, ne
+}
+
+func min(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// NamedBoundary is the boundary of a region read from a GeoJSON feature.
+type NamedBoundary struct {
+	Name     string
+	Boundary Boundary
+}
+
+var (
+	ErrNotFeatureCollection = errors.New("expected a GeoJSON FeatureCollection")
+	ErrUnsupportedGeometry  = errors.New("the geometry must be a Polygon or a MultiPolygon")
+)
+
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Properties map[string]any `json:"properties"`
+	Geometry   *struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	} `json:"geometry"`
+}
+
+// geoJSONPolygon is a list of rings of positions, each a [lon, lat] pair.
+type geoJSONPolygon [][][]float64
+
+func (p geoJSONPolygon) toCoords() ([][]Coords, error) {
+	rings := make([][]Coords, 0, len(p))
+	for _, ring := range p {
+		if len(ring) < 3 {
+			return nil, ErrInvalidPolygon
+		}
+		coords := make([]Coords, 0, len(ring))
+		for _, pos := range ring {
+			if len(pos) < 2 {
+				return nil, fmt.Errorf("invalid position: %v", pos)
+			}
+			coords = append(coords, Coords{Lat: pos[1], Lon: pos[0]})
+		}
+		rings = append(rings, coords)
+	}
+	if len(rings) == 0 {
+		return nil, ErrInvalidPolygon
+	}
+	return rings, nil
+}
+
+// ParseGeoJSON reads the boundaries of the features of a GeoJSON
+// FeatureCollection with Polygon or MultiPolygon geometries. The name of each
+// boundary is the value of the given property of the feature.
+func ParseGeoJSON(data []byte, nameProperty string) ([]NamedBoundary, error) {
+	var collection geoJSONFeatureCollection
+	if err := json.Unmarshal(data, &collection); err != nil {
+		return nil, err
+	}
+	if collection.Type != "FeatureCollection" {
+		return nil, ErrNotFeatureCollection
+	}
+
+	boundaries := make([]NamedBoundary, 0, len(collection.Features))
+	for i, feature := range collection.Features {
+		name, ok := feature.Properties[nameProperty].(string)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("feature %d: missing property '%s'",
+				i, nameProperty)
+		}
+		if feature.Geometry == nil {
+			return nil, fmt.Errorf("feature %d: %w", i, ErrUnsupportedGeometry)
+		}
+
+		var polygons []geoJSONPolygon
+		switch feature.Geometry.Type {
+		case "Polygon":
+			var polygon geoJSONPolygon
+			if err := json.Unmarshal(
+				feature.Geometry.Coordinates, &polygon,
+			); err != nil {
+				return nil, fmt.Errorf("feature %d: %v", i, err)
+			}
+			polygons = []geoJSONPolygon{polygon}
+		case "MultiPolygon":
+			if err := json.Unmarshal(
+				feature.Geometry.Coordinates, &polygons,
+			); err != nil {
+				return nil, fmt.Errorf("feature %d: %v", i, err)
+			}
+		default:
+			return nil, fmt.Errorf("feature %d: %w", i, ErrUnsupportedGeometry)
+		}
+
+		boundary := make(Boundary, 0, len(polygons))
+		for _, polygon := range polygons {
+			rings, err := polygon.toCoords()
+			if err != nil {
+				return nil, fmt.Errorf("feature %d: %w", i, err)
+			}
+			boundary = append(boundary, rings)
+		}
+		boundaries = append(boundaries, NamedBoundary{name, boundary})
+	}
+
+	return boundaries, nil
+}
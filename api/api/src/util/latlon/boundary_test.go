@@ -0,0 +1,115 @@
+package latlon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoundaryContains(t *testing.T) {
+	// A square with a square hole, and a second square to the east.
+	boundary := Boundary{
+		{
+			{{38.70, -9.20}, {38.70, -9.10}, {38.80, -9.10}, {38.80, -9.20}},
+			{{38.74, -9.16}, {38.74, -9.14}, {38.76, -9.14}, {38.76, -9.16}},
+		},
+		{
+			{{38.70, -9.00}, {38.70, -8.90}, {38.80, -8.90}, {38.80, -9.00}},
+		},
+	}
+
+	for i, tc := range []struct {
+		coords Coords
+		exp    bool
+	}{
+		{Coords{38.72, -9.18}, true},
+		{Coords{38.75, -9.15}, false}, // in the hole
+		{Coords{38.75, -8.95}, true},
+		{Coords{38.75, -9.05}, false},
+		{Coords{38.69, -9.15}, false},
+	} {
+		assert.Equal(t, tc.exp, boundary.Contains(tc.coords),
+			"failed test case %d", i)
+	}
+
+	assert.False(t, Boundary{}.Contains(Coords{38.72, -9.18}))
+}
+
+func TestBoundaryBounds(t *testing.T) {
+	sw, ne := Boundary{
+		{{{38.70, -9.20}, {38.70, -9.10}, {38.80, -9.10}}},
+		{{{38.65, -9.00}, {38.70, -8.90}, {38.75, -9.00}}},
+	}.Bounds()
+
+	assert.Equal(t, Coords{38.65, -9.20}, sw)
+	assert.Equal(t, Coords{38.80, -8.90}, ne)
+}
+
+func TestParseGeoJSON(t *testing.T) {
+	data := []byte(`{
+		"type": "FeatureCollection",
+		"features": [
+			{
+				"type": "Feature",
+				"properties": {"NOME": "Arroios"},
+				"geometry": {
+					"type": "Polygon",
+					"coordinates": [
+						[[-9.20, 38.70], [-9.10, 38.70], [-9.10, 38.80], [-9.20, 38.70]]
+					]
+				}
+			},
+			{
+				"type": "Feature",
+				"properties": {"NOME": "Belém"},
+				"geometry": {
+					"type": "MultiPolygon",
+					"coordinates": [
+						[[[-9.20, 38.70], [-9.10, 38.70], [-9.10, 38.80]]],
+						[[[-9.00, 38.70], [-8.90, 38.70], [-8.90, 38.80]]]
+					]
+				}
+			}
+		]
+	}`)
+
+	boundaries, err := ParseGeoJSON(data, "NOME")
+	require.NoError(t, err)
+	require.Len(t, boundaries, 2)
+
+	assert.Equal(t, "Arroios", boundaries[0].Name)
+	assert.Equal(t, Boundary{{{
+		{38.70, -9.20}, {38.70, -9.10}, {38.80, -9.10}, {38.70, -9.20},
+	}}}, boundaries[0].Boundary)
+
+	assert.Equal(t, "Belém", boundaries[1].Name)
+	assert.Len(t, boundaries[1].Boundary, 2)
+
+	_, err = ParseGeoJSON(data, "name")
+	assert.ErrorContains(t, err, "missing property 'name'")
+
+	_, err = ParseGeoJSON([]byte(`{"type": "Feature"}`), "NOME")
+	assert.ErrorIs(t, err, ErrNotFeatureCollection)
+
+	_, err = ParseGeoJSON([]byte(`{
+		"type": "FeatureCollection",
+		"features": [{
+			"properties": {"NOME": "Arroios"},
+			"geometry": {"type": "Point", "coordinates": [-9.1, 38.7]}
+		}]
+	}`), "NOME")
+	assert.ErrorIs(t, err, ErrUnsupportedGeometry)
+
+	_, err = ParseGeoJSON([]byte(`{
+		"type": "FeatureCollection",
+		"features": [{
+			"properties": {"NOME": "Arroios"},
+			"geometry": {"type": "Polygon", "coordinates": [[[-9.1, 38.7]]]}
+		}]
+	}`), "NOME")
+	assert.ErrorIs(t, err, ErrInvalidPolygon)
+
+	_, err = ParseGeoJSON([]byte(`not json`), "NOME")
+	assert.Error(t, err)
+}
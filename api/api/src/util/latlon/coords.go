@@ -7,8 +7,8 @@ const (
 )
 
 type Coords struct {
-	Lat float64 // Latitude in decimal degrees.
-	Lon float64 // Longitude in decimal degrees.
+	Lat float64 `json:"lat" example:"38.7223"` // Latitude in decimal degrees.
+	Lon float64 `json:"lon" example:"-9.1393"` // Longitude in decimal degrees.
 }
 
 // Dist calculates the distance in kilometers between two coordinates.
@@ -7,12 +7,32 @@ import (
 	"log"
 	"net/http"
 	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
 type Geocoder struct {
 	token string
 }
 
+var geocoderRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "cycleforlisbon",
+	Subsystem: "geocoder",
+	Name:      "requests_total",
+	Help:      "Number of reverse geocoding requests, by provider (maps or osm) and outcome.",
+}, []string{"provider", "outcome"})
+
+// observeRequest records the outcome of a request to a provider in the
+// metrics.
+func observeRequest(provider string, ok bool) {
+	outcome := "success"
+	if !ok {
+		outcome = "failure"
+	}
+	geocoderRequests.WithLabelValues(provider, outcome).Inc()
+}
+
 const (
 	gcMapsReverseURLStr = "https://maps.googleapis.com/maps/api/geocode/json?" +
 		"latlng=%f,%f&" +
@@ -174,14 +194,19 @@ func (g *Geocoder) reverseOSM(coords Coords) (*reverseOSMResult, error) {
 // simplified address.
 func (g *Geocoder) ReverseAddr(coords Coords) string {
 	mapsRes, err := g.reverseMaps(coords)
-	if err == nil && mapsRes.Status == "OK" {
+	ok := err == nil && mapsRes.Status == "OK"
+	observeRequest("maps", ok)
+	if ok {
 		return mapsRes.simplifiedAddr()
 	}
 
-	log.Printf("failed to query Maps, falling back to OSM: %v, %s\n",
-		err, mapsRes.Status)
+	if err == nil {
+		err = fmt.Errorf("status %s", mapsRes.Status)
+	}
+	log.Printf("failed to query Maps, falling back to OSM: %v\n", err)
 
 	osmRes, err := g.reverseOSM(coords)
+	observeRequest("osm", err == nil)
 	if err == nil {
 		return osmRes.simplifiedAddr()
 	}
@@ -39,6 +39,12 @@ func AlphanumericString(n int) string {
 	return stringFrom(n, alphanum)
 }
 
+// CodeString returns a random string of uppercase letters and digits, that is
+// easy to read and type.
+func CodeString(n int) string {
+	return stringFrom(n, uAlphabet+numbers)
+}
+
 func Bool() bool {
 	return rand.Intn(2) == 0
 }
@@ -0,0 +1,119 @@
+package worker
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+)
+
+// Outcomes of running a task, used as labels of the job metrics.
+const (
+	outcomeSuccess = "success"
+	// The task failed and was rescheduled.
+	outcomeRetry = "retry"
+	// The task failed and was discarded, with no retries left.
+	outcomeFailure = "failure"
+)
+
+var (
+	jobRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cycleforlisbon",
+		Subsystem: "worker",
+		Name:      "job_runs_total",
+		Help:      "Number of tasks run, by job and outcome: success, retry or failure.",
+	}, []string{"job", "outcome"})
+
+	jobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "cycleforlisbon",
+		Subsystem: "worker",
+		Name:      "job_duration_seconds",
+		Help:      "Time taken to run the tasks, by job.",
+		Buckets:   []float64{.01, .05, .1, .5, 1, 5, 10, 30, 60, 300, 900},
+	}, []string{"job"})
+)
+
+// observeRun records the outcome and duration of a task in the metrics.
+func observeRun(task *Task, outcome string, start time.Time) {
+	jobRuns.WithLabelValues(task.JobName, outcome).Inc()
+	jobDuration.WithLabelValues(task.JobName).
+		Observe(time.Since(start).Seconds())
+}
+
+var (
+	queueTasksDesc = prometheus.NewDesc(
+		"cycleforlisbon_worker_queue_tasks",
+		"Number of tasks in the queue, by job and state: running, due (waiting for a "+
+			"free routine) or scheduled (to a later time).",
+		[]string{"job", "state"}, nil,
+	)
+	queueAgeDesc = prometheus.NewDesc(
+		"cycleforlisbon_worker_queue_oldest_due_task_age_seconds",
+		"Time since the oldest due task of each job was scheduled to run.",
+		[]string{"job"}, nil,
+	)
+)
+
+// DbQueueCollector is a Prometheus collector of the depth of a DbQueue, and
+// the age of the tasks waiting to run, read from the database when the metrics
+// are scraped.
+type DbQueueCollector struct {
+	db *gorm.DB
+}
+
+// NewDbQueueCollector creates a collector of the tasks of the DbQueue in the
+// given database.
+func NewDbQueueCollector(db *gorm.DB) *DbQueueCollector {
+	return &DbQueueCollector{db}
+}
+
+// Describe implements prometheus.Collector.
+func (c *DbQueueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueTasksDesc
+	ch <- queueAgeDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *DbQueueCollector) Collect(ch chan<- prometheus.Metric) {
+	var stats []struct {
+		Job       string
+		Running   int64
+		Due       int64
+		Scheduled int64
+		DueAge    *float64
+	}
+	if err := c.db.Raw(`
+		SELECT
+			job,
+			count(*) FILTER (WHERE running) AS running,
+			count(*) FILTER (WHERE NOT running AND scheduled_to <= now())
+				AS due,
+			count(*) FILTER (WHERE NOT running AND scheduled_to > now())
+				AS scheduled,
+			extract(EPOCH FROM now() - min(scheduled_to)
+				FILTER (WHERE NOT running AND scheduled_to <= now()))
+				AS due_age
+		FROM worker_tasks
+		GROUP BY job
+	`).Scan(&stats).Error; err != nil {
+		ch <- prometheus.NewInvalidMetric(queueTasksDesc, err)
+		return
+	}
+
+	for _, s := range stats {
+		ch <- prometheus.MustNewConstMetric(queueTasksDesc,
+			prometheus.GaugeValue, float64(s.Running), s.Job, "running")
+		ch <- prometheus.MustNewConstMetric(queueTasksDesc,
+			prometheus.GaugeValue, float64(s.Due), s.Job, "due")
+		ch <- prometheus.MustNewConstMetric(queueTasksDesc,
+			prometheus.GaugeValue, float64(s.Scheduled), s.Job, "scheduled")
+
+		var age float64
+		if s.DueAge != nil {
+			age = *s.DueAge
+		}
+		ch <- prometheus.MustNewConstMetric(queueAgeDesc,
+			prometheus.GaugeValue, age, s.Job)
+	}
+}
@@ -153,10 +153,12 @@ func (w *Worker) handle(task *Task) {
 	w.lastTask = task
 
 	w.logInfo(fmt.Sprintf("running task: %s", task))
+	start := time.Now()
 	ctx := context.Background()
 	err := job.Handler(ctx, task.Args)
 
 	if err == nil {
+		observeRun(task, outcomeSuccess, start)
 		w.logInfo(fmt.Sprintf("task completed: %s", task))
 		w.dequeue(task)
 
@@ -168,11 +170,13 @@ func (w *Worker) handle(task *Task) {
 		w.logError(fmt.Sprintf("error running '%s': %v", task, err))
 
 		if task.Tries < job.Retries {
+			observeRun(task, outcomeRetry, start)
 			retryTime := time.Now().Add(job.nextDelay(task.Tries))
 			w.logInfo(fmt.Sprintf("rescheduling '%s' to: %s", task, retryTime))
 
 			w.reschedule(task, retryTime)
 		} else {
+			observeRun(task, outcomeFailure, start)
 			w.logError(fmt.Sprintf("discarding '%s', no more retries", task))
 			w.dequeue(task)
 
@@ -12,6 +12,7 @@ import (
 	"bitbucket.org/pensarmais/cycleforlisbon/src/database"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/database/models"
 	"bitbucket.org/pensarmais/cycleforlisbon/src/util/random"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"gorm.io/gorm"
@@ -455,6 +456,44 @@ func (s *WorkerTestSuite) TestJobCallbacks() {
 	}
 }
 
+// The depth of the queue and the outcomes of the tasks are recorded in the
+// metrics.
+func (s *WorkerTestSuite) TestMetrics() {
+	worker := New(s.queue)
+	worker.LogLevel = Silent
+	job := &Job{
+		Name:    "metrics",
+		Retries: 1,
+		Handler: func(_ context.Context, _ []byte) error {
+			return errors.New("AH!")
+		},
+	}
+	s.Require().NoError(worker.Register(job))
+
+	s.Require().NoError(worker.Schedule(&TaskConfig{
+		JobName:     job.Name,
+		ScheduledTo: time.Now().Add(-time.Minute),
+	}))
+	s.Require().NoError(worker.Schedule(&TaskConfig{
+		JobName:     job.Name,
+		Args:        []byte("later"),
+		ScheduledTo: time.Now().Add(time.Hour),
+	}))
+
+	// The running, due and scheduled tasks, and the age of the due tasks.
+	collector := NewDbQueueCollector(s.db)
+	s.Equal(4, testutil.CollectAndCount(collector))
+
+	retries := testutil.ToFloat64(jobRuns.WithLabelValues(job.Name, outcomeRetry))
+	task, ok, err := worker.queue.Poll()
+	s.Require().NoError(err)
+	s.Require().True(ok)
+	worker.handle(task)
+
+	s.Equal(retries+1,
+		testutil.ToFloat64(jobRuns.WithLabelValues(job.Name, outcomeRetry)))
+}
+
 func TestWorker(t *testing.T) {
 	config, err := config.Load("../../.env")
 	require.NoError(t, err)